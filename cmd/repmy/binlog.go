@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/spf13/pflag"
+)
+
+// binlogFlags are flags of commands streaming binlog from master as replica
+type binlogFlags struct {
+	user     *string
+	password *string
+	ssl      *bool
+	serverID *uint32
+}
+
+func addBinlogFlags(flags *pflag.FlagSet) *binlogFlags {
+	return &binlogFlags{
+		user:     flags.StringP("repl-user", "u", "", "replication user"),
+		password: flags.StringP("repl-password", "p", "", "password of replication user"),
+		ssl:      flags.Bool("repl-ssl", false, "connect with TLS"),
+		serverID: flags.Uint32("server-id", 0, "server id of the binlog client, random if zero"),
+	}
+}
+
+// config returns config of connection to master, random server id is used if it is not set
+func (f *binlogFlags) config(master *node.Node) binlog.Config {
+	serverID := *f.serverID
+	if serverID == 0 {
+		serverID = uint32(rand.New(rand.NewSource(time.Now().UnixNano())).Int31n(1<<30)) + 1<<30
+	}
+
+	return binlog.ConfigFor(mysql.ReplUser{
+		Name:       *f.user,
+		Password:   *f.password,
+		MasterHost: master.Host,
+		MasterPort: master.Port,
+		RequireSSL: *f.ssl,
+	}, serverID)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/partyzanex/repmy/pkg/backup"
+	"github.com/spf13/pflag"
+)
+
+func runBinlogBackup(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("binlog-backup", pflag.ExitOnError)
+
+	masterDSN := flags.StringP("master", "m", "", "master 'name=DSN'")
+	repl := addBinlogFlags(flags)
+	dir := flags.StringP("dir", "d", "", "backup directory, backup is resumed if it has manifest")
+	startFile := flags.String("start-file", "", "the first binlog of new backup, the oldest binlog of master if empty")
+
+	_ = flags.Parse(args)
+
+	if *masterDSN == "" || *repl.user == "" || *dir == "" {
+		return fmt.Errorf("flags --master, --repl-user and --dir are required")
+	}
+
+	nodes, err := openNodes(*masterDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	b := &backup.Backup{
+		Config:    repl.config(nodes[0]),
+		DB:        nodes[0].DB,
+		Dir:       *dir,
+		StartFile: *startFile,
+	}
+
+	return b.Run(ctx)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/partyzanex/repmy/pkg/cdc"
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/spf13/pflag"
+)
+
+func runCDC(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("cdc", pflag.ExitOnError)
+
+	masterDSN := flags.StringP("master", "m", "", "master 'name=DSN', column names are read from it")
+	repl := addBinlogFlags(flags)
+	output := flags.StringP("output", "o", "-", "file changes are appended to, '-' is stdout")
+	checkpoint := flags.String("checkpoint", "", "file of position, capture is resumed from it")
+	fromDump := flags.String("from-dump", "", "start from coordinates recorded by repmydump in directory")
+	startFile := flags.String("start-file", "", "start from binlog file")
+	startPos := flags.Uint32("start-pos", 4, "start from position of --start-file")
+	startGTID := flags.String("start-gtid", "", "start after executed GTID set")
+
+	_ = flags.Parse(args)
+
+	if *masterDSN == "" || *repl.user == "" {
+		return fmt.Errorf("flags --master and --repl-user are required")
+	}
+
+	nodes, err := openNodes(*masterDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	start := cdc.Checkpoint{File: *startFile, Position: *startPos, ExecutedGTIDSet: *startGTID}
+	if *startFile == "" {
+		start.Position = 0
+	}
+
+	if *fromDump != "" {
+		coordinates, err := dump.ReadCoordinates(*fromDump)
+		if err != nil {
+			return err
+		}
+
+		start = cdc.Checkpoint{
+			File:            coordinates.File,
+			Position:        uint32(coordinates.Position),
+			ExecutedGTIDSet: coordinates.ExecutedGTIDSet,
+		}
+	}
+
+	sink := cdc.NewJSONLines(os.Stdout)
+
+	if *output != "-" {
+		sink, err = cdc.OpenFile(*output)
+		if err != nil {
+			return err
+		}
+	}
+
+	defer sink.Close()
+
+	capturer := &cdc.Capturer{
+		Config:         repl.config(nodes[0]),
+		DB:             nodes[0].DB,
+		Sink:           sink,
+		CheckpointFile: *checkpoint,
+		Start:          start,
+	}
+
+	return capturer.Run(ctx)
+}
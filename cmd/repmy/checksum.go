@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/partyzanex/repmy/pkg/checksum"
+	"github.com/partyzanex/repmy/pkg/chunk"
+	"github.com/spf13/pflag"
+)
+
+func runChecksum(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("checksum", pflag.ExitOnError)
+
+	masterDSN := flags.StringP("master", "m", "", "master 'name=DSN', tables of its database are checked")
+	replicaDSNs := flags.StringArrayP("replica", "r", nil, "replica 'name=DSN', can be repeated")
+	tables := flags.StringSlice("tables", nil, "tables list, all tables are checked if empty")
+	checksums := flags.String("checksums-table", checksum.DefaultDatabase+"."+checksum.DefaultTable,
+		"table of checksums 'db.table', it is replicated to replicas")
+	chunkSize := flags.Int("chunk-size", chunk.DefaultSize, "number of rows in chunk")
+	timeout := flags.Duration("timeout", checksum.DefaultWaitTimeout, "timeout of waiting for replicas")
+
+	_ = flags.Parse(args)
+
+	if *masterDSN == "" || len(*replicaDSNs) == 0 {
+		return fmt.Errorf("flags --master and --replica are required")
+	}
+
+	parts := strings.SplitN(*checksums, ".", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid checksums table %q, expected 'db.table'", *checksums)
+	}
+
+	nodes, err := openNodes(append([]string{*masterDSN}, *replicaDSNs...)...)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	checker := &checksum.Checker{
+		Master:            nodes[0],
+		Replicas:          nodes[1:],
+		Tables:            *tables,
+		ChecksumsDatabase: parts[0],
+		ChecksumsTable:    parts[1],
+		ChunkSize:         *chunkSize,
+		WaitTimeout:       *timeout,
+	}
+
+	drifts, err := checker.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(drifts) == 0 {
+		fmt.Println("no drift found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPLICA\tTABLE\tCHUNK\tMASTER CNT\tMASTER CRC\tREPLICA CNT\tREPLICA CRC")
+
+	for _, drift := range drifts {
+		r := drift.Result
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%d\t%s\n",
+			drift.Replica, r.Table, r.Chunk, r.MasterCnt.Int64, r.MasterCRC.String, r.Count, r.CRC)
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%d chunks drifted", len(drifts))
+}
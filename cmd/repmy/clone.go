@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/partyzanex/repmy/pkg/clone"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/spf13/pflag"
+)
+
+func runClone(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("clone", pflag.ExitOnError)
+
+	masterDSN := flags.StringP("master", "m", "", "donor master 'name=DSN'")
+	replicaDSN := flags.StringP("replica", "r", "", "recipient replica 'name=DSN', its data is replaced")
+	user := flags.StringP("repl-user", "u", "", "replication user, it is used for cloning too")
+	password := flags.StringP("repl-password", "p", "", "password of replication user")
+	ssl := flags.Bool("repl-ssl", false, "require SSL for cloning and the replication user")
+	timeout := flags.Duration("restart-timeout", clone.DefaultRestartTimeout, "time to wait for replica restart after clone")
+	skipPreflight := flags.Bool("skip-preflight", false, "do not check master and replica before cloning")
+
+	_ = flags.Parse(args)
+
+	if *masterDSN == "" || *replicaDSN == "" || *user == "" {
+		return fmt.Errorf("flags --master, --replica and --repl-user are required")
+	}
+
+	nodes, err := openNodes(*masterDSN, *replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	p := &clone.Provisioner{
+		Master:         nodes[0],
+		Replica:        nodes[1],
+		User:           mysql.ReplUser{Name: *user, Password: *password, RequireSSL: *ssl},
+		RestartTimeout: *timeout,
+	}
+
+	if !*skipPreflight {
+		err = checkPair(ctx, p.Master, p.Replica, mysql.ReplUser{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.Run(ctx)
+}
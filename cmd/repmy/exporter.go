@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/exporter"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+func runExporter(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("exporter", pflag.ExitOnError)
+
+	instances := flags.StringArrayP("instance", "i", nil, "instance 'name=DSN', can be repeated")
+	listen := flags.StringP("listen", "l", ":9560", "listen address")
+	path := flags.String("path", "/metrics", "metrics path")
+	timeout := flags.Duration("timeout", exporter.DefaultTimeout, "timeout of collecting metrics of one instance")
+
+	_ = flags.Parse(args)
+
+	dsns, err := parseNamedDSN(*instances)
+	if err != nil {
+		return err
+	}
+
+	e := &exporter.Exporter{Timeout: *timeout}
+
+	for _, dsn := range dsns {
+		db, err := openDB(dsn.DSN)
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		e.Instances = append(e.Instances, exporter.Instance{Name: dsn.Name, DB: db})
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, e)
+
+	server := &http.Server{Addr: *listen, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(ctx)
+	}()
+
+	logrus.Infof("serving metrics of %d instances on %s%s", len(e.Instances), *listen, *path)
+
+	err = server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
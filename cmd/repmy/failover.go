@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/partyzanex/repmy/pkg/failover"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/spf13/pflag"
+)
+
+func runFailover(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("failover", pflag.ExitOnError)
+
+	replicaDSNs := flags.StringArrayP("replica", "r", nil, "replica of the lost master 'name=DSN', can be repeated")
+	user := flags.StringP("repl-user", "u", "", "replication user")
+	password := flags.StringP("repl-password", "p", "", "password of replication user")
+	exclude := flags.StringSlice("exclude", nil, "names of replicas which are never promoted")
+	priority := flags.StringToInt("priority", nil, "priorities of equally advanced replicas 'name=N', higher wins")
+	timeout := flags.Duration("timeout", failover.DefaultApplyTimeout, "timeout of applying relay logs")
+	dryRun := flags.Bool("dry-run", false, "print plan and expected candidate without executing")
+
+	_ = flags.Parse(args)
+
+	if len(*replicaDSNs) == 0 || *user == "" {
+		return fmt.Errorf("flags --replica and --repl-user are required")
+	}
+
+	nodes, err := openNodes(*replicaDSNs...)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	f := &failover.Failover{
+		Replicas:     nodes,
+		User:         mysql.ReplUser{Name: *user, Password: *password},
+		Exclude:      *exclude,
+		Priority:     *priority,
+		ApplyTimeout: *timeout,
+	}
+
+	plan, chosen, err := f.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("expected new master: %s\n", chosen.Node)
+	fmt.Print(plan)
+
+	if *dryRun {
+		return nil
+	}
+
+	return plan.Execute(ctx)
+}
@@ -1,5 +1,156 @@
 package main
 
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/sirupsen/logrus"
+)
+
+// command represents subcommand of repmy
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"binlog-backup": {usage: "stream binlog files of master to a directory", run: runBinlogBackup},
+	"cdc":           {usage: "stream row changes from binlog as JSON lines", run: runCDC},
+	"checksum":      {usage: "find data drift between master and replicas", run: runChecksum},
+	"clone":         {usage: "provision a replica with CLONE INSTANCE from master", run: runClone},
+	"exporter":      {usage: "serve replication metrics for Prometheus", run: runExporter},
+	"failover":      {usage: "promote the most advanced replica when master is lost", run: runFailover},
+	"monitor":       {usage: "watch replicas and alert on replication problems", run: runMonitor},
+	"pitr":          {usage: "restore a dump and replay backed up binlogs up to a point in time", run: runPITR},
+	"preflight":     {usage: "check master and replica before configuring replication", run: runPreflight},
+	"provision":     {usage: "load a dump to a new replica and start replication", run: runProvision},
+	"replica":       {usage: "start, stop and wait for replication threads of a replica", run: runReplica},
+	"schema-diff":   {usage: "find drift of table definitions between master and replicas", run: runSchemaDiff},
+	"semisync":      {usage: "configure semi-synchronous replication and verify it", run: runSemiSync},
+	"sync":          {usage: "repair rows of replica in drifted chunks", run: runSync},
+	"switchover":    {usage: "promote a replica to master and repoint the others", run: runSwitchover},
+	"topology":      {usage: "discover replication topology from a seed server", run: runTopology},
+}
+
 func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+
+	go func() {
+		<-quit
+		cancel()
+	}()
+
+	err := cmd.run(ctx, os.Args[2:])
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].usage)
+	}
+}
+
+// namedDSN represents DSN with name, ex. 'replica1=user:password@tcp(host:3306)/'
+type namedDSN struct {
+	Name string
+	DSN  string
+}
+
+// parseNamedDSN parses 'name=dsn' values,
+// address of DSN is used as name if name is omitted
+func parseNamedDSN(values []string) ([]namedDSN, error) {
+	result := make([]namedDSN, 0, len(values))
+
+	for _, value := range values {
+		item := namedDSN{DSN: value}
+
+		if i := strings.Index(value, "="); i > 0 && !strings.ContainsAny(value[:i], "@:/") {
+			item.Name, item.DSN = value[:i], value[i+1:]
+		}
+
+		cfg, err := mysql.ParseDSN(item.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DSN %q: %s", item.Name, err)
+		}
+
+		if item.Name == "" {
+			item.Name = cfg.Addr
+		}
+
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %s", err)
+	}
+
+	return db, nil
+}
+
+// openNodes opens connections to servers by 'name=dsn' values
+func openNodes(values ...string) ([]*node.Node, error) {
+	dsns, err := parseNamedDSN(values)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*node.Node, 0, len(dsns))
+
+	for _, dsn := range dsns {
+		n, err := node.Open(dsn.Name, dsn.DSN)
+		if err != nil {
+			closeNodes(nodes)
+			return nil, fmt.Errorf("%s: %s", dsn.Name, err)
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	return nodes, nil
+}
 
+func closeNodes(nodes []*node.Node) {
+	for _, n := range nodes {
+		err := n.Close()
+		if err != nil {
+			logrus.Errorf("unable to close connection to %s: %s", n, err)
+		}
+	}
 }
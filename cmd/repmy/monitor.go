@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/monitor"
+	"github.com/spf13/pflag"
+)
+
+func runMonitor(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("monitor", pflag.ExitOnError)
+
+	replicas := flags.StringArrayP("replica", "r", nil, "replica 'name=DSN', can be repeated")
+	interval := flags.DurationP("interval", "i", monitor.DefaultInterval, "interval between polls")
+	maxLag := flags.Duration("max-lag", time.Minute, "alert when replica lag is greater")
+	repeat := flags.Duration("repeat", 0, "repeat notifications for firing alerts, 0 disables")
+	rules := flags.StringSlice("rules", []string{"io", "sql", "lag", "errors", "gtid"}, "enabled rules")
+	webhook := flags.StringArray("webhook", nil, "send events as JSON to URL, can be repeated")
+	execCmd := flags.StringArray("exec", nil, "run command for every event, can be repeated")
+
+	_ = flags.Parse(args)
+
+	dsns, err := parseNamedDSN(*replicas)
+	if err != nil {
+		return err
+	}
+
+	m := &monitor.Monitor{
+		Interval:  *interval,
+		Repeat:    *repeat,
+		Notifiers: []monitor.Notifier{monitor.LogNotifier{}},
+	}
+
+	for _, dsn := range dsns {
+		db, err := openDB(dsn.DSN)
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		m.Replicas = append(m.Replicas, monitor.Replica{Name: dsn.Name, DB: db})
+	}
+
+	all := map[string]monitor.Rule{
+		"io":     monitor.IOThreadStopped{},
+		"sql":    monitor.SQLThreadStopped{},
+		"lag":    monitor.LagAbove{Threshold: *maxLag},
+		"errors": monitor.ErrorIncreasing{},
+		"gtid":   monitor.GTIDGap{},
+	}
+
+	for _, name := range *rules {
+		rule, ok := all[name]
+		if !ok {
+			return fmt.Errorf("unknown rule %q", name)
+		}
+
+		m.Rules = append(m.Rules, rule)
+	}
+
+	for _, url := range *webhook {
+		m.Notifiers = append(m.Notifiers, monitor.WebhookNotifier{URL: url})
+	}
+
+	for _, cmd := range *execCmd {
+		parts := strings.Fields(cmd)
+		if len(parts) == 0 {
+			return fmt.Errorf("empty --exec command")
+		}
+
+		m.Notifiers = append(m.Notifiers, monitor.ExecNotifier{Command: parts[0], Args: parts[1:]})
+	}
+
+	return m.Run(ctx)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/pitr"
+	"github.com/spf13/pflag"
+)
+
+func runPITR(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("pitr", pflag.ExitOnError)
+
+	targetDSN := flags.StringP("target", "t", "", "DSN of server the dump is restored to")
+	dumpDir := flags.StringP("dump", "d", "", "directory of dump written by repmydump")
+	binlogDir := flags.StringP("binlogs", "b", "", "directory of binlog-backup")
+	stopDatetime := flags.String("stop-datetime", "", "stop before transactions after 'YYYY-MM-DD hh:mm:ss' of local time")
+	stopPosition := flags.String("stop-position", "", "stop before transactions at or after 'file:position'")
+	stopGTID := flags.String("stop-gtid", "", "stop after transaction 'uuid:number'")
+	skipLoad := flags.Bool("skip-load", false, "replay binlogs only, the dump is loaded already")
+	verbose := flags.BoolP("verbose", "v", false, "verbose progress")
+
+	_ = flags.Parse(args)
+
+	if *targetDSN == "" || *dumpDir == "" || *binlogDir == "" {
+		return fmt.Errorf("flags --target, --dump and --binlogs are required")
+	}
+
+	stop := pitr.Stop{GTID: *stopGTID}
+
+	if *stopDatetime != "" {
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", *stopDatetime, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --stop-datetime: %s", err)
+		}
+
+		stop.Time = t
+	}
+
+	if *stopPosition != "" {
+		i := strings.LastIndex(*stopPosition, ":")
+		if i <= 0 {
+			return fmt.Errorf("invalid --stop-position %q, expected 'file:position'", *stopPosition)
+		}
+
+		pos, err := strconv.ParseUint((*stopPosition)[i+1:], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid --stop-position: %s", err)
+		}
+
+		stop.File, stop.Position = (*stopPosition)[:i], uint32(pos)
+	}
+
+	db, err := openDB(*targetDSN)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	recovery := &pitr.Recovery{
+		Target:    db,
+		DumpDir:   *dumpDir,
+		BackupDir: *binlogDir,
+		Stop:      stop,
+		SkipLoad:  *skipLoad,
+		Verbose:   *verbose,
+	}
+
+	result, err := recovery.Run(ctx)
+	if result != nil {
+		fmt.Printf("applied %d transactions, the last one at %s:%d of %s %s\n", result.Transactions,
+			result.File, result.Position, result.Timestamp.Local().Format("2006-01-02 15:04:05"), result.GTID)
+	}
+
+	return err
+}
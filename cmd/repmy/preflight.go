@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/preflight"
+	"github.com/spf13/pflag"
+)
+
+func runPreflight(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("preflight", pflag.ExitOnError)
+
+	masterDSN := flags.StringP("master", "m", "", "master 'name=DSN'")
+	replicaDSN := flags.StringP("replica", "r", "", "replica 'name=DSN'")
+	user := flags.StringP("repl-user", "u", "", "replication user, its connection to master is checked if set")
+	password := flags.StringP("repl-password", "p", "", "password of replication user")
+
+	_ = flags.Parse(args)
+
+	if *masterDSN == "" || *replicaDSN == "" {
+		return fmt.Errorf("flags --master and --replica are required")
+	}
+
+	nodes, err := openNodes(*masterDSN, *replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	return checkPair(ctx, nodes[0], nodes[1], mysql.ReplUser{Name: *user, Password: *password})
+}
+
+// checkPair prints report of pre-flight checks and returns error if any check is failed
+func checkPair(ctx context.Context, master, replica *node.Node, user mysql.ReplUser) error {
+	checker := &preflight.Checker{Master: master, Replica: replica, User: user}
+
+	report, err := checker.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s -> %s\n", master, replica)
+
+	_, err = report.WriteTo(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if report.Failed() {
+		return fmt.Errorf("pre-flight checks failed for %s -> %s", master, replica)
+	}
+
+	return nil
+}
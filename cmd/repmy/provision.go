@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+func runProvision(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("provision", pflag.ExitOnError)
+
+	replicaDSN := flags.StringP("replica", "r", "", "new replica 'name=DSN', the dump is loaded to it")
+	dumpDir := flags.StringP("dump", "d", "", "directory of dump written by repmydump")
+	masterDSN := flags.StringP("master", "m", "", "master 'name=DSN', master of donor replica is used if omitted")
+	user := flags.StringP("repl-user", "u", "", "replication user")
+	password := flags.StringP("repl-password", "p", "", "password of replication user")
+	skipLoad := flags.Bool("skip-load", false, "configure replication only, the dump is loaded already")
+	verbose := flags.BoolP("verbose", "v", false, "verbose progress")
+	skipPreflight := flags.Bool("skip-preflight", false, "do not check master and replica before provisioning")
+
+	_ = flags.Parse(args)
+
+	if *replicaDSN == "" || *dumpDir == "" || *user == "" {
+		return fmt.Errorf("flags --replica, --dump and --repl-user are required")
+	}
+
+	coordinates, err := dump.ReadCoordinates(*dumpDir)
+	if err != nil {
+		return err
+	}
+
+	replUser := mysql.ReplUser{
+		Name:       *user,
+		Password:   *password,
+		MasterHost: coordinates.MasterHost,
+		MasterPort: coordinates.MasterPort,
+	}
+
+	if *masterDSN != "" {
+		dsns, err := parseNamedDSN([]string{*masterDSN})
+		if err != nil {
+			return err
+		}
+
+		err = replUser.SetMasterHost(dsns[0].DSN)
+		if err != nil {
+			return err
+		}
+	}
+
+	if replUser.MasterHost == "" {
+		return fmt.Errorf("dump is taken from master, flag --master is required")
+	}
+
+	dsns := []string{*replicaDSN}
+	if *masterDSN != "" {
+		dsns = append(dsns, *masterDSN)
+	}
+
+	nodes, err := openNodes(dsns...)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	replica := nodes[0]
+
+	switch {
+	case *skipPreflight:
+	case *masterDSN == "":
+		logrus.Warnf("pre-flight checks are skipped, they require flag --master")
+	default:
+		err = checkPair(ctx, nodes[1], replica, replUser)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !*skipLoad {
+		logrus.Infof("loading dump %s to %s", *dumpDir, replica)
+
+		err = dump.Load(ctx, replica.DB, *dumpDir, *verbose)
+		if err != nil {
+			return err
+		}
+	}
+
+	info, err := replica.Server().Info(ctx)
+	if err != nil {
+		return err
+	}
+
+	useGTID := info.GTIDEnabled() && coordinates.ExecutedGTIDSet != ""
+
+	if useGTID {
+		err = replica.Server().SetGTIDPurged(ctx, coordinates.ExecutedGTIDSet)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = replica.Repoint(ctx, replUser, coordinates.Status(), useGTID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("%s replicates from %s:%d at %s:%d %s", replica, replUser.MasterHost, replUser.MasterPort,
+		coordinates.File, coordinates.Position, coordinates.ExecutedGTIDSet)
+
+	return nil
+}
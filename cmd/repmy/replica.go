@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+var replicaCommands = map[string]command{
+	"delay":   {usage: "manage delay of replica and fast-forward it before a transaction", run: runReplicaDelay},
+	"filters": {usage: "show and change replication filters online", run: runReplicaFilters},
+	"skip":    {usage: "skip the transaction failed on replica after confirmation", run: runReplicaSkip},
+	"start":   {usage: "start replication threads, optionally until a position", run: runReplicaStart},
+	"stop":    {usage: "stop replication threads", run: runReplicaStop},
+	"wait":    {usage: "wait until replica executes a GTID set or a master position", run: runReplicaWait},
+}
+
+// runReplica runs subcommand of replica command group
+func runReplica(ctx context.Context, args []string) error {
+	return runGroup(ctx, "replica", replicaCommands, args)
+}
+
+// runGroup runs subcommand of group selected by the first argument
+func runGroup(ctx context.Context, group string, commands map[string]command, args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd.run(ctx, args[1:])
+		}
+	}
+
+	names := make([]string, 0, len(commands))
+
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: %s %s <command> [flags]\n\nCommands:\n", os.Args[0], group)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].usage)
+	}
+
+	return fmt.Errorf("unknown %s command", group)
+}
+
+// threadFlags represents selection of replication threads
+type threadFlags struct {
+	io, sql *bool
+}
+
+func addThreadFlags(flags *pflag.FlagSet) threadFlags {
+	return threadFlags{
+		io:  flags.Bool("io-thread", false, "IO thread only"),
+		sql: flags.Bool("sql-thread", false, "SQL thread only"),
+	}
+}
+
+func openReplica(dsn string) (*slave.Repository, func(), error) {
+	if dsn == "" {
+		return nil, nil, fmt.Errorf("flag --replica [-r] is required")
+	}
+
+	nodes, err := openNodes(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nodes[0].Slave(), func() { closeNodes(nodes) }, nil
+}
+
+func runReplicaStart(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("replica start", pflag.ExitOnError)
+
+	replicaDSN := flags.StringP("replica", "r", "", "replica 'name=DSN'")
+	threads := addThreadFlags(flags)
+	untilFile := flags.String("until-file", "", "stop SQL thread at MASTER_LOG_FILE, requires --until-pos")
+	untilPos := flags.Int("until-pos", 0, "stop SQL thread at MASTER_LOG_POS")
+	beforeGTIDs := flags.String("until-before-gtids", "", "stop SQL thread before the first transaction of GTID set")
+	afterGTIDs := flags.String("until-after-gtids", "", "stop SQL thread after all transactions of GTID set")
+	afterGaps := flags.Bool("until-after-mts-gaps", false, "stop multi-threaded SQL thread when relay log gaps are filled")
+
+	_ = flags.Parse(args)
+
+	repo, closeFn, err := openReplica(*replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeFn()
+
+	until := slave.Until{
+		File:         *untilFile,
+		Position:     *untilPos,
+		BeforeGTIDs:  *beforeGTIDs,
+		AfterGTIDs:   *afterGTIDs,
+		AfterMTSGaps: *afterGaps,
+	}
+
+	if until != (slave.Until{}) {
+		if *threads.io {
+			return fmt.Errorf("UNTIL condition is not applicable to IO thread")
+		}
+
+		if *threads.sql {
+			return repo.StartSQLThreadUntil(ctx, until)
+		}
+
+		return repo.StartUntil(ctx, until)
+	}
+
+	switch {
+	case *threads.io && !*threads.sql:
+		return repo.StartIOThread(ctx)
+	case *threads.sql && !*threads.io:
+		return repo.StartSQLThread(ctx)
+	}
+
+	return repo.Start(ctx)
+}
+
+func runReplicaStop(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("replica stop", pflag.ExitOnError)
+
+	replicaDSN := flags.StringP("replica", "r", "", "replica 'name=DSN'")
+	threads := addThreadFlags(flags)
+
+	_ = flags.Parse(args)
+
+	repo, closeFn, err := openReplica(*replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeFn()
+
+	switch {
+	case *threads.io && !*threads.sql:
+		return repo.StopIOThread(ctx)
+	case *threads.sql && !*threads.io:
+		return repo.StopSQLThread(ctx)
+	}
+
+	return repo.Stop(ctx)
+}
+
+func runReplicaWait(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("replica wait", pflag.ExitOnError)
+
+	replicaDSN := flags.StringP("replica", "r", "", "replica 'name=DSN'")
+	gtids := flags.String("gtids", "", "GTID set executed by replica")
+	file := flags.String("file", "", "binlog file of master, requires --pos")
+	pos := flags.Int("pos", 0, "position in binlog file of master")
+	timeout := flags.Duration("timeout", time.Minute, "wait timeout, 0 waits without limit")
+
+	_ = flags.Parse(args)
+
+	if (*gtids == "") == (*file == "" || *pos <= 0) {
+		return fmt.Errorf("either --gtids or --file with --pos is required")
+	}
+
+	repo, closeFn, err := openReplica(*replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeFn()
+
+	if *gtids != "" {
+		err = repo.WaitForExecutedGTIDSet(ctx, *gtids, *timeout)
+	} else {
+		err = repo.MasterPosWait(ctx, *file, *pos, *timeout)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logrus.Info("replica has reached the position")
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/delay"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+var delayCommands = map[string]command{
+	"fast-forward": {usage: "apply delayed transactions until just before a GTID or a time", run: runDelayFastForward},
+	"set":          {usage: "set MASTER_DELAY in seconds, 'set 3600'", run: runDelaySet},
+	"status":       {usage: "show delay and the period of master history kept by replica", run: runDelayStatus},
+}
+
+func runReplicaDelay(ctx context.Context, args []string) error {
+	return runGroup(ctx, "replica delay", delayCommands, args)
+}
+
+func runDelaySet(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("replica delay set", pflag.ExitOnError)
+
+	replicaDSN := flags.StringP("replica", "r", "", "replica 'name=DSN'")
+
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("delay in seconds is required")
+	}
+
+	seconds, err := strconv.Atoi(flags.Arg(0))
+	if err != nil || seconds < 0 {
+		return fmt.Errorf("invalid delay %q", flags.Arg(0))
+	}
+
+	repo, closeFn, err := openReplica(*replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeFn()
+
+	err = repo.SetDelay(ctx, time.Duration(seconds)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("delay of replica is set to %s", time.Duration(seconds)*time.Second)
+
+	return nil
+}
+
+func runDelayStatus(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("replica delay status", pflag.ExitOnError)
+
+	replicaDSN := flags.StringP("replica", "r", "", "replica 'name=DSN'")
+
+	_ = flags.Parse(args)
+
+	if *replicaDSN == "" {
+		return fmt.Errorf("flag --replica [-r] is required")
+	}
+
+	nodes, err := openNodes(*replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	w, err := delay.Inspect(ctx, nodes[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %s\n", nodes[0], w)
+
+	return nil
+}
+
+func runDelayFastForward(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("replica delay fast-forward", pflag.ExitOnError)
+
+	replicaDSN := flags.StringP("replica", "r", "", "replica 'name=DSN'")
+	untilGTID := flags.String("until-gtid", "", "GTID of the transaction which is not applied")
+	untilDatetime := flags.String("until-datetime", "", "stop before the first transaction after "+
+		"'YYYY-MM-DD hh:mm:ss' of local time, binlog of master is inspected")
+	timeout := flags.Duration("timeout", 0, "wait timeout, 0 waits without limit")
+	bf := addBinlogFlags(flags)
+
+	_ = flags.Parse(args)
+
+	if *replicaDSN == "" || (*untilGTID == "") == (*untilDatetime == "") {
+		return fmt.Errorf("flag --replica and either --until-gtid or --until-datetime are required")
+	}
+
+	nodes, err := openNodes(*replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	ff := &delay.FastForward{
+		Replica: nodes[0],
+		GTID:    *untilGTID,
+		Timeout: *timeout,
+	}
+
+	if *untilDatetime != "" {
+		ff.Time, err = time.ParseInLocation("2006-01-02 15:04:05", *untilDatetime, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --until-datetime: %s", err)
+		}
+
+		if *bf.user == "" {
+			return fmt.Errorf("flag --repl-user is required to inspect binlog of master")
+		}
+
+		status, err := nodes[0].Slave().ShowStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		ff.Config = bf.config(&node.Node{Host: status.MasterHost, Port: int(status.MasterPort)})
+	}
+
+	result, err := ff.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("%s is stopped before %s at %s:%d", nodes[0], result.Target, result.File, result.Position)
+
+	if result.ExecutedGTIDSet != "" {
+		logrus.Infof("gtid_executed: %s", result.ExecutedGTIDSet)
+	}
+
+	logrus.Warnf("SQL thread is stopped and delay is 0, restore it with 'replica delay set %d' "+
+		"and 'replica start --sql-thread' after recovery", int64(result.Delay/time.Second))
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+func runReplicaFilters(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("replica filters", pflag.ExitOnError)
+
+	replicaDSN := flags.StringP("replica", "r", "", "replica 'name=DSN'")
+	masterDSN := flags.StringP("master", "m", "", "master 'name=DSN', its binlog_format is checked against filters")
+	channel := flags.String("channel", "", "replication channel, global filters are changed if empty")
+
+	// flags replace the whole list of rules, empty value clears it
+	doDB := flags.StringSlice("do-db", nil, "REPLICATE_DO_DB databases")
+	ignoreDB := flags.StringSlice("ignore-db", nil, "REPLICATE_IGNORE_DB databases")
+	doTable := flags.StringSlice("do-table", nil, "REPLICATE_DO_TABLE 'db.table' names")
+	ignoreTable := flags.StringSlice("ignore-table", nil, "REPLICATE_IGNORE_TABLE 'db.table' names")
+	wildDoTable := flags.StringSlice("wild-do-table", nil, "REPLICATE_WILD_DO_TABLE 'db%.table%' patterns")
+	wildIgnoreTable := flags.StringSlice("wild-ignore-table", nil, "REPLICATE_WILD_IGNORE_TABLE 'db%.table%' patterns")
+	rewriteDB := flags.StringSlice("rewrite-db", nil, "REPLICATE_REWRITE_DB 'from=to' rules")
+
+	_ = flags.Parse(args)
+
+	if *replicaDSN == "" {
+		return fmt.Errorf("flag --replica [-r] is required")
+	}
+
+	nodes, err := openNodes(append([]string{*replicaDSN}, nonEmpty(*masterDSN)...)...)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	repo := nodes[0].Slave()
+
+	statuses, err := repo.ShowStatuses(ctx)
+	if err != nil {
+		return err
+	}
+
+	var status *slave.Status
+
+	for i := range statuses {
+		if statuses[i].ChannelName == *channel {
+			status = &statuses[i]
+		}
+	}
+
+	if status == nil {
+		return fmt.Errorf("replica %s has no channel %q", nodes[0], *channel)
+	}
+
+	filters := status.Filters()
+	changed := false
+
+	lists := map[string]*[]string{
+		"do-db": &filters.DoDB, "ignore-db": &filters.IgnoreDB,
+		"do-table": &filters.DoTable, "ignore-table": &filters.IgnoreTable,
+		"wild-do-table": &filters.WildDoTable, "wild-ignore-table": &filters.WildIgnoreTable,
+	}
+	values := map[string]*[]string{
+		"do-db": doDB, "ignore-db": ignoreDB, "do-table": doTable, "ignore-table": ignoreTable,
+		"wild-do-table": wildDoTable, "wild-ignore-table": wildIgnoreTable,
+	}
+
+	for name, list := range lists {
+		if flags.Changed(name) {
+			*list = nonEmpty(*values[name]...)
+			changed = true
+		}
+	}
+
+	if flags.Changed("rewrite-db") {
+		filters.RewriteDB = nil
+
+		for _, rule := range nonEmpty(*rewriteDB...) {
+			i := strings.Index(rule, "=")
+			if i < 0 {
+				return fmt.Errorf("invalid rewrite rule %q, expected 'from=to'", rule)
+			}
+
+			filters.RewriteDB = append(filters.RewriteDB, slave.Rewrite{From: rule[:i], To: rule[i+1:]})
+		}
+
+		changed = true
+	}
+
+	if len(nodes) > 1 {
+		info, err := nodes[1].Server().Info(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, warning := range filters.Warnings(info.BinlogFormat) {
+			logrus.Warnf("binlog_format=%s: %s", info.BinlogFormat, warning)
+		}
+	}
+
+	if changed {
+		err = repo.ChangeFilters(ctx, filters, *channel)
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("replication filters of %s are changed", nodes[0])
+	}
+
+	printFilters(filters)
+
+	return nil
+}
+
+func printFilters(f slave.Filters) {
+	rewrites := make([]string, len(f.RewriteDB))
+
+	for i, r := range f.RewriteDB {
+		rewrites[i] = r.From + "=" + r.To
+	}
+
+	rows := []struct {
+		name  string
+		value []string
+	}{
+		{"do-db", f.DoDB}, {"ignore-db", f.IgnoreDB},
+		{"do-table", f.DoTable}, {"ignore-table", f.IgnoreTable},
+		{"wild-do-table", f.WildDoTable}, {"wild-ignore-table", f.WildIgnoreTable},
+		{"rewrite-db", rewrites},
+	}
+
+	for _, row := range rows {
+		fmt.Printf("%-18s %s\n", row.name, strings.Join(row.value, ","))
+	}
+}
+
+// nonEmpty returns values without empty strings
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
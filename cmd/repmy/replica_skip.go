@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/skip"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+func runReplicaSkip(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("replica skip", pflag.ExitOnError)
+
+	replicaDSN := flags.StringP("replica", "r", "", "replica 'name=DSN'")
+	unsafe := flags.StringSlice("unsafe", skip.DefaultUnsafe, "error classes or numbers which are never skipped, classes: "+
+		strings.Join([]string{skip.ClassTransient, skip.ClassRelayLog, skip.ClassMissingRow, skip.ClassDuplicateKey,
+			skip.ClassSchema, skip.ClassOther}, ", "))
+	audit := flags.String("audit-log", "repmy-audit.log", "file the skip is recorded to")
+
+	_ = flags.Parse(args)
+
+	if *replicaDSN == "" || *audit == "" {
+		return fmt.Errorf("flags --replica and --audit-log are required")
+	}
+
+	nodes, err := openNodes(*replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	replica := nodes[0]
+
+	failure, err := skip.Inspect(ctx, replica)
+	if err != nil {
+		return err
+	}
+
+	if failure == nil {
+		logrus.Infof("SQL thread of %s has no error", replica)
+		return nil
+	}
+
+	fmt.Printf("%s\n%s\n", replica, failure)
+
+	entry := skip.NewEntry(replica.String(), failure)
+
+	err = skip.Policy{Unsafe: *unsafe}.Check(failure.Errno)
+	if err != nil {
+		entry.Result = "refused"
+		return firstErr(err, skip.AppendAudit(*audit, entry))
+	}
+
+	fmt.Printf("the transaction is skipped by %s, data of replica may drift from master\n", entry.Action)
+	fmt.Print("type 'skip' to confirm: ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(answer) != "skip" {
+		return fmt.Errorf("skip is not confirmed")
+	}
+
+	// skip is not performed if it can not be audited
+	entry.Result = "confirmed"
+
+	err = skip.AppendAudit(*audit, entry)
+	if err != nil {
+		return err
+	}
+
+	err = skip.Skip(ctx, replica, failure)
+
+	entry.Result = "skipped"
+	if err != nil {
+		entry.Result = err.Error()
+	}
+
+	return firstErr(err, skip.AppendAudit(*audit, entry))
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/partyzanex/repmy/pkg/schema"
+	"github.com/spf13/pflag"
+)
+
+func runSchemaDiff(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("schema-diff", pflag.ExitOnError)
+
+	masterDSN := flags.StringP("master", "m", "", "master or any source 'name=DSN', its schema is expected")
+	replicaDSNs := flags.StringArrayP("replica", "r", nil, "replica or any target 'name=DSN', can be repeated")
+	tables := flags.StringSlice("tables", nil, "tables list, all tables are compared if empty")
+	alter := flags.Bool("alter", false, "print statements which reconcile schema of replicas")
+
+	_ = flags.Parse(args)
+
+	if *masterDSN == "" || len(*replicaDSNs) == 0 {
+		return fmt.Errorf("flags --master and --replica are required")
+	}
+
+	nodes, err := openNodes(append([]string{*masterDSN}, *replicaDSNs...)...)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	source, err := schema.Load(ctx, nodes[0].DB, *tables...)
+	if err != nil {
+		return fmt.Errorf("%s: %s", nodes[0], err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPLICA\tTABLE\tKIND\tNAME\tMASTER\tREPLICA")
+
+	count := 0
+	statements := make(map[string][]string)
+
+	for _, replica := range nodes[1:] {
+		target, err := schema.Load(ctx, replica.DB, *tables...)
+		if err != nil {
+			return fmt.Errorf("%s: %s", replica, err)
+		}
+
+		diffs := schema.Compare(source, target)
+		count += len(diffs)
+
+		for _, d := range diffs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", replica, d.Table, d.Kind, d.Name, brief(d.Source), brief(d.Target))
+		}
+
+		statements[replica.String()] = schema.Reconcile(source, diffs)
+	}
+
+	if count == 0 {
+		fmt.Println("no drift found")
+		return nil
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if *alter {
+		for _, replica := range nodes[1:] {
+			fmt.Printf("\n-- %s\n", replica)
+
+			for _, statement := range statements[replica.String()] {
+				fmt.Println(statement + ";")
+			}
+		}
+	}
+
+	return fmt.Errorf("%d differences found", count)
+}
+
+// brief shortens definitions of tables in the report
+func brief(s string) string {
+	const width = 60
+
+	if len(s) > width {
+		return s[:width-3] + "..."
+	}
+
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
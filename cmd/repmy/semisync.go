@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/semi"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+func runSemiSync(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("semisync", pflag.ExitOnError)
+
+	masterDSN := flags.StringP("master", "m", "", "master DSN")
+	replicaDSNs := flags.StringArrayP("replica", "r", nil, "replica 'name=DSN', can be repeated")
+	timeout := flags.Duration("timeout", 10*time.Second, "rpl_semi_sync_*_timeout")
+	waitPoint := flags.String("wait-point", semi.WaitAfterSync, "rpl_semi_sync_*_wait_point")
+	waitCount := flags.Int("wait-count", 1, "rpl_semi_sync_*_wait_for_*_count")
+	verify := flags.Duration("verify", 30*time.Second, "time to wait for negotiation of semisync")
+	disable := flags.Bool("disable", false, "disable semisync instead of enabling")
+
+	_ = flags.Parse(args)
+
+	if *masterDSN == "" {
+		return fmt.Errorf("flag --master [-m] is required")
+	}
+
+	dsns, err := parseNamedDSN(*replicaDSNs)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(*masterDSN)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	source := semi.New(db)
+	replicas := make(map[string]*semi.Repository, len(dsns))
+
+	for _, dsn := range dsns {
+		db, err := openDB(dsn.DSN)
+		if err != nil {
+			return err
+		}
+
+		defer db.Close()
+
+		replicas[dsn.Name] = semi.New(db)
+	}
+
+	if *disable {
+		for name, replica := range replicas {
+			err = replica.DisableReplica(ctx)
+			if err != nil {
+				return fmt.Errorf("replica %s: %s", name, err)
+			}
+		}
+
+		return source.DisableSource(ctx)
+	}
+
+	err = source.InstallSource(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = source.EnableSource(ctx, semi.SourceConfig{
+		Timeout:             *timeout,
+		WaitPoint:           *waitPoint,
+		WaitForReplicaCount: *waitCount,
+	})
+	if err != nil {
+		return err
+	}
+
+	for name, replica := range replicas {
+		err = replica.InstallReplica(ctx)
+		if err == nil {
+			err = replica.EnableReplica(ctx)
+		}
+
+		if err != nil {
+			return fmt.Errorf("replica %s: %s", name, err)
+		}
+
+		logrus.Infof("semisync is enabled on replica %s", name)
+	}
+
+	return verifySemiSync(ctx, source, replicas, *waitCount, *verify)
+}
+
+// verifySemiSync waits until master and replicas report semisync as operational
+func verifySemiSync(ctx context.Context, source *semi.Repository, replicas map[string]*semi.Repository, count int, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		status, err := source.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		pending := make([]string, 0)
+
+		for name, replica := range replicas {
+			rs, err := replica.Status(ctx)
+			if err != nil {
+				return fmt.Errorf("replica %s: %s", name, err)
+			}
+
+			if !rs.ReplicaOn {
+				pending = append(pending, name)
+			}
+		}
+
+		if status.Negotiated(count) && len(pending) == 0 {
+			logrus.Infof("semisync is negotiated with %d clients", status.Clients)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("semisync is not negotiated: master status=%v clients=%d, pending replicas %v",
+				status.SourceOn, status.Clients, pending)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/switchover"
+	"github.com/spf13/pflag"
+)
+
+func runSwitchover(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("switchover", pflag.ExitOnError)
+
+	masterDSN := flags.StringP("master", "m", "", "current master 'name=DSN'")
+	candidateDSN := flags.StringP("candidate", "c", "", "replica promoted to master 'name=DSN'")
+	replicaDSNs := flags.StringArrayP("replica", "r", nil, "replica repointed to the new master 'name=DSN', can be repeated")
+	user := flags.StringP("repl-user", "u", "", "replication user")
+	password := flags.StringP("repl-password", "p", "", "password of replication user")
+	repointOld := flags.Bool("repoint-old-master", false, "make the old master a replica of the new master")
+	timeout := flags.Duration("timeout", switchover.DefaultCatchUpTimeout, "catch-up timeout")
+	dryRun := flags.Bool("dry-run", false, "print plan without executing")
+	skipPreflight := flags.Bool("skip-preflight", false, "do not check the new master and its replicas before switchover")
+
+	_ = flags.Parse(args)
+
+	if *masterDSN == "" || *candidateDSN == "" || *user == "" {
+		return fmt.Errorf("flags --master, --candidate and --repl-user are required")
+	}
+
+	nodes, err := openNodes(append([]string{*masterDSN, *candidateDSN}, *replicaDSNs...)...)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	s := &switchover.Switchover{
+		Master:           nodes[0],
+		Candidate:        nodes[1],
+		Replicas:         nodes[2:],
+		User:             mysql.ReplUser{Name: *user, Password: *password},
+		RepointOldMaster: *repointOld,
+		CatchUpTimeout:   *timeout,
+	}
+
+	if !*skipPreflight {
+		replicas := s.Replicas
+		if s.RepointOldMaster {
+			replicas = append([]*node.Node{s.Master}, replicas...)
+		}
+
+		for _, replica := range replicas {
+			err = checkPair(ctx, s.Candidate, replica, s.User)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	plan, err := s.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(plan)
+
+	if *dryRun {
+		return nil
+	}
+
+	return plan.Execute(ctx)
+}
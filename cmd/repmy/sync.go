@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/checksum"
+	"github.com/partyzanex/repmy/pkg/tablesync"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+func runSync(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("sync", pflag.ExitOnError)
+
+	masterDSN := flags.StringP("master", "m", "", "master 'name=DSN', tables of its database are synced")
+	replicaDSN := flags.StringP("replica", "r", "", "drifted replica 'name=DSN'")
+	tables := flags.StringSlice("tables", nil, "tables list, all drifted tables are synced if empty")
+	checksums := flags.String("checksums-table", checksum.DefaultDatabase+"."+checksum.DefaultTable,
+		"table of checksums 'db.table' written by 'repmy checksum'")
+	execute := flags.Bool("execute", false, "apply changes on master with binlog_format=STATEMENT, so they replicate")
+	onReplica := flags.Bool("on-replica", false, "apply changes directly on replica with sql_log_bin=0")
+	timeout := flags.Duration("timeout", tablesync.DefaultWaitTimeout, "timeout of waiting for replica to catch up")
+
+	_ = flags.Parse(args)
+
+	if *masterDSN == "" || *replicaDSN == "" {
+		return fmt.Errorf("flags --master and --replica are required")
+	}
+
+	parts := strings.SplitN(*checksums, ".", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid checksums table %q, expected 'db.table'", *checksums)
+	}
+
+	mode := tablesync.Print
+
+	switch {
+	case *execute && *onReplica:
+		return fmt.Errorf("flags --execute and --on-replica are mutually exclusive")
+	case *execute:
+		mode = tablesync.ApplyMaster
+	case *onReplica:
+		mode = tablesync.ApplyReplica
+	}
+
+	nodes, err := openNodes(*masterDSN, *replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	defer closeNodes(nodes)
+
+	syncer := &tablesync.Syncer{
+		Master:            nodes[0],
+		Replica:           nodes[1],
+		Mode:              mode,
+		Out:               os.Stdout,
+		Tables:            *tables,
+		ChecksumsDatabase: parts[0],
+		ChecksumsTable:    parts[1],
+		WaitTimeout:       *timeout,
+	}
+
+	n, err := syncer.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("%d changes (%s)", n, mode)
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/partyzanex/repmy/pkg/topology"
+	"github.com/spf13/pflag"
+)
+
+func runTopology(ctx context.Context, args []string) error {
+	flags := pflag.NewFlagSet("topology", pflag.ExitOnError)
+
+	dsn := flags.StringP("dsn", "d", "", "DSN of seed server, its credentials are used for every server")
+	format := flags.StringP("format", "f", "tree", "output format: tree, json or dot")
+	maxInstances := flags.Int("max-instances", topology.DefaultMaxInstances, "limit of discovered servers")
+
+	_ = flags.Parse(args)
+
+	if *dsn == "" {
+		return fmt.Errorf("flag --dsn is required")
+	}
+
+	write := map[string]func(t *topology.Topology) error{
+		"tree": func(t *topology.Topology) error { return t.WriteTree(os.Stdout) },
+		"json": func(t *topology.Topology) error { return t.WriteJSON(os.Stdout) },
+		"dot":  func(t *topology.Topology) error { return t.WriteDOT(os.Stdout) },
+	}[*format]
+
+	if write == nil {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	connect, seed, err := topology.DSNConnector(*dsn)
+	if err != nil {
+		return err
+	}
+
+	d := &topology.Discoverer{Connect: connect, MaxInstances: *maxInstances}
+
+	t, err := d.Discover(ctx, seed)
+	if err != nil {
+		return err
+	}
+
+	return write(t)
+}
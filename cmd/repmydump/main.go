@@ -5,12 +5,15 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/mask"
+	"github.com/partyzanex/repmy/pkg/subset"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"os"
 	"os/signal"
 	"runtime"
 	"runtime/pprof"
+	"syscall"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "runtime/pprof"
@@ -32,6 +35,24 @@ var (
 	noHeaders   = pflag.Bool("no-headers", false, "dump tables without headers")
 	noDropTable = pflag.Bool("no-drop-table", false, "dump tables without DROP TABLE IF EXISTS ...")
 	noData      = pflag.Bool("no-data", false, "dump only DLL (without data)")
+	fromReplica = pflag.Bool("from-replica", false, "source is replica, its SQL thread is stopped during dump "+
+		"and position of its master is recorded")
+
+	subsets = pflag.StringArray("subset", nil, "seed rows of subset 'table:condition', can be repeated, "+
+		"rows related by foreign keys are dumped, other tables are dumped without rows")
+	samples = pflag.StringArray("sample", nil, "seed rows of subset sampled randomly 'table:N%' or 'table:N', "+
+		"N rows are sorted by RAND(), so the table is read entirely")
+	subsetDepth   = pflag.Int("subset-depth", subset.DefaultMaxDepth, "max depth of children collected for subset")
+	subsetMaxRows = pflag.Int("subset-max-rows", subset.DefaultMaxRows, "max rows of seeds and children of table "+
+		"in subset, parents required by foreign keys are not capped")
+
+	maskRules = pflag.String("mask-rules", "", "JSON file of rules anonymizing columns 'table.column' in dumped rows")
+
+	users        = pflag.Bool("users", false, "dump accounts and grants to "+dump.UsersFile)
+	usersInclude = pflag.StringSlice("users-include", nil, "accounts 'user' or 'user@host' dumped with --users, "+
+		"wildcards * and ? are allowed")
+	usersExclude = pflag.StringSlice("users-exclude", nil, "accounts 'user' or 'user@host' skipped by --users")
+	systemUsers  = pflag.Bool("system-users", false, "dump root, mysql.* and other system accounts with --users")
 
 	debug = pflag.Bool("debug", false, "debug mode")
 )
@@ -97,12 +118,14 @@ func main() {
 		}
 	}
 
-	quit := make(chan os.Signal)
-	signal.Notify(quit, os.Interrupt, os.Kill)
+	// dump is interrupted by canceling ctx, so SQL thread of replica is started again
+	ctx, cancel := context.WithCancel(context.Background())
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-quit
-		exit("exited")
+		cancel()
 	}()
 
 	d := dump.Dumper{
@@ -116,33 +139,155 @@ func main() {
 		NoDropTable: *noDropTable,
 		NoData:      *noData,
 		Verbose:     *verbose,
+		FromReplica: *fromReplica,
 	}
 
-	ctx := context.Background()
+	if *maskRules != "" {
+		d.Mask, err = mask.ReadRules(*maskRules)
+		if err != nil {
+			exit(err.Error())
+		}
 
-	dll, err := dump.NewFileWriter(*output, "__dll.sql", *gzip)
+		// typos in rules fail before dump
+		err = d.ValidateMask(ctx, *tables...)
+		if err != nil {
+			exit(err.Error())
+		}
+	}
+
+	// subset is selected under the lock of dump, so foreign keys of dumped rows are not dangling
+	if len(*subsets)+len(*samples) > 0 {
+		d.OnLock = func(ctx context.Context) (err error) {
+			d.Where, err = selectSubset(ctx, &d)
+			return err
+		}
+	}
+
+	err = dumpSource(ctx, &d)
 	if err != nil {
 		exit(err.Error())
 	}
 
+	// coordinates are meaningless without data
+	if !*noData && !d.Coordinates().IsZero() {
+		err = dump.WriteCoordinates(*output, d.Coordinates())
+		if err != nil {
+			exit(err.Error())
+		}
+	}
+
+	if *users {
+		err = dumpUsers(ctx, &d)
+		if err != nil {
+			exit(err.Error())
+		}
+	}
+}
+
+// dumpSource dumps schema and data, SQL thread of replica is stopped before schema is dumped
+func dumpSource(ctx context.Context, d *dump.Dumper) (err error) {
+	if d.FromReplica && !d.NoData {
+		err = d.StopReplica(ctx)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			errStart := d.StartReplica(context.Background())
+			if errStart != nil && err == nil {
+				err = errStart
+			}
+		}()
+	}
+
+	dll, err := dump.NewFileWriter(*output, dump.DLLFile, *gzip)
+	if err != nil {
+		return err
+	}
+
 	err = d.DumpDLL(ctx, dll, *tables...)
 	if err != nil {
 		_ = dll.Close()
-		exit(err.Error())
+		return err
 	}
 
 	dir, err := dump.NewDirWriter(*output, *gzip)
 	if err != nil {
-		exit(err.Error())
+		return err
 	}
 
 	err = d.DumpData(ctx, dir, *tables...)
 	if err != nil {
-		exit(err.Error())
+		return err
+	}
+
+	// errors of tables are logged by DumpData, interrupted dump is incomplete
+	return ctx.Err()
+}
+
+// selectSubset returns conditions of rows of subset for dumped tables
+func selectSubset(ctx context.Context, d *dump.Dumper) (map[string]string, error) {
+	s := &subset.Subset{
+		DB:       d.Source,
+		MaxDepth: *subsetDepth,
+		MaxRows:  *subsetMaxRows,
+		Verbose:  *verbose,
+	}
+
+	for _, value := range *subsets {
+		seed, err := subset.ParseSeed(value)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Seeds = append(s.Seeds, seed)
+	}
+
+	for _, value := range *samples {
+		seed, err := subset.ParseSample(value)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Seeds = append(s.Seeds, seed)
 	}
+
+	toDump, err := d.GetTablesForDump(ctx, *tables...)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(toDump))
+
+	for _, table := range toDump {
+		if table.Type == dump.BaseTable {
+			names = append(names, table.Name)
+		}
+	}
+
+	return s.Run(ctx, names)
+}
+
+func dumpUsers(ctx context.Context, d *dump.Dumper) error {
+	w, err := dump.NewFileWriter(*output, dump.UsersFile, *gzip)
+	if err != nil {
+		return err
+	}
+
+	err = d.DumpUsers(ctx, w, dump.UserFilter{
+		Include: *usersInclude,
+		Exclude: *usersExclude,
+		System:  *systemUsers,
+	})
+	if err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	return w.Close()
 }
 
 func exit(msg string) {
-	logrus.Info(msg)
-	os.Exit(0)
+	logrus.Error(msg)
+	os.Exit(1)
 }
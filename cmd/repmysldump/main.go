@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/partyzanex/repmy/pkg/dump"
@@ -43,8 +44,8 @@ func main() {
 	dumper := &dump.Dumper{Source: src}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	quit := make(chan os.Signal)
-	signal.Notify(quit, os.Interrupt, os.Kill)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-quit
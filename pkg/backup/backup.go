@@ -0,0 +1,421 @@
+// Package backup continuously copies binlog files of master to local directory
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultSyncInterval = time.Second
+
+	partialExt = ".partial"
+	gzipExt    = ".gz"
+)
+
+// Backup streams binlog files of master to Dir, the file being written is kept
+// uncompressed with .partial extension and gzipped when master rotates it.
+// Backup is resumed from the end of the partial file.
+type Backup struct {
+	Config binlog.Config
+	// DB is connection to master, the oldest binlog is read from it for new backup
+	DB  *sql.DB
+	Dir string
+	// StartFile is the first binlog of new backup, the oldest binlog of master if empty
+	StartFile string
+	// SyncInterval is interval of syncing partial file and saving manifest
+	SyncInterval time.Duration
+
+	manifest *Manifest
+	current  int
+	file     *os.File
+	buf      *bufio.Writer
+	gtids    gtid.Set
+	synced   time.Time
+}
+
+// Run streams binlog files until ctx is canceled
+func (b *Backup) Run(ctx context.Context) error {
+	if b.SyncInterval <= 0 {
+		b.SyncInterval = DefaultSyncInterval
+	}
+
+	err := os.MkdirAll(b.Dir, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create directory %s", b.Dir)
+	}
+
+	b.manifest, err = LoadManifest(b.Dir)
+	if err != nil {
+		return err
+	}
+
+	b.current = -1
+
+	file, pos, err := b.start(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer b.closeFile()
+
+	conn, err := binlog.Dial(ctx, b.Config)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	err = conn.RegisterSlave("", 0)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("backup from %s:%d", file, pos)
+
+	streamer, err := conn.Dump(file, pos)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+
+	for {
+		e, err := streamer.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return b.sync()
+			}
+
+			_ = b.sync()
+
+			return err
+		}
+
+		err = b.handle(e)
+		if err != nil {
+			return err
+		}
+
+		if time.Since(b.synced) >= b.SyncInterval {
+			err = b.sync()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// start returns position to start streaming from, partial file is recovered
+func (b *Backup) start(ctx context.Context) (string, uint32, error) {
+	last := b.manifest.Last()
+
+	if last != nil && last.Complete {
+		return last.Name, last.End, nil
+	}
+
+	if last != nil {
+		err := b.recover(len(b.manifest.Files) - 1)
+		if err != nil {
+			return "", 0, err
+		}
+
+		return last.Name, last.End, nil
+	}
+
+	if b.StartFile != "" {
+		return b.StartFile, 4, nil
+	}
+
+	logs, err := master.New(b.DB).BinaryLogs(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if len(logs) == 0 {
+		return "", 0, errors.New("master has no binary logs")
+	}
+
+	return logs[0].Name, 4, nil
+}
+
+// recover reads partial file, truncates incomplete event at its end and opens it for writing
+func (b *Backup) recover(i int) error {
+	info := &b.manifest.Files[i]
+	path := filepath.Join(b.Dir, info.Path)
+
+	*info = File{Name: info.Name, Path: info.Path}
+	b.gtids = make(gtid.Set)
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return b.openFile(info.Name, i)
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", path)
+	}
+
+	b.file, b.current = f, i
+
+	r, err := binlog.NewFileReader(f)
+	if err != nil {
+		// magic was not written completely
+		_ = b.closeFile()
+		return b.openFile(info.Name, i)
+	}
+
+	info.End = 4
+
+	for {
+		e, err := r.Next()
+		if err != nil {
+			if err != io.EOF {
+				logrus.Warnf("%s is truncated at %d: %s", info.Path, info.End, err)
+			}
+
+			break
+		}
+
+		b.update(e)
+	}
+
+	err = f.Truncate(int64(info.End))
+	if err != nil {
+		return errors.Wrapf(err, "unable to truncate %s", path)
+	}
+
+	_, err = f.Seek(int64(info.End), io.SeekStart)
+	if err != nil {
+		return errors.Wrapf(err, "unable to seek %s", path)
+	}
+
+	b.buf = bufio.NewWriterSize(f, 1<<20)
+
+	return nil
+}
+
+// openFile creates partial file of binlog, i is index in manifest or -1 for new file
+func (b *Backup) openFile(name string, i int) error {
+	path := name + partialExt
+
+	f, err := os.Create(filepath.Join(b.Dir, path))
+	if err != nil {
+		return errors.Wrapf(err, "unable to create %s", path)
+	}
+
+	_, err = f.Write([]byte(binlog.Magic))
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "unable to write %s", path)
+	}
+
+	if i < 0 {
+		b.manifest.Files = append(b.manifest.Files, File{})
+		i = len(b.manifest.Files) - 1
+	}
+
+	b.manifest.Files[i] = File{Name: name, Path: path, End: 4}
+	b.current, b.file, b.buf = i, f, bufio.NewWriterSize(f, 1<<20)
+	b.gtids = make(gtid.Set)
+
+	return nil
+}
+
+func (b *Backup) closeFile() error {
+	if b.file == nil {
+		return nil
+	}
+
+	err := b.flush()
+	if err != nil {
+		return err
+	}
+
+	err = b.file.Close()
+	b.file, b.buf = nil, nil
+
+	return errors.Wrap(err, "unable to close binlog file")
+}
+
+// handle writes event to the current file
+func (b *Backup) handle(e *binlog.Event) error {
+	// heartbeats are absent in binlog file, but they have log_pos and no artificial flag
+	if _, ok := e.Body.(*binlog.HeartbeatEvent); ok {
+		return nil
+	}
+
+	rotate, isRotate := e.Body.(*binlog.RotateEvent)
+
+	if e.Header.Artificial() {
+		// master starts every file with artificial rotate, the last file of manifest
+		// is continued if it is partial or is followed by the next one if it is complete
+		if last := b.manifest.Last(); isRotate && (last == nil || last.Name != rotate.File) {
+			err := b.complete()
+			if err != nil {
+				return err
+			}
+
+			return b.openFile(rotate.File, -1)
+		}
+
+		return nil
+	}
+
+	if b.file == nil {
+		return errors.Errorf("%s event at %d before rotate", e.Header.Type, e.Header.LogPos)
+	}
+
+	_, err := b.buf.Write(e.Raw)
+	if err != nil {
+		return errors.Wrap(err, "unable to write event")
+	}
+
+	b.update(e)
+
+	if isRotate {
+		return b.complete()
+	}
+
+	return nil
+}
+
+// update updates info of the current file by written event
+func (b *Backup) update(e *binlog.Event) {
+	info := &b.manifest.Files[b.current]
+	info.End = e.Header.LogPos
+
+	if e.Header.Timestamp > 0 {
+		ts := time.Unix(int64(e.Header.Timestamp), 0).UTC()
+
+		if info.FirstTimestamp.IsZero() {
+			info.FirstTimestamp = ts
+		}
+
+		info.LastTimestamp = ts
+	}
+
+	switch body := e.Body.(type) {
+	case *binlog.PreviousGTIDsEvent:
+		info.PreviousGTIDs = body.Set.String()
+	case *binlog.GTIDEvent:
+		if body.UUID != "" {
+			b.gtids.Add(body.UUID, body.GNO)
+			info.GTIDs = b.gtids.String()
+		}
+	}
+}
+
+// complete gzips the current file and marks it complete in manifest
+func (b *Backup) complete() error {
+	if b.file == nil {
+		return nil
+	}
+
+	err := b.closeFile()
+	if err != nil {
+		return err
+	}
+
+	info := &b.manifest.Files[b.current]
+	partial := filepath.Join(b.Dir, info.Path)
+	path := info.Name + gzipExt
+
+	err = compress(partial, filepath.Join(b.Dir, path))
+	if err != nil {
+		return err
+	}
+
+	info.Path, info.Complete = path, true
+
+	err = b.manifest.Save(b.Dir)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("%s is complete, %d bytes", info.Name, info.End)
+
+	return errors.Wrap(os.Remove(partial), "unable to remove partial file")
+}
+
+// flush writes buffered events and syncs the file
+func (b *Backup) flush() error {
+	if b.file == nil {
+		return nil
+	}
+
+	err := b.buf.Flush()
+	if err != nil {
+		return errors.Wrap(err, "unable to write binlog file")
+	}
+
+	return errors.Wrap(b.file.Sync(), "unable to sync binlog file")
+}
+
+// sync flushes the current file and saves manifest
+func (b *Backup) sync() error {
+	b.synced = time.Now()
+
+	err := b.flush()
+	if err != nil {
+		return err
+	}
+
+	return b.manifest.Save(b.Dir)
+}
+
+func compress(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open %s", src)
+	}
+
+	defer in.Close()
+
+	out, err := os.Create(dst + ".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "unable to create %s", dst)
+	}
+
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+
+	_, err = io.Copy(gz, in)
+	if err != nil {
+		return errors.Wrapf(err, "unable to compress %s", src)
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return errors.Wrapf(err, "unable to compress %s", src)
+	}
+
+	err = out.Sync()
+	if err != nil {
+		return errors.Wrapf(err, "unable to sync %s", dst)
+	}
+
+	return errors.Wrap(os.Rename(dst+".tmp", dst), "unable to rename compressed file")
+}
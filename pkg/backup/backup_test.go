@@ -0,0 +1,97 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/backup"
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/internal/binlogtest"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/testutils"
+)
+
+var le = binlogtest.LE
+
+// heartbeat returns heartbeat event as master sends it: log_pos is position of the last event
+func heartbeat(pos uint32, t binlog.EventType, body ...[]byte) []byte {
+	size := uint32(binlog.HeaderSize + len(bytes.Join(body, nil)) + 4)
+	w := &binlogtest.Writer{Pos: pos - size}
+
+	return w.Event(t, body...)
+}
+
+func transaction(w *binlogtest.Writer, gno uint64) [][]byte {
+	return [][]byte{
+		w.Event(binlog.GTIDEventType, []byte{1}, binlogtest.SID(), le(gno, 8), []byte{2}, le(0, 8), le(1, 8)),
+		w.Event(binlog.QueryEventType, le(10, 4), le(0, 4), []byte{2}, le(0, 2), le(0, 2), []byte("db\x00BEGIN")),
+		w.Event(binlog.XIDEventType, le(gno, 8)),
+	}
+}
+
+func TestBackup_Run_Heartbeat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dir)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testutils.FatalErr(t, "Listen", err)
+
+	defer listener.Close()
+
+	w := &binlogtest.Writer{Pos: 4, Timestamp: 1600000000}
+	written := append([][]byte{w.FormatDescription(1)}, transaction(w, 1)...)
+	pos := w.Pos
+	idle := [][]byte{
+		heartbeat(pos, binlog.HeartbeatEventType, []byte("binlog.000001")),
+		heartbeat(pos, binlog.HeartbeatV2EventType,
+			[]byte{1}, binlogtest.Str("binlog.000001"), []byte{2, 3, 0xfc}, le(uint64(pos), 2), []byte{0}),
+	}
+	last := transaction(w, 2)
+
+	events := [][]byte{(&binlogtest.Writer{}).Event(binlog.RotateEventType, le(4, 8), []byte("binlog.000001"))}
+	events = append(append(append(events, written...), idle...), last...)
+	file := bytes.Join(append(append([][]byte{[]byte(binlog.Magic)}, written...), last...), nil)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		master := &binlogtest.Master{T: t, Conn: conn}
+		master.Serve("secret", events)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cfg := binlog.ConfigFor(mysql.ReplUser{Name: "repl", Password: "secret"}, 100)
+	cfg.Addr = listener.Addr().String()
+	cfg.NonBlock = true
+
+	b := &backup.Backup{Config: cfg, Dir: dir, StartFile: "binlog.000001"}
+
+	err = b.Run(ctx)
+	testutils.AssertEqual(t, "end of stream", io.EOF, err)
+
+	m, err := backup.LoadManifest(dir)
+	testutils.FatalErr(t, "LoadManifest", err)
+	testutils.AssertEqual(t, "files", 1, len(m.Files))
+	testutils.AssertEqual(t, "end", w.Pos, m.Files[0].End)
+	testutils.AssertEqual(t, "gtids", binlogtest.UUID+":1-2", m.Files[0].GTIDs)
+
+	// heartbeats are not written
+	data, err := ioutil.ReadFile(filepath.Join(dir, m.Files[0].Path))
+	testutils.FatalErr(t, "ReadFile", err)
+	testutils.AssertEqual(t, "file", true, bytes.Equal(file, data))
+}
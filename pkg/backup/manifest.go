@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ManifestFile is name of manifest in backup directory
+const ManifestFile = "manifest.json"
+
+// File represents backed up binlog file
+type File struct {
+	// Name is name of binlog file on master
+	Name string `json:"name"`
+	// Path is name of file in backup directory, it is gzipped when file is complete
+	Path string `json:"path"`
+	// End is position after the last event
+	End           uint32 `json:"end"`
+	PreviousGTIDs string `json:"previous_gtids,omitempty"`
+	// GTIDs are transactions written in file
+	GTIDs          string    `json:"gtids,omitempty"`
+	FirstTimestamp time.Time `json:"first_timestamp"`
+	LastTimestamp  time.Time `json:"last_timestamp"`
+	// Complete is true if file is rotated on master and will not change
+	Complete bool `json:"complete"`
+}
+
+// Open returns reader of binlog file from backup directory
+func (f File) Open(dir string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(dir, f.Path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open %s", f.Path)
+	}
+
+	if !strings.HasSuffix(f.Path, ".gz") {
+		return file, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, errors.Wrapf(err, "unable to read %s", f.Path)
+	}
+
+	return &gzipFile{Reader: gz, file: file}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f *gzipFile) Close() error {
+	_ = f.Reader.Close()
+	return f.file.Close()
+}
+
+// Manifest lists backed up binlog files in order of master
+type Manifest struct {
+	Files []File `json:"files"`
+}
+
+// LoadManifest reads manifest of backup directory, empty manifest is returned for new backup
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{Files: make([]File, 0)}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read manifest")
+	}
+
+	err = json.Unmarshal(data, m)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid manifest in %s", dir)
+	}
+
+	return m, nil
+}
+
+// Save writes manifest to temporary file and renames it
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to encode manifest")
+	}
+
+	name := filepath.Join(dir, ManifestFile)
+
+	err = ioutil.WriteFile(name+".tmp", append(data, '\n'), 0644)
+	if err != nil {
+		return errors.Wrap(err, "unable to write manifest")
+	}
+
+	return errors.Wrap(os.Rename(name+".tmp", name), "unable to write manifest")
+}
+
+// Find returns index of binlog file or -1
+func (m *Manifest) Find(name string) int {
+	for i, f := range m.Files {
+		if f.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Last returns the last file or nil for empty manifest
+func (m *Manifest) Last() *File {
+	if len(m.Files) == 0 {
+		return nil
+	}
+
+	return &m.Files[len(m.Files)-1]
+}
@@ -0,0 +1,69 @@
+package backup_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/backup"
+	"github.com/partyzanex/testutils"
+)
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dir)
+
+	m, err := backup.LoadManifest(dir)
+	testutils.FatalErr(t, "LoadManifest", err)
+	testutils.AssertEqual(t, "empty", true, m.Last() == nil)
+
+	m.Files = append(m.Files,
+		backup.File{Name: "binlog.000001", Path: "binlog.000001.gz", End: 1024, Complete: true},
+		backup.File{Name: "binlog.000002", Path: "binlog.000002.partial", End: 120},
+	)
+	testutils.FatalErr(t, "Save", m.Save(dir))
+
+	m, err = backup.LoadManifest(dir)
+	testutils.FatalErr(t, "LoadManifest", err)
+	testutils.AssertEqual(t, "files", 2, len(m.Files))
+	testutils.AssertEqual(t, "find", 1, m.Find("binlog.000002"))
+	testutils.AssertEqual(t, "missing", -1, m.Find("binlog.000003"))
+	testutils.AssertEqual(t, "last", "binlog.000002", m.Last().Name)
+	testutils.AssertEqual(t, "complete", true, m.Files[0].Complete)
+}
+
+func TestFile_Open(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "binlog.000001.gz"))
+	testutils.FatalErr(t, "Create", err)
+
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte("events"))
+	testutils.FatalErr(t, "Write", err)
+	testutils.FatalErr(t, "Close", gz.Close())
+	testutils.FatalErr(t, "Close", f.Close())
+
+	testutils.FatalErr(t, "WriteFile",
+		ioutil.WriteFile(filepath.Join(dir, "binlog.000002.partial"), []byte("partial"), 0644))
+
+	data := map[string]string{"binlog.000001.gz": "events", "binlog.000002.partial": "partial"}
+
+	for path, exp := range data {
+		r, err := backup.File{Path: path}.Open(dir)
+		testutils.FatalErr(t, "Open", err)
+
+		content, err := ioutil.ReadAll(r)
+		testutils.FatalErr(t, "ReadAll", err)
+		testutils.FatalErr(t, "Close", r.Close())
+
+		testutils.AssertEqual(t, path, exp, string(content))
+	}
+}
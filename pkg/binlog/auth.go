@@ -0,0 +1,103 @@
+package binlog
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	nativePassword      = "mysql_native_password"
+	cachingSHA2Password = "caching_sha2_password"
+
+	// responses of caching_sha2_password
+	fastAuthSuccess   = 3
+	performFullAuth   = 4
+	requestPublicKey  = 2
+	authMoreData      = 0x01
+	authSwitchRequest = 0xfe
+)
+
+// scramble returns auth response of plugin for password and nonce of server
+func scramble(plugin string, password, nonce []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, nil
+	}
+
+	switch plugin {
+	case nativePassword:
+		return scrambleNative(password, nonce), nil
+	case cachingSHA2Password:
+		return scrambleSHA256(password, nonce), nil
+	}
+
+	return nil, errors.Errorf("unsupported auth plugin %s", plugin)
+}
+
+// scrambleNative returns SHA1(password) XOR SHA1(nonce + SHA1(SHA1(password)))
+func scrambleNative(password, nonce []byte) []byte {
+	stage1 := sha1.Sum(password)
+	stage2 := sha1.Sum(stage1[:])
+
+	h := sha1.New()
+	h.Write(nonce[:20])
+	h.Write(stage2[:])
+
+	result := h.Sum(nil)
+
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+
+	return result
+}
+
+// scrambleSHA256 returns SHA256(password) XOR SHA256(SHA256(SHA256(password)) + nonce)
+func scrambleSHA256(password, nonce []byte) []byte {
+	stage1 := sha256.Sum256(password)
+	stage2 := sha256.Sum256(stage1[:])
+
+	h := sha256.New()
+	h.Write(stage2[:])
+	h.Write(nonce[:20])
+
+	result := h.Sum(nil)
+
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+
+	return result
+}
+
+// encryptPassword encrypts password XOR nonce by RSA public key of server,
+// it is used by caching_sha2_password without TLS
+func encryptPassword(password, nonce, pemKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("invalid public key of server")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid public key of server")
+	}
+
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key of server is not RSA")
+	}
+
+	plain := append(append([]byte{}, password...), 0)
+
+	for i := range plain {
+		plain[i] ^= nonce[i%20]
+	}
+
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, plain, nil)
+}
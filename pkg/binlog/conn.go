@@ -0,0 +1,445 @@
+// Package binlog implements client of MySQL replication protocol
+// and parser of binlog events streamed by master or read from binlog files
+package binlog
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/pkg/errors"
+)
+
+// capability flags of client
+const (
+	clientLongPassword     = 0x00000001
+	clientLongFlag         = 0x00000004
+	clientProtocol41       = 0x00000200
+	clientSSL              = 0x00000800
+	clientTransactions     = 0x00002000
+	clientSecureConnection = 0x00008000
+	clientPluginAuth       = 0x00080000
+	clientPluginAuthLenenc = 0x00200000
+)
+
+// commands
+const (
+	comQuery          = 0x03
+	comRegisterSlave  = 0x15
+	comBinlogDump     = 0x12
+	comBinlogDumpGTID = 0x1e
+
+	// dumpNonBlock makes server return EOF instead of waiting for new events
+	dumpNonBlock = 0x01
+	// dumpThroughGTID makes COM_BINLOG_DUMP_GTID use GTID set instead of position
+	dumpThroughGTID = 0x04
+)
+
+const (
+	DefaultHeartbeat = 30 * time.Second
+	charsetUTF8MB4   = 45
+)
+
+// Config represents connection of replica to master
+type Config struct {
+	// Addr is host:port of master
+	Addr string
+	User mysql.ReplUser
+	// ServerID must be unique in topology, it is shown in SHOW SLAVE HOSTS
+	ServerID uint32
+	// TLS enables TLS if not nil
+	TLS *tls.Config
+	// Heartbeat is period of heartbeat events of master, DefaultHeartbeat if zero
+	Heartbeat time.Duration
+	// NonBlock stops streaming at the end of the last binlog instead of waiting
+	NonBlock bool
+}
+
+// ConfigFor returns Config of replication user created by master.Repository.SetReplUser,
+// address is taken from MasterHost and MasterPort of user, TLS is enabled without
+// verification of certificate if user requires SSL
+func ConfigFor(user mysql.ReplUser, serverID uint32) Config {
+	port := user.MasterPort
+	if port == 0 {
+		port = 3306
+	}
+
+	cfg := Config{
+		Addr:     net.JoinHostPort(user.MasterHost, strconv.Itoa(port)),
+		User:     user,
+		ServerID: serverID,
+	}
+
+	if user.RequireSSL {
+		cfg.TLS = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return cfg
+}
+
+// Conn represents replication connection to master
+type Conn struct {
+	cfg           Config
+	pc            *packetConn
+	serverVersion string
+}
+
+// Dial connects to master and authenticates
+func Dial(ctx context.Context, cfg Config) (*Conn, error) {
+	if cfg.ServerID == 0 {
+		return nil, errors.New("server id of replica is required")
+	}
+
+	dialer := &net.Dialer{KeepAlive: time.Minute}
+
+	nc, err := dialer.DialContext(ctx, "tcp", cfg.Addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to connect to %s", cfg.Addr)
+	}
+
+	c := &Conn{cfg: cfg, pc: newPacketConn(nc)}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = nc.SetDeadline(deadline)
+	}
+
+	err = c.handshake()
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	_ = c.pc.conn.SetDeadline(time.Time{})
+
+	return c, nil
+}
+
+// ServerVersion returns version of master from handshake
+func (c *Conn) ServerVersion() string {
+	return c.serverVersion
+}
+
+// Close closes connection
+func (c *Conn) Close() error {
+	return c.pc.conn.Close()
+}
+
+// handshake reads initial handshake of server and authenticates
+func (c *Conn) handshake() error {
+	data, err := c.pc.readPacket()
+	if err != nil {
+		return err
+	}
+
+	if data[0] == packetErr {
+		return parseError(data)
+	}
+
+	b := &buffer{data: data}
+
+	if protocol := b.uint8(); protocol != 10 {
+		return errors.Errorf("unsupported protocol version %d", protocol)
+	}
+
+	c.serverVersion = b.nulString()
+	b.skip(4) // connection id
+	nonce := append([]byte{}, b.bytes(8)...)
+	b.skip(1)
+	caps := uint32(b.uint16())
+	b.skip(1 + 2) // charset, status
+	caps |= uint32(b.uint16()) << 16
+	nonceLen := int(b.uint8())
+	b.skip(10)
+
+	if caps&clientSecureConnection != 0 {
+		n := nonceLen - 8
+		if n < 13 {
+			n = 13
+		}
+
+		nonce = append(nonce, b.bytes(n)...)
+	}
+
+	plugin := nativePassword
+	if caps&clientPluginAuth != 0 {
+		plugin = b.nulString()
+	}
+
+	if b.err != nil {
+		return errors.Wrap(b.err, "invalid handshake")
+	}
+
+	if len(nonce) > 20 {
+		nonce = nonce[:20]
+	}
+
+	clientCaps := uint32(clientLongPassword | clientLongFlag | clientProtocol41 | clientTransactions |
+		clientSecureConnection | clientPluginAuth | clientPluginAuthLenenc)
+
+	if c.cfg.TLS != nil {
+		if caps&clientSSL == 0 {
+			return errors.New("server does not support TLS")
+		}
+
+		clientCaps |= clientSSL
+
+		err = c.startTLS(clientCaps)
+		if err != nil {
+			return err
+		}
+	}
+
+	password := []byte(c.cfg.User.Password)
+
+	authData, err := scramble(plugin, password, nonce)
+	if err != nil {
+		return err
+	}
+
+	resp := make([]byte, 0, 128)
+	resp = appendUint32(resp, clientCaps)
+	resp = appendUint32(resp, maxPayload)
+	resp = append(resp, charsetUTF8MB4)
+	resp = append(resp, make([]byte, 23)...)
+	resp = append(append(resp, c.cfg.User.Name...), 0)
+	resp = appendLenenc(resp, uint64(len(authData)))
+	resp = append(resp, authData...)
+	resp = append(append(resp, plugin...), 0)
+
+	err = c.pc.writePacket(resp)
+	if err != nil {
+		return err
+	}
+
+	return c.authResult(plugin, password, nonce)
+}
+
+// startTLS sends SSL request and upgrades connection
+func (c *Conn) startTLS(caps uint32) error {
+	req := make([]byte, 0, 32)
+	req = appendUint32(req, caps)
+	req = appendUint32(req, maxPayload)
+	req = append(req, charsetUTF8MB4)
+	req = append(req, make([]byte, 23)...)
+
+	err := c.pc.writePacket(req)
+	if err != nil {
+		return err
+	}
+
+	cfg := c.cfg.TLS.Clone()
+	if cfg.ServerName == "" && !cfg.InsecureSkipVerify {
+		cfg.ServerName, _, _ = net.SplitHostPort(c.cfg.Addr)
+	}
+
+	conn := tls.Client(c.pc.conn, cfg)
+
+	err = conn.Handshake()
+	if err != nil {
+		return errors.Wrap(err, "TLS handshake failed")
+	}
+
+	seq := c.pc.seq
+	c.pc = newPacketConn(conn)
+	c.pc.seq = seq
+
+	return nil
+}
+
+// authResult handles auth switch and caching_sha2_password exchange until OK
+func (c *Conn) authResult(plugin string, password, nonce []byte) error {
+	for {
+		data, err := c.pc.readPacket()
+		if err != nil {
+			return err
+		}
+
+		switch data[0] {
+		case packetOK:
+			return nil
+		case packetErr:
+			return parseError(data)
+		case authSwitchRequest:
+			b := &buffer{data: data[1:]}
+			plugin = b.nulString()
+			nonce = b.rest()
+
+			if len(nonce) > 20 {
+				nonce = nonce[:20]
+			}
+
+			authData, err := scramble(plugin, password, nonce)
+			if err != nil {
+				return err
+			}
+
+			err = c.pc.writePacket(authData)
+			if err != nil {
+				return err
+			}
+		case authMoreData:
+			if plugin != cachingSHA2Password || len(data) < 2 {
+				return errors.Errorf("unexpected auth data for %s", plugin)
+			}
+
+			err = c.fullAuth(data[1:], password, nonce)
+			if err != nil {
+				return err
+			}
+		default:
+			return errors.Errorf("unexpected auth packet 0x%02x", data[0])
+		}
+	}
+}
+
+// fullAuth continues caching_sha2_password authentication
+func (c *Conn) fullAuth(data, password, nonce []byte) error {
+	switch data[0] {
+	case fastAuthSuccess:
+		return nil
+	case performFullAuth:
+		if c.cfg.TLS != nil {
+			return c.pc.writePacket(append(append([]byte{}, password...), 0))
+		}
+
+		err := c.pc.writePacket([]byte{requestPublicKey})
+		if err != nil {
+			return err
+		}
+
+		key, err := c.pc.readPacket()
+		if err != nil {
+			return err
+		}
+
+		if key[0] != authMoreData {
+			return errors.New("public key of server expected")
+		}
+
+		encrypted, err := encryptPassword(password, nonce, key[1:])
+		if err != nil {
+			return err
+		}
+
+		return c.pc.writePacket(encrypted)
+	}
+
+	// public key of server
+	encrypted, err := encryptPassword(password, nonce, data)
+	if err != nil {
+		return err
+	}
+
+	return c.pc.writePacket(encrypted)
+}
+
+// Exec executes statement which returns no rows
+func (c *Conn) Exec(query string) error {
+	err := c.pc.writeCommand(append([]byte{comQuery}, query...))
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(c.pc.readOK(), "unable to execute %s", query)
+}
+
+// RegisterSlave registers replica on master, it is shown in SHOW SLAVE HOSTS
+func (c *Conn) RegisterSlave(reportHost string, reportPort int) error {
+	if reportHost == "" {
+		reportHost, _ = os.Hostname()
+	}
+
+	data := []byte{comRegisterSlave}
+	data = appendUint32(data, c.cfg.ServerID)
+	data = append(append(data, byte(len(reportHost))), reportHost...)
+	data = append(append(data, byte(len(c.cfg.User.Name))), c.cfg.User.Name...)
+	data = append(data, 0) // password is not reported
+	data = appendUint16(data, uint16(reportPort))
+	data = appendUint32(data, 0) // replication rank
+	data = appendUint32(data, 0) // master id
+
+	err := c.pc.writeCommand(data)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(c.pc.readOK(), "unable to register slave")
+}
+
+// prepare asks master to send checksums and heartbeats
+func (c *Conn) prepare() error {
+	err := c.Exec(`SET @master_binlog_checksum = @@global.binlog_checksum`)
+	if err != nil {
+		return err
+	}
+
+	heartbeat := c.cfg.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+
+	return c.Exec(`SET @master_heartbeat_period = ` + strconv.FormatInt(heartbeat.Nanoseconds(), 10))
+}
+
+// Dump starts streaming of binlog from file and position
+func (c *Conn) Dump(file string, pos uint32) (*Streamer, error) {
+	err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	if pos < 4 {
+		pos = 4
+	}
+
+	data := []byte{comBinlogDump}
+	data = appendUint32(data, pos)
+	data = appendUint16(data, c.flags())
+	data = appendUint32(data, c.cfg.ServerID)
+	data = append(data, file...)
+
+	err = c.pc.writeCommand(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStreamer(c, file), nil
+}
+
+// DumpGTID starts streaming of transactions which are not contained in executed
+func (c *Conn) DumpGTID(executed gtid.Set) (*Streamer, error) {
+	err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	set := EncodeGTIDSet(executed)
+
+	data := []byte{comBinlogDumpGTID}
+	data = appendUint16(data, c.flags()|dumpThroughGTID)
+	data = appendUint32(data, c.cfg.ServerID)
+	data = appendUint32(data, 0) // file name length
+	data = appendUint64(data, 4) // position
+	data = appendUint32(data, uint32(len(set)))
+	data = append(data, set...)
+
+	err = c.pc.writeCommand(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStreamer(c, ""), nil
+}
+
+func (c *Conn) flags() uint16 {
+	if c.cfg.NonBlock {
+		return dumpNonBlock
+	}
+
+	return 0
+}
@@ -0,0 +1,133 @@
+package binlog_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/internal/binlogtest"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/testutils"
+)
+
+func TestConn_DumpGTID(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testutils.FatalErr(t, "Listen", err)
+
+	defer listener.Close()
+
+	w := &binlogtest.Writer{}
+	rotate := w.Event(binlog.RotateEventType, le(4, 8), []byte("binlog.000003"))
+	w.Pos = 4
+	events := [][]byte{
+		rotate,
+		w.FormatDescription(1),
+		w.Event(binlog.GTIDEventType, []byte{1}, binlogtest.SID(), le(23, 8)),
+		w.Event(binlog.XIDEventType, le(1, 8)),
+	}
+
+	master := &binlogtest.Master{T: t}
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		master.Conn = conn
+
+		master.Serve("secret", events)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	cfg := binlog.ConfigFor(mysql.ReplUser{Name: "repl", Password: "secret", MasterHost: host}, 100)
+	cfg.Addr = net.JoinHostPort(host, port)
+	cfg.NonBlock = true
+
+	conn, err := binlog.Dial(ctx, cfg)
+	testutils.FatalErr(t, "Dial", err)
+
+	defer conn.Close()
+
+	testutils.AssertEqual(t, "version", "8.0.30", conn.ServerVersion())
+	testutils.FatalErr(t, "RegisterSlave", conn.RegisterSlave("replica", 3306))
+
+	streamer, err := conn.DumpGTID(gtid.MustParse(binlogtest.UUID + ":1-22"))
+	testutils.FatalErr(t, "DumpGTID", err)
+
+	types := make([]string, 0)
+
+	for {
+		e, err := streamer.Next()
+		if err == io.EOF {
+			break
+		}
+
+		testutils.FatalErr(t, "Next", err)
+
+		types = append(types, e.Header.Type.String())
+	}
+
+	<-done
+
+	testutils.AssertEqual(t, "events", "ROTATE FORMAT_DESCRIPTION GTID XID", strings.Join(types, " "))
+	testutils.AssertEqual(t, "file", "binlog.000003", streamer.Position().File)
+	testutils.AssertEqual(t, "pos", w.Pos, streamer.Position().Pos)
+
+	testutils.AssertEqual(t, "commands", 4, len(master.Commands))
+	testutils.AssertEqual(t, "register", byte(0x15), master.Commands[0][0])
+	testutils.AssertEqual(t, "checksum", "\x03SET @master_binlog_checksum = @@global.binlog_checksum",
+		string(master.Commands[1]))
+
+	dump := master.Commands[3]
+	testutils.AssertEqual(t, "flags", byte(0x05), dump[1])
+	testutils.AssertEqual(t, "server id", byte(100), dump[3])
+	testutils.AssertEqual(t, "gtid set", true,
+		bytes.HasSuffix(dump, binlog.EncodeGTIDSet(gtid.MustParse(binlogtest.UUID+":1-22"))))
+}
+
+func TestDial_AccessDenied(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testutils.FatalErr(t, "Listen", err)
+
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		master := &binlogtest.Master{T: t, Conn: conn}
+		master.Serve("secret", nil)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = binlog.Dial(ctx, binlog.Config{
+		Addr:     listener.Addr().String(),
+		User:     mysql.ReplUser{Name: "repl", Password: "wrong"},
+		ServerID: 100,
+	})
+
+	serverErr, ok := err.(*binlog.ServerError)
+	testutils.AssertEqual(t, "server error", true, ok)
+
+	if ok {
+		testutils.AssertEqual(t, "code", uint16(1045), serverErr.Code)
+	}
+}
@@ -0,0 +1,641 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/pkg/errors"
+)
+
+// EventType represents type code of binlog event
+type EventType uint8
+
+const (
+	QueryEventType             EventType = 2
+	RotateEventType            EventType = 4
+	IntvarEventType            EventType = 5
+	RandEventType              EventType = 13
+	UserVarEventType           EventType = 14
+	FormatDescriptionEventType EventType = 15
+	XIDEventType               EventType = 16
+	TableMapEventType          EventType = 19
+	HeartbeatEventType         EventType = 27
+	WriteRowsEventType         EventType = 30
+	UpdateRowsEventType        EventType = 31
+	DeleteRowsEventType        EventType = 32
+	GTIDEventType              EventType = 33
+	AnonymousGTIDEventType     EventType = 34
+	PreviousGTIDsEventType     EventType = 35
+	HeartbeatV2EventType       EventType = 41
+)
+
+const (
+	// HeaderSize is size of event header of binlog version 4
+	HeaderSize = 19
+	// Magic starts every binlog file
+	Magic = "\xfebin"
+
+	checksumOff   = 0
+	checksumCRC32 = 1
+	checksumSize  = 4
+
+	// artificialFlag marks events which are not written in binlog file
+	artificialFlag = 0x20
+)
+
+var eventTypeNames = map[EventType]string{
+	QueryEventType:             "QUERY",
+	RotateEventType:            "ROTATE",
+	IntvarEventType:            "INTVAR",
+	RandEventType:              "RAND",
+	UserVarEventType:           "USER_VAR",
+	FormatDescriptionEventType: "FORMAT_DESCRIPTION",
+	XIDEventType:               "XID",
+	TableMapEventType:          "TABLE_MAP",
+	HeartbeatEventType:         "HEARTBEAT",
+	WriteRowsEventType:         "WRITE_ROWS",
+	UpdateRowsEventType:        "UPDATE_ROWS",
+	DeleteRowsEventType:        "DELETE_ROWS",
+	GTIDEventType:              "GTID",
+	AnonymousGTIDEventType:     "ANONYMOUS_GTID",
+	PreviousGTIDsEventType:     "PREVIOUS_GTIDS",
+	HeartbeatV2EventType:       "HEARTBEAT_V2",
+}
+
+// String returns name of event type
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+
+	return "UNKNOWN_" + strconv.Itoa(int(t))
+}
+
+// Header represents common header of binlog events
+type Header struct {
+	Timestamp uint32
+	Type      EventType
+	ServerID  uint32
+	EventSize uint32
+	// LogPos is position of the next event in binlog file
+	LogPos uint32
+	Flags  uint16
+}
+
+// Artificial returns true if event is generated by master for stream and is absent in binlog file
+func (h Header) Artificial() bool {
+	return h.Flags&artificialFlag != 0 || h.LogPos == 0
+}
+
+// Event represents parsed binlog event
+type Event struct {
+	Header Header
+	// Body is one of *FormatDescriptionEvent, *RotateEvent, *QueryEvent, *XIDEvent,
+	// *IntvarEvent, *RandEvent, *UserVarEvent, *TableMapEvent, *RowsEvent, *GTIDEvent,
+	// *PreviousGTIDsEvent, *HeartbeatEvent, or nil for events which are not parsed
+	Body interface{}
+	// Raw is event as it is written in binlog file including checksum
+	Raw []byte
+}
+
+// FormatDescriptionEvent describes format of the following events
+type FormatDescriptionEvent struct {
+	BinlogVersion     uint16
+	ServerVersion     string
+	CreateTimestamp   uint32
+	HeaderLength      uint8
+	PostHeaderLengths []byte
+	ChecksumAlgorithm uint8
+}
+
+// RotateEvent points to the next binlog file
+type RotateEvent struct {
+	Position uint64
+	File     string
+}
+
+// QueryEvent represents statement written in binlog
+type QueryEvent struct {
+	ThreadID      uint32
+	ExecutionTime uint32
+	ErrorCode     uint16
+	Schema        string
+	Query         string
+	Status        QueryStatus
+}
+
+// QueryStatus represents session variables of statement written in QUERY event,
+// variables absent in event are nil
+type QueryStatus struct {
+	// Flags2 has bits of foreign_key_checks, unique_checks, autocommit and sql_auto_is_null
+	Flags2  *uint32
+	SQLMode *uint64
+	// AutoIncrement is auto_increment_increment and auto_increment_offset
+	AutoIncrement *[2]uint16
+	// Charset is ids of character_set_client, collation_connection and collation_server
+	Charset  *[3]uint16
+	TimeZone *string
+}
+
+// Flags of QueryStatus.Flags2
+const (
+	OptionAutoIsNull          = 0x00004000
+	OptionNotAutocommit       = 0x00080000
+	OptionNoForeignKeyChecks  = 0x04000000
+	OptionRelaxedUniqueChecks = 0x08000000
+)
+
+// IntvarEvent sets LAST_INSERT_ID() or INSERT_ID for the following statement
+type IntvarEvent struct {
+	Type  uint8
+	Value uint64
+}
+
+// Types of IntvarEvent
+const (
+	LastInsertID = 1
+	InsertID     = 2
+)
+
+// RandEvent sets seeds of RAND() for the following statement
+type RandEvent struct {
+	Seed1 uint64
+	Seed2 uint64
+}
+
+// UserVarEvent sets user variable used by the following statement
+type UserVarEvent struct {
+	Name string
+	Null bool
+	Type uint8
+	// Charset is id of collation of string value
+	Charset uint32
+	// Value is bytes of string, formatted number otherwise
+	Value string
+}
+
+// Types of UserVarEvent
+const (
+	StringResult  = 0
+	RealResult    = 1
+	IntResult     = 2
+	DecimalResult = 4
+)
+
+// XIDEvent commits transaction
+type XIDEvent struct {
+	XID uint64
+}
+
+// GTIDEvent starts transaction, UUID is empty for ANONYMOUS_GTID
+type GTIDEvent struct {
+	UUID           string
+	GNO            int64
+	LastCommitted  int64
+	SequenceNumber int64
+}
+
+// String returns GTID as uuid:gno
+func (e *GTIDEvent) String() string {
+	if e.UUID == "" {
+		return "ANONYMOUS"
+	}
+
+	return e.UUID + ":" + strconv.FormatInt(e.GNO, 10)
+}
+
+// PreviousGTIDsEvent contains GTIDs of all previous binlog files
+type PreviousGTIDsEvent struct {
+	Set gtid.Set
+}
+
+// HeartbeatEvent is sent by master when there are no events during heartbeat period,
+// it is HEARTBEAT or HEARTBEAT_V2 of 8.0.26+ which has Position
+type HeartbeatEvent struct {
+	File     string
+	Position uint64
+}
+
+// Parser parses events keeping format description and table maps of stream
+type Parser struct {
+	format *FormatDescriptionEvent
+	tables map[uint64]*TableMapEvent
+}
+
+// NewParser returns Parser
+func NewParser() *Parser {
+	return &Parser{tables: make(map[uint64]*TableMapEvent)}
+}
+
+// Format returns the last format description event
+func (p *Parser) Format() *FormatDescriptionEvent {
+	return p.format
+}
+
+// Parse parses raw event and verifies its checksum
+func (p *Parser) Parse(raw []byte) (*Event, error) {
+	if len(raw) < HeaderSize {
+		return nil, errors.Errorf("event of %d bytes is shorter than header", len(raw))
+	}
+
+	b := &buffer{data: raw}
+	e := &Event{Raw: raw}
+	e.Header = Header{
+		Timestamp: b.uint32(),
+		Type:      EventType(b.uint8()),
+		ServerID:  b.uint32(),
+		EventSize: b.uint32(),
+		LogPos:    b.uint32(),
+		Flags:     b.uint16(),
+	}
+
+	if int(e.Header.EventSize) != len(raw) {
+		return nil, errors.Errorf("size of %s event is %d, header says %d", e.Header.Type, len(raw), e.Header.EventSize)
+	}
+
+	data := raw[HeaderSize:]
+
+	if e.Header.Type == FormatDescriptionEventType {
+		format, err := parseFormatDescription(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		p.format = format
+		// table ids are valid within one binlog file
+		p.tables = make(map[uint64]*TableMapEvent)
+		e.Body = format
+
+		return e, nil
+	}
+
+	switch {
+	case p.format == nil:
+		// master sends artificial ROTATE before FORMAT_DESCRIPTION, it is checksummed
+		// if replica announced @master_binlog_checksum
+		if validChecksum(raw) {
+			data = data[:len(data)-checksumSize]
+		}
+	case p.format.ChecksumAlgorithm == checksumCRC32:
+		if !validChecksum(raw) {
+			return nil, errors.Errorf("checksum mismatch of %s event at %d", e.Header.Type, e.Header.LogPos)
+		}
+
+		data = data[:len(data)-checksumSize]
+	}
+
+	body, err := p.parseBody(e.Header.Type, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s event at %d", e.Header.Type, e.Header.LogPos)
+	}
+
+	e.Body = body
+
+	return e, nil
+}
+
+func (p *Parser) parseBody(t EventType, data []byte) (interface{}, error) {
+	b := &buffer{data: data}
+
+	switch t {
+	case RotateEventType:
+		pos := b.uint64()
+		return &RotateEvent{Position: pos, File: string(b.rest())}, b.err
+	case QueryEventType:
+		return parseQuery(b)
+	case IntvarEventType:
+		t := b.uint8()
+		return &IntvarEvent{Type: t, Value: b.uint64()}, b.err
+	case RandEventType:
+		seed1 := b.uint64()
+		return &RandEvent{Seed1: seed1, Seed2: b.uint64()}, b.err
+	case UserVarEventType:
+		return parseUserVar(b)
+	case XIDEventType:
+		return &XIDEvent{XID: b.uint64()}, b.err
+	case GTIDEventType, AnonymousGTIDEventType:
+		return parseGTID(b, t)
+	case PreviousGTIDsEventType:
+		set, err := DecodeGTIDSet(data)
+		return &PreviousGTIDsEvent{Set: set}, err
+	case HeartbeatEventType:
+		return &HeartbeatEvent{File: string(data)}, nil
+	case HeartbeatV2EventType:
+		return parseHeartbeatV2(b)
+	case TableMapEventType:
+		table, err := parseTableMap(b)
+		if err != nil {
+			return nil, err
+		}
+
+		p.tables[table.TableID] = table
+
+		return table, nil
+	case WriteRowsEventType, UpdateRowsEventType, DeleteRowsEventType:
+		return p.parseRows(b, t)
+	}
+
+	return nil, nil
+}
+
+// parseFormatDescription parses whole FORMAT_DESCRIPTION event, it has checksum
+// algorithm and checksum at the end since MySQL 5.6.1
+func parseFormatDescription(raw []byte) (*FormatDescriptionEvent, error) {
+	b := &buffer{data: raw[HeaderSize:]}
+	e := &FormatDescriptionEvent{
+		BinlogVersion:   b.uint16(),
+		ServerVersion:   strings.TrimRight(string(b.bytes(50)), "\x00"),
+		CreateTimestamp: b.uint32(),
+		HeaderLength:    b.uint8(),
+	}
+
+	if b.err != nil {
+		return nil, errors.Wrap(b.err, "invalid FORMAT_DESCRIPTION event")
+	}
+
+	lengths := b.rest()
+
+	if hasChecksumAlgorithm(e.ServerVersion) {
+		if len(lengths) < 1+checksumSize {
+			return nil, errors.New("invalid FORMAT_DESCRIPTION event")
+		}
+
+		e.ChecksumAlgorithm = lengths[len(lengths)-1-checksumSize]
+		lengths = lengths[:len(lengths)-1-checksumSize]
+
+		if e.ChecksumAlgorithm == checksumCRC32 && !validChecksum(raw) {
+			return nil, errors.New("checksum mismatch of FORMAT_DESCRIPTION event")
+		}
+	}
+
+	e.PostHeaderLengths = lengths
+
+	return e, nil
+}
+
+// hasChecksumAlgorithm returns true for versions since 5.6.1
+func hasChecksumAlgorithm(version string) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 3 {
+		return false
+	}
+
+	nums := make([]int, 3)
+
+	for i, part := range parts {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+
+		nums[i], _ = strconv.Atoi(part[:end])
+	}
+
+	return nums[0]*10000+nums[1]*100+nums[2] >= 50601
+}
+
+// validChecksum verifies CRC32 in the last 4 bytes of event
+func validChecksum(raw []byte) bool {
+	if len(raw) < HeaderSize+checksumSize {
+		return false
+	}
+
+	n := len(raw) - checksumSize
+
+	return crc32.ChecksumIEEE(raw[:n]) == binary.LittleEndian.Uint32(raw[n:])
+}
+
+func parseQuery(b *buffer) (*QueryEvent, error) {
+	e := &QueryEvent{
+		ThreadID:      b.uint32(),
+		ExecutionTime: b.uint32(),
+	}
+
+	schemaLen := int(b.uint8())
+	e.ErrorCode = b.uint16()
+	statusLen := int(b.uint16())
+
+	status := b.bytes(statusLen)
+	e.Schema = string(b.bytes(schemaLen))
+	b.skip(1)
+	e.Query = string(b.rest())
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	e.Status = parseQueryStatus(&buffer{data: status})
+
+	return e, nil
+}
+
+// Codes of QUERY event status variables
+const (
+	qFlags2        = 0
+	qSQLMode       = 1
+	qCatalog       = 2
+	qAutoIncrement = 3
+	qCharset       = 4
+	qTimeZone      = 5
+	qCatalogNZ     = 6
+	qLCTimeNames   = 7
+	qCharsetDB     = 8
+	qTableMap      = 9
+)
+
+// parseQueryStatus reads status variables up to the first one which is not used
+// to replay statement, sizes of variables after it are not known for all versions
+func parseQueryStatus(b *buffer) QueryStatus {
+	status := QueryStatus{}
+
+	for b.err == nil && b.left() > 0 {
+		switch b.uint8() {
+		case qFlags2:
+			v := b.uint32()
+			status.Flags2 = &v
+		case qSQLMode:
+			v := b.uint64()
+			status.SQLMode = &v
+		case qCatalog:
+			b.skip(int(b.uint8()) + 1)
+		case qAutoIncrement:
+			status.AutoIncrement = &[2]uint16{b.uint16(), b.uint16()}
+		case qCharset:
+			status.Charset = &[3]uint16{b.uint16(), b.uint16(), b.uint16()}
+		case qTimeZone:
+			v := string(b.bytes(int(b.uint8())))
+			status.TimeZone = &v
+		case qCatalogNZ:
+			b.skip(int(b.uint8()))
+		case qLCTimeNames, qCharsetDB:
+			b.skip(2)
+		case qTableMap:
+			b.skip(8)
+		default:
+			return status
+		}
+	}
+
+	return status
+}
+
+func parseUserVar(b *buffer) (*UserVarEvent, error) {
+	e := &UserVarEvent{Name: string(b.bytes(int(b.uint32())))}
+	e.Null = b.uint8() != 0
+
+	if e.Null {
+		return e, b.err
+	}
+
+	e.Type = b.uint8()
+	e.Charset = b.uint32()
+	value := b.bytes(int(b.uint32()))
+	unsigned := b.left() > 0 && b.uint8()&1 != 0
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	v := &buffer{data: value}
+
+	switch e.Type {
+	case StringResult:
+		e.Value = string(value)
+	case RealResult:
+		e.Value = strconv.FormatFloat(math.Float64frombits(v.uint64()), 'g', -1, 64)
+	case IntResult:
+		if unsigned {
+			e.Value = strconv.FormatUint(v.uint64(), 10)
+		} else {
+			e.Value = strconv.FormatInt(int64(v.uint64()), 10)
+		}
+	case DecimalResult:
+		precision, scale := int(v.uint8()), int(v.uint8())
+
+		var err error
+
+		e.Value, err = readDecimal(v, precision, scale)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unsupported type %d of user variable %s", e.Type, e.Name)
+	}
+
+	return e, v.err
+}
+
+func parseGTID(b *buffer, t EventType) (*GTIDEvent, error) {
+	b.skip(1) // commit flag
+
+	sid := b.bytes(16)
+	e := &GTIDEvent{GNO: int64(b.uint64())}
+
+	if t == GTIDEventType {
+		e.UUID = formatUUID(sid)
+	}
+
+	// logical timestamps of multi-threaded replication
+	if b.err == nil && b.left() >= 17 && b.uint8() == 2 {
+		e.LastCommitted = int64(b.uint64())
+		e.SequenceNumber = int64(b.uint64())
+	}
+
+	return e, b.err
+}
+
+// Fields of HEARTBEAT_V2 event
+const (
+	hbEndMark  = 0
+	hbFile     = 1
+	hbPosition = 2
+)
+
+// parseHeartbeatV2 reads fields of HEARTBEAT_V2 event, a field is type, length and value
+func parseHeartbeatV2(b *buffer) (*HeartbeatEvent, error) {
+	e := &HeartbeatEvent{}
+
+	for b.err == nil && b.left() > 0 {
+		field := b.uint8()
+		if field == hbEndMark {
+			break
+		}
+
+		v := &buffer{data: b.lenencBytes()}
+
+		switch field {
+		case hbFile:
+			e.File = string(v.rest())
+		case hbPosition:
+			e.Position = v.lenenc()
+		}
+
+		if v.err != nil {
+			return nil, v.err
+		}
+	}
+
+	return e, b.err
+}
+
+func formatUUID(sid []byte) string {
+	s := hex.EncodeToString(sid)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+func parseUUID(uuid string) ([]byte, error) {
+	sid, err := hex.DecodeString(strings.Replace(uuid, "-", "", -1))
+	if err != nil || len(sid) != 16 {
+		return nil, errors.Errorf("invalid uuid %s", uuid)
+	}
+
+	return sid, nil
+}
+
+// EncodeGTIDSet encodes GTID set as in COM_BINLOG_DUMP_GTID and PREVIOUS_GTIDS event
+func EncodeGTIDSet(set gtid.Set) []byte {
+	uuids := set.UUIDs()
+
+	data := appendUint64(nil, uint64(len(uuids)))
+
+	for _, uuid := range uuids {
+		sid, err := parseUUID(uuid)
+		if err != nil {
+			// uuids of parsed set are valid
+			panic(err)
+		}
+
+		data = append(data, sid...)
+		data = appendUint64(data, uint64(len(set[uuid])))
+
+		for _, interval := range set[uuid] {
+			// end of interval is exclusive
+			data = appendUint64(data, uint64(interval.Start))
+			data = appendUint64(data, uint64(interval.End+1))
+		}
+	}
+
+	return data
+}
+
+// DecodeGTIDSet decodes GTID set encoded by EncodeGTIDSet
+func DecodeGTIDSet(data []byte) (gtid.Set, error) {
+	b := &buffer{data: data}
+	set := make(gtid.Set)
+
+	for n := b.uint64(); n > 0 && b.err == nil; n-- {
+		uuid := formatUUID(b.bytes(16))
+
+		for m := b.uint64(); m > 0 && b.err == nil; m-- {
+			start, end := int64(b.uint64()), int64(b.uint64())
+			set.AddInterval(uuid, gtid.Interval{Start: start, End: end - 1})
+		}
+	}
+
+	return set, errors.Wrap(b.err, "invalid GTID set")
+}
@@ -0,0 +1,239 @@
+package binlog_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/internal/binlogtest"
+	"github.com/partyzanex/testutils"
+)
+
+var (
+	le    = binlogtest.LE
+	str   = binlogtest.Str
+	unhex = binlogtest.Unhex
+)
+
+func testBinlog() []byte {
+	w := &binlogtest.Writer{Pos: 4}
+	sid := binlogtest.SID()
+
+	events := [][]byte{
+		[]byte(binlog.Magic),
+		w.FormatDescription(1),
+		w.Event(binlog.PreviousGTIDsEventType, binlog.EncodeGTIDSet(gtid.MustParse(binlogtest.UUID+":1-22"))),
+		w.Event(binlog.GTIDEventType, []byte{1}, sid, le(23, 8), []byte{2}, le(5, 8), le(6, 8)),
+		w.Event(binlog.QueryEventType, le(10, 4), le(0, 4), []byte{2}, le(0, 2), le(0, 2), []byte("db\x00BEGIN")),
+		w.Event(binlog.TableMapEventType,
+			le(42, 6), le(1, 2), str("db"), []byte{0}, str("t"), []byte{0},
+			// id INT UNSIGNED, name VARCHAR(255), price DECIMAL(10,4), at DATETIME, doc JSON
+			[]byte{5, 3, 15, 246, 18, 245},
+			[]byte{6, 0xff, 0, 10, 4, 0, 4},
+			[]byte{0x1e},
+			[]byte{1, 1, 0x80},
+			[]byte{4, 21}, str("id"), str("name"), str("price"), str("at"), str("doc"),
+		),
+		w.Event(binlog.WriteRowsEventType,
+			le(42, 6), le(1, 2), le(2, 2), []byte{5, 0x1f},
+			[]byte{0}, le(0xffffffff, 4), str("abc"), unhex("8004d2162e"), unhex("99a5443105"),
+			le(13, 4), unhex("0001000c000b00010005010061"),
+		),
+		w.Event(binlog.UpdateRowsEventType,
+			le(42, 6), le(1, 2), le(2, 2), []byte{5, 0x1f, 0x1f},
+			[]byte{0}, le(0xffffffff, 4), str("abc"), unhex("8004d2162e"), unhex("99a5443105"),
+			le(13, 4), unhex("0001000c000b00010005010061"),
+			[]byte{0x18}, le(7, 4), str("x"), unhex("7ffb2de9d1"),
+		),
+		w.Event(binlog.DeleteRowsEventType,
+			le(42, 6), le(1, 2), le(2, 2), []byte{5, 0x1f},
+			[]byte{0x1c}, le(7, 4), str("x"),
+		),
+		w.Event(binlog.XIDEventType, le(99, 8)),
+		w.Event(binlog.RotateEventType, le(4, 8), []byte("binlog.000002")),
+	}
+
+	return bytes.Join(events, nil)
+}
+
+func readAll(t *testing.T, data []byte) []*binlog.Event {
+	r, err := binlog.NewFileReader(bytes.NewReader(data))
+	testutils.FatalErr(t, "NewFileReader", err)
+
+	events := make([]*binlog.Event, 0)
+
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return events
+		}
+
+		testutils.FatalErr(t, "Next", err)
+
+		events = append(events, e)
+	}
+}
+
+func TestFileReader(t *testing.T) {
+	events := readAll(t, testBinlog())
+	testutils.AssertEqual(t, "events", 10, len(events))
+
+	format := events[0].Body.(*binlog.FormatDescriptionEvent)
+	testutils.AssertEqual(t, "version", "8.0.30-log", format.ServerVersion)
+	testutils.AssertEqual(t, "checksum", uint8(1), format.ChecksumAlgorithm)
+	testutils.AssertEqual(t, "post headers", 40, len(format.PostHeaderLengths))
+
+	previous := events[1].Body.(*binlog.PreviousGTIDsEvent)
+	testutils.AssertEqual(t, "previous", binlogtest.UUID+":1-22", previous.Set.String())
+
+	g := events[2].Body.(*binlog.GTIDEvent)
+	testutils.AssertEqual(t, "gtid", binlogtest.UUID+":23", g.String())
+	testutils.AssertEqual(t, "last committed", int64(5), g.LastCommitted)
+	testutils.AssertEqual(t, "sequence number", int64(6), g.SequenceNumber)
+
+	query := events[3].Body.(*binlog.QueryEvent)
+	testutils.AssertEqual(t, "schema", "db", query.Schema)
+	testutils.AssertEqual(t, "query", "BEGIN", query.Query)
+
+	table := events[4].Body.(*binlog.TableMapEvent)
+	testutils.AssertEqual(t, "table", "db.t", table.Schema+"."+table.Table)
+	testutils.AssertEqual(t, "columns", "[id name price at doc]", fmt.Sprint(table.ColumnNames))
+	testutils.AssertEqual(t, "unsigned", "[true false false false false]", fmt.Sprint(table.Unsigned))
+	testutils.AssertEqual(t, "nullable", "[false true true true true]", fmt.Sprint(table.Nullable))
+
+	insert := events[5].Body.(*binlog.RowsEvent)
+	testutils.AssertEqual(t, "insert rows", 1, len(insert.Rows))
+	testutils.AssertEqual(t, "insert before", true, insert.Rows[0].Before == nil)
+	testutils.AssertEqual(t, "insert", "[4294967295 abc 1234.5678 2020-01-02 03:04:05 map[a:1]]",
+		fmt.Sprint(insert.Rows[0].After))
+
+	update := events[6].Body.(*binlog.RowsEvent)
+	testutils.AssertEqual(t, "update rows", 1, len(update.Rows))
+	testutils.AssertEqual(t, "update after", "[7 x -1234.5678 <nil> <nil>]", fmt.Sprint(update.Rows[0].After))
+
+	del := events[7].Body.(*binlog.RowsEvent)
+	testutils.AssertEqual(t, "delete", "[7 x <nil> <nil> <nil>]", fmt.Sprint(del.Rows[0].Before))
+	testutils.AssertEqual(t, "delete table", "t", del.Table.Table)
+
+	testutils.AssertEqual(t, "xid", uint64(99), events[8].Body.(*binlog.XIDEvent).XID)
+
+	rotate := events[9].Body.(*binlog.RotateEvent)
+	testutils.AssertEqual(t, "rotate", "binlog.000002:4", fmt.Sprintf("%s:%d", rotate.File, rotate.Position))
+	testutils.AssertEqual(t, "log pos", uint32(len(testBinlog())), events[9].Header.LogPos)
+}
+
+func TestFileReader_Checksum(t *testing.T) {
+	data := testBinlog()
+	// corrupt query of QUERY event
+	i := bytes.Index(data, []byte("BEGIN"))
+	data[i] = 'b'
+
+	r, err := binlog.NewFileReader(bytes.NewReader(data))
+	testutils.FatalErr(t, "NewFileReader", err)
+
+	for err == nil {
+		_, err = r.Next()
+	}
+
+	testutils.AssertEqual(t, "error", true, err != io.EOF)
+
+	_, err = binlog.NewFileReader(bytes.NewReader([]byte("not a binlog")))
+	testutils.AssertEqual(t, "magic", true, err != nil)
+}
+
+func TestFileReader_NoChecksum(t *testing.T) {
+	w := &binlogtest.Writer{Pos: 4}
+	format := w.FormatDescription(0)
+	xid := w.Event(binlog.XIDEventType, le(7, 8))
+
+	// without checksum the last 4 bytes are absent, size is fixed
+	xid = xid[:len(xid)-4]
+	binary.LittleEndian.PutUint32(xid[9:], uint32(len(xid)))
+
+	events := readAll(t, bytes.Join([][]byte{[]byte(binlog.Magic), format, xid}, nil))
+	testutils.AssertEqual(t, "events", 2, len(events))
+	testutils.AssertEqual(t, "xid", uint64(7), events[1].Body.(*binlog.XIDEvent).XID)
+}
+
+func TestGTIDSet(t *testing.T) {
+	set := gtid.MustParse(binlogtest.UUID + ":1-5:7,4a6f3bb5-71ca-11e1-9e33-c80aa9429562:3")
+
+	decoded, err := binlog.DecodeGTIDSet(binlog.EncodeGTIDSet(set))
+	testutils.FatalErr(t, "DecodeGTIDSet", err)
+	testutils.AssertEqual(t, "set", set.String(), decoded.String())
+
+	_, err = binlog.DecodeGTIDSet([]byte{1, 0})
+	testutils.AssertEqual(t, "invalid", true, err != nil)
+}
+
+func TestFileReader_StatementEvents(t *testing.T) {
+	w := &binlogtest.Writer{Pos: 4}
+
+	status := bytes.Join([][]byte{
+		{0}, le(binlog.OptionNoForeignKeyChecks, 4),
+		{1}, le(0x40000000, 8),
+		{3}, le(1, 2), le(1, 2),
+		{4}, le(255, 2), le(255, 2), le(8, 2),
+		{5}, str("+03:00"),
+		// the rest is not parsed
+		{12}, {1}, []byte("db\x00"),
+	}, nil)
+
+	data := bytes.Join([][]byte{
+		[]byte(binlog.Magic),
+		w.FormatDescription(1),
+		w.Event(binlog.IntvarEventType, []byte{binlog.InsertID}, le(42, 8)),
+		w.Event(binlog.RandEventType, le(1, 8), le(2, 8)),
+		w.Event(binlog.UserVarEventType, le(1, 4), []byte("a"), []byte{0}, []byte{binlog.IntResult}, le(63, 4),
+			le(8, 4), le(uint64(1<<64-5), 8), []byte{0}),
+		w.Event(binlog.UserVarEventType, le(1, 4), []byte("b"), []byte{0}, []byte{binlog.DecimalResult}, le(63, 4),
+			le(7, 4), []byte{10, 4}, unhex("8004d2162e"), []byte{0}),
+		w.Event(binlog.UserVarEventType, le(1, 4), []byte("c"), []byte{1}),
+		w.Event(binlog.QueryEventType, le(10, 4), le(0, 4), []byte{2}, le(0, 2), le(uint64(len(status)), 2),
+			status, []byte("db\x00INSERT INTO t VALUES (@a)")),
+	}, nil)
+
+	events := readAll(t, data)
+	testutils.AssertEqual(t, "events", 7, len(events))
+
+	intvar := events[1].Body.(*binlog.IntvarEvent)
+	testutils.AssertEqual(t, "intvar", "2 42", fmt.Sprint(intvar.Type, intvar.Value))
+
+	rand := events[2].Body.(*binlog.RandEvent)
+	testutils.AssertEqual(t, "rand", "1 2", fmt.Sprint(rand.Seed1, rand.Seed2))
+
+	testutils.AssertEqual(t, "int", "-5", events[3].Body.(*binlog.UserVarEvent).Value)
+	testutils.AssertEqual(t, "decimal", "1234.5678", events[4].Body.(*binlog.UserVarEvent).Value)
+	testutils.AssertEqual(t, "null", true, events[5].Body.(*binlog.UserVarEvent).Null)
+
+	query := events[6].Body.(*binlog.QueryEvent)
+	testutils.AssertEqual(t, "query", "INSERT INTO t VALUES (@a)", query.Query)
+	testutils.AssertEqual(t, "flags2", uint32(binlog.OptionNoForeignKeyChecks), *query.Status.Flags2)
+	testutils.AssertEqual(t, "sql_mode", uint64(0x40000000), *query.Status.SQLMode)
+	testutils.AssertEqual(t, "auto_increment", [2]uint16{1, 1}, *query.Status.AutoIncrement)
+	testutils.AssertEqual(t, "charset", [3]uint16{255, 255, 8}, *query.Status.Charset)
+	testutils.AssertEqual(t, "time_zone", "+03:00", *query.Status.TimeZone)
+}
+
+func TestParser_Heartbeat(t *testing.T) {
+	w := &binlogtest.Writer{}
+	p := binlog.NewParser()
+
+	_, err := p.Parse(w.FormatDescription(1))
+	testutils.FatalErr(t, "Parse", err)
+
+	e, err := p.Parse(w.Event(binlog.HeartbeatEventType, []byte("binlog.000003")))
+	testutils.FatalErr(t, "Parse", err)
+	testutils.AssertEqual(t, "v1", "binlog.000003", e.Body.(*binlog.HeartbeatEvent).File)
+
+	e, err = p.Parse(w.Event(binlog.HeartbeatV2EventType,
+		[]byte{1}, str("binlog.000003"), []byte{2, 4, 0xfd}, le(70000, 3), []byte{0}))
+	testutils.FatalErr(t, "Parse", err)
+
+	heartbeat := e.Body.(*binlog.HeartbeatEvent)
+	testutils.AssertEqual(t, "v2", "binlog.000003:70000", fmt.Sprintf("%s:%d", heartbeat.File, heartbeat.Position))
+}
@@ -0,0 +1,236 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// types of binary JSON values
+const (
+	jsonSmallObject = 0x00
+	jsonLargeObject = 0x01
+	jsonSmallArray  = 0x02
+	jsonLargeArray  = 0x03
+	jsonLiteral     = 0x04
+	jsonInt16       = 0x05
+	jsonUint16      = 0x06
+	jsonInt32       = 0x07
+	jsonUint32      = 0x08
+	jsonInt64       = 0x09
+	jsonUint64      = 0x0a
+	jsonDouble      = 0x0b
+	jsonString      = 0x0c
+	jsonOpaque      = 0x0f
+
+	jsonNull  = 0x00
+	jsonTrue  = 0x01
+	jsonFalse = 0x02
+)
+
+var errInvalidJSON = errors.New("invalid binary JSON")
+
+// decodeJSON decodes JSON column stored in MySQL binary format into
+// nil, bool, int64, uint64, float64, string, []interface{} or map[string]interface{},
+// opaque values (DECIMAL, temporal types) are returned as []byte
+func decodeJSON(data []byte) (interface{}, error) {
+	// empty value is stored for JSON null of partial updates
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	return decodeJSONValue(data[0], data[1:])
+}
+
+func decodeJSONValue(t byte, data []byte) (interface{}, error) {
+	switch t {
+	case jsonSmallObject, jsonLargeObject:
+		return decodeJSONComposite(data, t == jsonLargeObject, true)
+	case jsonSmallArray, jsonLargeArray:
+		return decodeJSONComposite(data, t == jsonLargeArray, false)
+	case jsonLiteral:
+		if len(data) < 1 {
+			return nil, errInvalidJSON
+		}
+
+		switch data[0] {
+		case jsonNull:
+			return nil, nil
+		case jsonTrue:
+			return true, nil
+		case jsonFalse:
+			return false, nil
+		}
+
+		return nil, errInvalidJSON
+	case jsonString:
+		n, size := jsonVarLen(data)
+		if size == 0 || size+n > len(data) {
+			return nil, errInvalidJSON
+		}
+
+		return string(data[size : size+n]), nil
+	case jsonOpaque:
+		if len(data) < 1 {
+			return nil, errInvalidJSON
+		}
+
+		n, size := jsonVarLen(data[1:])
+		if size == 0 || 1+size+n > len(data) {
+			return nil, errInvalidJSON
+		}
+
+		return append([]byte{}, data[1+size:1+size+n]...), nil
+	}
+
+	sizes := map[byte]int{
+		jsonInt16: 2, jsonUint16: 2, jsonInt32: 4, jsonUint32: 4, jsonInt64: 8, jsonUint64: 8, jsonDouble: 8,
+	}
+
+	size, ok := sizes[t]
+	if !ok {
+		return nil, errors.Errorf("unsupported binary JSON type 0x%02x", t)
+	}
+
+	if len(data) < size {
+		return nil, errInvalidJSON
+	}
+
+	switch t {
+	case jsonInt16:
+		return int64(int16(binary.LittleEndian.Uint16(data))), nil
+	case jsonUint16:
+		return uint64(binary.LittleEndian.Uint16(data)), nil
+	case jsonInt32:
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case jsonUint32:
+		return uint64(binary.LittleEndian.Uint32(data)), nil
+	case jsonInt64:
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case jsonUint64:
+		return binary.LittleEndian.Uint64(data), nil
+	}
+
+	return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+}
+
+// decodeJSONComposite decodes object or array, offsets are relative to start of data
+func decodeJSONComposite(data []byte, large, object bool) (interface{}, error) {
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+
+	readOffset := func(pos int) (int, bool) {
+		if pos+offsetSize > len(data) {
+			return 0, false
+		}
+
+		if large {
+			return int(binary.LittleEndian.Uint32(data[pos:])), true
+		}
+
+		return int(binary.LittleEndian.Uint16(data[pos:])), true
+	}
+
+	count, ok1 := readOffset(0)
+	size, ok2 := readOffset(offsetSize)
+
+	if !ok1 || !ok2 || size > len(data) {
+		return nil, errInvalidJSON
+	}
+
+	pos := 2 * offsetSize
+
+	keys := make([]string, 0, count)
+
+	if object {
+		// key entry is offset and uint16 length
+		for i := 0; i < count; i++ {
+			offset, ok := readOffset(pos)
+			if !ok || pos+offsetSize+2 > len(data) {
+				return nil, errInvalidJSON
+			}
+
+			length := int(binary.LittleEndian.Uint16(data[pos+offsetSize:]))
+			if offset+length > len(data) {
+				return nil, errInvalidJSON
+			}
+
+			keys = append(keys, string(data[offset:offset+length]))
+			pos += offsetSize + 2
+		}
+	}
+
+	values := make([]interface{}, 0, count)
+
+	// value entry is type and inlined value or offset
+	for i := 0; i < count; i++ {
+		if pos+1+offsetSize > len(data) {
+			return nil, errInvalidJSON
+		}
+
+		t := data[pos]
+
+		var (
+			value interface{}
+			err   error
+		)
+
+		if jsonInlined(t, large) {
+			value, err = decodeJSONValue(t, data[pos+1:pos+1+offsetSize])
+		} else {
+			offset, _ := readOffset(pos + 1)
+			if offset >= len(data) {
+				return nil, errInvalidJSON
+			}
+
+			value, err = decodeJSONValue(t, data[offset:])
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+		pos += 1 + offsetSize
+	}
+
+	if !object {
+		return values, nil
+	}
+
+	result := make(map[string]interface{}, count)
+	for i, key := range keys {
+		result[key] = values[i]
+	}
+
+	return result, nil
+}
+
+func jsonInlined(t byte, large bool) bool {
+	switch t {
+	case jsonLiteral, jsonInt16, jsonUint16:
+		return true
+	case jsonInt32, jsonUint32:
+		return large
+	}
+
+	return false
+}
+
+// jsonVarLen reads length stored by 7 bits per byte, it returns length and its size
+func jsonVarLen(data []byte) (int, int) {
+	n := 0
+
+	for i := 0; i < len(data) && i < 5; i++ {
+		n |= int(data[i]&0x7f) << (7 * uint(i))
+
+		if data[i]&0x80 == 0 {
+			return n, i + 1
+		}
+	}
+
+	return 0, 0
+}
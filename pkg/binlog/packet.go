@@ -0,0 +1,296 @@
+package binlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	maxPayload = 1<<24 - 1
+
+	packetOK  = 0x00
+	packetEOF = 0xfe
+	packetErr = 0xff
+)
+
+// ServerError represents ERR packet of server
+type ServerError struct {
+	Code    uint16
+	State   string
+	Message string
+}
+
+// Error returns code and message of server error
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("ERROR %d (%s): %s", e.Code, e.State, e.Message)
+}
+
+// packetConn reads and writes packets of client/server protocol
+type packetConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	seq  uint8
+}
+
+func newPacketConn(conn net.Conn) *packetConn {
+	return &packetConn{conn: conn, r: bufio.NewReaderSize(conn, 64<<10)}
+}
+
+// readPacket reads packet, payloads longer than 16MB are joined
+func (c *packetConn) readPacket() ([]byte, error) {
+	var payload []byte
+
+	for {
+		header := make([]byte, 4)
+
+		_, err := io.ReadFull(c.r, header)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read packet header")
+		}
+
+		length := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+		c.seq = header[3] + 1
+
+		data := make([]byte, length)
+
+		_, err = io.ReadFull(c.r, data)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read packet")
+		}
+
+		payload = append(payload, data...)
+
+		if length < maxPayload {
+			return payload, nil
+		}
+	}
+}
+
+// writePacket writes payload splitting it by 16MB packets
+func (c *packetConn) writePacket(payload []byte) error {
+	for {
+		length := len(payload)
+		if length > maxPayload {
+			length = maxPayload
+		}
+
+		packet := make([]byte, 4+length)
+		packet[0], packet[1], packet[2] = byte(length), byte(length>>8), byte(length>>16)
+		packet[3] = c.seq
+		copy(packet[4:], payload[:length])
+
+		c.seq++
+
+		_, err := c.conn.Write(packet)
+		if err != nil {
+			return errors.Wrap(err, "unable to write packet")
+		}
+
+		payload = payload[length:]
+
+		// payload of exactly 16MB is terminated by empty packet
+		if length < maxPayload {
+			return nil
+		}
+	}
+}
+
+// writeCommand starts new command with sequence 0
+func (c *packetConn) writeCommand(payload []byte) error {
+	c.seq = 0
+	return c.writePacket(payload)
+}
+
+// readOK reads OK packet, ERR packet is returned as *ServerError
+func (c *packetConn) readOK() error {
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+
+	switch data[0] {
+	case packetOK:
+		return nil
+	case packetErr:
+		return parseError(data)
+	}
+
+	return errors.Errorf("unexpected packet 0x%02x, OK expected", data[0])
+}
+
+func parseError(data []byte) error {
+	e := &ServerError{}
+
+	if len(data) >= 3 {
+		e.Code = binary.LittleEndian.Uint16(data[1:3])
+		data = data[3:]
+	}
+
+	// SQL state marker '#' and 5 bytes of state
+	if len(data) >= 6 && data[0] == '#' {
+		e.State = string(data[1:6])
+		data = data[6:]
+	}
+
+	e.Message = string(data)
+
+	return e
+}
+
+// buffer reads little-endian values of protocol
+type buffer struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (b *buffer) fail() {
+	if b.err == nil {
+		b.err = errors.New("unexpected end of data")
+	}
+}
+
+func (b *buffer) bytes(n int) []byte {
+	if n < 0 {
+		n = 0
+		b.fail()
+	}
+
+	if b.err != nil || b.pos+n > len(b.data) {
+		b.fail()
+		return make([]byte, n)
+	}
+
+	v := b.data[b.pos : b.pos+n]
+	b.pos += n
+
+	return v
+}
+
+func (b *buffer) skip(n int) {
+	b.bytes(n)
+}
+
+func (b *buffer) rest() []byte {
+	if b.err != nil {
+		return nil
+	}
+
+	v := b.data[b.pos:]
+	b.pos = len(b.data)
+
+	return v
+}
+
+func (b *buffer) left() int {
+	return len(b.data) - b.pos
+}
+
+func (b *buffer) uint8() uint8 {
+	return b.bytes(1)[0]
+}
+
+func (b *buffer) uint16() uint16 {
+	return binary.LittleEndian.Uint16(b.bytes(2))
+}
+
+func (b *buffer) uint24() uint32 {
+	v := b.bytes(3)
+	return uint32(v[0]) | uint32(v[1])<<8 | uint32(v[2])<<16
+}
+
+func (b *buffer) uint32() uint32 {
+	return binary.LittleEndian.Uint32(b.bytes(4))
+}
+
+func (b *buffer) uint48() uint64 {
+	v := b.bytes(6)
+	return uint64(binary.LittleEndian.Uint32(v)) | uint64(binary.LittleEndian.Uint16(v[4:]))<<32
+}
+
+func (b *buffer) uint64() uint64 {
+	return binary.LittleEndian.Uint64(b.bytes(8))
+}
+
+// uintN reads little-endian unsigned integer of n bytes
+func (b *buffer) uintN(n int) uint64 {
+	var v uint64
+
+	for i, c := range b.bytes(n) {
+		v |= uint64(c) << (8 * uint(i))
+	}
+
+	return v
+}
+
+// lenenc reads length-encoded integer
+func (b *buffer) lenenc() uint64 {
+	first := b.uint8()
+
+	switch first {
+	case 0xfc:
+		return uint64(b.uint16())
+	case 0xfd:
+		return uint64(b.uint24())
+	case 0xfe:
+		return b.uint64()
+	}
+
+	return uint64(first)
+}
+
+// lenencBytes reads length-encoded string
+func (b *buffer) lenencBytes() []byte {
+	return b.bytes(int(b.lenenc()))
+}
+
+// nulString reads NUL terminated string
+func (b *buffer) nulString() string {
+	if b.err != nil {
+		return ""
+	}
+
+	for i := b.pos; i < len(b.data); i++ {
+		if b.data[i] == 0 {
+			s := string(b.data[b.pos:i])
+			b.pos = i + 1
+
+			return s
+		}
+	}
+
+	s := string(b.data[b.pos:])
+	b.pos = len(b.data)
+
+	return s
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
+
+func appendLenenc(b []byte, v uint64) []byte {
+	switch {
+	case v < 0xfb:
+		return append(b, byte(v))
+	case v < 1<<16:
+		return appendUint16(append(b, 0xfc), uint16(v))
+	case v < 1<<24:
+		return append(b, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	}
+
+	return appendUint64(append(b, 0xfe), v)
+}
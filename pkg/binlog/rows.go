@@ -0,0 +1,580 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// column types
+const (
+	typeDecimal    = 0
+	typeTiny       = 1
+	typeShort      = 2
+	typeLong       = 3
+	typeFloat      = 4
+	typeDouble     = 5
+	typeNull       = 6
+	typeTimestamp  = 7
+	typeLongLong   = 8
+	typeInt24      = 9
+	typeDate       = 10
+	typeTime       = 11
+	typeDatetime   = 12
+	typeYear       = 13
+	typeNewDate    = 14
+	typeVarchar    = 15
+	typeBit        = 16
+	typeTimestamp2 = 17
+	typeDatetime2  = 18
+	typeTime2      = 19
+	typeJSON       = 245
+	typeNewDecimal = 246
+	typeEnum       = 247
+	typeSet        = 248
+	typeTinyBlob   = 249
+	typeMediumBlob = 250
+	typeLongBlob   = 251
+	typeBlob       = 252
+	typeVarString  = 253
+	typeString     = 254
+	typeGeometry   = 255
+)
+
+// optional metadata of TABLE_MAP event
+const (
+	metaSignedness = 1
+	metaColumnName = 4
+)
+
+// TableMapEvent describes table of the following rows events
+type TableMapEvent struct {
+	TableID     uint64
+	Schema      string
+	Table       string
+	ColumnTypes []byte
+	ColumnMeta  []uint16
+	Nullable    []bool
+	// Unsigned and ColumnNames are filled if binlog_row_metadata=FULL (MySQL 8.0.1+),
+	// Unsigned is also filled for MINIMAL
+	Unsigned    []bool
+	ColumnNames []string
+}
+
+// RowChange represents changed row, Before is nil for insert and After is nil for delete,
+// values are nil, int64, uint64, float32, float64, string (also DECIMAL and temporal types),
+// []byte (BLOB and GEOMETRY), uint64 (BIT, ENUM index, SET bitmap) and JSON values decoded
+// as by encoding/json
+type RowChange struct {
+	Before []interface{}
+	After  []interface{}
+}
+
+// RowsEvent represents WRITE_ROWS, UPDATE_ROWS or DELETE_ROWS event version 2
+type RowsEvent struct {
+	Type    EventType
+	TableID uint64
+	Table   *TableMapEvent
+	Flags   uint16
+	Rows    []RowChange
+}
+
+func parseTableMap(b *buffer) (*TableMapEvent, error) {
+	e := &TableMapEvent{TableID: b.uint48()}
+	b.skip(2) // flags
+
+	e.Schema = string(b.bytes(int(b.uint8())))
+	b.skip(1)
+	e.Table = string(b.bytes(int(b.uint8())))
+	b.skip(1)
+
+	count := int(b.lenenc())
+	e.ColumnTypes = append([]byte{}, b.bytes(count)...)
+
+	meta := &buffer{data: b.lenencBytes()}
+	e.ColumnMeta = make([]uint16, count)
+
+	for i, t := range e.ColumnTypes {
+		switch t {
+		case typeFloat, typeDouble, typeBlob, typeGeometry, typeJSON,
+			typeTimestamp2, typeDatetime2, typeTime2:
+			e.ColumnMeta[i] = uint16(meta.uint8())
+		case typeVarchar, typeVarString, typeBit:
+			e.ColumnMeta[i] = meta.uint16()
+		case typeNewDecimal, typeString, typeEnum, typeSet:
+			// big-endian: precision and scale, real type and length
+			hi := meta.uint8()
+			e.ColumnMeta[i] = uint16(hi)<<8 | uint16(meta.uint8())
+		}
+	}
+
+	nulls := b.bytes((count + 7) / 8)
+	e.Nullable = make([]bool, count)
+
+	for i := range e.Nullable {
+		e.Nullable[i] = bitSet(nulls, i)
+	}
+
+	if b.err != nil || meta.err != nil {
+		return nil, errors.New("invalid TABLE_MAP event")
+	}
+
+	for b.left() > 0 && b.err == nil {
+		t := b.uint8()
+		value := &buffer{data: b.lenencBytes()}
+
+		switch t {
+		case metaSignedness:
+			e.Unsigned = make([]bool, count)
+			n := 0
+
+			for i, ct := range e.ColumnTypes {
+				if numeric(ct) {
+					e.Unsigned[i] = bitSetBE(value.data, n)
+					n++
+				}
+			}
+		case metaColumnName:
+			for value.left() > 0 && value.err == nil {
+				e.ColumnNames = append(e.ColumnNames, string(value.lenencBytes()))
+			}
+		}
+	}
+
+	return e, b.err
+}
+
+func numeric(t byte) bool {
+	switch t {
+	case typeTiny, typeShort, typeInt24, typeLong, typeLongLong, typeNewDecimal, typeFloat, typeDouble:
+		return true
+	}
+
+	return false
+}
+
+func (p *Parser) parseRows(b *buffer, t EventType) (*RowsEvent, error) {
+	e := &RowsEvent{Type: t, TableID: b.uint48(), Flags: b.uint16()}
+
+	// extra data, its length includes length itself
+	extra := int(b.uint16())
+	b.skip(extra - 2)
+
+	table, ok := p.tables[e.TableID]
+	if !ok {
+		return nil, errors.Errorf("TABLE_MAP event for table id %d not found", e.TableID)
+	}
+
+	e.Table = table
+
+	count := int(b.lenenc())
+	if count != len(table.ColumnTypes) {
+		return nil, errors.Errorf("rows event has %d columns, table %s.%s has %d",
+			count, table.Schema, table.Table, len(table.ColumnTypes))
+	}
+
+	present := b.bytes((count + 7) / 8)
+	presentAfter := present
+
+	if t == UpdateRowsEventType {
+		presentAfter = b.bytes((count + 7) / 8)
+	}
+
+	for b.left() > 0 && b.err == nil {
+		row, err := readRow(b, table, present)
+		if err != nil {
+			return nil, err
+		}
+
+		change := RowChange{}
+
+		switch t {
+		case WriteRowsEventType:
+			change.After = row
+		case DeleteRowsEventType:
+			change.Before = row
+		case UpdateRowsEventType:
+			change.Before = row
+
+			change.After, err = readRow(b, table, presentAfter)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		e.Rows = append(e.Rows, change)
+	}
+
+	return e, b.err
+}
+
+// readRow reads row image, values of absent columns are nil
+func readRow(b *buffer, table *TableMapEvent, present []byte) ([]interface{}, error) {
+	count := len(table.ColumnTypes)
+	n := 0
+
+	for i := 0; i < count; i++ {
+		if bitSet(present, i) {
+			n++
+		}
+	}
+
+	nulls := b.bytes((n + 7) / 8)
+	row := make([]interface{}, count)
+	j := 0
+
+	for i := 0; i < count; i++ {
+		if !bitSet(present, i) {
+			continue
+		}
+
+		isNull := bitSet(nulls, j)
+		j++
+
+		if isNull {
+			continue
+		}
+
+		unsigned := table.Unsigned != nil && table.Unsigned[i]
+
+		value, err := readValue(b, table.ColumnTypes[i], table.ColumnMeta[i], unsigned)
+		if err != nil {
+			return nil, errors.Wrapf(err, "column %d of %s.%s", i, table.Schema, table.Table)
+		}
+
+		row[i] = value
+	}
+
+	return row, b.err
+}
+
+// readValue reads value of column of type t with metadata meta
+func readValue(b *buffer, t byte, meta uint16, unsigned bool) (interface{}, error) {
+	if t == typeString && meta >= 256 {
+		realType := byte(meta >> 8)
+
+		if realType&0x30 != 0x30 {
+			// length above 255 is stored in bits of real type
+			meta = uint16(realType&0x30^0x30)<<4 | meta&0xff
+			realType |= 0x30
+		} else {
+			meta &= 0xff
+		}
+
+		if realType == typeEnum || realType == typeSet {
+			t = realType
+		}
+	}
+
+	switch t {
+	case typeTiny:
+		v := b.uint8()
+		if unsigned {
+			return uint64(v), nil
+		}
+
+		return int64(int8(v)), nil
+	case typeShort:
+		v := b.uint16()
+		if unsigned {
+			return uint64(v), nil
+		}
+
+		return int64(int16(v)), nil
+	case typeInt24:
+		v := b.uint24()
+		if unsigned {
+			return uint64(v), nil
+		}
+
+		return int64(int32(v<<8) >> 8), nil
+	case typeLong:
+		v := b.uint32()
+		if unsigned {
+			return uint64(v), nil
+		}
+
+		return int64(int32(v)), nil
+	case typeLongLong:
+		v := b.uint64()
+		if unsigned {
+			return v, nil
+		}
+
+		return int64(v), nil
+	case typeFloat:
+		return math.Float32frombits(b.uint32()), nil
+	case typeDouble:
+		return math.Float64frombits(b.uint64()), nil
+	case typeNewDecimal:
+		return readDecimal(b, int(meta>>8), int(meta&0xff))
+	case typeYear:
+		v := b.uint8()
+		if v == 0 {
+			return int64(0), nil
+		}
+
+		return int64(v) + 1900, nil
+	case typeDate, typeNewDate:
+		v := b.uint24()
+		return fmt.Sprintf("%04d-%02d-%02d", v>>9, v>>5&15, v&31), nil
+	case typeTime:
+		v := int64(int32(b.uint24()<<8) >> 8)
+		sign := ""
+
+		if v < 0 {
+			sign, v = "-", -v
+		}
+
+		return fmt.Sprintf("%s%02d:%02d:%02d", sign, v/10000, v/100%100, v%100), nil
+	case typeDatetime:
+		v := b.uint64()
+		d, t := v/1000000, v%1000000
+
+		return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d",
+			d/10000, d/100%100, d%100, t/10000, t/100%100, t%100), nil
+	case typeTimestamp:
+		return formatTimestamp(int64(b.uint32()), 0, 0), nil
+	case typeTimestamp2:
+		sec := int64(binary.BigEndian.Uint32(b.bytes(4)))
+		return formatTimestamp(sec, readFraction(b, int(meta)), int(meta)), nil
+	case typeDatetime2:
+		return readDatetime2(b, int(meta)), nil
+	case typeTime2:
+		return readTime2(b, int(meta)), nil
+	case typeVarchar, typeVarString, typeString:
+		if meta < 256 {
+			return string(b.bytes(int(b.uint8()))), nil
+		}
+
+		return string(b.bytes(int(b.uint16()))), nil
+	case typeEnum, typeSet:
+		return b.uintN(int(meta & 0xff)), nil
+	case typeBit:
+		bits := int(meta>>8)*8 + int(meta&0xff)
+		return readBigEndian(b.bytes((bits + 7) / 8)), nil
+	case typeBlob, typeGeometry, typeTinyBlob, typeMediumBlob, typeLongBlob:
+		n := b.uintN(int(meta))
+		return append([]byte{}, b.bytes(int(n))...), nil
+	case typeJSON:
+		n := b.uintN(int(meta))
+		data := b.bytes(int(n))
+
+		if b.err != nil {
+			return nil, b.err
+		}
+
+		return decodeJSON(data)
+	}
+
+	return nil, errors.Errorf("unsupported column type %d", t)
+}
+
+func readBigEndian(data []byte) uint64 {
+	var v uint64
+
+	for _, c := range data {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
+
+// readFraction reads fractional seconds of fsp digits and returns microseconds
+func readFraction(b *buffer, fsp int) int {
+	n := (fsp + 1) / 2
+	if n == 0 {
+		return 0
+	}
+
+	v := int(readBigEndian(b.bytes(n)))
+
+	switch n {
+	case 1:
+		return v * 10000
+	case 2:
+		return v * 100
+	}
+
+	return v
+}
+
+func formatFraction(usec, fsp int) string {
+	if fsp == 0 {
+		return ""
+	}
+
+	return "." + fmt.Sprintf("%06d", usec)[:fsp]
+}
+
+// formatTimestamp formats TIMESTAMP in UTC
+func formatTimestamp(sec int64, usec, fsp int) string {
+	if sec == 0 {
+		return "0000-00-00 00:00:00" + formatFraction(0, fsp)
+	}
+
+	return time.Unix(sec, 0).UTC().Format("2006-01-02 15:04:05") + formatFraction(usec, fsp)
+}
+
+func readDatetime2(b *buffer, fsp int) string {
+	v := int64(readBigEndian(b.bytes(5))) - 0x8000000000
+	usec := readFraction(b, fsp)
+
+	if v < 0 {
+		v = -v
+	}
+
+	ymd, hms := v>>17, v&(1<<17-1)
+	ym := ymd >> 5
+
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d",
+		ym/13, ym%13, ymd&31, hms>>12, hms>>6&63, hms&63) + formatFraction(usec, fsp)
+}
+
+func readTime2(b *buffer, fsp int) string {
+	const (
+		intOffset  = 0x800000
+		timeOffset = 0x800000000000
+	)
+
+	var packed int64
+
+	switch fsp {
+	case 1, 2:
+		intPart := int64(readBigEndian(b.bytes(3))) - intOffset
+		frac := int64(b.uint8())
+
+		if intPart < 0 && frac > 0 {
+			intPart++
+			frac -= 0x100
+		}
+
+		packed = intPart<<24 + frac*10000
+	case 3, 4:
+		intPart := int64(readBigEndian(b.bytes(3))) - intOffset
+		frac := int64(readBigEndian(b.bytes(2)))
+
+		if intPart < 0 && frac > 0 {
+			intPart++
+			frac -= 0x10000
+		}
+
+		packed = intPart<<24 + frac*100
+	case 5, 6:
+		packed = int64(readBigEndian(b.bytes(6))) - timeOffset
+	default:
+		packed = (int64(readBigEndian(b.bytes(3))) - intOffset) << 24
+	}
+
+	sign := ""
+	if packed < 0 {
+		sign, packed = "-", -packed
+	}
+
+	hms, usec := packed>>24, packed%(1<<24)
+
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, hms>>12%(1<<10), hms>>6%64, hms%64) +
+		formatFraction(int(usec), fsp)
+}
+
+// readDecimal reads DECIMAL(precision, scale) stored in binary format,
+// every 9 digits are stored in 4 bytes, the rest in as few bytes as possible
+func readDecimal(b *buffer, precision, scale int) (string, error) {
+	integral := precision - scale
+	size := decimalSize(integral) + decimalSize(scale)
+
+	data := append([]byte{}, b.bytes(size)...)
+	if b.err != nil || size == 0 {
+		return "", b.err
+	}
+
+	// sign is stored in the first bit, negative values are inverted
+	negative := data[0]&0x80 == 0
+	data[0] ^= 0x80
+
+	if negative {
+		for i := range data {
+			data[i] ^= 0xff
+		}
+	}
+
+	d := &buffer{data: data}
+	s := strings.Builder{}
+
+	if negative {
+		s.WriteByte('-')
+	}
+
+	// leftover digits of integral part are stored first
+	intPart := strings.Builder{}
+
+	if rest := integral % digitsPerWord; rest > 0 {
+		intPart.WriteString(fmt.Sprintf("%0*d", rest, readBigEndian(d.bytes(compressedSize[rest]))))
+	}
+
+	for i := 0; i < integral/digitsPerWord; i++ {
+		intPart.WriteString(fmt.Sprintf("%09d", readBigEndian(d.bytes(4))))
+	}
+
+	trimmed := strings.TrimLeft(intPart.String(), "0")
+	if trimmed == "" {
+		trimmed = "0"
+	}
+
+	s.WriteString(trimmed)
+
+	if scale == 0 {
+		return s.String(), nil
+	}
+
+	s.WriteByte('.')
+
+	// leftover digits of fractional part are stored last
+	for i := 0; i < scale/digitsPerWord; i++ {
+		s.WriteString(fmt.Sprintf("%09d", readBigEndian(d.bytes(4))))
+	}
+
+	if rest := scale % digitsPerWord; rest > 0 {
+		s.WriteString(fmt.Sprintf("%0*d", rest, readBigEndian(d.bytes(compressedSize[rest]))))
+	}
+
+	return s.String(), nil
+}
+
+const digitsPerWord = 9
+
+// compressedSize is number of bytes to store leftover digits of decimal
+var compressedSize = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+func decimalSize(digits int) int {
+	return digits/digitsPerWord*4 + compressedSize[digits%digitsPerWord]
+}
+
+// bitSet returns bit i of little-endian bitmap
+func bitSet(bitmap []byte, i int) bool {
+	return i/8 < len(bitmap) && bitmap[i/8]&(1<<uint(i%8)) != 0
+}
+
+// bitSetBE returns bit i of bitmap where the first bit is the most significant
+func bitSetBE(bitmap []byte, i int) bool {
+	return i/8 < len(bitmap) && bitmap[i/8]&(0x80>>uint(i%8)) != 0
+}
+
+// String returns value formatted for printing
+func String(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return "0x" + strings.ToUpper(fmt.Sprintf("%x", v))
+	case string:
+		return strconv.Quote(v)
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,123 @@
+package binlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Position represents position in binlog of master
+type Position struct {
+	File string
+	Pos  uint32
+}
+
+// Streamer reads events sent by master after dump command
+type Streamer struct {
+	conn     *Conn
+	parser   *Parser
+	position Position
+}
+
+func newStreamer(conn *Conn, file string) *Streamer {
+	return &Streamer{conn: conn, parser: NewParser(), position: Position{File: file}}
+}
+
+// Next returns the next event, io.EOF is returned when master has sent all events
+// of non-blocking dump
+func (s *Streamer) Next() (*Event, error) {
+	data, err := s.conn.pc.readPacket()
+	if err != nil {
+		return nil, err
+	}
+
+	switch data[0] {
+	case packetOK:
+	case packetEOF:
+		return nil, io.EOF
+	case packetErr:
+		return nil, parseError(data)
+	default:
+		return nil, errors.Errorf("unexpected packet 0x%02x in binlog stream", data[0])
+	}
+
+	e, err := s.parser.Parse(data[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	s.track(e)
+
+	return e, nil
+}
+
+// Position returns position of master after the last event
+func (s *Streamer) Position() Position {
+	return s.position
+}
+
+// track follows rotations and positions of events
+func (s *Streamer) track(e *Event) {
+	if rotate, ok := e.Body.(*RotateEvent); ok {
+		s.position = Position{File: rotate.File, Pos: uint32(rotate.Position)}
+		return
+	}
+
+	if e.Header.LogPos > 0 {
+		s.position.Pos = e.Header.LogPos
+	}
+}
+
+// FileReader reads events of binlog file
+type FileReader struct {
+	r      *bufio.Reader
+	parser *Parser
+}
+
+// NewFileReader checks magic of binlog file and returns FileReader
+func NewFileReader(r io.Reader) (*FileReader, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+	magic := make([]byte, len(Magic))
+
+	_, err := io.ReadFull(br, magic)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read magic of binlog file")
+	}
+
+	if string(magic) != Magic {
+		return nil, errors.New("not a binlog file")
+	}
+
+	return &FileReader{r: br, parser: NewParser()}, nil
+}
+
+// Next returns the next event, io.EOF is returned at the end of file
+func (r *FileReader) Next() (*Event, error) {
+	header := make([]byte, HeaderSize)
+
+	_, err := io.ReadFull(r.r, header)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read event header")
+	}
+
+	size := int(binary.LittleEndian.Uint32(header[9:13]))
+	if size < HeaderSize {
+		return nil, errors.Errorf("invalid event size %d", size)
+	}
+
+	raw := make([]byte, size)
+	copy(raw, header)
+
+	_, err = io.ReadFull(r.r, raw[HeaderSize:])
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read event")
+	}
+
+	return r.parser.Parse(raw)
+}
@@ -0,0 +1,230 @@
+// Package cdc captures row changes from binlog of master
+package cdc
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const DefaultCheckpointInterval = time.Second
+
+// Capturer streams binlog of master and writes row changes to sink.
+// Changes are delivered at least once: after restart changes of transaction
+// which was not checkpointed are written again.
+type Capturer struct {
+	Config binlog.Config
+	// DB is connection to master, column names are read from its information_schema
+	DB   *sql.DB
+	Sink Sink
+	// CheckpointFile is updated after transactions, capture is resumed from it
+	CheckpointFile     string
+	CheckpointInterval time.Duration
+	// Start is used if there is no checkpoint, the current position of master is used if it is empty
+	Start Checkpoint
+
+	columns    *Columns
+	checkpoint Checkpoint
+	executed   gtid.Set
+	saved      time.Time
+	gtid       string
+}
+
+// Run captures changes until ctx is canceled
+func (c *Capturer) Run(ctx context.Context) error {
+	if c.CheckpointInterval <= 0 {
+		c.CheckpointInterval = DefaultCheckpointInterval
+	}
+
+	c.columns = NewColumns(c.DB)
+
+	err := c.start(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn, err := binlog.Dial(ctx, c.Config)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	err = conn.RegisterSlave("", 0)
+	if err != nil {
+		return err
+	}
+
+	var streamer *binlog.Streamer
+
+	if c.executed != nil {
+		logrus.Infof("capture after GTID set %s", c.executed)
+		streamer, err = conn.DumpGTID(c.executed)
+	} else {
+		logrus.Infof("capture from %s:%d", c.checkpoint.File, c.checkpoint.Position)
+		streamer, err = conn.Dump(c.checkpoint.File, c.checkpoint.Position)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// reading from connection is interrupted by closing it
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+
+	for {
+		e, err := streamer.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return c.save()
+			}
+
+			return err
+		}
+
+		err = c.handle(ctx, e, streamer.Position())
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// start sets position of the first transaction
+func (c *Capturer) start(ctx context.Context) error {
+	c.checkpoint = c.Start
+
+	if c.CheckpointFile != "" {
+		saved, err := LoadCheckpoint(c.CheckpointFile)
+		if err != nil {
+			return err
+		}
+
+		if saved != nil {
+			c.checkpoint = *saved
+		}
+	}
+
+	if c.checkpoint.File == "" && c.checkpoint.ExecutedGTIDSet == "" {
+		status, err := master.New(c.DB).ShowStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		c.checkpoint = Checkpoint{
+			File:            status.File,
+			Position:        uint32(status.Position),
+			ExecutedGTIDSet: status.ExecutedGTIDSet,
+		}
+	}
+
+	if c.checkpoint.ExecutedGTIDSet != "" {
+		set, err := gtid.Parse(c.checkpoint.ExecutedGTIDSet)
+		if err != nil {
+			return errors.Wrap(err, "invalid GTID set of start position")
+		}
+
+		c.executed = set
+	}
+
+	return nil
+}
+
+// handle writes changes of rows events and checkpoints at the end of transactions
+func (c *Capturer) handle(ctx context.Context, e *binlog.Event, pos binlog.Position) error {
+	switch body := e.Body.(type) {
+	case *binlog.GTIDEvent:
+		c.gtid = ""
+		if body.UUID != "" {
+			c.gtid = body.String()
+		}
+	case *binlog.RowsEvent:
+		columns := body.Table.ColumnNames
+		if len(columns) == 0 {
+			var err error
+
+			columns, err = c.columns.Get(ctx, body.Table.Schema, body.Table.Table)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, change := range Changes(body, columns) {
+			change.GTID = c.gtid
+			change.File, change.Position = pos.File, pos.Pos
+			change.Timestamp = time.Unix(int64(e.Header.Timestamp), 0).UTC()
+
+			err := c.Sink.Write(change)
+			if err != nil {
+				return err
+			}
+		}
+	case *binlog.QueryEvent:
+		ddl := IsDDL(body.Query)
+		if ddl {
+			c.columns.Invalidate()
+		}
+
+		// DDL is committed implicitly
+		if ddl || strings.EqualFold(body.Query, "COMMIT") {
+			return c.commit(pos)
+		}
+	case *binlog.XIDEvent:
+		return c.commit(pos)
+	}
+
+	return nil
+}
+
+// commit flushes sink and saves checkpoint not more often than CheckpointInterval
+func (c *Capturer) commit(pos binlog.Position) error {
+	err := c.Sink.Flush()
+	if err != nil {
+		return err
+	}
+
+	c.checkpoint.File, c.checkpoint.Position = pos.File, pos.Pos
+
+	if c.executed != nil && c.gtid != "" {
+		set, err := gtid.Parse(c.gtid)
+		if err != nil {
+			return errors.Wrapf(err, "invalid GTID %s", c.gtid)
+		}
+
+		c.executed = c.executed.Union(set)
+		c.checkpoint.ExecutedGTIDSet = c.executed.String()
+	}
+
+	c.gtid = ""
+
+	if time.Since(c.saved) < c.CheckpointInterval {
+		return nil
+	}
+
+	return c.save()
+}
+
+func (c *Capturer) save() error {
+	if c.CheckpointFile == "" || c.checkpoint.File == "" {
+		return nil
+	}
+
+	c.saved = time.Now()
+
+	return c.checkpoint.Save(c.CheckpointFile)
+}
@@ -0,0 +1,121 @@
+package cdc_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/cdc"
+	"github.com/partyzanex/testutils"
+)
+
+func TestIsDDL(t *testing.T) {
+	data := map[string]bool{
+		"ALTER TABLE t ADD COLUMN c INT":         true,
+		"/* app */ create table t (id int)":      true,
+		"DROP\tTABLE t":                          true,
+		"TRUNCATE TABLE t":                       true,
+		"BEGIN":                                  false,
+		"COMMIT":                                 false,
+		"INSERT INTO drop_log VALUES (1)":        false,
+		"CREATED":                                false,
+		"/* unterminated comment ALTER TABLE t ": false,
+	}
+
+	for query, exp := range data {
+		testutils.AssertEqual(t, query, exp, cdc.IsDDL(query))
+	}
+}
+
+func TestChanges(t *testing.T) {
+	table := &binlog.TableMapEvent{Schema: "db", Table: "t", ColumnTypes: []byte{3, 15, 15}}
+	rows := &binlog.RowsEvent{
+		Type:  binlog.UpdateRowsEventType,
+		Table: table,
+		Rows: []binlog.RowChange{
+			{Before: []interface{}{int64(1), "a", nil}, After: []interface{}{int64(1), "b", "x"}},
+		},
+	}
+
+	changes := cdc.Changes(rows, []string{"id", "name"})
+	testutils.AssertEqual(t, "changes", 1, len(changes))
+
+	c := changes[0]
+	testutils.AssertEqual(t, "op", cdc.Update, c.Op)
+	testutils.AssertEqual(t, "table", "db.t", c.Database+"."+c.Table)
+	testutils.AssertEqual(t, "before", "a", c.Before["name"])
+	testutils.AssertEqual(t, "after", "x", c.After["@3"])
+
+	c.File, c.Position, c.Timestamp = "binlog.000001", 120, time.Unix(1600000000, 0).UTC()
+
+	buf := &bytes.Buffer{}
+	sink := cdc.NewJSONLines(buf)
+	testutils.FatalErr(t, "Write", sink.Write(c))
+	testutils.FatalErr(t, "Flush", sink.Flush())
+
+	testutils.AssertEqual(t, "json", `{"op":"update","database":"db","table":"t",`+
+		`"before":{"@3":null,"id":1,"name":"a"},"after":{"@3":"x","id":1,"name":"b"},`+
+		`"file":"binlog.000001","position":120,"timestamp":"2020-09-13T12:26:40Z"}`,
+		strings.TrimSpace(buf.String()))
+
+	rows.Type = binlog.WriteRowsEventType
+	rows.Rows = []binlog.RowChange{{After: []interface{}{int64(2)}}}
+
+	c = cdc.Changes(rows, nil)[0]
+	testutils.AssertEqual(t, "insert", cdc.Insert, c.Op)
+	testutils.AssertEqual(t, "insert before", true, c.Before == nil)
+}
+
+func TestColumns(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	columns := cdc.NewColumns(db)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`SELECT COLUMN_NAME FROM information_schema.COLUMNS`).WithArgs("db", "t").
+			WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id").AddRow("name"))
+	}
+
+	names, err := columns.Get(ctx, "db", "t")
+	testutils.FatalErr(t, "Get", err)
+	testutils.AssertEqual(t, "names", "id,name", strings.Join(names, ","))
+
+	// cached
+	_, err = columns.Get(ctx, "db", "t")
+	testutils.FatalErr(t, "Get", err)
+
+	columns.Invalidate()
+
+	_, err = columns.Get(ctx, "db", "t")
+	testutils.FatalErr(t, "Get", err)
+	testutils.FatalErr(t, "ExpectationsWereMet", mock.ExpectationsWereMet())
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cdc")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "checkpoint.json")
+
+	saved, err := cdc.LoadCheckpoint(name)
+	testutils.FatalErr(t, "LoadCheckpoint", err)
+	testutils.AssertEqual(t, "missing", true, saved == nil)
+
+	checkpoint := cdc.Checkpoint{File: "binlog.000002", Position: 1234, ExecutedGTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}
+	testutils.FatalErr(t, "Save", checkpoint.Save(name))
+
+	saved, err = cdc.LoadCheckpoint(name)
+	testutils.FatalErr(t, "LoadCheckpoint", err)
+	testutils.AssertEqual(t, "checkpoint", checkpoint, *saved)
+}
@@ -0,0 +1,78 @@
+package cdc
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/binlog"
+)
+
+// Op represents kind of row change
+type Op string
+
+const (
+	Insert Op = "insert"
+	Update Op = "update"
+	Delete Op = "delete"
+)
+
+// Change represents one changed row, it is written as one JSON object
+type Change struct {
+	Op       Op                     `json:"op"`
+	Database string                 `json:"database"`
+	Table    string                 `json:"table"`
+	Before   map[string]interface{} `json:"before,omitempty"`
+	After    map[string]interface{} `json:"after,omitempty"`
+	GTID     string                 `json:"gtid,omitempty"`
+	// File and Position is binlog position of master after rows event
+	File      string    `json:"file"`
+	Position  uint32    `json:"position"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Changes returns changes of rows event, columns are names of table columns in order of definition,
+// columns which are unknown are named @1, @2, ... as by mysqlbinlog
+func Changes(rows *binlog.RowsEvent, columns []string) []Change {
+	op := Insert
+
+	switch rows.Type {
+	case binlog.UpdateRowsEventType:
+		op = Update
+	case binlog.DeleteRowsEventType:
+		op = Delete
+	}
+
+	changes := make([]Change, 0, len(rows.Rows))
+
+	for _, row := range rows.Rows {
+		changes = append(changes, Change{
+			Op:       op,
+			Database: rows.Table.Schema,
+			Table:    rows.Table.Table,
+			Before:   image(columns, row.Before),
+			After:    image(columns, row.After),
+		})
+	}
+
+	return changes
+}
+
+// image maps values of row to column names
+func image(columns []string, values []interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(values))
+
+	for i, value := range values {
+		name := "@" + strconv.Itoa(i+1)
+		if i < len(columns) {
+			name = columns[i]
+		}
+
+		result[name] = value
+	}
+
+	return result
+}
@@ -0,0 +1,56 @@
+package cdc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Checkpoint represents position of the last captured transaction
+type Checkpoint struct {
+	File     string `json:"file"`
+	Position uint32 `json:"position"`
+	// ExecutedGTIDSet is used to resume instead of position if it is not empty
+	ExecutedGTIDSet string `json:"executed_gtid_set,omitempty"`
+}
+
+// LoadCheckpoint reads checkpoint from file, nil is returned if file does not exist
+func LoadCheckpoint(name string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read checkpoint")
+	}
+
+	c := &Checkpoint{}
+
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid checkpoint in %s", name)
+	}
+
+	return c, nil
+}
+
+// Save writes checkpoint to temporary file and renames it,
+// so checkpoint is never left half-written
+func (c Checkpoint) Save(name string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode checkpoint")
+	}
+
+	tmp := name + ".tmp"
+
+	err = ioutil.WriteFile(tmp, append(data, '\n'), 0644)
+	if err != nil {
+		return errors.Wrap(err, "unable to write checkpoint")
+	}
+
+	return errors.Wrap(os.Rename(tmp, name), "unable to write checkpoint")
+}
@@ -0,0 +1,84 @@
+package cdc
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// Columns caches column names of tables read from information_schema of master,
+// cache must be invalidated when schema is changed
+type Columns struct {
+	db    *sqlx.DB
+	mu    sync.Mutex
+	cache map[string][]string
+}
+
+// NewColumns returns Columns of server
+func NewColumns(db *sql.DB) *Columns {
+	return &Columns{
+		db:    sqlx.NewDb(db, "mysql"),
+		cache: make(map[string][]string),
+	}
+}
+
+// Get returns column names of table in order of definition
+func (c *Columns) Get(ctx context.Context, database, table string) ([]string, error) {
+	key := database + "." + table
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if columns, ok := c.cache[key]; ok {
+		return columns, nil
+	}
+
+	columns := make([]string, 0)
+
+	err := c.db.SelectContext(ctx, &columns, `SELECT COLUMN_NAME FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`, database, table)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get columns of %s", key)
+	}
+
+	c.cache[key] = columns
+
+	return columns, nil
+}
+
+// Invalidate clears cache
+func (c *Columns) Invalidate() {
+	c.mu.Lock()
+	c.cache = make(map[string][]string)
+	c.mu.Unlock()
+}
+
+var ddlKeywords = []string{"ALTER", "CREATE", "DROP", "RENAME", "TRUNCATE"}
+
+// IsDDL returns true if query changes schema
+func IsDDL(query string) bool {
+	query = strings.TrimSpace(query)
+
+	// skip leading comments like /* ApplicationName=... */
+	for strings.HasPrefix(query, "/*") {
+		end := strings.Index(query, "*/")
+		if end < 0 {
+			return false
+		}
+
+		query = strings.TrimSpace(query[end+2:])
+	}
+
+	for _, keyword := range ddlKeywords {
+		if len(query) > len(keyword) && strings.EqualFold(query[:len(keyword)], keyword) &&
+			strings.ContainsAny(query[len(keyword):len(keyword)+1], " \t\r\n") {
+			return true
+		}
+	}
+
+	return false
+}
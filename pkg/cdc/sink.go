@@ -0,0 +1,74 @@
+package cdc
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Sink receives row changes
+type Sink interface {
+	Write(change Change) error
+	// Flush is called at the end of every transaction before checkpoint is saved,
+	// changes written before Flush must be durable when it returns
+	Flush() error
+}
+
+// JSONLines writes changes as one JSON object per line
+type JSONLines struct {
+	w   io.Writer
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewJSONLines returns JSONLines writing to w, w is synced on Flush if it is *os.File
+func NewJSONLines(w io.Writer) *JSONLines {
+	buf := bufio.NewWriter(w)
+	return &JSONLines{w: w, buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// OpenFile returns JSONLines appending to file
+func OpenFile(name string) (*JSONLines, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to open %s", name)
+	}
+
+	return NewJSONLines(f), nil
+}
+
+// Write encodes change
+func (s *JSONLines) Write(change Change) error {
+	return errors.Wrap(s.enc.Encode(change), "unable to write change")
+}
+
+// Flush writes buffered changes
+func (s *JSONLines) Flush() error {
+	err := s.buf.Flush()
+	if err != nil {
+		return errors.Wrap(err, "unable to write changes")
+	}
+
+	if f, ok := s.w.(*os.File); ok && f != os.Stdout {
+		return errors.Wrap(f.Sync(), "unable to sync changes")
+	}
+
+	return nil
+}
+
+// Close flushes changes and closes underlying writer if it is io.Closer
+func (s *JSONLines) Close() error {
+	err := s.Flush()
+	if err != nil {
+		return err
+	}
+
+	if c, ok := s.w.(io.Closer); ok && s.w != os.Stdout {
+		return c.Close()
+	}
+
+	return nil
+}
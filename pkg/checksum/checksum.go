@@ -0,0 +1,220 @@
+// Package checksum detects data drift between master and replicas
+// by checksums of table chunks computed on master and replicated by statement
+package checksum
+
+import (
+	"context"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/chunk"
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultDatabase    = "repmy"
+	DefaultTable       = "checksums"
+	DefaultWaitTimeout = 10 * time.Minute
+)
+
+// Checker computes checksums on master and compares them on replicas,
+// tables of default database of master DSN are checked
+type Checker struct {
+	Master   *node.Node
+	Replicas []*node.Node
+	// Tables limits checked tables, all base tables are checked if empty
+	Tables []string
+	// ChecksumsDatabase and ChecksumsTable is location of checksums,
+	// DefaultDatabase and DefaultTable are used if empty
+	ChecksumsDatabase string
+	ChecksumsTable    string
+	ChunkSize         int
+	// WaitTimeout limits waiting for replicas to apply checksums
+	WaitTimeout time.Duration
+}
+
+// Drift represents drifted chunk of replica
+type Drift struct {
+	Replica *node.Node
+	Result  Result
+}
+
+// Checksums returns qualified name of checksums table
+func (c *Checker) Checksums() string {
+	if c.ChecksumsDatabase == "" {
+		c.ChecksumsDatabase = DefaultDatabase
+	}
+
+	if c.ChecksumsTable == "" {
+		c.ChecksumsTable = DefaultTable
+	}
+
+	return quote.Qualified(c.ChecksumsDatabase, c.ChecksumsTable)
+}
+
+// Run computes checksums and returns drifted chunks of replicas
+func (c *Checker) Run(ctx context.Context) ([]Drift, error) {
+	database, err := c.Checksum(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Compare(ctx, database)
+}
+
+// Checksum computes checksums of tables on master and returns name of checked database
+func (c *Checker) Checksum(ctx context.Context) (string, error) {
+	conn, err := c.Master.DB.Conn(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get connection")
+	}
+
+	defer conn.Close()
+
+	var database string
+
+	err = conn.QueryRowContext(ctx, `SELECT DATABASE()`).Scan(&database)
+	if err != nil || database == "" {
+		return "", errors.Errorf("database is not selected in DSN of master %s", c.Master)
+	}
+
+	// checksum queries are replicated and executed again on replicas
+	_, err = conn.ExecContext(ctx, `SET SESSION binlog_format = 'STATEMENT'`)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to set binlog_format=STATEMENT, SUPER or SYSTEM_VARIABLES_ADMIN is required")
+	}
+
+	checksums := c.Checksums()
+
+	_, err = conn.ExecContext(ctx, `CREATE DATABASE IF NOT EXISTS `+quote.Ident(c.ChecksumsDatabase))
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to create database %s", c.ChecksumsDatabase)
+	}
+
+	err = CreateTable(ctx, conn, checksums)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = conn.ExecContext(ctx, `DELETE FROM `+checksums+` WHERE db = ?`, database)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to delete previous checksums")
+	}
+
+	tables, err := c.tables(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	repo := dump.New(c.Master.DB)
+
+	for _, table := range tables {
+		columns, err := repo.GetTableColumns(ctx, *table)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to get columns of %s", table.Name)
+		}
+
+		key, err := repo.GetPrimaryKey(ctx, *table)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to get primary key of %s", table.Name)
+		}
+
+		if len(key) == 0 {
+			logrus.Warnf("table %s has no primary key, it is checked as one chunk", table.Name)
+		}
+
+		chunks, err := chunk.Split(ctx, conn, quote.Qualified(database, table.Name), key, c.ChunkSize)
+		if err != nil {
+			return "", err
+		}
+
+		for _, ch := range chunks {
+			_, err = Compute(ctx, conn, checksums, database, table.Name, columns, key, ch)
+			if err != nil {
+				return "", err
+			}
+		}
+
+		logrus.Infof("table %s: %d chunks", table.Name, len(chunks))
+	}
+
+	return database, nil
+}
+
+// Compare waits for replicas to apply checksums of master and returns drifted chunks
+func (c *Checker) Compare(ctx context.Context, database string) ([]Drift, error) {
+	if c.WaitTimeout <= 0 {
+		c.WaitTimeout = DefaultWaitTimeout
+	}
+
+	status, err := c.Master.Master().ShowStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	drifts := make([]Drift, 0)
+
+	for _, replica := range c.Replicas {
+		info, err := replica.Server().Info(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "replica %s", replica)
+		}
+
+		err = c.wait(ctx, replica, *status, info.GTIDEnabled() && status.ExecutedGTIDSet != "")
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := Results(ctx, replica.DB, c.Checksums(), database, true)
+		if err != nil {
+			return nil, errors.Wrapf(err, "replica %s", replica)
+		}
+
+		for _, result := range results {
+			drifts = append(drifts, Drift{Replica: replica, Result: result})
+		}
+	}
+
+	return drifts, nil
+}
+
+func (c *Checker) wait(ctx context.Context, replica *node.Node, status master.Status, useGTID bool) error {
+	ctx, cancel := context.WithTimeout(ctx, c.WaitTimeout)
+	defer cancel()
+
+	return replica.WaitExecuted(ctx, status, useGTID)
+}
+
+// tables returns base tables of master filtered by Tables
+func (c *Checker) tables(ctx context.Context) ([]*dump.Table, error) {
+	all, err := dump.New(c.Master.DB).GetTables(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get tables")
+	}
+
+	wanted := make(map[string]bool, len(c.Tables))
+
+	for _, name := range c.Tables {
+		wanted[name] = true
+	}
+
+	tables := make([]*dump.Table, 0, len(all))
+
+	for _, table := range all {
+		if table.Type != dump.BaseTable {
+			continue
+		}
+
+		if len(wanted) > 0 && !wanted[table.Name] {
+			continue
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
@@ -0,0 +1,172 @@
+package checksum
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/chunk"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+)
+
+// Result represents checksum of chunk computed on a server
+// and checksum of the same chunk replicated from master
+type Result struct {
+	Database  string
+	Table     string
+	Chunk     chunk.Chunk
+	Count     int64
+	CRC       string
+	MasterCnt sql.NullInt64
+	MasterCRC sql.NullString
+}
+
+// Drifted returns true if checksum of replica differs from checksum of master
+func (r Result) Drifted() bool {
+	if !r.MasterCnt.Valid || !r.MasterCRC.Valid {
+		return true
+	}
+
+	return r.Count != r.MasterCnt.Int64 || r.CRC != r.MasterCRC.String
+}
+
+// Exec executes statements, it is implemented by *sql.DB and *sql.Conn
+type Exec interface {
+	chunk.Queryer
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// CreateTable creates table of checksums, table is qualified name
+func CreateTable(ctx context.Context, db Exec, table string) error {
+	_, err := db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS `+table+` (
+  db             CHAR(64)     NOT NULL,
+  tbl            CHAR(64)     NOT NULL,
+  chunk          INT          NOT NULL,
+  lower_boundary TEXT         NULL,
+  upper_boundary TEXT         NULL,
+  this_crc       CHAR(40)     NOT NULL,
+  this_cnt       INT          NOT NULL,
+  master_crc     CHAR(40)     NULL,
+  master_cnt     INT          NULL,
+  ts             TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (db, tbl, chunk)
+) ENGINE=InnoDB`)
+
+	return errors.Wrapf(err, "unable to create table %s", table)
+}
+
+// Expression returns expression of checksum of rows with columns,
+// NULL and empty string are distinguished by ISNULL of nullable columns
+func Expression(columns []string) string {
+	parts := make([]string, 0, len(columns)+1)
+
+	for _, column := range columns {
+		parts = append(parts, quote.Ident(column))
+	}
+
+	isNull := make([]string, 0, len(columns))
+
+	for _, column := range columns {
+		isNull = append(isNull, "ISNULL("+quote.Ident(column)+")")
+	}
+
+	parts = append(parts, "CONCAT("+strings.Join(isNull, ", ")+")")
+
+	return fmt.Sprintf(
+		"COALESCE(LOWER(CONV(BIT_XOR(CAST(CRC32(CONCAT_WS('#', %s)) AS UNSIGNED)), 10, 16)), 0)",
+		strings.Join(parts, ", "),
+	)
+}
+
+// Compute computes checksum of chunk on master by REPLACE ... SELECT,
+// with binlog_format=STATEMENT the statement is replicated and computed again on replicas,
+// then checksum of master is written to master_crc and master_cnt of replicas
+func Compute(ctx context.Context, db Exec, checksums, database, table string, columns, key []string, c chunk.Chunk) (*Result, error) {
+	where, args := c.Where(key)
+	lower, upper := c.Lower.Encode(), c.Upper.Encode()
+
+	q := fmt.Sprintf(`
+REPLACE INTO %s (db, tbl, chunk, lower_boundary, upper_boundary, this_cnt, this_crc)
+SELECT ?, ?, ?, ?, ?, COUNT(*), %s FROM %s WHERE %s`,
+		checksums, Expression(columns), quote.Qualified(database, table), where)
+
+	_, err := db.ExecContext(ctx, q, append([]interface{}{database, table, c.Index, lower, upper}, args...)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to compute checksum of %s chunk %s", table, c)
+	}
+
+	result := &Result{Database: database, Table: table, Chunk: c}
+
+	err = db.QueryRowContext(ctx,
+		`SELECT this_cnt, this_crc FROM `+checksums+` WHERE db = ? AND tbl = ? AND chunk = ?`,
+		database, table, c.Index,
+	).Scan(&result.Count, &result.CRC)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read checksum of %s chunk %s", table, c)
+	}
+
+	_, err = db.ExecContext(ctx,
+		`UPDATE `+checksums+` SET master_cnt = ?, master_crc = ? WHERE db = ? AND tbl = ? AND chunk = ?`,
+		result.Count, result.CRC, database, table, c.Index,
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to save checksum of %s chunk %s", table, c)
+	}
+
+	result.MasterCnt = sql.NullInt64{Int64: result.Count, Valid: true}
+	result.MasterCRC = sql.NullString{String: result.CRC, Valid: true}
+
+	return result, nil
+}
+
+// Results returns checksums of chunks of database, drifted only if drifted is true
+func Results(ctx context.Context, db chunk.Queryer, checksums, database string, drifted bool) ([]Result, error) {
+	q := `
+SELECT tbl, chunk, lower_boundary, upper_boundary, this_cnt, this_crc, master_cnt, master_crc
+FROM ` + checksums + ` WHERE db = ?`
+
+	if drifted {
+		q += ` AND (master_cnt IS NULL OR master_crc IS NULL OR master_cnt <> this_cnt OR master_crc <> this_crc)`
+	}
+
+	q += ` ORDER BY tbl, chunk`
+
+	rows, err := db.QueryContext(ctx, q, database)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read checksums")
+	}
+
+	defer rows.Close()
+
+	results := make([]Result, 0)
+
+	for rows.Next() {
+		var (
+			r            = Result{Database: database}
+			lower, upper sql.NullString
+		)
+
+		err = rows.Scan(&r.Table, &r.Chunk.Index, &lower, &upper, &r.Count, &r.CRC, &r.MasterCnt, &r.MasterCRC)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan checksum")
+		}
+
+		r.Chunk.Lower, err = chunk.DecodeBound(lower)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Chunk.Upper, err = chunk.DecodeBound(upper)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, r)
+	}
+
+	return results, errors.Wrap(rows.Err(), "unable to read checksums")
+}
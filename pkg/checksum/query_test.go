@@ -0,0 +1,61 @@
+package checksum_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/checksum"
+	"github.com/partyzanex/repmy/pkg/chunk"
+	"github.com/partyzanex/testutils"
+)
+
+func TestExpression(t *testing.T) {
+	exp := "COALESCE(LOWER(CONV(BIT_XOR(CAST(CRC32(CONCAT_WS('#', `id`, `name`, " +
+		"CONCAT(ISNULL(`id`), ISNULL(`name`)))) AS UNSIGNED)), 10, 16)), 0)"
+
+	testutils.AssertEqual(t, "expression", exp, checksum.Expression([]string{"id", "name"}))
+}
+
+func TestCompute(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	c := chunk.Chunk{Index: 1, Lower: chunk.Bound{"10"}, Upper: chunk.Bound{"20"}}
+
+	mock.ExpectExec("REPLACE INTO `repmy`.`checksums` .* FROM `shop`.`orders` WHERE \\(`id`\\) > \\(\\?\\) AND \\(`id`\\) <= \\(\\?\\)").
+		WithArgs("shop", "orders", 1, c.Lower.Encode(), c.Upper.Encode(), "10", "20").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT this_cnt, this_crc FROM `repmy`.`checksums`").WithArgs("shop", "orders", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"this_cnt", "this_crc"}).AddRow(10, "3f2a"))
+	mock.ExpectExec("UPDATE `repmy`.`checksums` SET master_cnt = \\?, master_crc = \\?").
+		WithArgs(10, "3f2a", "shop", "orders", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	result, err := checksum.Compute(context.Background(), db, "`repmy`.`checksums`",
+		"shop", "orders", []string{"id", "total"}, []string{"id"}, c)
+	testutils.FatalErr(t, "Compute", err)
+
+	testutils.AssertEqual(t, "count", int64(10), result.Count)
+	testutils.AssertEqual(t, "drifted", false, result.Drifted())
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestResults(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	mock.ExpectQuery("SELECT tbl, chunk, .* master_cnt <> this_cnt").WithArgs("shop").WillReturnRows(
+		sqlmock.NewRows([]string{"tbl", "chunk", "lower_boundary", "upper_boundary", "this_cnt", "this_crc", "master_cnt", "master_crc"}).
+			AddRow("orders", 2, `["20"]`, nil, 9, "aa", 10, "bb"),
+	)
+
+	results, err := checksum.Results(context.Background(), db, "`repmy`.`checksums`", "shop", true)
+	testutils.FatalErr(t, "Results", err)
+
+	testutils.AssertEqual(t, "results", 1, len(results))
+	testutils.AssertEqual(t, "chunk", "#2 (20, ∞]", results[0].Chunk.String())
+	testutils.AssertEqual(t, "drifted", true, results[0].Drifted())
+	testutils.AssertEqual(t, "master count", sql.NullInt64{Int64: 10, Valid: true}, results[0].MasterCnt)
+}
@@ -0,0 +1,180 @@
+// Package chunk splits table into ranges of primary key
+package chunk
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultSize = 1000
+)
+
+// Bound represents values of key columns, nil is unbounded
+type Bound []string
+
+// Chunk represents range of rows (Lower, Upper] of key,
+// the first chunk has no lower bound and the last one has no upper bound
+type Chunk struct {
+	Index int
+	Lower Bound
+	Upper Bound
+}
+
+// Where returns condition selecting rows of chunk by key columns and its arguments,
+// returns "1=1" for unbounded chunk or empty key
+func (c Chunk) Where(key []string) (string, []interface{}) {
+	conds := make([]string, 0, 2)
+	args := make([]interface{}, 0, 2*len(key))
+
+	if len(key) > 0 && c.Lower != nil {
+		conds = append(conds, fmt.Sprintf("(%s) > (%s)", quote.Idents(key...), placeholders(len(key))))
+		args = appendBound(args, c.Lower)
+	}
+
+	if len(key) > 0 && c.Upper != nil {
+		conds = append(conds, fmt.Sprintf("(%s) <= (%s)", quote.Idents(key...), placeholders(len(key))))
+		args = appendBound(args, c.Upper)
+	}
+
+	if len(conds) == 0 {
+		return "1=1", args
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// String returns range of chunk
+func (c Chunk) String() string {
+	return fmt.Sprintf("#%d (%s, %s]", c.Index, c.Lower, c.Upper)
+}
+
+// String returns values of bound or ∞ if it is unbounded
+func (b Bound) String() string {
+	if b == nil {
+		return "∞"
+	}
+
+	return strings.Join(b, ",")
+}
+
+// Encode returns JSON of bound to store it, NULL for unbounded
+func (b Bound) Encode() sql.NullString {
+	if b == nil {
+		return sql.NullString{}
+	}
+
+	data, _ := json.Marshal([]string(b))
+
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// DecodeBound parses bound stored by Encode
+func DecodeBound(s sql.NullString) (Bound, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+
+	var b []string
+
+	err := json.Unmarshal([]byte(s.String), &b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid chunk bound %q", s.String)
+	}
+
+	return b, nil
+}
+
+// Queryer runs queries, it is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Split returns chunks of table with about size rows by key,
+// table without key is returned as one chunk.
+// Table is qualified name, ex. quote.Qualified(db, name)
+func Split(ctx context.Context, db Queryer, table string, key []string, size int) ([]Chunk, error) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+
+	if len(key) == 0 {
+		return []Chunk{{}}, nil
+	}
+
+	chunks := make([]Chunk, 0)
+
+	var lower Bound
+
+	for {
+		upper, err := nextBound(ctx, db, table, key, lower, size)
+		if err != nil {
+			return nil, err
+		}
+
+		chunks = append(chunks, Chunk{Index: len(chunks), Lower: lower, Upper: upper})
+
+		if upper == nil {
+			return chunks, nil
+		}
+
+		lower = upper
+	}
+}
+
+// nextBound returns key of size-th row after lower, nil if there are less rows
+func nextBound(ctx context.Context, db Queryer, table string, key []string, lower Bound, size int) (Bound, error) {
+	where, args := Chunk{Lower: lower}.Where(key)
+
+	q := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT 1 OFFSET %d",
+		quote.Idents(key...), table, where, quote.Idents(key...), size-1)
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find chunk bound of %s", table)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, errors.Wrapf(rows.Err(), "unable to find chunk bound of %s", table)
+	}
+
+	values := make([]sql.RawBytes, len(key))
+	dest := make([]interface{}, len(key))
+
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	err = rows.Scan(dest...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to scan chunk bound of %s", table)
+	}
+
+	bound := make(Bound, len(key))
+
+	for i, value := range values {
+		bound[i] = string(value)
+	}
+
+	return bound, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func appendBound(args []interface{}, b Bound) []interface{} {
+	for _, v := range b {
+		args = append(args, v)
+	}
+
+	return args
+}
@@ -0,0 +1,53 @@
+package chunk_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/chunk"
+	"github.com/partyzanex/testutils"
+)
+
+func TestChunk_Where(t *testing.T) {
+	c := chunk.Chunk{Lower: chunk.Bound{"1", "10"}, Upper: chunk.Bound{"2", "5"}}
+
+	where, args := c.Where([]string{"a", "b"})
+	testutils.AssertEqual(t, "where", "(`a`, `b`) > (?,?) AND (`a`, `b`) <= (?,?)", where)
+	testutils.AssertEqual(t, "args", "[1 10 2 5]", fmt.Sprint(args))
+
+	where, args = chunk.Chunk{}.Where([]string{"id"})
+	testutils.AssertEqual(t, "unbounded", "1=1", where)
+	testutils.AssertEqual(t, "no args", 0, len(args))
+}
+
+func TestBound_Encode(t *testing.T) {
+	b := chunk.Bound{"1", "a\"b"}
+
+	decoded, err := chunk.DecodeBound(b.Encode())
+	testutils.FatalErr(t, "DecodeBound", err)
+	testutils.AssertEqual(t, "bound", b.String(), decoded.String())
+
+	decoded, err = chunk.DecodeBound(chunk.Bound(nil).Encode())
+	testutils.FatalErr(t, "DecodeBound", err)
+	testutils.AssertEqual(t, "unbounded", true, decoded == nil)
+}
+
+func TestSplit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	mock.ExpectQuery("SELECT `id` FROM `db`.`t` WHERE 1=1 ORDER BY `id` LIMIT 1 OFFSET 9").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("10"))
+	mock.ExpectQuery("SELECT `id` FROM `db`.`t` WHERE \\(`id`\\) > \\(\\?\\) ORDER BY `id` LIMIT 1 OFFSET 9").
+		WithArgs("10").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("20"))
+	mock.ExpectQuery("SELECT `id` FROM `db`.`t` WHERE \\(`id`\\) > \\(\\?\\) ORDER BY `id` LIMIT 1 OFFSET 9").
+		WithArgs("20").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	chunks, err := chunk.Split(context.Background(), db, "`db`.`t`", []string{"id"}, 10)
+	testutils.FatalErr(t, "Split", err)
+
+	testutils.AssertEqual(t, "chunks", "[#0 (∞, 10] #1 (10, 20] #2 (20, ∞]]", fmt.Sprint(chunks))
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
@@ -0,0 +1,264 @@
+package clone
+
+import (
+	"context"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultRestartTimeout = 10 * time.Minute
+
+	// ER_CLONE_RESTART: mysqld of recipient is not managed by supervisor and must be restarted manually
+	errCloneRestart = 3707
+
+	pollInterval = 5 * time.Second
+)
+
+// Provisioner replaces data of Replica by clone of Master and starts replication
+type Provisioner struct {
+	Master  *node.Node
+	Replica *node.Node
+	// User connects replica to master for cloning and replication,
+	// it is created on master if not exists
+	User mysql.ReplUser
+	// RestartTimeout limits waiting for replica to come back after restart
+	RestartTimeout time.Duration
+	// PollInterval is interval of progress reports, 5s by default
+	PollInterval time.Duration
+}
+
+// Run clones master to replica and configures replication from the cloned position
+func (p *Provisioner) Run(ctx context.Context) error {
+	if p.RestartTimeout <= 0 {
+		p.RestartTimeout = DefaultRestartTimeout
+	}
+
+	if p.PollInterval <= 0 {
+		p.PollInterval = pollInterval
+	}
+
+	err := p.validate(ctx)
+	if err != nil {
+		return err
+	}
+
+	user := p.Master.ReplUser(p.User)
+	donor := New(p.Master.DB)
+	recipient := New(p.Replica.DB)
+
+	err = donor.InstallPlugin(ctx)
+	if err == nil {
+		err = p.Master.Master().SetReplUser(ctx, user)
+	}
+
+	if err == nil {
+		err = donor.GrantDonor(ctx, user)
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "master %s", p.Master)
+	}
+
+	err = recipient.InstallPlugin(ctx)
+	if err == nil {
+		err = recipient.GrantRecipient(ctx)
+	}
+
+	if err == nil {
+		err = recipient.SetValidDonors(ctx, p.Master.Addr())
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "replica %s", p.Replica)
+	}
+
+	started := time.Now()
+
+	err = p.clone(ctx, recipient, user)
+	if err != nil {
+		return err
+	}
+
+	status, err := p.waitRestart(ctx, recipient, started)
+	if err != nil {
+		return err
+	}
+
+	return p.startReplication(ctx, user, status)
+}
+
+// validate checks that both servers support cloning between them
+func (p *Provisioner) validate(ctx context.Context) error {
+	masterInfo, err := p.Master.Server().Info(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "master %s", p.Master)
+	}
+
+	replicaInfo, err := p.Replica.Server().Info(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "replica %s", p.Replica)
+	}
+
+	mv, rv := masterInfo.Version, replicaInfo.Version
+
+	if mv.MariaDB || rv.MariaDB || !mv.AtLeast(8, 0, 17) || !rv.AtLeast(8, 0, 17) {
+		return errors.Errorf("clone requires MySQL 8.0.17+, master %s has %s, replica %s has %s",
+			p.Master, mv.Raw, p.Replica, rv.Raw)
+	}
+
+	// before 8.0.37 donor and recipient must have the same version
+	sameSeries := mv.Major == rv.Major && mv.Minor == rv.Minor && mv.AtLeast(8, 0, 37) && rv.AtLeast(8, 0, 37)
+
+	if mv.String() != rv.String() && !sameSeries {
+		return errors.Errorf("clone requires the same version of master %s and replica %s", mv, rv)
+	}
+
+	if masterInfo.ServerUUID == replicaInfo.ServerUUID {
+		return errors.Errorf("master %s and replica %s are the same server", p.Master, p.Replica)
+	}
+
+	return nil
+}
+
+// clone executes CLONE INSTANCE and reports progress until the statement returns
+func (p *Provisioner) clone(ctx context.Context, recipient *Repository, user mysql.ReplUser) error {
+	logrus.Infof("cloning %s to %s, data of %s is replaced", p.Master, p.Replica, p.Replica)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- recipient.CloneFrom(ctx, user)
+	}()
+
+	ticker := time.NewTicker(p.PollInterval)
+	defer ticker.Stop()
+
+	var last string
+
+	for {
+		select {
+		case err := <-done:
+			return p.cloned(err)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		stages, err := recipient.Progress(ctx)
+		if err != nil {
+			// server is restarted at the end of cloning
+			continue
+		}
+
+		for _, stage := range stages {
+			if stage.State != StateInProgress {
+				continue
+			}
+
+			if s := stage.String(); s != last {
+				logrus.Info(s)
+				last = s
+			}
+		}
+	}
+}
+
+// cloned checks result of CLONE INSTANCE, the lost connection is expected on restart
+func (p *Provisioner) cloned(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *driver.MySQLError
+
+	if !errors.As(err, &e) {
+		logrus.Infof("connection to %s is lost, waiting for restart: %s", p.Replica, err)
+		return nil
+	}
+
+	if e.Number == errCloneRestart {
+		logrus.Warnf("mysqld of %s is not managed by supervisor, restart it manually within %s",
+			p.Replica, p.RestartTimeout)
+
+		return nil
+	}
+
+	return errors.Wrapf(err, "replica %s", p.Replica)
+}
+
+// waitRestart waits until replica is restarted after started and returns status of completed clone
+func (p *Provisioner) waitRestart(ctx context.Context, recipient *Repository, started time.Time) (*Status, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.RestartTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(p.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		uptime, err := recipient.Uptime(ctx)
+		if err == nil && uptime < time.Since(started) {
+			status, err := recipient.Status(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			if status == nil {
+				return nil, errors.Errorf("replica %s has no clone status after restart", p.Replica)
+			}
+
+			if err = status.Err(); err != nil {
+				return nil, err
+			}
+
+			if status.State == StateCompleted {
+				return status, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "replica %s is not restarted after clone", p.Replica)
+		case <-ticker.C:
+		}
+	}
+}
+
+// startReplication configures replication from the position of cloned data
+func (p *Provisioner) startReplication(ctx context.Context, user mysql.ReplUser, status *Status) error {
+	info, err := p.Replica.Server().Info(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "replica %s", p.Replica)
+	}
+
+	coordinates := master.Status{
+		File:            status.BinlogFile.String,
+		Position:        int(status.BinlogPosition.Int64),
+		ExecutedGTIDSet: info.GTIDExecuted,
+	}
+
+	useGTID := info.GTIDEnabled()
+
+	if useGTID {
+		logrus.Infof("replica %s has cloned gtid_executed %s", p.Replica, coordinates.ExecutedGTIDSet)
+	} else if coordinates.File == "" {
+		return errors.Errorf("replica %s has no binlog coordinates of clone", p.Replica)
+	} else {
+		logrus.Infof("replica %s is cloned at %s:%d", p.Replica, coordinates.File, coordinates.Position)
+	}
+
+	err = p.Replica.Repoint(ctx, user, coordinates, useGTID)
+	if err != nil {
+		return errors.Wrapf(err, "replica %s", p.Replica)
+	}
+
+	logrus.Infof("replication %s -> %s is started", p.Master, p.Replica)
+
+	return nil
+}
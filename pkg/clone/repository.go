@@ -0,0 +1,218 @@
+// Package clone provisions replicas by physical copy of master data with the MySQL CLONE plugin
+package clone
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+)
+
+const (
+	pluginName = "clone"
+	pluginLib  = "mysql_clone.so"
+)
+
+// states of clone operation in performance_schema
+const (
+	StateNotStarted = "Not Started"
+	StateInProgress = "In Progress"
+	StateCompleted  = "Completed"
+	StateFailed     = "Failed"
+)
+
+// Repository represents repository layer for clone plugin
+type Repository struct {
+	db *sqlx.DB
+}
+
+// New creates a new repository
+func New(db *sql.DB) *Repository {
+	return &Repository{
+		db: sqlx.NewDb(db, "mysql"),
+	}
+}
+
+// Status represents row of performance_schema.clone_status
+type Status struct {
+	State          string         `db:"STATE"`
+	Source         string         `db:"SOURCE"`
+	ErrorNo        int            `db:"ERROR_NO"`
+	ErrorMessage   sql.NullString `db:"ERROR_MESSAGE"`
+	BinlogFile     sql.NullString `db:"BINLOG_FILE"`
+	BinlogPosition sql.NullInt64  `db:"BINLOG_POSITION"`
+	GTIDExecuted   sql.NullString `db:"GTID_EXECUTED"`
+}
+
+// Err returns error of failed clone
+func (s Status) Err() error {
+	if s.State != StateFailed && s.ErrorNo == 0 {
+		return nil
+	}
+
+	return errors.Errorf("clone from %s failed: [%d] %s", s.Source, s.ErrorNo, s.ErrorMessage.String)
+}
+
+// Stage represents row of performance_schema.clone_progress
+type Stage struct {
+	Stage    string        `db:"STAGE"`
+	State    string        `db:"STATE"`
+	Estimate sql.NullInt64 `db:"ESTIMATE"`
+	Data     sql.NullInt64 `db:"DATA"`
+}
+
+// String returns stage with its transferred data
+func (s Stage) String() string {
+	if s.Estimate.Int64 <= 0 {
+		return fmt.Sprintf("%s %s", s.Stage, s.State)
+	}
+
+	return fmt.Sprintf("%s %s %d/%d MiB (%.1f%%)", s.Stage, s.State, s.Data.Int64>>20, s.Estimate.Int64>>20,
+		float64(s.Data.Int64)*100/float64(s.Estimate.Int64))
+}
+
+// InstallPlugin installs clone plugin if it is not installed
+func (repo *Repository) InstallPlugin(ctx context.Context) error {
+	var count int
+
+	q := `SELECT COUNT(*) FROM information_schema.PLUGINS WHERE PLUGIN_NAME = ? AND PLUGIN_STATUS = 'ACTIVE'`
+
+	err := repo.db.GetContext(ctx, &count, q, pluginName)
+	if err != nil {
+		return errors.Wrap(err, "unable to check clone plugin")
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	_, err = repo.db.ExecContext(ctx, `INSTALL PLUGIN `+pluginName+` SONAME '`+pluginLib+`'`)
+	if err != nil {
+		return errors.Wrap(err, "unable to install clone plugin")
+	}
+
+	return nil
+}
+
+// GrantDonor grants BACKUP_ADMIN privilege required on donor to user,
+// the account must exist
+func (repo *Repository) GrantDonor(ctx context.Context, user mysql.ReplUser) error {
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `GRANT BACKUP_ADMIN ON *.* TO `+quoter.Account(user.Name, user.GetHost()))
+	if err != nil {
+		return errors.Wrap(err, "unable to grant BACKUP_ADMIN")
+	}
+
+	return nil
+}
+
+// GrantRecipient grants CLONE_ADMIN privilege required on recipient to the current user
+func (repo *Repository) GrantRecipient(ctx context.Context) error {
+	_, err := repo.db.ExecContext(ctx, `GRANT CLONE_ADMIN ON *.* TO CURRENT_USER()`)
+	if err != nil {
+		return errors.Wrap(err, "unable to grant CLONE_ADMIN")
+	}
+
+	return nil
+}
+
+// SetValidDonors sets clone_valid_donor_list of recipient, addr is host:port
+func (repo *Repository) SetValidDonors(ctx context.Context, addr string) error {
+	_, err := repo.db.ExecContext(ctx, `SET GLOBAL clone_valid_donor_list = ?`, addr)
+	if err != nil {
+		return errors.Wrap(err, "unable to set clone_valid_donor_list")
+	}
+
+	return nil
+}
+
+// CloneFrom executes CLONE INSTANCE FROM master of user. Data of recipient is replaced
+// and the server is restarted, so the connection is usually lost when cloning is done.
+func (repo *Repository) CloneFrom(ctx context.Context, user mysql.ReplUser) error {
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	q := fmt.Sprintf(`CLONE INSTANCE FROM %s:%d IDENTIFIED BY %s`,
+		quoter.Account(user.Name, user.MasterHost), masterPort(user), quoter.String(user.Password))
+
+	if user.RequireSSL {
+		q += ` REQUIRE SSL`
+	}
+
+	_, err = conn.ExecContext(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "unable to clone instance")
+	}
+
+	return nil
+}
+
+func masterPort(user mysql.ReplUser) int {
+	if user.MasterPort == 0 {
+		return node.DefaultPort
+	}
+
+	return user.MasterPort
+}
+
+// Status returns state of the last clone operation, nil is returned if clone was never executed
+func (repo *Repository) Status(ctx context.Context) (*Status, error) {
+	var statuses []Status
+
+	q := `SELECT STATE, SOURCE, ERROR_NO, ERROR_MESSAGE, BINLOG_FILE, BINLOG_POSITION, GTID_EXECUTED 
+FROM performance_schema.clone_status`
+
+	err := repo.db.SelectContext(ctx, &statuses, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get clone status")
+	}
+
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+
+	return &statuses[0], nil
+}
+
+// Progress returns stages of the last clone operation
+func (repo *Repository) Progress(ctx context.Context) ([]Stage, error) {
+	var stages []Stage
+
+	q := `SELECT STAGE, STATE, ESTIMATE, DATA FROM performance_schema.clone_progress`
+
+	err := repo.db.SelectContext(ctx, &stages, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get clone progress")
+	}
+
+	return stages, nil
+}
+
+// Uptime returns time since the server was started
+func (repo *Repository) Uptime(ctx context.Context) (time.Duration, error) {
+	var name string
+	var seconds int64
+
+	err := repo.db.QueryRowxContext(ctx, `SHOW GLOBAL STATUS LIKE 'Uptime'`).Scan(&name, &seconds)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to get uptime")
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,95 @@
+package clone_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/clone"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/testutils"
+)
+
+func TestRepository_InstallPlugin(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := clone.New(db)
+	ctx := context.Background()
+	q := `SELECT COUNT(*) FROM information_schema.PLUGINS WHERE PLUGIN_NAME = ? AND PLUGIN_STATUS = 'ACTIVE'`
+
+	mock.ExpectQuery(q).WithArgs("clone").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectExec(`INSTALL PLUGIN clone SONAME 'mysql_clone.so'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(q).WithArgs("clone").WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+
+	testutils.FatalErr(t, "repo.InstallPlugin", repo.InstallPlugin(ctx))
+	testutils.FatalErr(t, "repo.InstallPlugin", repo.InstallPlugin(ctx))
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_CloneFrom(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := clone.New(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`GRANT BACKUP_ADMIN ON *.* TO 'repl'@'%'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`CLONE INSTANCE FROM 'repl'@'10.0.0.1':3306 IDENTIFIED BY 'it\'s' REQUIRE SSL`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	user := mysql.ReplUser{Name: "repl", Password: "it's", MasterHost: "10.0.0.1", RequireSSL: true}
+
+	testutils.FatalErr(t, "repo.GrantDonor", repo.GrantDonor(ctx, user))
+	testutils.FatalErr(t, "repo.CloneFrom", repo.CloneFrom(ctx, user))
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_Status(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := clone.New(db)
+	ctx := context.Background()
+
+	columns := []string{
+		"STATE", "SOURCE", "ERROR_NO", "ERROR_MESSAGE", "BINLOG_FILE", "BINLOG_POSITION", "GTID_EXECUTED",
+	}
+
+	mock.ExpectQuery(`FROM performance_schema.clone_status`).WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(`FROM performance_schema.clone_status`).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(clone.StateCompleted, "10.0.0.1:3306", 0, "", "binlog.000012", 1547, "uuid:1-10"))
+	mock.ExpectQuery(`FROM performance_schema.clone_status`).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(clone.StateFailed, "10.0.0.1:3306", 3862, "Clone Donor Error", nil, nil, nil))
+
+	status, err := repo.Status(ctx)
+	testutils.FatalErr(t, "repo.Status", err)
+	testutils.AssertEqual(t, "never cloned", true, status == nil)
+
+	status, err = repo.Status(ctx)
+	testutils.FatalErr(t, "repo.Status", err)
+	testutils.FatalErr(t, "status.Err", status.Err())
+	testutils.AssertEqual(t, "file", "binlog.000012", status.BinlogFile.String)
+	testutils.AssertEqual(t, "position", int64(1547), status.BinlogPosition.Int64)
+
+	status, err = repo.Status(ctx)
+	testutils.FatalErr(t, "repo.Status", err)
+	testutils.AssertEqual(t, "failed", "clone from 10.0.0.1:3306 failed: [3862] Clone Donor Error", status.Err().Error())
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestStage_String(t *testing.T) {
+	stage := clone.Stage{Stage: "FILE COPY", State: clone.StateInProgress,
+		Estimate: sql.NullInt64{Int64: 4 << 30, Valid: true}, Data: sql.NullInt64{Int64: 1 << 30, Valid: true}}
+
+	testutils.AssertEqual(t, "copy", "FILE COPY In Progress 1024/4096 MiB (25.0%)", stage.String())
+
+	stage = clone.Stage{Stage: "RESTART", State: clone.StateNotStarted}
+	testutils.AssertEqual(t, "restart", "RESTART Not Started", stage.String())
+}
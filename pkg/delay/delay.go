@@ -0,0 +1,115 @@
+// Package delay manages delayed replicas kept as undo buffer of master
+package delay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+)
+
+// Window represents the period of master history which is received
+// by delayed replica but not applied yet
+type Window struct {
+	// Delay is configured MASTER_DELAY
+	Delay time.Duration
+	// Remaining is the delay left for the next event, -1 if SQL thread does not wait
+	Remaining time.Duration
+	// AppliedUntil is commit time on master of the last applied transaction,
+	// zero if replica has not applied transactions
+	AppliedUntil time.Time
+	// Now is time of inspection
+	Now time.Time
+	// IORunning is false if replica does not receive the latest transactions of master
+	IORunning  bool
+	SQLRunning bool
+}
+
+// Span returns duration of the window
+func (w *Window) Span() time.Duration {
+	if w.AppliedUntil.IsZero() || w.AppliedUntil.After(w.Now) {
+		return 0
+	}
+
+	return w.Now.Sub(w.AppliedUntil)
+}
+
+// String returns description of window
+func (w *Window) String() string {
+	s := fmt.Sprintf("delay %s", w.Delay)
+
+	if w.Remaining >= 0 {
+		s += fmt.Sprintf(", next event in %s", w.Remaining)
+	}
+
+	if w.AppliedUntil.IsZero() {
+		s += ", no transactions applied"
+	} else {
+		s += fmt.Sprintf(", applied until %s, window %s",
+			w.AppliedUntil.Format(time.RFC3339), w.Span().Truncate(time.Second))
+	}
+
+	if !w.IORunning {
+		s += ", IO thread is stopped: window ends at the last received transaction"
+	}
+
+	if !w.SQLRunning {
+		s += ", SQL thread is stopped"
+	}
+
+	return s
+}
+
+// Inspect returns window of replica
+func Inspect(ctx context.Context, replica *node.Node) (*Window, error) {
+	status, err := replica.Slave().ShowStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.MasterHost == "" {
+		return nil, errors.Errorf("%s is not a replica", replica)
+	}
+
+	w := &Window{
+		Delay:      time.Duration(status.SQLDelay) * time.Second,
+		Remaining:  -1,
+		Now:        time.Now().UTC(),
+		IORunning:  status.IORunning(),
+		SQLRunning: status.SQLRunning(),
+	}
+
+	if status.SQLRemainingDelay.Valid {
+		w.Remaining = time.Duration(status.SQLRemainingDelay.Int) * time.Second
+	}
+
+	w.AppliedUntil, err = appliedUntil(ctx, replica, status, w.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// appliedUntil returns commit time on master of the last applied transaction,
+// before 8.0 it is estimated by lag in status of running replica at now
+func appliedUntil(ctx context.Context, replica *node.Node, status *slave.Status, now time.Time) (time.Time, error) {
+	info, err := replica.Server().Info(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !info.Version.MariaDB && info.Version.AtLeast(8, 0, 2) {
+		return replica.Slave().AppliedCommitTime(ctx)
+	}
+
+	if status.SecondsBehindMaster.Valid {
+		// commit times of transactions are not reported before 8.0
+		return now.Add(-time.Duration(status.SecondsBehindMaster.Int) * time.Second), nil
+	}
+
+	return time.Time{}, nil
+}
@@ -0,0 +1,106 @@
+package delay_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/delay"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/testutils"
+)
+
+const testGTID = "3e11fa47-71ca-11e1-9e33-c80aa9429562:42"
+
+var statusColumns = []string{"Master_Host", "Slave_IO_Running", "Slave_SQL_Running", "SQL_Delay",
+	"SQL_Remaining_Delay", "Seconds_Behind_Master", "Relay_Master_Log_File", "Exec_Master_Log_Pos", "Executed_Gtid_Set"}
+
+func TestInspect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	replica := &node.Node{Name: "delayed", Host: "10.0.0.3", Port: 3306, DB: db}
+	// fraction of second added below keeps the window above 50m
+	applied := time.Now().Add(-50*time.Minute - time.Second).Unix()
+
+	mock.ExpectQuery(`show slave status`).WillReturnRows(sqlmock.NewRows(statusColumns).
+		AddRow("10.0.0.1", "Yes", "Yes", 3600, 120, 3480, "binlog.000007", 4410, ""))
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES`).WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).
+		AddRow("version", "8.0.30"))
+	mock.ExpectQuery(`LAST_APPLIED_TRANSACTION_ORIGINAL_COMMIT_TIMESTAMP`).
+		WillReturnRows(sqlmock.NewRows([]string{"ts"}).AddRow(float64(applied) + 0.5))
+
+	w, err := delay.Inspect(context.Background(), replica)
+	testutils.FatalErr(t, "Inspect", err)
+	testutils.AssertEqual(t, "delay", time.Hour, w.Delay)
+	testutils.AssertEqual(t, "remaining", 2*time.Minute, w.Remaining)
+	testutils.AssertEqual(t, "applied", applied, w.AppliedUntil.Unix())
+	testutils.AssertEqual(t, "span", 50*time.Minute, w.Span().Round(time.Minute))
+	testutils.AssertEqual(t, "string", true, strings.Contains(w.String(), "window 50m"))
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestFastForward_Run(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	replica := &node.Node{Name: "delayed", Host: "10.0.0.3", Port: 3306, DB: db}
+
+	mock.ExpectQuery(`show slave status`).WillReturnRows(sqlmock.NewRows(statusColumns).
+		AddRow("10.0.0.1", "Yes", "Yes", 3600, 120, 3480, "binlog.000007", 4410, ""))
+	mock.ExpectExec(`STOP SLAVE SQL_THREAD`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`show slave status`).WillReturnRows(sqlmock.NewRows(statusColumns).
+		AddRow("10.0.0.1", "Yes", "No", 3600, nil, nil, "binlog.000007", 4410, ""))
+	mock.ExpectQuery(`show slave status`).WillReturnRows(sqlmock.NewRows(statusColumns).
+		AddRow("10.0.0.1", "Yes", "No", 3600, nil, nil, "binlog.000007", 4410, ""))
+	mock.ExpectExec(`CHANGE MASTER TO MASTER_DELAY = 0`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`START SLAVE SQL_THREAD UNTIL SQL_BEFORE_GTIDS = '` + testGTID + `'`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`show slave status`).WillReturnRows(sqlmock.NewRows(statusColumns).
+		AddRow("10.0.0.1", "Yes", "No", 0, nil, nil, "binlog.000009", 1290, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-41"))
+
+	ff := &delay.FastForward{Replica: replica, GTID: testGTID}
+
+	result, err := ff.Run(context.Background())
+	testutils.FatalErr(t, "Run", err)
+	testutils.AssertEqual(t, "delay", time.Hour, result.Delay)
+	testutils.AssertEqual(t, "file", "binlog.000009", result.File)
+	testutils.AssertEqual(t, "position", 1290, result.Position)
+	testutils.AssertEqual(t, "executed", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-41", result.ExecutedGTIDSet)
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestFastForward_Run_Applied(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	replica := &node.Node{Name: "delayed", Host: "10.0.0.3", Port: 3306, DB: db}
+	applied := time.Now().Add(-10 * time.Minute)
+
+	// target transaction is in Executed_Gtid_Set
+	mock.ExpectQuery(`show slave status`).WillReturnRows(sqlmock.NewRows(statusColumns).
+		AddRow("10.0.0.1", "Yes", "No", 3600, nil, nil, "binlog.000007", 4410, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-42"))
+
+	ff := &delay.FastForward{Replica: replica, GTID: testGTID}
+
+	_, err = ff.Run(context.Background())
+	testutils.AssertEqual(t, "gtid applied", true, err != nil && strings.Contains(err.Error(), "is applied"))
+
+	// target time is before commit time of the last applied transaction
+	mock.ExpectQuery(`show slave status`).WillReturnRows(sqlmock.NewRows(statusColumns).
+		AddRow("10.0.0.1", "Yes", "No", 3600, nil, nil, "binlog.000007", 4410, ""))
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES`).WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).
+		AddRow("version", "8.0.30"))
+	mock.ExpectQuery(`LAST_APPLIED_TRANSACTION_ORIGINAL_COMMIT_TIMESTAMP`).
+		WillReturnRows(sqlmock.NewRows([]string{"ts"}).AddRow(float64(applied.Unix())))
+
+	ff = &delay.FastForward{Replica: replica, Time: applied.Add(-time.Minute)}
+
+	_, err = ff.Run(context.Background())
+	testutils.AssertEqual(t, "time applied", true, err != nil && strings.Contains(err.Error(), "is before"))
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
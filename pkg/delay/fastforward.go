@@ -0,0 +1,347 @@
+package delay
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const pollInterval = time.Second
+
+// FastForward applies delayed transactions of replica until just before the target
+// transaction, e.g. a bad DROP TABLE on master. The replica is left with SQL thread
+// stopped and MASTER_DELAY = 0, so its data can be inspected or copied.
+type FastForward struct {
+	Replica *node.Node
+	// GTID is the target transaction
+	GTID string
+	// Time selects the first transaction started on master after it as the target,
+	// Config is used to find it in binlog of master
+	Time   time.Time
+	Config binlog.Config
+	// Timeout limits waiting for SQL thread, 0 waits without limit
+	Timeout time.Duration
+}
+
+// Result represents position of replica after fast-forward
+type Result struct {
+	// Target describes the transaction which is not applied
+	Target string
+	// Delay is MASTER_DELAY of replica before fast-forward
+	Delay time.Duration
+	// File and Position are coordinates of replica in binlog of master
+	File     string
+	Position int
+	// ExecutedGTIDSet is gtid_executed of replica
+	ExecutedGTIDSet string
+}
+
+// Run stops SQL thread, removes delay and applies transactions until the target
+func (f *FastForward) Run(ctx context.Context) (*Result, error) {
+	if (f.GTID == "") == f.Time.IsZero() {
+		return nil, errors.New("either GTID or time of target transaction is required")
+	}
+
+	repo := f.Replica.Slave()
+
+	status, err := repo.ShowStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.MasterHost == "" {
+		return nil, errors.Errorf("%s is not a replica", f.Replica)
+	}
+
+	if status.LastSQLErrno != 0 {
+		return nil, errors.Errorf("SQL thread of %s has error [%d] %s", f.Replica, status.LastSQLErrno, status.LastSQLError)
+	}
+
+	// lag is reported by running replica only
+	running, now := status, time.Now()
+
+	if status.SQLRunning() {
+		err = repo.StopSQLThread(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// position is read again after the stop
+		status, err = repo.ShowStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{Target: f.GTID, Delay: time.Duration(status.SQLDelay) * time.Second}
+	until := slave.Until{BeforeGTIDs: f.GTID}
+	targetGTID := f.GTID
+
+	if f.GTID != "" {
+		err = f.checkGTID(f.GTID, status.ExecutedGTIDSet)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		applied, err := appliedUntil(ctx, f.Replica, running, now)
+		if err != nil {
+			return nil, err
+		}
+
+		// the first transaction after the time is applied already
+		if f.Time.Before(applied) {
+			return nil, errors.Errorf("%s has applied transactions committed on master until %s, target time %s is before",
+				f.Replica, applied.Format(time.RFC3339), f.Time.Format(time.RFC3339))
+		}
+
+		target, err := f.locate(ctx, status.RelayMasterLogFile, status.ExecMasterLogPos)
+		if err != nil {
+			return nil, err
+		}
+
+		if target == nil {
+			return nil, errors.Errorf("no transactions after %s are found in binlog of master from %s:%d",
+				f.Time.Format(time.RFC3339), status.RelayMasterLogFile, status.ExecMasterLogPos)
+		}
+
+		result.Target, until, targetGTID = target.String(), target.until(), target.GTID
+	}
+
+	err = repo.SetDelay(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("applying transactions of %s until %s, delay %s is removed", f.Replica, result.Target, result.Delay)
+
+	err = repo.StartSQLThreadUntil(ctx, until)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err = f.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result.File, result.Position = status.RelayMasterLogFile, status.ExecMasterLogPos
+	result.ExecutedGTIDSet = status.ExecutedGTIDSet
+
+	// SQL thread may be stopped after the target by other session
+	if targetGTID != "" {
+		err = f.checkGTID(targetGTID, status.ExecutedGTIDSet)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// checkGTID returns error if target transaction is in executed GTID set of replica
+func (f *FastForward) checkGTID(target, executed string) error {
+	set, err := gtid.Parse(target)
+	if err != nil || set.Count() != 1 {
+		return errors.Errorf("invalid target GTID %q, expected 'uuid:number'", target)
+	}
+
+	executedSet, err := gtid.Parse(executed)
+	if err != nil {
+		return errors.Wrapf(err, "invalid executed GTID set of %s", f.Replica)
+	}
+
+	if executedSet.Contains(set) {
+		return errors.Errorf("target transaction %s is applied on %s", target, f.Replica)
+	}
+
+	return nil
+}
+
+// wait waits until SQL thread of replica is stopped by UNTIL condition
+func (f *FastForward) wait(ctx context.Context) (*slave.Status, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := f.Replica.Slave().ShowStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.LastSQLErrno != 0 {
+			return nil, errors.Errorf("SQL thread of %s has failed before the target: [%d] %s",
+				f.Replica, status.LastSQLErrno, status.LastSQLError)
+		}
+
+		if !status.SQLRunning() {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(slave.ErrWaitTimeout, "replica %s", f.Replica)
+		case <-ticker.C:
+		}
+	}
+}
+
+// transaction represents the start of transaction in binlog of master
+type transaction struct {
+	GTID      string
+	File      string
+	Position  uint32
+	Timestamp time.Time
+}
+
+// String returns description of transaction
+func (t *transaction) String() string {
+	s := t.File + ":" + strconv.FormatUint(uint64(t.Position), 10)
+
+	if t.GTID != "" {
+		s = t.GTID + " at " + s
+	}
+
+	return s + " started at " + t.Timestamp.Format(time.RFC3339)
+}
+
+// until returns condition stopping SQL thread before the transaction,
+// the position of its start is the end of the previous transaction
+func (t *transaction) until() slave.Until {
+	if t.GTID != "" {
+		return slave.Until{BeforeGTIDs: t.GTID}
+	}
+
+	return slave.Until{File: t.File, Position: int(t.Position)}
+}
+
+// locate streams binlog of master from file:pos and returns the first transaction
+// with timestamp after f.Time, nil is returned if there is no such transaction
+func (f *FastForward) locate(ctx context.Context, file string, pos int) (*transaction, error) {
+	cfg := f.Config
+	cfg.NonBlock = true
+
+	conn, err := binlog.Dial(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	err = conn.RegisterSlave("", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	streamer, err := conn.Dump(file, uint32(pos))
+	if err != nil {
+		return nil, err
+	}
+
+	// reading from connection is interrupted by closing it
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+
+	target, err := firstAfter(streamer, f.Time)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return target, err
+}
+
+// events is a stream of binlog events with position of master
+type events interface {
+	Next() (*binlog.Event, error)
+	Position() binlog.Position
+}
+
+// states of transaction boundary detection
+const (
+	outside = iota
+	afterGTID
+	insideBegin
+)
+
+// firstAfter returns the first transaction of stream which starts with timestamp after t
+func firstAfter(stream events, t time.Time) (*transaction, error) {
+	state := outside
+
+	for {
+		e, err := stream.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Header.Artificial() {
+			continue
+		}
+
+		start := &transaction{
+			File:      stream.Position().File,
+			Position:  e.Header.LogPos - e.Header.EventSize,
+			Timestamp: time.Unix(int64(e.Header.Timestamp), 0).UTC(),
+		}
+
+		switch body := e.Body.(type) {
+		case *binlog.GTIDEvent:
+			state = afterGTID
+
+			if body.UUID != "" {
+				start.GTID = body.String()
+			}
+		case *binlog.QueryEvent:
+			current := state
+			query := strings.ToUpper(strings.TrimSpace(body.Query))
+
+			switch {
+			case query == "BEGIN":
+				state = insideBegin
+			case query == "COMMIT" || current != insideBegin:
+				// DDL is a transaction of single statement
+				state = outside
+			}
+
+			// transaction without GTID event starts with BEGIN or DDL
+			if current != outside {
+				continue
+			}
+		case *binlog.XIDEvent:
+			state = outside
+			continue
+		default:
+			continue
+		}
+
+		if start.Timestamp.After(t) {
+			return start, nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package dump
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+)
+
+// CoordinatesFile is name of file in dump directory with binlog position
+// of source read under the lock held during dump
+const CoordinatesFile = "__coordinates.json"
+
+// Coordinates represents binlog position of source consistent with dumped data
+type Coordinates struct {
+	File            string `json:"file"`
+	Position        int    `json:"position"`
+	ExecutedGTIDSet string `json:"executed_gtid_set,omitempty"`
+	// MasterHost and MasterPort are set if dump is taken from replica,
+	// File and Position are coordinates in binlog of its master then
+	MasterHost string    `json:"master_host,omitempty"`
+	MasterPort int       `json:"master_port,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// NewCoordinates returns coordinates of master status
+func NewCoordinates(status master.Status) Coordinates {
+	return Coordinates{
+		File:            status.File,
+		Position:        status.Position,
+		ExecutedGTIDSet: status.ExecutedGTIDSet,
+		Time:            time.Now().UTC(),
+	}
+}
+
+// NewReplicaCoordinates returns coordinates of master executed by replica
+func NewReplicaCoordinates(status slave.Status) Coordinates {
+	return Coordinates{
+		File:            status.RelayMasterLogFile,
+		Position:        status.ExecMasterLogPos,
+		ExecutedGTIDSet: status.ExecutedGTIDSet,
+		MasterHost:      status.MasterHost,
+		MasterPort:      int(status.MasterPort),
+		Time:            time.Now().UTC(),
+	}
+}
+
+// FromReplica returns true if dump is taken from replica
+func (c Coordinates) FromReplica() bool {
+	return c.MasterHost != ""
+}
+
+// IsZero returns true if position of source is unknown, for example binary log is disabled
+func (c Coordinates) IsZero() bool {
+	return c.File == "" && c.ExecutedGTIDSet == ""
+}
+
+// Status returns coordinates as master status
+func (c Coordinates) Status() master.Status {
+	return master.Status{File: c.File, Position: c.Position, ExecutedGTIDSet: c.ExecutedGTIDSet}
+}
+
+// WriteCoordinates writes coordinates to CoordinatesFile of dump directory
+func WriteCoordinates(dir string, c Coordinates) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to encode coordinates")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, CoordinatesFile), append(data, '\n'), 0644)
+
+	return errors.Wrap(err, "unable to write coordinates")
+}
+
+// ReadCoordinates reads CoordinatesFile of dump directory
+func ReadCoordinates(dir string) (Coordinates, error) {
+	c := Coordinates{}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, CoordinatesFile))
+	if os.IsNotExist(err) {
+		return c, errors.Errorf("dump %s has no recorded coordinates", dir)
+	}
+
+	if err != nil {
+		return c, errors.Wrap(err, "unable to read coordinates")
+	}
+
+	err = json.Unmarshal(data, &c)
+
+	return c, errors.Wrapf(err, "invalid coordinates in %s", dir)
+}
@@ -0,0 +1,28 @@
+package dump_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/testutils"
+)
+
+func TestCoordinates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dir)
+
+	_, err = dump.ReadCoordinates(dir)
+	testutils.AssertEqual(t, "missing", true, err != nil)
+
+	status := master.Status{File: "mysql-bin.000003", Position: 154, ExecutedGTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}
+	testutils.FatalErr(t, "WriteCoordinates", dump.WriteCoordinates(dir, dump.NewCoordinates(status)))
+
+	c, err := dump.ReadCoordinates(dir)
+	testutils.FatalErr(t, "ReadCoordinates", err)
+	testutils.AssertEqual(t, "status", status, c.Status())
+}
@@ -8,7 +8,11 @@ import (
 	"io"
 	"sync"
 
+	"github.com/partyzanex/repmy/pkg/mask"
+	"github.com/partyzanex/repmy/pkg/master"
 	"github.com/partyzanex/repmy/pkg/pool"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/partyzanex/repmy/pkg/slave"
 	"github.com/sirupsen/logrus"
 )
 
@@ -31,8 +35,23 @@ type Dumper struct {
 	NoDropTable bool
 	NoData      bool
 	Verbose     bool
-
-	repo *Repository
+	// FromReplica dumps replica with stopped SQL thread instead of locking it,
+	// coordinates are read from its slave status
+	FromReplica bool
+	// Mask anonymizes values of matched columns, rules must be compiled
+	Mask *mask.Rules
+	// Where restricts dumped rows by conditions of tables, tables absent in it are dumped entirely
+	Where map[string]string
+	// OnLock is called by DumpData when rows of source do not change anymore and before they are dumped,
+	// Where set by it is applied, so rows selected by conditions are consistent with dumped rows
+	OnLock func(ctx context.Context) error
+
+	repo        *Repository
+	coordinates Coordinates
+	// stopped is true if SQL thread of replica is stopped by StopReplica,
+	// restart is true if it was running before
+	stopped bool
+	restart bool
 }
 
 func (d *Dumper) Repo() *Repository {
@@ -47,6 +66,7 @@ func (d *Dumper) DumpDLL(ctx context.Context, w io.WriteCloser, tables ...string
 	defer closeWriter(w, err)
 
 	buf := &bytes.Buffer{}
+	buf.Write(setSQLMode)
 
 	toDump, err := d.GetTablesForDump(ctx, tables...)
 	if err != nil {
@@ -94,31 +114,182 @@ func (d *Dumper) DumpData(ctx context.Context, w io.WriteCloser, tables ...strin
 		return
 	}
 
+	// rows are not dumped, so no lock and coordinates are needed
+	if d.NoData {
+		d.dumpData(ctx, w, toDump...)
+		return
+	}
+
+	if d.FromReplica {
+		return d.dumpReplica(ctx, w, toDump)
+	}
+
 	if d.Verbose {
 		logrus.Infof("flush tables with read lock")
 	}
 
-	_, err = d.Repo().FlushTablesWithReadLock(ctx)
+	// the lock is held on dedicated connection during the whole dump,
+	// so binlog position read under it is consistent with dumped data
+	lock, err := master.New(d.Source).ReadLock(ctx, master.LockOptions{Mode: master.LockFlushTables})
 	if err != nil {
 		err = fmt.Errorf("flush tables with read lock failed: %s", err)
 		return
 	}
 
-	defer func() {
-		_, err := d.Repo().UnlockTables(ctx)
+	d.coordinates = NewCoordinates(lock.Status())
+
+	err = d.onLock(ctx, toDump)
+	if err != nil {
+		_ = lock.Unlock()
+		return
+	}
+
+	d.dumpData(ctx, w, toDump...)
+
+	err = lock.Unlock()
+	if err != nil {
+		err = fmt.Errorf("read lock was released before the end of dump: %s", err)
+		return
+	}
+
+	if d.Verbose {
+		logrus.Infof("unlock tables for read")
+	}
+
+	return
+}
+
+// StopReplica stops SQL thread of replica source and reads executed position of its master,
+// it is called before DumpDLL, so schema and data are consistent with the position.
+// StartReplica must be called after the dump.
+func (d *Dumper) StopReplica(ctx context.Context) error {
+	repo := slave.New(d.Source)
+
+	status, err := repo.ShowStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("source is not a replica: %s", err)
+	}
+
+	if status.SQLRunning() {
+		if d.Verbose {
+			logrus.Infof("stop slave SQL thread")
+		}
+
+		err = repo.StopSQLThread(ctx)
 		if err != nil {
-			logrus.Error(err)
-			return
+			return err
 		}
 
-		if d.Verbose {
-			logrus.Infof("unlock tables for read")
+		d.restart = true
+
+		// position is read after stop, the thread does not apply events anymore
+		status, err = repo.ShowStatus(ctx)
+		if err != nil {
+			return err
 		}
-	}()
+	}
 
-	d.dumpData(ctx, w, toDump...)
+	d.stopped = true
+	d.coordinates = NewReplicaCoordinates(*status)
 
-	return
+	return nil
+}
+
+// StartReplica starts SQL thread of replica stopped by StopReplica
+func (d *Dumper) StartReplica(ctx context.Context) error {
+	if !d.restart {
+		return nil
+	}
+
+	err := slave.New(d.Source).StartSQLThread(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to start slave SQL thread: %s", err)
+	}
+
+	d.restart = false
+
+	if d.Verbose {
+		logrus.Infof("start slave SQL thread")
+	}
+
+	return nil
+}
+
+// dumpReplica dumps tables while SQL thread of replica is stopped,
+// so executed position of master is consistent with dumped data
+func (d *Dumper) dumpReplica(ctx context.Context, w io.Writer, tables []*Table) (err error) {
+	if !d.stopped {
+		err = d.StopReplica(ctx)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			errStart := d.StartReplica(context.Background())
+			if errStart != nil && err == nil {
+				err = errStart
+			}
+		}()
+	}
+
+	err = d.onLock(ctx, tables)
+	if err != nil {
+		return err
+	}
+
+	d.dumpData(ctx, w, tables...)
+
+	return nil
+}
+
+// onLock calls OnLock and applies Where to tables
+func (d *Dumper) onLock(ctx context.Context, tables []*Table) error {
+	if d.OnLock == nil {
+		return nil
+	}
+
+	err := d.OnLock(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		table.Where = d.Where[table.Name]
+	}
+
+	return nil
+}
+
+// Coordinates returns binlog position of source read by DumpData
+func (d *Dumper) Coordinates() Coordinates {
+	return d.coordinates
+}
+
+// ValidateMask checks that every rule of Mask matches a column of dumped tables
+func (d *Dumper) ValidateMask(ctx context.Context, tables ...string) error {
+	if d.Mask == nil {
+		return nil
+	}
+
+	toDump, err := d.GetTablesForDump(ctx, tables...)
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string][]string, len(toDump))
+
+	for _, table := range toDump {
+		if table.Type != BaseTable {
+			continue
+		}
+
+		columns[table.Name], err = d.Repo().GetTableColumns(ctx, *table)
+		if err != nil {
+			return fmt.Errorf("unable to get columns of %s: %s", table.Name, err)
+		}
+	}
+
+	return d.Mask.Validate(columns)
 }
 
 func (d *Dumper) GetTablesForDump(ctx context.Context, tables ...string) ([]*Table, error) {
@@ -149,6 +320,10 @@ func (d *Dumper) GetTablesForDump(ctx context.Context, tables ...string) ([]*Tab
 		toDump = tbs
 	}
 
+	for _, table := range toDump {
+		table.Where = d.Where[table.Name]
+	}
+
 	return toDump, nil
 }
 
@@ -168,9 +343,10 @@ func (d *Dumper) dumpData(ctx context.Context, w io.Writer, tables ...*Table) {
 	processes := &pool.ProcessPool{}
 	errors := &pool.ProcessPool{}
 
-	errors.RunProcess(ctx, func(ctx context.Context) error {
-		errs := processes.Errors()
+	// errors channel is created before processes, so Wait always closes it
+	errs := processes.Errors()
 
+	errors.RunProcess(ctx, func(ctx context.Context) error {
 		for err := range errs {
 			logrus.Errorf("process error: %s", err)
 		}
@@ -203,6 +379,7 @@ func (d *Dumper) processDump(tables <-chan *Table, w io.Writer) pool.Process {
 			}
 
 			table.Columns = columns
+			table.Masks = d.Mask.Columns(table.Name, columns)
 
 			err = d.dumpTable(ctx, w, table)
 			if err != nil {
@@ -224,6 +401,10 @@ var (
 	comma             = []byte(",")
 	commaSpace        = []byte(", ")
 	eol               = []byte(";\n")
+
+	// values are always escaped with backslashes, so every dump file sets SQL mode
+	// without NO_BACKSLASH_ESCAPES before its statements regardless of mode of source and target
+	setSQLMode = []byte("SET SESSION sql_mode = 'NO_AUTO_VALUE_ON_ZERO';\n")
 )
 
 func (d *Dumper) dumpTable(ctx context.Context, w io.Writer, table *Table) (err error) {
@@ -231,7 +412,7 @@ func (d *Dumper) dumpTable(ctx context.Context, w io.Writer, table *Table) (err
 		wg  = &sync.WaitGroup{}
 		buf = &bytes.Buffer{}
 
-		insert  = []byte(fmt.Sprintf("INSERT INTO `%s` VALUES ", table.Name))
+		insert  = []byte("INSERT INTO " + quote.Ident(table.Name) + " VALUES ")
 		max     = d.MaxRows
 		current = 0
 	)
@@ -249,6 +430,7 @@ func (d *Dumper) dumpTable(ctx context.Context, w io.Writer, table *Table) (err
 
 	values, errors := d.Repo().GetValues(ctx, *table, d.Buffer, d.Workers)
 
+	buf.Write(setSQLMode)
 	buf.Write(insert)
 	wg.Add(1)
 
@@ -309,7 +491,7 @@ Results:
 
 func (d *Dumper) writeTableHeaders(w io.Writer, table *Table) error {
 	if !d.NoHeaders {
-		str := fmt.Sprintf("--\n-- Structure for table `%s`\n--\n\n", table.Name)
+		str := fmt.Sprintf("--\n-- Structure for table %s\n--\n\n", quote.Ident(table.Name))
 
 		_, err := io.WriteString(w, str)
 		if err != nil {
@@ -322,7 +504,7 @@ func (d *Dumper) writeTableHeaders(w io.Writer, table *Table) error {
 
 func (d *Dumper) writeDropTable(w io.Writer, table *Table) error {
 	if !d.NoDropTable {
-		str := fmt.Sprintf("DROP TABLE IF EXISTS `%s`;\n", table.Name)
+		str := fmt.Sprintf("DROP TABLE IF EXISTS %s;\n", quote.Ident(table.Name))
 
 		_, err := io.WriteString(w, str)
 		if err != nil {
@@ -0,0 +1,152 @@
+package dump_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/mask"
+	"github.com/partyzanex/testutils"
+)
+
+type nopCloser struct {
+	bytes.Buffer
+}
+
+func (*nopCloser) Close() error {
+	return nil
+}
+
+func TestDumper_DumpData_FromReplica(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	columns := []string{
+		"Master_Host", "Master_Port", "Relay_Master_Log_File", "Exec_Master_Log_Pos",
+		"Slave_SQL_Running", "Executed_Gtid_Set",
+	}
+
+	mock.ExpectQuery("SHOW FULL TABLES").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_db", "Table_type"}))
+	mock.ExpectQuery("show slave status").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("10.0.0.1", 3307, "binlog.000004", 1200, "Yes", "uuid:1-100"))
+	mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("show slave status").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("10.0.0.1", 3307, "binlog.000004", 1540, "No", "uuid:1-101"))
+	mock.ExpectExec("START SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	d := &dump.Dumper{Source: db, Threads: 1, FromReplica: true}
+	locked := 0
+
+	d.OnLock = func(ctx context.Context) error {
+		// SQL thread is stopped already
+		locked = d.Coordinates().Position
+		return nil
+	}
+
+	err = d.DumpData(context.Background(), &nopCloser{})
+	testutils.FatalErr(t, "DumpData", err)
+	testutils.AssertEqual(t, "on lock", 1540, locked)
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+
+	c := d.Coordinates()
+	testutils.AssertEqual(t, "from replica", true, c.FromReplica())
+	testutils.AssertEqual(t, "master", "10.0.0.1:3307", c.MasterHost+":"+strconv.Itoa(c.MasterPort))
+	testutils.AssertEqual(t, "position", "binlog.000004:1540", c.File+":"+strconv.Itoa(c.Position))
+	testutils.AssertEqual(t, "gtid", "uuid:1-101", c.ExecutedGTIDSet)
+}
+
+func TestDumper_StopReplica(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	columns := []string{"Relay_Master_Log_File", "Exec_Master_Log_Pos", "Slave_SQL_Running"}
+
+	mock.ExpectQuery("show slave status").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("binlog.000004", 1200, "Yes"))
+	mock.ExpectExec("STOP SLAVE SQL_THREAD").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("show slave status").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("binlog.000004", 1540, "No"))
+	// SQL thread is not stopped again by DumpData
+	mock.ExpectQuery("SHOW FULL TABLES").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_db", "Table_type"}))
+	mock.ExpectExec("START SLAVE SQL_THREAD").WillReturnError(errors.New("start failed"))
+
+	d := &dump.Dumper{Source: db, Threads: 1, FromReplica: true}
+	ctx := context.Background()
+
+	testutils.FatalErr(t, "StopReplica", d.StopReplica(ctx))
+	testutils.FatalErr(t, "DumpData", d.DumpData(ctx, &nopCloser{}))
+	testutils.AssertEqual(t, "start error", true, d.StartReplica(ctx) != nil)
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+
+	testutils.AssertEqual(t, "position", 1540, d.Coordinates().Position)
+}
+
+func TestRepository_GetValues_Escape(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := dump.New(db)
+
+	mock.ExpectQuery("SELECT `name` FROM `tbl`").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("it's\n\\").AddRow(nil))
+
+	table := dump.Table{
+		Name:    "tbl",
+		Type:    dump.BaseTable,
+		Columns: []string{"name"},
+	}
+
+	results, errs := repo.GetValues(context.Background(), table, 0, 1)
+
+	go func() {
+		for err := range errs {
+			testutils.Err(t, "err", err)
+		}
+	}()
+
+	values := make([]string, 0)
+	for raw := range results {
+		values = append(values, string(raw[0]))
+	}
+
+	testutils.AssertEqual(t, "values", `'it\'s\n\\',NULL`, strings.Join(values, ","))
+}
+
+func TestRepository_GetValues_Mask(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	rules := &mask.Rules{Rules: []mask.Rule{{Column: "tbl.name", Transform: mask.Constant, Value: "x"}}}
+	testutils.FatalErr(t, "Compile", rules.Compile())
+
+	mock.ExpectQuery("SELECT `name` FROM `tbl`").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("").AddRow(nil))
+
+	table := dump.Table{
+		Name:    "tbl",
+		Type:    dump.BaseTable,
+		Columns: []string{"name"},
+		Masks:   rules.Columns("tbl", []string{"name"}),
+	}
+
+	results, errs := dump.New(db).GetValues(context.Background(), table, 0, 1)
+
+	go func() {
+		for err := range errs {
+			testutils.Err(t, "err", err)
+		}
+	}()
+
+	values := make([]string, 0)
+	for raw := range results {
+		values = append(values, string(raw[0]))
+	}
+
+	// empty string is masked, NULL is kept
+	testutils.AssertEqual(t, "values", "'x',NULL", strings.Join(values, ","))
+}
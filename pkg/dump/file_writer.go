@@ -16,8 +16,31 @@ var (
 	endSuffix    = []byte("\n--\n-- end of data\n--\n")
 
 	prefixLength = len(insertPrefix)
+
+	// keeps files of tables with odd names inside of dump directory
+	fileNameReplacer = bytesReplacer{
+		'/':  []byte("@002f"),
+		'\\': []byte("@005c"),
+		0:    []byte("@0000"),
+	}
 )
 
+type bytesReplacer map[byte][]byte
+
+func (r bytesReplacer) Replace(b []byte) []byte {
+	dest := make([]byte, 0, len(b))
+
+	for _, c := range b {
+		if rep, ok := r[c]; ok {
+			dest = append(dest, rep...)
+		} else {
+			dest = append(dest, c)
+		}
+	}
+
+	return dest
+}
+
 type DirWriter interface {
 	io.WriteCloser
 
@@ -119,29 +142,41 @@ func (w *dirWriter) getFile(fileName string) (io.WriteCloser, error) {
 }
 
 func (*dirWriter) parseFileName(b []byte) ([]byte, error) {
-	start, end := bytes.Index(b, insertPrefix), 0
-
+	start := bytes.Index(b, insertPrefix)
 	if start < 0 {
 		return nil, fmt.Errorf("unable to parse start of file name")
 	}
 
 	start += prefixLength
 
-	for i, char := range b[start:] {
-		if char == '`' {
-			end = i + start
+	var (
+		name  = make([]byte, 0, 64)
+		ended = false
+	)
+
+	// table name is quoted identifier, backticks inside are doubled
+	for i := start; i < len(b); i++ {
+		if b[i] == '`' {
+			if i+1 < len(b) && b[i+1] == '`' {
+				name = append(name, '`')
+				i++
+
+				continue
+			}
+
+			ended = true
+
 			break
 		}
+
+		name = append(name, b[i])
 	}
 
-	if end == 0 {
+	if !ended || len(name) == 0 {
 		return nil, fmt.Errorf("unable to parse end of file name")
 	}
 
-	n := make([]byte, end-start)
-	copy(n, b[start:end])
-
-	return append(n, fileExt...), nil
+	return append(fileNameReplacer.Replace(name), fileExt...), nil
 }
 
 type fileWriter struct {
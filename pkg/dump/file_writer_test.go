@@ -20,6 +20,8 @@ func TestFileWriter_Write(t *testing.T) {
 		{Input: []byte("INSERT INTO `table` VALUES (1, 'test')"), Err: false},
 		{Input: []byte("--\ntable's data [count=8876]\n\nINSERT INTO `table` VALUES (1, 'test');\n"), Err: false},
 		{Input: []byte("UPDATE `table` SET name = 'test'"), Err: true},
+		{Input: []byte("INSERT INTO `ta``b/le` VALUES (1, 'test')"), Err: false},
+		{Input: []byte("INSERT INTO `ta``ble"), Err: true},
 	}
 
 	dirName := testutils.RandomString(12)
@@ -0,0 +1,212 @@
+package dump
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DLLFile is name of file with structure of tables written by repmydump
+const DLLFile = "__dll.sql"
+
+// Load executes statements of dump directory written by repmydump on db:
+// structure of tables first, then data of tables, UsersFile is skipped
+func Load(ctx context.Context, db *sql.DB, dir string, verbose bool) error {
+	files, err := dumpFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get connection: %s", err)
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SET SESSION FOREIGN_KEY_CHECKS = 0, SESSION UNIQUE_CHECKS = 0")
+	if err != nil {
+		return fmt.Errorf("unable to disable foreign key checks: %s", err)
+	}
+
+	for _, file := range files {
+		if verbose {
+			logrus.Infof("loading %s", file)
+		}
+
+		err = loadFile(ctx, conn, filepath.Join(dir, file))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dumpFiles returns .sql and .sql.gz files of dump, DLLFile is the first
+func dumpFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read dump directory: %s", err)
+	}
+
+	files := make([]string, 0, len(infos))
+	dll := ""
+
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !(strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, ".sql.gz")) {
+			continue
+		}
+
+		if strings.TrimSuffix(name, ".gz") == UsersFile {
+			continue
+		}
+
+		if strings.TrimSuffix(name, ".gz") == DLLFile {
+			dll = name
+			continue
+		}
+
+		files = append(files, name)
+	}
+
+	if dll == "" {
+		return nil, fmt.Errorf("%s is not found in %s", DLLFile, dir)
+	}
+
+	sort.Strings(files)
+
+	return append([]string{dll}, files...), nil
+}
+
+func loadFile(ctx context.Context, conn *sql.Conn, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %s", path, err)
+	}
+
+	defer f.Close()
+
+	var r io.Reader = f
+
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %s", path, err)
+		}
+
+		defer gz.Close()
+
+		r = gz
+	}
+
+	return SplitStatements(r, func(statement string) error {
+		_, err := conn.ExecContext(ctx, statement)
+		if err != nil {
+			n := len(statement)
+			if n > 100 {
+				n = 100
+			}
+
+			return fmt.Errorf("unable to execute %s...: %s", statement[:n], err)
+		}
+
+		return nil
+	})
+}
+
+// SplitStatements calls fn for every statement of r, statements are terminated by ';',
+// comment lines starting with '--' between statements are skipped.
+// Backslash escapes quotes inside of strings unless SQL mode set by the statements has NO_BACKSLASH_ESCAPES
+func SplitStatements(r io.Reader, fn func(statement string) error) error {
+	br := bufio.NewReaderSize(r, 1<<20)
+	statement := strings.Builder{}
+
+	var (
+		quote              byte
+		escaped            bool
+		noBackslashEscapes bool
+	)
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("unable to read statements: %s", err)
+		}
+
+		if statement.Len() == 0 && (strings.HasPrefix(line, "--") || strings.TrimSpace(line) == "") {
+			if err == io.EOF {
+				return nil
+			}
+
+			continue
+		}
+
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+
+			switch {
+			case escaped:
+				escaped = false
+			case quote != 0:
+				if c == '\\' && quote != '`' && !noBackslashEscapes {
+					escaped = true
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"' || c == '`':
+				quote = c
+			case c == ';':
+				statement.WriteString(line[:i])
+
+				s := strings.TrimSpace(statement.String())
+				statement.Reset()
+
+				if s != "" {
+					if errFn := fn(s); errFn != nil {
+						return errFn
+					}
+
+					if mode, ok := setsSQLMode(s); ok {
+						noBackslashEscapes = strings.Contains(mode, "NO_BACKSLASH_ESCAPES")
+					}
+				}
+
+				line = line[i+1:]
+				i = -1
+			}
+		}
+
+		if statement.Len() > 0 || strings.TrimSpace(line) != "" {
+			statement.WriteString(line)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if s := strings.TrimSpace(statement.String()); s != "" {
+		return fn(s)
+	}
+
+	return nil
+}
+
+// setsSQLMode returns upper cased statement if it is SET statement of sql_mode
+func setsSQLMode(statement string) (string, bool) {
+	s := strings.ToUpper(statement)
+
+	return s, strings.HasPrefix(s, "SET ") && strings.Contains(s, "SQL_MODE")
+}
@@ -0,0 +1,140 @@
+package dump_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/testutils"
+)
+
+func TestSplitStatements(t *testing.T) {
+	input := "-- dump of db\n\n" +
+		"CREATE TABLE `a;b` (id INT);\n" +
+		"INSERT INTO t VALUES (1,'x;y'),(2,'it\\'s;'),\n(3,\"q;\");\n" +
+		"-- between statements\n" +
+		"SELECT 1; SELECT 2;\n" +
+		"SET SESSION sql_mode = 'NO_BACKSLASH_ESCAPES';\n" +
+		"INSERT INTO t VALUES ('a\\'), ('b''c;');\n" +
+		"SET sql_mode = '';\n" +
+		"INSERT INTO t VALUES ('a\\'');\n"
+
+	statements := make([]string, 0)
+
+	err := dump.SplitStatements(strings.NewReader(input), func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	})
+	testutils.FatalErr(t, "SplitStatements", err)
+
+	testutils.AssertEqual(t, "statements", strings.Join([]string{
+		"CREATE TABLE `a;b` (id INT)",
+		"INSERT INTO t VALUES (1,'x;y'),(2,'it\\'s;'),\n(3,\"q;\")",
+		"SELECT 1",
+		"SELECT 2",
+		"SET SESSION sql_mode = 'NO_BACKSLASH_ESCAPES'",
+		"INSERT INTO t VALUES ('a\\'), ('b''c;')",
+		"SET sql_mode = ''",
+		"INSERT INTO t VALUES ('a\\'')",
+	}, "|"), strings.Join(statements, "|"))
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"b.sql":      "INSERT INTO b VALUES (1);\n",
+		"a.sql":      "INSERT INTO a VALUES (1);\n",
+		dump.DLLFile: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+		"notes.txt":  "not loaded",
+	}
+
+	for name, content := range files {
+		testutils.FatalErr(t, name, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New", err)
+
+	defer db.Close()
+
+	mock.ExpectExec("SET SESSION FOREIGN_KEY_CHECKS = 0, SESSION UNIQUE_CHECKS = 0").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	for _, statement := range []string{
+		"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)", "INSERT INTO a VALUES (1)", "INSERT INTO b VALUES (1)",
+	} {
+		mock.ExpectExec(statement).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	testutils.FatalErr(t, "Load", dump.Load(context.Background(), db, dir, false))
+	testutils.FatalErr(t, "ExpectationsWereMet", mock.ExpectationsWereMet())
+}
+
+func TestLoad_Dump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dump")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dir)
+
+	source, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New", err)
+
+	defer source.Close()
+
+	tables := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"Tables_in_db", "Table_type"}).AddRow("tbl", dump.BaseTable)
+	}
+	status := []string{"Relay_Master_Log_File", "Exec_Master_Log_Pos", "Slave_SQL_Running"}
+
+	// sql_mode of source is not read, values are escaped with backslashes even for NO_BACKSLASH_ESCAPES
+	mock.ExpectQuery("SHOW FULL TABLES").WillReturnRows(tables())
+	mock.ExpectQuery("SHOW CREATE TABLE `tbl`").WillReturnRows(sqlmock.NewRows([]string{"Table", "Create Table"}).
+		AddRow("tbl", "CREATE TABLE `tbl` (`name` text)"))
+	mock.ExpectQuery("SHOW FULL TABLES").WillReturnRows(tables())
+	mock.ExpectQuery("show slave status").WillReturnRows(sqlmock.NewRows(status).AddRow("binlog.000001", 4, "No"))
+	mock.ExpectQuery("SELECT \\* FROM `tbl` LIMIT 1").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT `name` FROM `tbl`").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("it's;\n\\"))
+
+	d := &dump.Dumper{Source: source, Threads: 1, FromReplica: true}
+	ctx := context.Background()
+
+	dll, err := dump.NewFileWriter(dir, dump.DLLFile, false)
+	testutils.FatalErr(t, "NewFileWriter", err)
+	testutils.FatalErr(t, "DumpDLL", d.DumpDLL(ctx, dll))
+
+	data, err := dump.NewDirWriter(dir, false)
+	testutils.FatalErr(t, "NewDirWriter", err)
+	testutils.FatalErr(t, "DumpData", d.DumpData(ctx, data))
+	testutils.FatalErr(t, "source ExpectationsWereMet", mock.ExpectationsWereMet())
+
+	// target has default sql_mode
+	target, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New", err)
+
+	defer target.Close()
+
+	for _, statement := range []string{
+		"SET SESSION FOREIGN_KEY_CHECKS = 0, SESSION UNIQUE_CHECKS = 0",
+		"SET SESSION sql_mode = 'NO_AUTO_VALUE_ON_ZERO'",
+		"DROP TABLE IF EXISTS `tbl`",
+		"CREATE TABLE `tbl` (`name` text)",
+		"SET SESSION sql_mode = 'NO_AUTO_VALUE_ON_ZERO'",
+		"INSERT INTO `tbl` VALUES ('it\\'s;\\n\\\\')",
+	} {
+		mock.ExpectExec(statement).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	testutils.FatalErr(t, "Load", dump.Load(ctx, target, dir, false))
+	testutils.FatalErr(t, "target ExpectationsWereMet", mock.ExpectationsWereMet())
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
+
 	"github.com/partyzanex/repmy/pkg/pool"
+	"github.com/partyzanex/repmy/pkg/quote"
 	"github.com/sirupsen/logrus"
-	"math"
 )
 
 type Repository struct {
@@ -20,11 +22,11 @@ func New(db *sql.DB) *Repository {
 }
 
 func (repo *Repository) LockRead(ctx context.Context, table string) (sql.Result, error) {
-	return repo.db.ExecContext(ctx, fmt.Sprintf("LOCK TABLES `%s` READ", table))
+	return repo.db.ExecContext(ctx, "LOCK TABLES "+quote.Ident(table)+" READ")
 }
 
 func (repo *Repository) FlushTable(ctx context.Context, table string) (sql.Result, error) {
-	return repo.db.ExecContext(ctx, fmt.Sprintf("FLUSH TABLES `%s`", table))
+	return repo.db.ExecContext(ctx, "FLUSH TABLES "+quote.Ident(table))
 }
 
 func (repo *Repository) UnlockTables(ctx context.Context) (sql.Result, error) {
@@ -75,7 +77,11 @@ type Config struct {
 }
 
 func (repo *Repository) Count(ctx context.Context, table Table) (count uint64, err error) {
-	query := fmt.Sprintf("SELECT COUNT(*) FROM `%s`", table.Name)
+	query := "SELECT COUNT(*) FROM " + quote.Ident(table.Name)
+	if table.Where != "" {
+		query += " WHERE " + table.Where
+	}
+
 	row := repo.db.QueryRowContext(ctx, query)
 	err = row.Scan(&count)
 
@@ -83,7 +89,7 @@ func (repo *Repository) Count(ctx context.Context, table Table) (count uint64, e
 }
 
 func (repo *Repository) GetCreateTable(ctx context.Context, table Table) (string, error) {
-	row := repo.db.QueryRowContext(ctx, fmt.Sprintf("SHOW CREATE TABLE `%s`", table.Name))
+	row := repo.db.QueryRowContext(ctx, "SHOW CREATE TABLE "+quote.Ident(table.Name))
 
 	var tableName, dll string
 
@@ -113,7 +119,7 @@ func (repo *Repository) GetCreateTable(ctx context.Context, table Table) (string
 }
 
 func (repo *Repository) GetTableColumns(ctx context.Context, table Table) ([]string, error) {
-	query := fmt.Sprintf("SELECT * FROM `%s` LIMIT 1", table.Name)
+	query := "SELECT * FROM " + quote.Ident(table.Name) + " LIMIT 1"
 
 	rows, err := repo.db.QueryContext(ctx, query)
 	if err != nil {
@@ -130,9 +136,42 @@ func (repo *Repository) GetTableColumns(ctx context.Context, table Table) ([]str
 	return columns, nil
 }
 
+// GetPrimaryKey returns columns of primary key in index order,
+// returns empty slice if table has no primary key
+func (repo *Repository) GetPrimaryKey(ctx context.Context, table Table) ([]string, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+SELECT COLUMN_NAME FROM information_schema.STATISTICS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = 'PRIMARY'
+ORDER BY SEQ_IN_INDEX`, table.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	columns := make([]string, 0)
+
+	for rows.Next() {
+		var column string
+
+		err = rows.Scan(&column)
+		if err != nil {
+			return nil, err
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
 // todo: replace query to SHOW COLUMNS FROM table
 func (repo *Repository) GetSelectQuery(table Table, limit, offset int) string {
-	query := fmt.Sprintf("SELECT %s FROM `%s`", table.GetColumns(), table.Name)
+	query := fmt.Sprintf("SELECT %s FROM %s", table.GetColumns(), quote.Ident(table.Name))
+
+	if table.Where != "" {
+		query += " WHERE " + table.Where
+	}
 
 	if limit > 0 {
 		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
@@ -142,8 +181,7 @@ func (repo *Repository) GetSelectQuery(table Table, limit, offset int) string {
 }
 
 var (
-	null  = []byte("NULL")
-	quote = []byte("'")
+	null = []byte("NULL")
 )
 
 func (repo *Repository) GetValues(ctx context.Context, table Table, buffer, workers int) (<-chan [][]byte, <-chan error) {
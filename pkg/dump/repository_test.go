@@ -4,12 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strconv"
 	"sync"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/quote"
 	"github.com/partyzanex/testutils"
 )
 
@@ -241,8 +243,7 @@ func BenchmarkRepository_GetValues(b *testing.B) {
 }
 
 var (
-	null  = []byte("NULL")
-	quote = []byte("'")
+	null = []byte("NULL")
 )
 
 func BenchmarkRepository_GetValues2(b *testing.B) {
@@ -341,8 +342,7 @@ func rowsScanAndWrite(rows *sql.Rows, results chan<- [][]byte, args []interface{
 			val := null
 
 			if col != nil {
-				val = append(quote, dump.Escape(*col)...)
-				val = append(val, quote...)
+				val = []byte(quote.String(string(*col)))
 			}
 
 			raw[i] = val
@@ -432,3 +432,18 @@ func BenchmarkRepository_GetValues3(b *testing.B) {
 
 	wg.Wait()
 }
+
+func TestRepository_GetPrimaryKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := dump.New(db)
+
+	mock.ExpectQuery("SELECT COLUMN_NAME FROM information_schema.STATISTICS").WithArgs("orders").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("shop_id").AddRow("id"))
+
+	key, err := repo.GetPrimaryKey(context.Background(), dump.Table{Name: "orders"})
+	testutils.FatalErr(t, "repo.GetPrimaryKey", err)
+
+	testutils.AssertEqual(t, "key", "[shop_id id]", fmt.Sprint(key))
+}
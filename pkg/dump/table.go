@@ -1,6 +1,9 @@
 package dump
 
-import "strings"
+import (
+	"github.com/partyzanex/repmy/pkg/mask"
+	"github.com/partyzanex/repmy/pkg/quote"
+)
 
 type Table struct {
 	Name string
@@ -8,6 +11,10 @@ type Table struct {
 
 	Count   uint64
 	Columns []string
+	// Masks are transforms of Columns applied to dumped rows, nil if table is not masked
+	Masks []mask.Transform
+	// Where restricts dumped rows if it is not empty
+	Where string
 }
 
 func (table Table) GetColumns() string {
@@ -15,7 +22,7 @@ func (table Table) GetColumns() string {
 		return "*"
 	}
 
-	return "`" + strings.Join(table.Columns, "`, `") + "`"
+	return quote.Idents(table.Columns...)
 }
 
 const (
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/partyzanex/repmy/pkg/mask"
+	"github.com/partyzanex/repmy/pkg/quote"
 	"github.com/sirupsen/logrus"
 )
 
@@ -66,9 +68,12 @@ func (t *task) Run(ctx context.Context) error {
 		for i, col := range values {
 			val := null
 
+			if table.Masks != nil && table.Masks[i] != nil {
+				col = masked(table.Masks[i], col)
+			}
+
 			if col != nil {
-				val = append(quote, Escape(*col)...)
-				val = append(val, quote...)
+				val = []byte(quote.String(string(*col)))
 			}
 
 			raw[i] = val
@@ -80,3 +85,21 @@ func (t *task) Run(ctx context.Context) error {
 
 	return nil
 }
+
+// masked applies transform to scanned value, nil is NULL
+func masked(transform mask.Transform, col *sql.RawBytes) *sql.RawBytes {
+	var value []byte
+
+	if col != nil {
+		value = *col
+	}
+
+	value, null := transform(value, col == nil)
+	if null {
+		return nil
+	}
+
+	raw := sql.RawBytes(value)
+
+	return &raw
+}
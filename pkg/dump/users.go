@@ -0,0 +1,282 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/sirupsen/logrus"
+)
+
+// UsersFile is name of file with accounts and grants written by repmydump,
+// it is not executed by Load: accounts of replica are replicated from master
+const UsersFile = "__users.sql"
+
+// SystemUsers are names of accounts which exist on every server,
+// the empty name is anonymous account
+var SystemUsers = []string{"root", "mysql.sys", "mysql.session", "mysql.infoschema", "mariadb.sys",
+	"debian-sys-maint", ""}
+
+// Account represents user or role of server
+type Account struct {
+	User, Host string
+	// Role is true for role of MariaDB, it is named without host
+	Role bool
+}
+
+// String returns account as 'user'@'host' or 'role' for role of MariaDB
+func (a Account) String() string {
+	if a.Role {
+		return quote.Ident(a.User)
+	}
+
+	return quote.Ident(a.User) + "@" + quote.Ident(a.Host)
+}
+
+// UserFilter selects accounts for dump by patterns 'user@host' or 'user'
+// in syntax of path.Match, excluded patterns are checked first
+type UserFilter struct {
+	Include []string
+	Exclude []string
+	// System includes SystemUsers
+	System bool
+}
+
+// Match returns true if account is selected
+func (f UserFilter) Match(a Account) bool {
+	if match(f.Exclude, a) {
+		return false
+	}
+
+	if !f.System && isSystem(a) && !match(f.Include, a) {
+		return false
+	}
+
+	return len(f.Include) == 0 || match(f.Include, a)
+}
+
+func match(patterns []string, a Account) bool {
+	for _, pattern := range patterns {
+		value := a.User
+		if strings.Contains(pattern, "@") {
+			value += "@" + a.Host
+		}
+
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isSystem(a Account) bool {
+	for _, name := range SystemUsers {
+		if a.User == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	createUser  = regexp.MustCompile(`^(?i)CREATE USER `)
+	defaultRole = regexp.MustCompile(` DEFAULT ROLE .*? REQUIRE `)
+)
+
+// DumpUsers writes accounts selected by filter and their grants to w as idempotent statements:
+// CREATE USER IF NOT EXISTS with password hashes, GRANT statements and default roles.
+// Accounts are created before grants, so roles granted to users exist.
+func (d *Dumper) DumpUsers(ctx context.Context, w io.Writer, filter UserFilter) error {
+	conn, err := d.Source.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get connection: %s", err)
+	}
+
+	defer conn.Close()
+
+	var raw string
+
+	err = conn.QueryRowContext(ctx, "SELECT VERSION()").Scan(&raw)
+	if err != nil {
+		return fmt.Errorf("unable to get version: %s", err)
+	}
+
+	version, err := mysql.ParseVersion(raw)
+	if err != nil {
+		return err
+	}
+
+	if !version.MariaDB && !version.AtLeast(5, 7, 6) {
+		return fmt.Errorf("dump of users requires SHOW CREATE USER of MySQL 5.7.6+, server has %s", raw)
+	}
+
+	// binary hashes of caching_sha2_password are printed as hex literals
+	if !version.MariaDB && version.AtLeast(8, 0, 17) {
+		_, err = conn.ExecContext(ctx, "SET SESSION print_identified_with_as_hex = ON")
+		if err != nil {
+			return fmt.Errorf("unable to set print_identified_with_as_hex: %s", err)
+		}
+	}
+
+	accounts, err := getAccounts(ctx, conn, filter, version)
+	if err != nil {
+		return err
+	}
+
+	if d.Verbose {
+		logrus.Infof("dump %d accounts", len(accounts))
+	}
+
+	creates, grants := &bytes.Buffer{}, &bytes.Buffer{}
+
+	for _, a := range accounts {
+		// SHOW CREATE USER fails for roles of MariaDB
+		if a.Role {
+			fmt.Fprintf(creates, "CREATE ROLE IF NOT EXISTS %s;\n", a)
+		} else {
+			var statement string
+
+			err = conn.QueryRowContext(ctx, "SHOW CREATE USER "+a.String()).Scan(&statement)
+			if err != nil {
+				return fmt.Errorf("unable to show create user %s: %s", a, err)
+			}
+
+			// default roles are set after grants of roles
+			statement = defaultRole.ReplaceAllString(statement, " REQUIRE ")
+			statement = createUser.ReplaceAllString(statement, "CREATE USER IF NOT EXISTS ")
+
+			fmt.Fprintf(creates, "%s;\n", statement)
+		}
+
+		err = writeGrants(ctx, conn, grants, a)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !version.MariaDB && version.AtLeast(8, 0, 0) {
+		err = writeDefaultRoles(ctx, conn, grants, filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "-- accounts dumped by repmydump\n\n%s\n%s", creates, grants)
+
+	return err
+}
+
+// getAccounts returns accounts selected by filter, roles of MariaDB are rows of mysql.user with is_role = 'Y'
+func getAccounts(ctx context.Context, conn *sql.Conn, filter UserFilter, version mysql.Version) ([]Account, error) {
+	q := "SELECT User, Host, plugin, 'N' FROM mysql.user ORDER BY User, Host"
+	if version.MariaDB {
+		q = "SELECT User, Host, plugin, is_role FROM mysql.user ORDER BY User, Host"
+	}
+
+	rows, err := conn.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get accounts: %s", err)
+	}
+
+	defer rows.Close()
+
+	// hashes are printed as raw binary before print_identified_with_as_hex of 8.0.17
+	rawHashes := !version.MariaDB && version.AtLeast(8, 0, 0) && !version.AtLeast(8, 0, 17)
+	accounts := make([]Account, 0)
+
+	for rows.Next() {
+		var (
+			a              Account
+			plugin, isRole string
+		)
+
+		err = rows.Scan(&a.User, &a.Host, &plugin, &isRole)
+		if err != nil {
+			return nil, err
+		}
+
+		a.Role = isRole == "Y"
+
+		if !filter.Match(a) {
+			continue
+		}
+
+		if rawHashes && plugin == "caching_sha2_password" {
+			return nil, fmt.Errorf("hash of caching_sha2_password of %s can not be dumped by %s, "+
+				"MySQL 8.0.17+ is required or the account must be excluded", a, version)
+		}
+
+		accounts = append(accounts, a)
+	}
+
+	return accounts, rows.Err()
+}
+
+func writeGrants(ctx context.Context, conn *sql.Conn, w io.Writer, a Account) error {
+	rows, err := conn.QueryContext(ctx, "SHOW GRANTS FOR "+a.String())
+	if err != nil {
+		return fmt.Errorf("unable to show grants for %s: %s", a, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var grant string
+
+		err = rows.Scan(&grant)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s;\n", grant)
+	}
+
+	return rows.Err()
+}
+
+func writeDefaultRoles(ctx context.Context, conn *sql.Conn, w io.Writer, filter UserFilter) error {
+	rows, err := conn.QueryContext(ctx, `SELECT USER, HOST, DEFAULT_ROLE_USER, DEFAULT_ROLE_HOST
+FROM mysql.default_roles ORDER BY USER, HOST, DEFAULT_ROLE_USER, DEFAULT_ROLE_HOST`)
+	if err != nil {
+		return fmt.Errorf("unable to get default roles: %s", err)
+	}
+
+	defer rows.Close()
+
+	roles := make(map[Account][]string)
+	order := make([]Account, 0)
+
+	for rows.Next() {
+		var user, role Account
+
+		err = rows.Scan(&user.User, &user.Host, &role.User, &role.Host)
+		if err != nil {
+			return err
+		}
+
+		if !filter.Match(user) {
+			continue
+		}
+
+		if _, ok := roles[user]; !ok {
+			order = append(order, user)
+		}
+
+		roles[user] = append(roles[user], role.String())
+	}
+
+	for _, user := range order {
+		fmt.Fprintf(w, "SET DEFAULT ROLE %s TO %s;\n", strings.Join(roles[user], ", "), user)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,126 @@
+package dump_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/testutils"
+)
+
+func TestUserFilter_Match(t *testing.T) {
+	filter := dump.UserFilter{Include: []string{"app*", "root@10.%"}, Exclude: []string{"app_tmp"}}
+
+	data := map[dump.Account]bool{
+		{User: "app", Host: "%"}:               true,
+		{User: "app_ro", Host: "10.0.0.%"}:     true,
+		{User: "app_tmp", Host: "%"}:           false,
+		{User: "root", Host: "localhost"}:      false,
+		{User: "root", Host: "10.%"}:           true,
+		{User: "mysql.sys", Host: "localhost"}: false,
+		{User: "backup", Host: "%"}:            false,
+	}
+
+	for account, expected := range data {
+		testutils.AssertEqual(t, account.String(), expected, filter.Match(account))
+	}
+
+	testutils.AssertEqual(t, "system", false, dump.UserFilter{}.Match(dump.Account{User: "mysql.session", Host: "localhost"}))
+	testutils.AssertEqual(t, "system", true, dump.UserFilter{System: true}.Match(dump.Account{User: "root", Host: "%"}))
+}
+
+func TestDumper_DumpUsers(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	mock.ExpectQuery("SELECT VERSION()").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("8.0.30"))
+	mock.ExpectExec("SET SESSION print_identified_with_as_hex = ON").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT User, Host, plugin, 'N' FROM mysql.user ORDER BY User, Host").
+		WillReturnRows(sqlmock.NewRows([]string{"User", "Host", "plugin", "N"}).
+			AddRow("app", "%", "caching_sha2_password", "N").
+			AddRow("mysql.sys", "localhost", "caching_sha2_password", "N").
+			AddRow("reader", "%", "mysql_native_password", "N"))
+	mock.ExpectQuery("SHOW CREATE USER `app`@`%`").WillReturnRows(sqlmock.NewRows([]string{"CREATE USER"}).
+		AddRow("CREATE USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x244124 " +
+			"DEFAULT ROLE `reader`@`%` REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"))
+	mock.ExpectQuery("SHOW GRANTS FOR `app`@`%`").WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+		AddRow("GRANT USAGE ON *.* TO `app`@`%`").AddRow("GRANT `reader`@`%` TO `app`@`%`"))
+	mock.ExpectQuery("SHOW CREATE USER `reader`@`%`").WillReturnRows(sqlmock.NewRows([]string{"CREATE USER"}).
+		AddRow("CREATE USER `reader`@`%` IDENTIFIED WITH 'mysql_native_password' REQUIRE NONE ACCOUNT LOCK"))
+	mock.ExpectQuery("SHOW GRANTS FOR `reader`@`%`").WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+		AddRow("GRANT SELECT ON `db`.* TO `reader`@`%`"))
+	mock.ExpectQuery(`SELECT USER, HOST, DEFAULT_ROLE_USER, DEFAULT_ROLE_HOST
+FROM mysql.default_roles ORDER BY USER, HOST, DEFAULT_ROLE_USER, DEFAULT_ROLE_HOST`).
+		WillReturnRows(sqlmock.NewRows([]string{"USER", "HOST", "DEFAULT_ROLE_USER", "DEFAULT_ROLE_HOST"}).
+			AddRow("app", "%", "reader", "%"))
+
+	buf := &bytes.Buffer{}
+	d := &dump.Dumper{Source: db}
+
+	err = d.DumpUsers(context.Background(), buf, dump.UserFilter{})
+	testutils.FatalErr(t, "DumpUsers", err)
+
+	expected := "-- accounts dumped by repmydump\n\n" +
+		"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x244124 " +
+		"REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;\n" +
+		"CREATE USER IF NOT EXISTS `reader`@`%` IDENTIFIED WITH 'mysql_native_password' REQUIRE NONE ACCOUNT LOCK;\n\n" +
+		"GRANT USAGE ON *.* TO `app`@`%`;\n" +
+		"GRANT `reader`@`%` TO `app`@`%`;\n" +
+		"GRANT SELECT ON `db`.* TO `reader`@`%`;\n" +
+		"SET DEFAULT ROLE `reader`@`%` TO `app`@`%`;\n"
+
+	testutils.AssertEqual(t, "users", expected, buf.String())
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestDumper_DumpUsers_MariaDB(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	mock.ExpectQuery("SELECT VERSION()").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("10.6.12-MariaDB-log"))
+	mock.ExpectQuery("SELECT User, Host, plugin, is_role FROM mysql.user ORDER BY User, Host").
+		WillReturnRows(sqlmock.NewRows([]string{"User", "Host", "plugin", "is_role"}).
+			AddRow("app", "%", "mysql_native_password", "N").
+			AddRow("reader", "", "", "Y"))
+	mock.ExpectQuery("SHOW CREATE USER `app`@`%`").WillReturnRows(sqlmock.NewRows([]string{"CREATE USER"}).
+		AddRow("CREATE USER `app`@`%` IDENTIFIED BY PASSWORD '*ABC'"))
+	mock.ExpectQuery("SHOW GRANTS FOR `app`@`%`").WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+		AddRow("GRANT `reader` TO `app`@`%`").AddRow("SET DEFAULT ROLE `reader` FOR `app`@`%`"))
+	mock.ExpectQuery("SHOW GRANTS FOR `reader`").WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+		AddRow("GRANT SELECT ON `db`.* TO `reader`"))
+
+	buf := &bytes.Buffer{}
+	d := &dump.Dumper{Source: db}
+
+	err = d.DumpUsers(context.Background(), buf, dump.UserFilter{})
+	testutils.FatalErr(t, "DumpUsers", err)
+
+	expected := "-- accounts dumped by repmydump\n\n" +
+		"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED BY PASSWORD '*ABC';\n" +
+		"CREATE ROLE IF NOT EXISTS `reader`;\n\n" +
+		"GRANT `reader` TO `app`@`%`;\n" +
+		"SET DEFAULT ROLE `reader` FOR `app`@`%`;\n" +
+		"GRANT SELECT ON `db`.* TO `reader`;\n"
+
+	testutils.AssertEqual(t, "users", expected, buf.String())
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestDumper_DumpUsers_RawHashes(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	mock.ExpectQuery("SELECT VERSION()").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("8.0.16"))
+	mock.ExpectQuery("SELECT User, Host, plugin, 'N' FROM mysql.user ORDER BY User, Host").
+		WillReturnRows(sqlmock.NewRows([]string{"User", "Host", "plugin", "N"}).
+			AddRow("app", "%", "caching_sha2_password", "N"))
+
+	d := &dump.Dumper{Source: db}
+
+	err = d.DumpUsers(context.Background(), &bytes.Buffer{}, dump.UserFilter{})
+	testutils.AssertEqual(t, "refused", true, err != nil)
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
@@ -0,0 +1,210 @@
+// Package exporter serves replication metrics in Prometheus format
+package exporter
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/semi"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultTimeout = 5 * time.Second
+
+	namespace = "repmy_"
+)
+
+// Instance represents mysql server exposed by exporter
+type Instance struct {
+	Name string
+	DB   *sql.DB
+}
+
+// Exporter collects metrics of instances on every scrape
+type Exporter struct {
+	Instances []Instance
+	// Timeout of collecting metrics of one instance
+	Timeout time.Duration
+}
+
+// ServeHTTP handles scrape request
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reg := e.collectAll(r.Context())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	_, err := reg.WriteTo(w)
+	if err != nil {
+		logrus.Errorf("unable to write metrics: %s", err)
+	}
+}
+
+// collectAll collects metrics of all instances concurrently
+func (e *Exporter) collectAll(ctx context.Context) *registry {
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	reg := newRegistry()
+	wg := &sync.WaitGroup{}
+
+	for _, instance := range e.Instances {
+		wg.Add(1)
+
+		go func(instance Instance) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := collect(ctx, reg, instance)
+
+			if err != nil {
+				logrus.Errorf("unable to collect metrics of %s: %s", instance.Name, err)
+			}
+
+			reg.add(namespace+"up", "1 if all metrics of instance were collected",
+				gauge, boolValue(err == nil), "instance", instance.Name)
+			reg.add(namespace+"scrape_duration_seconds", "duration of collecting metrics of instance",
+				gauge, time.Since(start).Seconds(), "instance", instance.Name)
+		}(instance)
+	}
+
+	wg.Wait()
+
+	return reg
+}
+
+func collect(ctx context.Context, reg *registry, instance Instance) error {
+	err := collectMaster(ctx, reg, instance)
+	if err != nil {
+		return err
+	}
+
+	err = collectReplica(ctx, reg, instance)
+	if err != nil {
+		return err
+	}
+
+	return collectSemi(ctx, reg, instance)
+}
+
+func collectMaster(ctx context.Context, reg *registry, instance Instance) error {
+	status, err := master.New(instance.DB).ShowStatus(ctx)
+	if errors.Cause(err) == sql.ErrNoRows {
+		// binary logging is disabled
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	labels := []string{"instance", instance.Name}
+
+	reg.add(namespace+"master_binlog_file_index", "index of the current binary log file",
+		gauge, binlogIndex(status.File), labels...)
+	reg.add(namespace+"master_binlog_position", "position in the current binary log file",
+		gauge, float64(status.Position), labels...)
+
+	return nil
+}
+
+func collectReplica(ctx context.Context, reg *registry, instance Instance) error {
+	statuses, err := slave.New(instance.DB).ShowStatuses(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		labels := []string{"instance", instance.Name, "channel", status.ChannelName}
+
+		if status.SecondsBehindMaster.Valid {
+			reg.add(namespace+"replica_seconds_behind_master", "Seconds_Behind_Master of replication channel",
+				gauge, float64(status.SecondsBehindMaster.Int), labels...)
+		}
+
+		reg.add(namespace+"replica_io_running", "1 if replication IO thread is running",
+			gauge, boolValue(status.IORunning()), labels...)
+		reg.add(namespace+"replica_sql_running", "1 if replication SQL thread is running",
+			gauge, boolValue(status.SQLRunning()), labels...)
+		reg.add(namespace+"replica_relay_log_space_bytes", "total size of relay log files",
+			gauge, float64(status.RelayLogSpace), labels...)
+		reg.add(namespace+"replica_last_io_errno", "number of the last IO thread error",
+			gauge, float64(status.LastIOErrno), labels...)
+		reg.add(namespace+"replica_last_sql_errno", "number of the last SQL thread error",
+			gauge, float64(status.LastSQLErrno), labels...)
+		reg.add(namespace+"replica_read_master_log_pos", "position in master binary log read by IO thread",
+			gauge, float64(status.ReadMasterLogPos), labels...)
+		reg.add(namespace+"replica_exec_master_log_pos", "position in master binary log executed by SQL thread",
+			gauge, float64(status.ExecMasterLogPos), labels...)
+		reg.add(namespace+"replica_sql_delay_seconds", "configured delay of SQL thread",
+			gauge, float64(status.SQLDelay), labels...)
+	}
+
+	return nil
+}
+
+func collectSemi(ctx context.Context, reg *registry, instance Instance) error {
+	variables, err := semi.New(instance.DB).Show(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, variable := range variables {
+		value, ok := parseStatusValue(variable.Value)
+		if !ok {
+			continue
+		}
+
+		typ := gauge
+		if strings.HasSuffix(strings.ToLower(variable.Name), "_tx") {
+			typ = counter
+		}
+
+		reg.add(metricName(namespace, variable.Name), "status variable "+variable.Name,
+			typ, value, "instance", instance.Name)
+	}
+
+	return nil
+}
+
+// parseStatusValue parses numbers and ON/OFF values
+func parseStatusValue(value string) (float64, bool) {
+	switch strings.ToUpper(value) {
+	case "ON", "YES":
+		return 1, true
+	case "OFF", "NO":
+		return 0, true
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+
+	return v, err == nil
+}
+
+// binlogIndex returns numeric suffix of binary log file name, ex. 3 for 'mysql-bin.000003'
+func binlogIndex(file string) float64 {
+	i := strings.LastIndex(file, ".")
+	if i < 0 {
+		return 0
+	}
+
+	index, err := strconv.ParseFloat(file[i+1:], 64)
+	if err != nil {
+		return 0
+	}
+
+	return index
+}
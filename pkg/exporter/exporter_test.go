@@ -0,0 +1,54 @@
+package exporter_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/exporter"
+	"github.com/partyzanex/testutils"
+)
+
+func TestExporter_ServeHTTP(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	mock.ExpectQuery("show master status").WillReturnRows(
+		sqlmock.NewRows([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}).
+			AddRow("mysql-bin.000012", 4567, "", "", ""),
+	)
+	mock.ExpectQuery("show slave status").WillReturnRows(
+		sqlmock.NewRows([]string{"Slave_IO_Running", "Slave_SQL_Running", "Seconds_Behind_Master", "Channel_Name"}).
+			AddRow("Yes", "No", nil, "ch1"),
+	)
+	mock.ExpectQuery("show status like '%semi%'").WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("Rpl_semi_sync_master_status", "ON").
+			AddRow("Rpl_semi_sync_master_no_tx", "3"),
+	)
+
+	e := &exporter.Exporter{
+		Instances: []exporter.Instance{{Name: "db1", DB: db}},
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		`repmy_up{instance="db1"} 1`,
+		`repmy_master_binlog_file_index{instance="db1"} 12`,
+		`repmy_master_binlog_position{instance="db1"} 4567`,
+		`repmy_replica_io_running{instance="db1",channel="ch1"} 1`,
+		`repmy_replica_sql_running{instance="db1",channel="ch1"} 0`,
+		`repmy_rpl_semi_sync_master_status{instance="db1"} 1`,
+		`# TYPE repmy_rpl_semi_sync_master_no_tx counter`,
+		`repmy_rpl_semi_sync_master_no_tx{instance="db1"} 3`,
+	} {
+		testutils.AssertEqual(t, line, true, strings.Contains(body, line+"\n"))
+	}
+
+	testutils.AssertEqual(t, "no lag", false, strings.Contains(body, "repmy_replica_seconds_behind_master{"))
+}
@@ -0,0 +1,148 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metricType represents type of metric family in text exposition format
+type metricType string
+
+const (
+	gauge   metricType = "gauge"
+	counter metricType = "counter"
+)
+
+type sample struct {
+	labels []string // pairs of name and value
+	value  float64
+}
+
+type family struct {
+	help    string
+	typ     metricType
+	samples []sample
+}
+
+// registry collects metrics of one scrape
+type registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func newRegistry() *registry {
+	return &registry{
+		families: make(map[string]*family),
+	}
+}
+
+// add adds sample, labels are pairs of name and value
+func (r *registry) add(name, help string, typ metricType, value float64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{help: help, typ: typ}
+		r.families[name] = f
+	}
+
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// WriteTo writes metrics in Prometheus text exposition format
+func (r *registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	b := &strings.Builder{}
+
+	for _, name := range names {
+		f := r.families[name]
+
+		fmt.Fprintf(b, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(b, "# TYPE %s %s\n", name, f.typ)
+
+		for _, s := range f.samples {
+			b.WriteString(name)
+			writeLabels(b, s.labels)
+			b.WriteString(" ")
+			b.WriteString(formatValue(s.value))
+			b.WriteString("\n")
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func writeLabels(b *strings.Builder, labels []string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	b.WriteString("{")
+
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelReplacer.Replace(labels[i+1]))
+		b.WriteString(`"`)
+	}
+
+	b.WriteString("}")
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// metricName converts variable name to metric name
+func metricName(prefix, name string) string {
+	return prefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+
+		return '_'
+	}, name)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,157 @@
+package failover
+
+import (
+	"sort"
+
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/server"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+)
+
+// Candidate represents replica with its replication state
+type Candidate struct {
+	Node     *node.Node
+	Status   *slave.Status
+	Info     *server.Info
+	Priority int
+	// Executed is @@GLOBAL.gtid_executed, includes local transactions of replica
+	Executed gtid.Set
+	// Retrieved is set of transactions received from master
+	Retrieved gtid.Set
+}
+
+// NewCandidate parses GTID sets of replica
+func NewCandidate(n *node.Node, status *slave.Status, info *server.Info) (*Candidate, error) {
+	c := &Candidate{Node: n, Status: status, Info: info}
+
+	var err error
+
+	c.Executed, err = gtid.Parse(info.GTIDExecuted)
+	if err != nil {
+		return nil, errors.Wrapf(err, "replica %s", n)
+	}
+
+	c.Retrieved, err = gtid.Parse(status.RetrievedGTIDSet)
+	if err != nil {
+		return nil, errors.Wrapf(err, "replica %s", n)
+	}
+
+	return c, nil
+}
+
+// Received returns all transactions which are executed or will be executed from relay logs
+func (c *Candidate) Received() gtid.Set {
+	return c.Executed.Union(c.Retrieved)
+}
+
+// Compare returns positive number if a is more advanced than b,
+// negative if b is more advanced and zero if they are equal.
+// GTID sets are compared if useGTID is true: a set containing the other one
+// is more advanced, otherwise sets with more transactions of master win.
+// Without GTID coordinates of master binlog read by IO thread are compared.
+func Compare(a, b *Candidate, useGTID bool) int {
+	if !useGTID {
+		aReached := node.Reached(a.Status.MasterLogFile, a.Status.ReadMasterLogPos,
+			b.Status.MasterLogFile, b.Status.ReadMasterLogPos)
+		bReached := node.Reached(b.Status.MasterLogFile, b.Status.ReadMasterLogPos,
+			a.Status.MasterLogFile, a.Status.ReadMasterLogPos)
+
+		return boolInt(aReached) - boolInt(bReached)
+	}
+
+	ar, br := a.Received(), b.Received()
+
+	if ar.Equal(br) {
+		return 0
+	}
+
+	if ar.Contains(br) {
+		return 1
+	}
+
+	if br.Contains(ar) {
+		return -1
+	}
+
+	uuid := a.Status.MasterUUID
+
+	ac, bc := masterOnly(ar, uuid).Count(), masterOnly(br, uuid).Count()
+
+	switch {
+	case ac > bc:
+		return 1
+	case ac < bc:
+		return -1
+	}
+
+	return 0
+}
+
+// Rank orders candidates from the best to the worst:
+// the most advanced first, then by priority, then by name
+func Rank(candidates []*Candidate, useGTID bool) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+
+		if cmp := Compare(a, b, useGTID); cmp != 0 {
+			return cmp > 0
+		}
+
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+
+		return a.Node.Name < b.Node.Name
+	})
+}
+
+// Errant returns transactions of candidate which are absent on every other replica
+// and were not replicated from the master with masterUUID
+func Errant(candidate *Candidate, others []*Candidate, masterUUID string) gtid.Set {
+	known := gtid.Set{}
+
+	for _, other := range others {
+		known = known.Union(other.Received())
+	}
+
+	errant := candidate.Executed.Subtract(known)
+	delete(errant, masterUUID)
+
+	return errant
+}
+
+// Missing returns errant transactions which replica has executed but candidate has not,
+// they must be known by candidate, otherwise auto-positioning of replica fails.
+// Transactions of the master with masterUUID are returned by Lost.
+func Missing(candidate, replica *Candidate, masterUUID string) gtid.Set {
+	missing := replica.Executed.Subtract(candidate.Received())
+	delete(missing, masterUUID)
+
+	return missing
+}
+
+// Lost returns transactions of the master with masterUUID which replica has received
+// but candidate has not, candidate must apply them before it is promoted
+func Lost(candidate, replica *Candidate, masterUUID string) gtid.Set {
+	return masterOnly(replica.Received().Subtract(candidate.Received()), masterUUID)
+}
+
+func masterOnly(set gtid.Set, uuid string) gtid.Set {
+	result := gtid.Set{}
+
+	if intervals, ok := set[uuid]; ok {
+		result[uuid] = intervals
+	}
+
+	return result
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
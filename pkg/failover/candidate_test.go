@@ -0,0 +1,95 @@
+package failover_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/failover"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/server"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/partyzanex/testutils"
+)
+
+const (
+	masterUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	localUUID  = "5d2a4c8e-71ca-11e1-9e33-c80aa9429562"
+)
+
+func candidate(t *testing.T, name, executed, retrieved string) *failover.Candidate {
+	c, err := failover.NewCandidate(
+		&node.Node{Name: name},
+		&slave.Status{MasterUUID: masterUUID, RetrievedGTIDSet: retrieved},
+		&server.Info{GTIDExecuted: executed, LogBin: true},
+	)
+	testutils.FatalErr(t, "NewCandidate", err)
+
+	return c
+}
+
+func names(candidates []*failover.Candidate) []string {
+	result := make([]string, 0, len(candidates))
+
+	for _, c := range candidates {
+		result = append(result, c.Node.Name)
+	}
+
+	return result
+}
+
+func TestRank(t *testing.T) {
+	a := candidate(t, "a", masterUUID+":1-10", masterUUID+":1-10")
+	b := candidate(t, "b", masterUUID+":1-8", masterUUID+":1-12")
+	c := candidate(t, "c", masterUUID+":1-10", masterUUID+":1-10")
+	c.Priority = 1
+
+	candidates := []*failover.Candidate{a, b, c}
+	failover.Rank(candidates, true)
+
+	testutils.AssertEqual(t, "rank", "[b c a]", fmt.Sprint(names(candidates)))
+}
+
+func TestRank_Coordinates(t *testing.T) {
+	a := candidate(t, "a", "", "")
+	a.Status.MasterLogFile, a.Status.ReadMasterLogPos = "mysql-bin.000009", 900
+	b := candidate(t, "b", "", "")
+	b.Status.MasterLogFile, b.Status.ReadMasterLogPos = "mysql-bin.000010", 4
+
+	candidates := []*failover.Candidate{a, b}
+	failover.Rank(candidates, false)
+
+	testutils.AssertEqual(t, "rank", "[b a]", fmt.Sprint(names(candidates)))
+}
+
+func TestChoose(t *testing.T) {
+	a := candidate(t, "a", masterUUID+":1-12", "")
+	b := candidate(t, "b", masterUUID+":1-10", "")
+
+	f := &failover.Failover{Exclude: []string{"a"}}
+
+	chosen, rest, err := f.Choose([]*failover.Candidate{a, b}, true)
+	testutils.FatalErr(t, "Choose", err)
+	testutils.AssertEqual(t, "chosen", "b", chosen.Node.Name)
+	testutils.AssertEqual(t, "rest", "[a]", fmt.Sprint(names(rest)))
+
+	f.Exclude = []string{"a", "b"}
+
+	_, _, err = f.Choose([]*failover.Candidate{a, b}, true)
+	testutils.AssertEqual(t, "no candidate", true, err != nil)
+}
+
+func TestErrant(t *testing.T) {
+	c := candidate(t, "c", masterUUID+":1-10,"+localUUID+":1-3", "")
+	r1 := candidate(t, "r1", masterUUID+":1-9,"+localUUID+":1", "")
+	r2 := candidate(t, "r2", masterUUID+":1-10,"+localUUID+":1-2", "")
+
+	errant := failover.Errant(c, []*failover.Candidate{r1, r2}, masterUUID)
+	testutils.AssertEqual(t, "errant", gtid.MustParse(localUUID+":3").String(), errant.String())
+
+	missing := failover.Missing(r1, r2, masterUUID)
+	testutils.AssertEqual(t, "missing", gtid.MustParse(localUUID+":2").String(), missing.String())
+
+	lost := failover.Lost(r1, r2, masterUUID)
+	testutils.AssertEqual(t, "lost", gtid.MustParse(masterUUID+":10").String(), lost.String())
+}
@@ -0,0 +1,388 @@
+// Package failover promotes the most advanced replica when master is lost
+package failover
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/switchover"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultApplyTimeout = 5 * time.Minute
+)
+
+// Failover represents emergency change of master
+type Failover struct {
+	Replicas []*node.Node
+	// User is used by replicas to connect to the new master
+	User mysql.ReplUser
+	// Exclude contains names of replicas which are never promoted
+	Exclude []string
+	// Priority chooses between equally advanced replicas, higher wins
+	Priority map[string]int
+	// ApplyTimeout limits waiting for replicas to apply relay logs
+	ApplyTimeout time.Duration
+}
+
+// state holds values captured during execution of plan
+type state struct {
+	candidate  *Candidate
+	replicas   []*Candidate
+	useGTID    bool
+	masterUUID string
+	promoted   master.Status
+}
+
+// Collect reads replication state of replicas, unreachable replicas are skipped
+func (f *Failover) Collect(ctx context.Context) ([]*Candidate, error) {
+	candidates := make([]*Candidate, 0, len(f.Replicas))
+
+	for _, replica := range f.Replicas {
+		status, err := replica.Slave().ShowStatus(ctx)
+		if err != nil {
+			logrus.Warnf("replica %s is skipped: %s", replica, err)
+			continue
+		}
+
+		info, err := replica.Server().Info(ctx)
+		if err != nil {
+			logrus.Warnf("replica %s is skipped: %s", replica, err)
+			continue
+		}
+
+		candidate, err := NewCandidate(replica, status, info)
+		if err != nil {
+			return nil, err
+		}
+
+		candidate.Priority = f.Priority[replica.Name]
+		candidates = append(candidates, candidate)
+	}
+
+	if len(candidates) == 0 {
+		return nil, errors.New("no reachable replicas")
+	}
+
+	return candidates, nil
+}
+
+// Choose returns the best candidate which is not excluded and the rest of replicas
+func (f *Failover) Choose(candidates []*Candidate, useGTID bool) (*Candidate, []*Candidate, error) {
+	ranked := append([]*Candidate(nil), candidates...)
+	Rank(ranked, useGTID)
+
+	for i, candidate := range ranked {
+		if f.excluded(candidate.Node.Name) {
+			continue
+		}
+
+		if !candidate.Info.LogBin {
+			logrus.Warnf("replica %s is not promoted: binary logging is disabled", candidate.Node)
+			continue
+		}
+
+		if i > 0 && Compare(ranked[0], candidate, useGTID) > 0 {
+			logrus.Warnf("replica %s is behind %s, transactions received only by %s are applied from it",
+				candidate.Node, ranked[0].Node, ranked[0].Node)
+		}
+
+		rest := make([]*Candidate, 0, len(ranked)-1)
+		rest = append(rest, ranked[:i]...)
+		rest = append(rest, ranked[i+1:]...)
+
+		return candidate, rest, nil
+	}
+
+	return nil, nil, errors.New("no replica can be promoted")
+}
+
+// Plan inspects replicas and builds steps of failover,
+// the candidate is chosen again after relay logs are applied
+func (f *Failover) Plan(ctx context.Context) (*switchover.Plan, *Candidate, error) {
+	if f.ApplyTimeout <= 0 {
+		f.ApplyTimeout = DefaultApplyTimeout
+	}
+
+	candidates, err := f.Collect(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	st := &state{useGTID: true}
+
+	for _, c := range candidates {
+		st.useGTID = st.useGTID && c.Info.GTIDEnabled()
+	}
+
+	chosen, _, err := f.Choose(candidates, st.useGTID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mode := "binlog coordinates"
+	if st.useGTID {
+		mode = "GTID auto-positioning"
+	}
+
+	plan := &switchover.Plan{}
+
+	for _, c := range candidates {
+		replica := c.Node
+
+		plan.Steps = append(plan.Steps, switchover.Step{
+			Description: fmt.Sprintf("stop IO thread on replica %s", replica),
+			Do: func(ctx context.Context) error {
+				return replica.Slave().StopIOThread(ctx)
+			},
+			Undo: func(ctx context.Context) error {
+				return replica.Slave().StartIOThread(ctx)
+			},
+		})
+	}
+
+	plan.Steps = append(plan.Steps,
+		switchover.Step{
+			Description: fmt.Sprintf("wait up to %s for replicas to apply relay logs", f.ApplyTimeout),
+			Do: func(ctx context.Context) error {
+				ctx, cancel := context.WithTimeout(ctx, f.ApplyTimeout)
+				defer cancel()
+
+				for _, c := range candidates {
+					err := c.Node.WaitRelayApplied(ctx)
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		switchover.Step{
+			Description: fmt.Sprintf("choose the most advanced replica, expected %s", chosen.Node),
+			Do: func(ctx context.Context) error {
+				return f.choose(ctx, st)
+			},
+		},
+		switchover.Step{
+			Description: "apply on the chosen replica transactions of master received only by other replicas",
+			Do: func(ctx context.Context) error {
+				return f.catchUp(ctx, st)
+			},
+		},
+		switchover.Step{
+			Description: "stop and reset replication on the chosen replica",
+			Do: func(ctx context.Context) error {
+				repo := st.candidate.Node.Slave()
+
+				err := repo.Stop(ctx)
+				if err != nil {
+					return err
+				}
+
+				return repo.ResetAll(ctx)
+			},
+		},
+		switchover.Step{
+			Description: "inject empty transactions on the chosen replica for errant GTIDs of other replicas",
+			Do: func(ctx context.Context) error {
+				if !st.useGTID {
+					return nil
+				}
+
+				missing := gtid.Set{}
+
+				for _, replica := range st.replicas {
+					set := Missing(st.candidate, replica, st.masterUUID)
+					if !set.IsEmpty() {
+						logrus.Warnf("replica %s has errant transactions %s", replica.Node, set)
+						missing = missing.Union(set)
+					}
+				}
+
+				if missing.IsEmpty() {
+					return nil
+				}
+
+				return inject(ctx, st.candidate, missing)
+			},
+		},
+		switchover.Step{
+			Description: "make the chosen replica writable",
+			Do: func(ctx context.Context) error {
+				return st.candidate.Node.Server().SetReadOnly(ctx, false, false)
+			},
+		},
+		switchover.Step{
+			Description: "capture binlog coordinates of the new master",
+			Do: func(ctx context.Context) error {
+				status, err := st.candidate.Node.Master().ShowStatus(ctx)
+				if err != nil {
+					return err
+				}
+
+				st.promoted = *status
+
+				return nil
+			},
+		},
+		switchover.Step{
+			Description: fmt.Sprintf("inject errant transactions of the new master and repoint other replicas using %s", mode),
+			Do: func(ctx context.Context) error {
+				return f.repoint(ctx, st)
+			},
+		},
+	)
+
+	return plan, chosen, nil
+}
+
+// choose collects state of replicas after relay logs are applied and chooses the candidate
+func (f *Failover) choose(ctx context.Context, st *state) error {
+	candidates, err := f.Collect(ctx)
+	if err != nil {
+		return err
+	}
+
+	st.candidate, st.replicas, err = f.Choose(candidates, st.useGTID)
+	if err != nil {
+		return err
+	}
+
+	st.masterUUID = st.candidate.Status.MasterUUID
+
+	logrus.Infof("replica %s is chosen as the new master", st.candidate.Node)
+
+	if st.useGTID {
+		// transactions of master are told apart from errant ones by its UUID
+		if st.masterUUID == "" {
+			return errors.Errorf("UUID of master is unknown on replica %s", st.candidate.Node)
+		}
+
+		return nil
+	}
+
+	// without GTID coordinates of old master can not be translated to coordinates
+	// of the new master, so only replicas stopped at the same position can be repointed
+	for _, replica := range st.replicas {
+		if replica.Status.RelayMasterLogFile != st.candidate.Status.RelayMasterLogFile ||
+			replica.Status.ExecMasterLogPos != st.candidate.Status.ExecMasterLogPos {
+			return errors.Errorf("replica %s stopped at %s:%d, candidate %s at %s:%d, GTID is required to repoint it",
+				replica.Node, replica.Status.RelayMasterLogFile, replica.Status.ExecMasterLogPos,
+				st.candidate.Node, st.candidate.Status.RelayMasterLogFile, st.candidate.Status.ExecMasterLogPos)
+		}
+	}
+
+	return nil
+}
+
+// catchUp replicates to the candidate transactions of master which it has not received,
+// but other replicas have, for example the more advanced replica is excluded.
+// Failover is aborted if they can not be applied, so they are never lost.
+func (f *Failover) catchUp(ctx context.Context, st *state) error {
+	if !st.useGTID {
+		return nil
+	}
+
+	for _, replica := range st.replicas {
+		lost := Lost(st.candidate, replica, st.masterUUID)
+		if lost.IsEmpty() {
+			continue
+		}
+
+		logrus.Warnf("replica %s has transactions of master %s which %s has not, applying them from %s",
+			replica.Node, lost, st.candidate.Node, replica.Node)
+
+		err := st.candidate.Node.Repoint(ctx, replica.Node.ReplUser(f.User), master.Status{}, true)
+		if err != nil {
+			return errors.Wrapf(err, "unable to replicate %s from %s", lost, replica.Node)
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, f.ApplyTimeout)
+		err = st.candidate.Node.WaitExecuted(waitCtx, master.Status{ExecutedGTIDSet: lost.String()}, true)
+		cancel()
+
+		if err != nil {
+			return errors.Wrapf(err, "transactions %s of %s can not be applied on %s, failover is aborted",
+				lost, replica.Node, st.candidate.Node)
+		}
+
+		err = st.candidate.Node.Slave().Stop(ctx)
+		if err != nil {
+			return err
+		}
+
+		st.candidate.Executed = st.candidate.Executed.Union(lost)
+	}
+
+	return nil
+}
+
+// repoint changes master of other replicas to the new master
+func (f *Failover) repoint(ctx context.Context, st *state) error {
+	user := st.candidate.Node.ReplUser(f.User)
+
+	errant := gtid.Set{}
+	if st.useGTID {
+		errant = Errant(st.candidate, st.replicas, st.masterUUID)
+		if !errant.IsEmpty() {
+			logrus.Warnf("new master %s has errant transactions %s", st.candidate.Node, errant)
+		}
+	}
+
+	for _, replica := range st.replicas {
+		if !errant.IsEmpty() {
+			err := inject(ctx, replica, errant)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := replica.Node.Repoint(ctx, user, st.promoted, st.useGTID)
+		if err != nil {
+			return errors.Wrapf(err, "replica %s", replica.Node)
+		}
+	}
+
+	return nil
+}
+
+// inject commits empty transactions on replica, super_read_only is disabled meanwhile
+func inject(ctx context.Context, replica *Candidate, set gtid.Set) error {
+	srv := replica.Node.Server()
+
+	if replica.Info.SuperReadOnly {
+		err := srv.SetReadOnly(ctx, true, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := srv.InjectEmpty(ctx, set)
+	if err != nil {
+		return errors.Wrapf(err, "replica %s", replica.Node)
+	}
+
+	if replica.Info.SuperReadOnly {
+		return srv.SetReadOnly(ctx, replica.Info.ReadOnly, replica.Info.SuperReadOnly)
+	}
+
+	return nil
+}
+
+func (f *Failover) excluded(name string) bool {
+	for _, excluded := range f.Exclude {
+		if excluded == name {
+			return true
+		}
+	}
+
+	return false
+}
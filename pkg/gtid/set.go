@@ -0,0 +1,306 @@
+// Package gtid implements MySQL GTID sets
+package gtid
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Interval represents closed interval of transaction numbers
+type Interval struct {
+	Start, End int64
+}
+
+// Set represents GTID set: source UUID -> sorted and merged intervals
+type Set map[string][]Interval
+
+// Parse parses GTID set like 'uuid:1-5:7,uuid2:1-3',
+// whitespaces and new lines are ignored
+func Parse(s string) (Set, error) {
+	set := Set{}
+
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\n', '\r', '\t':
+			return -1
+		}
+
+		return r
+	}, s)
+
+	if s == "" {
+		return set, nil
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 {
+			return nil, errors.Errorf("invalid GTID set item %q", item)
+		}
+
+		uuid := strings.ToLower(parts[0])
+		if len(uuid) != 36 {
+			return nil, errors.Errorf("invalid source UUID %q", parts[0])
+		}
+
+		for _, rng := range parts[1:] {
+			interval, err := parseInterval(rng)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid GTID set item %q", item)
+			}
+
+			set.AddInterval(uuid, interval)
+		}
+	}
+
+	return set, nil
+}
+
+// MustParse parses GTID set and panics on error
+func MustParse(s string) Set {
+	set, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return set
+}
+
+func parseInterval(s string) (Interval, error) {
+	bounds := strings.SplitN(s, "-", 2)
+
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil || start < 1 {
+		return Interval{}, errors.Errorf("invalid interval %q", s)
+	}
+
+	end := start
+
+	if len(bounds) == 2 {
+		end, err = strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil || end < start {
+			return Interval{}, errors.Errorf("invalid interval %q", s)
+		}
+	}
+
+	return Interval{Start: start, End: end}, nil
+}
+
+// Add adds single transaction to the set
+func (s Set) Add(uuid string, gno int64) {
+	s.AddInterval(uuid, Interval{Start: gno, End: gno})
+}
+
+// AddInterval adds interval of transactions to the set
+func (s Set) AddInterval(uuid string, interval Interval) {
+	uuid = strings.ToLower(uuid)
+	s[uuid] = merge(append(s[uuid], interval))
+}
+
+// Clone returns copy of the set
+func (s Set) Clone() Set {
+	clone := make(Set, len(s))
+
+	for uuid, intervals := range s {
+		clone[uuid] = append([]Interval(nil), intervals...)
+	}
+
+	return clone
+}
+
+// Union returns set of transactions contained in s or other
+func (s Set) Union(other Set) Set {
+	union := s.Clone()
+
+	for uuid, intervals := range other {
+		union[uuid] = merge(append(union[uuid], intervals...))
+	}
+
+	return union
+}
+
+// Subtract returns set of transactions contained in s but not in other
+func (s Set) Subtract(other Set) Set {
+	diff := Set{}
+
+	for uuid, intervals := range s {
+		rest := subtract(intervals, other[uuid])
+		if len(rest) > 0 {
+			diff[uuid] = rest
+		}
+	}
+
+	return diff
+}
+
+// Contains returns true if all transactions of other are contained in s
+func (s Set) Contains(other Set) bool {
+	return other.Subtract(s).IsEmpty()
+}
+
+// Equal returns true if sets contain the same transactions
+func (s Set) Equal(other Set) bool {
+	return s.Contains(other) && other.Contains(s)
+}
+
+// IsEmpty returns true if the set has no transactions
+func (s Set) IsEmpty() bool {
+	for _, intervals := range s {
+		if len(intervals) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Count returns number of transactions in the set
+func (s Set) Count() int64 {
+	var count int64
+
+	for _, intervals := range s {
+		for _, interval := range intervals {
+			count += interval.End - interval.Start + 1
+		}
+	}
+
+	return count
+}
+
+// Gaps returns missing intervals between the first and the last transaction of uuid
+func (s Set) Gaps(uuid string) []Interval {
+	intervals := s[strings.ToLower(uuid)]
+	gaps := make([]Interval, 0)
+
+	for i := 1; i < len(intervals); i++ {
+		gaps = append(gaps, Interval{
+			Start: intervals[i-1].End + 1,
+			End:   intervals[i].Start - 1,
+		})
+	}
+
+	return gaps
+}
+
+// Each calls fn for every transaction of the set in order
+func (s Set) Each(fn func(uuid string, gno int64) error) error {
+	for _, uuid := range s.UUIDs() {
+		for _, interval := range s[uuid] {
+			for gno := interval.Start; gno <= interval.End; gno++ {
+				err := fn(uuid, gno)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// UUIDs returns sorted source UUIDs of the set
+func (s Set) UUIDs() []string {
+	uuids := make([]string, 0, len(s))
+
+	for uuid, intervals := range s {
+		if len(intervals) > 0 {
+			uuids = append(uuids, uuid)
+		}
+	}
+
+	sort.Strings(uuids)
+
+	return uuids
+}
+
+// String returns GTID set in MySQL format
+func (s Set) String() string {
+	items := make([]string, 0, len(s))
+
+	for _, uuid := range s.UUIDs() {
+		b := strings.Builder{}
+		b.WriteString(uuid)
+
+		for _, interval := range s[uuid] {
+			b.WriteString(":")
+			b.WriteString(interval.String())
+		}
+
+		items = append(items, b.String())
+	}
+
+	return strings.Join(items, ",")
+}
+
+// String returns interval in MySQL format
+func (i Interval) String() string {
+	if i.Start == i.End {
+		return strconv.FormatInt(i.Start, 10)
+	}
+
+	return strconv.FormatInt(i.Start, 10) + "-" + strconv.FormatInt(i.End, 10)
+}
+
+func merge(intervals []Interval) []Interval {
+	if len(intervals) < 2 {
+		return intervals
+	}
+
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Start < intervals[j].Start
+	})
+
+	merged := intervals[:1]
+
+	for _, interval := range intervals[1:] {
+		last := &merged[len(merged)-1]
+
+		if interval.Start <= last.End+1 {
+			if interval.End > last.End {
+				last.End = interval.End
+			}
+
+			continue
+		}
+
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+func subtract(intervals, other []Interval) []Interval {
+	result := make([]Interval, 0, len(intervals))
+
+	for _, interval := range intervals {
+		rest := []Interval{interval}
+
+		for _, o := range other {
+			next := make([]Interval, 0, len(rest))
+
+			for _, r := range rest {
+				if o.End < r.Start || o.Start > r.End {
+					next = append(next, r)
+					continue
+				}
+
+				if o.Start > r.Start {
+					next = append(next, Interval{Start: r.Start, End: o.Start - 1})
+				}
+
+				if o.End < r.End {
+					next = append(next, Interval{Start: o.End + 1, End: r.End})
+				}
+			}
+
+			rest = next
+		}
+
+		result = append(result, rest...)
+	}
+
+	return result
+}
@@ -0,0 +1,57 @@
+package gtid_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/testutils"
+)
+
+const (
+	uuid1 = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	uuid2 = "8a94f357-aab4-11df-86ab-c80aa9429563"
+)
+
+func TestParse(t *testing.T) {
+	set, err := gtid.Parse(uuid2 + ":1-5:3-10:12,\n" + uuid1 + ":7")
+	testutils.FatalErr(t, "gtid.Parse", err)
+
+	testutils.AssertEqual(t, "String", uuid1+":7,"+uuid2+":1-10:12", set.String())
+	testutils.AssertEqual(t, "Count", int64(12), set.Count())
+
+	empty, err := gtid.Parse("")
+	testutils.FatalErr(t, "gtid.Parse(empty)", err)
+	testutils.AssertEqual(t, "IsEmpty", true, empty.IsEmpty())
+
+	for _, invalid := range []string{"uuid:1", uuid1, uuid1 + ":0", uuid1 + ":5-3", uuid1 + ":a"} {
+		_, err := gtid.Parse(invalid)
+		testutils.AssertEqual(t, invalid, true, err != nil)
+	}
+}
+
+func TestSet_Subtract(t *testing.T) {
+	a := gtid.MustParse(uuid1 + ":1-100," + uuid2 + ":1-5")
+	b := gtid.MustParse(uuid1 + ":10-20:50-100")
+
+	testutils.AssertEqual(t, "a-b", uuid1+":1-9:21-49,"+uuid2+":1-5", a.Subtract(b).String())
+	testutils.AssertEqual(t, "b-a", "", b.Subtract(a).String())
+	testutils.AssertEqual(t, "a contains b", true, a.Contains(b))
+	testutils.AssertEqual(t, "b contains a", false, b.Contains(a))
+	testutils.AssertEqual(t, "equal", true, a.Equal(b.Union(a)))
+	testutils.AssertEqual(t, "gaps", 1, len(b.Gaps(uuid1)))
+	testutils.AssertEqual(t, "gap", "21-49", b.Gaps(uuid1)[0].String())
+}
+
+func TestSet_Each(t *testing.T) {
+	set := gtid.MustParse(uuid1 + ":1-2:5")
+	gnos := make([]int64, 0)
+
+	err := set.Each(func(uuid string, gno int64) error {
+		gnos = append(gnos, gno)
+		return nil
+	})
+	testutils.FatalErr(t, "set.Each", err)
+
+	testutils.AssertEqual(t, "gnos", "[1 2 5]", fmt.Sprint(gnos))
+}
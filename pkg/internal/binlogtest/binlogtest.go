@@ -0,0 +1,193 @@
+// Package binlogtest builds binlog events and serves them by fake master in tests
+package binlogtest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/binlog"
+)
+
+// UUID is server UUID of GTIDs of test events
+const UUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+// SID returns UUID as it is encoded in GTID events
+func SID() []byte {
+	return Unhex("3e11fa4771ca11e19e33c80aa9429562")
+}
+
+// Writer builds binlog events with CRC32 checksums
+type Writer struct {
+	// Pos is position of the next event, log_pos of events is zero if it is zero
+	Pos       uint32
+	Flags     uint16
+	Timestamp uint32
+}
+
+// Event returns event of type t with body
+func (w *Writer) Event(t binlog.EventType, body ...[]byte) []byte {
+	data := bytes.Join(body, nil)
+	size := uint32(binlog.HeaderSize + len(data) + 4)
+
+	raw := make([]byte, binlog.HeaderSize, size)
+	binary.LittleEndian.PutUint32(raw[0:], w.Timestamp)
+	raw[4] = byte(t)
+	binary.LittleEndian.PutUint32(raw[5:], 1)
+	binary.LittleEndian.PutUint32(raw[9:], size)
+
+	if w.Pos > 0 {
+		w.Pos += size
+		binary.LittleEndian.PutUint32(raw[13:], w.Pos)
+	}
+
+	binary.LittleEndian.PutUint16(raw[17:], w.Flags)
+
+	raw = append(raw, data...)
+
+	return append(raw, LE(uint64(crc32.ChecksumIEEE(raw)), 4)...)
+}
+
+// FormatDescription returns FORMAT_DESCRIPTION event of 8.0.30 with checksum algorithm alg
+func (w *Writer) FormatDescription(alg byte) []byte {
+	version := make([]byte, 50)
+	copy(version, "8.0.30-log")
+
+	return w.Event(binlog.FormatDescriptionEventType,
+		LE(4, 2), version, LE(1600000000, 4), []byte{binlog.HeaderSize}, make([]byte, 40), []byte{alg})
+}
+
+// LE returns v as little-endian integer of n bytes
+func LE(v uint64, n int) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+
+	return b[:n]
+}
+
+// Str returns s prefixed by its length byte
+func Str(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+// Unhex decodes hex string, it panics if s is invalid
+func Unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// Master serves one replication connection: it accepts user repl, replies OK to commands
+// until COM_BINLOG_DUMP or COM_BINLOG_DUMP_GTID and sends events followed by EOF
+type Master struct {
+	T    *testing.T
+	Conn net.Conn
+	// Commands are commands received from replica
+	Commands [][]byte
+
+	seq byte
+}
+
+func (m *Master) write(payload []byte) {
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), m.seq}
+	m.seq++
+
+	_, err := m.Conn.Write(append(header, payload...))
+	if err != nil {
+		m.T.Error(err)
+	}
+}
+
+func (m *Master) read() []byte {
+	header := make([]byte, 4)
+
+	_, err := io.ReadFull(m.Conn, header)
+	if err != nil {
+		m.T.Error(err)
+		return nil
+	}
+
+	m.seq = header[3] + 1
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+
+	_, err = io.ReadFull(m.Conn, payload)
+	if err != nil {
+		m.T.Error(err)
+	}
+
+	return payload
+}
+
+func nativeScramble(password string, nonce []byte) []byte {
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	result := sha1.Sum(append(append([]byte{}, nonce...), stage2[:]...))
+
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+
+	return result[:]
+}
+
+// Serve handles the connection and closes it
+func (m *Master) Serve(password string, events [][]byte) {
+	defer m.Conn.Close()
+
+	nonce := []byte("abcdefghijklmnopqrst")
+	caps := uint32(0x00000200 | 0x00008000 | 0x00080000 | 0x00200000)
+
+	handshake := append([]byte{10}, "8.0.30\x00"...)
+	handshake = append(handshake, LE(7, 4)...)
+	handshake = append(append(handshake, nonce[:8]...), 0)
+	handshake = append(handshake, LE(uint64(caps), 2)...)
+	handshake = append(handshake, 45, 2, 0)
+	handshake = append(handshake, LE(uint64(caps>>16), 2)...)
+	handshake = append(append(handshake, 21), make([]byte, 10)...)
+	handshake = append(append(handshake, nonce[8:]...), 0)
+	handshake = append(handshake, "mysql_native_password\x00"...)
+
+	m.write(handshake)
+
+	resp := m.read()
+	// caps, max packet, charset, filler and NUL terminated user name
+	user := resp[32:]
+	name := string(user[:bytes.IndexByte(user, 0)])
+	auth := user[len(name)+2 : len(name)+2+int(user[len(name)+1])]
+
+	if name != "repl" || !bytes.Equal(auth, nativeScramble(password, nonce)) {
+		m.write([]byte("\xff\x15\x04#28000Access denied"))
+		return
+	}
+
+	m.write([]byte{0, 0, 0, 2, 0, 0, 0})
+
+	for {
+		command := m.read()
+		if command == nil {
+			return
+		}
+
+		m.Commands = append(m.Commands, command)
+
+		if command[0] == 0x12 || command[0] == 0x1e {
+			break
+		}
+
+		m.write([]byte{0, 0, 0, 2, 0, 0, 0})
+	}
+
+	for _, event := range events {
+		m.write(append([]byte{0}, event...))
+	}
+
+	m.write([]byte{0xfe, 0, 0, 2, 0})
+}
@@ -0,0 +1,97 @@
+package mask_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/partyzanex/repmy/pkg/mask"
+	"github.com/partyzanex/testutils"
+)
+
+const rulesJSON = `{
+  "salt": "staging",
+  "rules": [
+    {"column": "users.email", "transform": "email"},
+    {"column": "users.name", "transform": "name"},
+    {"column": "*.phone", "transform": "phone"},
+    {"column": "users.password", "transform": "constant", "value": "secret"},
+    {"column": "users.ssn", "transform": "null"},
+    {"column": "users.token", "transform": "hash", "length": 16},
+    {"column": "users.note", "transform": "random"},
+    {"column": "users.born", "transform": "shift-date", "days": -10}
+  ]
+}`
+
+func readRules(t *testing.T, data string) (*mask.Rules, error) {
+	dir, err := ioutil.TempDir("", "mask")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "rules.json")
+	testutils.FatalErr(t, "WriteFile", ioutil.WriteFile(file, []byte(data), 0600))
+
+	return mask.ReadRules(file)
+}
+
+func TestRules_Columns(t *testing.T) {
+	rules, err := readRules(t, rulesJSON)
+	testutils.FatalErr(t, "ReadRules", err)
+
+	columns := []string{"id", "email", "name", "phone", "password", "ssn", "token", "note", "born"}
+	transforms := rules.Columns("users", columns)
+
+	testutils.AssertEqual(t, "len", len(columns), len(transforms))
+	testutils.AssertEqual(t, "id", true, transforms[0] == nil)
+	testutils.AssertEqual(t, "orders", 0, len(rules.Columns("orders", []string{"id", "total"})))
+
+	apply := func(column int, value string) string {
+		v, _ := transforms[column]([]byte(value), false)
+		return string(v)
+	}
+	isNull := func(column int, value []byte, null bool) bool {
+		_, null = transforms[column](value, null)
+		return null
+	}
+
+	a, b := apply(1, "alice@corp.com"), apply(1, "bob@corp.com")
+	testutils.AssertEqual(t, "email deterministic", a, apply(1, "alice@corp.com"))
+	testutils.AssertEqual(t, "email unique", true, a != b)
+	testutils.AssertEqual(t, "name unique", true, apply(2, "Alice") != apply(2, "Bob"))
+	testutils.AssertEqual(t, "phone", 16, len(apply(3, "+1 202 555 0100")))
+	testutils.AssertEqual(t, "constant", "secret", apply(4, "hunter2"))
+	testutils.AssertEqual(t, "null", true, isNull(5, []byte("123-45-6789"), false))
+	testutils.AssertEqual(t, "hash", 16, len(apply(6, "token")))
+	testutils.AssertEqual(t, "random", utf8.RuneCountInString("привет!"), len(apply(7, "привет!")))
+	testutils.AssertEqual(t, "date", "1989-12-22", apply(8, "1990-01-01"))
+	testutils.AssertEqual(t, "datetime", "1989-12-22 10:00:00.500", apply(8, "1990-01-01 10:00:00.500"))
+	testutils.AssertEqual(t, "zero date", "0000-00-00", apply(8, "0000-00-00"))
+	testutils.AssertEqual(t, "NULL", true, isNull(1, nil, true))
+	// empty string is scanned as nil, it is not NULL
+	testutils.AssertEqual(t, "empty", false, isNull(4, nil, false))
+	testutils.AssertEqual(t, "empty constant", "secret", apply(4, ""))
+}
+
+func TestRules_Validate(t *testing.T) {
+	rules, err := readRules(t, rulesJSON)
+	testutils.FatalErr(t, "ReadRules", err)
+
+	tables := map[string][]string{
+		"users":  {"id", "email", "name", "password", "ssn", "token", "note", "born"},
+		"orders": {"id", "phone"},
+	}
+
+	testutils.FatalErr(t, "Validate", rules.Validate(tables))
+
+	tables["users"] = []string{"id", "e_mail", "name", "password", "ssn", "token", "note", "born"}
+	testutils.AssertEqual(t, "typo", true, rules.Validate(tables) != nil)
+
+	_, err = readRules(t, `{"rules": [{"column": "email", "transform": "email"}]}`)
+	testutils.AssertEqual(t, "format", true, err != nil)
+
+	_, err = readRules(t, `{"rules": [{"column": "users.email", "transform": "scramble"}]}`)
+	testutils.AssertEqual(t, "transform", true, err != nil)
+}
@@ -0,0 +1,123 @@
+// Package mask anonymizes column values of dumped rows
+package mask
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// Rule masks columns matched by pattern 'table.column' in syntax of path.Match
+type Rule struct {
+	Column    string `json:"column"`
+	Transform string `json:"transform"`
+	// Value is value of constant transform
+	Value string `json:"value,omitempty"`
+	// Length truncates hash if it is positive
+	Length int `json:"length,omitempty"`
+	// Days shifts dates of shift-date transform, negative shifts to the past
+	Days int `json:"days,omitempty"`
+}
+
+// Rules represents rules file, the first matched rule is applied to column
+type Rules struct {
+	// Salt keys hash and fake values, values are not reproducible without it
+	Salt  string `json:"salt"`
+	Rules []Rule `json:"rules"`
+
+	transforms []Transform
+}
+
+// ReadRules reads and compiles rules file
+func ReadRules(file string) (*Rules, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rules: %s", err)
+	}
+
+	rules := &Rules{}
+
+	err = json.Unmarshal(data, rules)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse rules %s: %s", file, err)
+	}
+
+	return rules, rules.Compile()
+}
+
+// Compile checks rules and prepares transforms
+func (r *Rules) Compile() error {
+	r.transforms = make([]Transform, len(r.Rules))
+
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+
+		if strings.Count(rule.Column, ".") != 1 {
+			return fmt.Errorf("column %q of rule is not in 'table.column' format", rule.Column)
+		}
+
+		if _, err := path.Match(rule.Column, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %s", rule.Column, err)
+		}
+
+		t, err := rule.transform([]byte(r.Salt))
+		if err != nil {
+			return err
+		}
+
+		r.transforms[i] = t
+	}
+
+	return nil
+}
+
+// Columns returns transforms of table columns in their order, nil for columns without rules
+func (r *Rules) Columns(table string, columns []string) []Transform {
+	if r == nil {
+		return nil
+	}
+
+	var transforms []Transform
+
+	for i, column := range columns {
+		for j, rule := range r.Rules {
+			if ok, _ := path.Match(rule.Column, table+"."+column); !ok {
+				continue
+			}
+
+			if transforms == nil {
+				transforms = make([]Transform, len(columns))
+			}
+
+			transforms[i] = r.transforms[j]
+
+			break
+		}
+	}
+
+	return transforms
+}
+
+// Validate checks that every rule matches a column of tables,
+// tables maps names of tables to their columns
+func (r *Rules) Validate(tables map[string][]string) error {
+	for _, rule := range r.Rules {
+		matched := false
+
+		for table, columns := range tables {
+			for _, column := range columns {
+				if ok, _ := path.Match(rule.Column, table+"."+column); ok {
+					matched = true
+				}
+			}
+		}
+
+		if !matched {
+			return fmt.Errorf("rule %s does not match any column of dumped tables", rule.Column)
+		}
+	}
+
+	return nil
+}
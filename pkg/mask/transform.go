@@ -0,0 +1,143 @@
+package mask
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Transform returns masked value, null is true for NULL, so it differs from empty string
+type Transform func(value []byte, null bool) ([]byte, bool)
+
+// names of transforms in rules file
+const (
+	Constant  = "constant"
+	Null      = "null"
+	Hash      = "hash"
+	Email     = "email"
+	Name      = "name"
+	Phone     = "phone"
+	Random    = "random"
+	ShiftDate = "shift-date"
+)
+
+var (
+	firstNames = []string{"James", "Mary", "John", "Linda", "Robert", "Susan", "Michael", "Karen",
+		"David", "Nancy", "Daniel", "Laura", "Paul", "Emma", "Mark", "Olga"}
+	lastNames = []string{"Smith", "Johnson", "Brown", "Miller", "Davis", "Wilson", "Moore", "Taylor",
+		"Clark", "Lewis", "Walker", "Young", "Allen", "King", "Scott", "Green"}
+)
+
+const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// transform returns Transform of rule, salt keys deterministic transforms
+func (r *Rule) transform(salt []byte) (Transform, error) {
+	sum := func(value []byte) []byte {
+		mac := hmac.New(sha256.New, salt)
+		mac.Write(value)
+
+		return mac.Sum(nil)
+	}
+
+	switch r.Transform {
+	case Constant:
+		value := []byte(r.Value)
+
+		return notNull(func([]byte) []byte {
+			return value
+		}), nil
+	case Null:
+		return func([]byte, bool) ([]byte, bool) { return nil, true }, nil
+	case Hash:
+		return notNull(func(v []byte) []byte {
+			s := hex.EncodeToString(sum(v))
+			if r.Length > 0 && r.Length < len(s) {
+				s = s[:r.Length]
+			}
+
+			return []byte(s)
+		}), nil
+	case Email:
+		// the local part is long enough to keep distinct values distinct
+		return notNull(func(v []byte) []byte {
+			return []byte("user_" + hex.EncodeToString(sum(v)[:10]) + "@example.com")
+		}), nil
+	case Name:
+		return notNull(func(v []byte) []byte {
+			h := sum(v)
+
+			return []byte(fmt.Sprintf("%s %s-%s", firstNames[int(h[0])%len(firstNames)],
+				lastNames[int(h[1])%len(lastNames)], hex.EncodeToString(h[2:10])))
+		}), nil
+	case Phone:
+		// numbers have 15 digits of E.164, so distinct values collide with probability
+		// about n^2/2e15: 1 of 2000 for a million of numbers, use hash for unique columns
+		return notNull(func(v []byte) []byte {
+			return []byte(fmt.Sprintf("+%015d", binary.BigEndian.Uint64(sum(v))%1e15))
+		}), nil
+	case Random:
+		return notNull(func(v []byte) []byte {
+			b := make([]byte, utf8.RuneCount(v))
+			for i := range b {
+				b[i] = alphabet[rand.Intn(len(alphabet))]
+			}
+
+			return b
+		}), nil
+	case ShiftDate:
+		shift := time.Duration(r.Days) * 24 * time.Hour
+
+		return notNull(func(v []byte) []byte {
+			return shiftDate(v, shift)
+		}), nil
+	}
+
+	return nil, fmt.Errorf("unknown transform %q of %s", r.Transform, r.Column)
+}
+
+// notNull returns Transform of fn which keeps NULL values
+func notNull(fn func(value []byte) []byte) Transform {
+	return func(v []byte, null bool) ([]byte, bool) {
+		if null {
+			return nil, true
+		}
+
+		return fn(v), false
+	}
+}
+
+var dateLayouts = []string{"2006-01-02 15:04:05.999999", "2006-01-02 15:04:05", "2006-01-02"}
+
+// shiftDate shifts DATE, DATETIME and TIMESTAMP values keeping their format,
+// zero and unparsed values are not changed
+func shiftDate(v []byte, shift time.Duration) []byte {
+	s := string(v)
+
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+
+		out := t.Add(shift).Format(layout)
+
+		// fractional part keeps its width
+		if i := strings.IndexByte(s, '.'); i > 0 && len(out) < len(s) {
+			if !strings.Contains(out, ".") {
+				out += "."
+			}
+
+			out += strings.Repeat("0", len(s)-len(out))
+		}
+
+		return []byte(out)
+	}
+
+	return v
+}
@@ -0,0 +1,42 @@
+package master
+
+import (
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+)
+
+// ER_NONEXISTING_GRANT
+const errNonexistingGrant = 1141
+
+// account returns quoted account name 'user'@'host'
+func account(q quote.Quoter, user mysql.ReplUser) string {
+	return q.Account(user.Name, user.GetHost())
+}
+
+// identifiedBy returns IDENTIFIED [WITH plugin] BY 'password' clause
+func identifiedBy(q quote.Quoter, user mysql.ReplUser) string {
+	clause := ` IDENTIFIED`
+
+	if user.AuthPlugin != "" {
+		clause += ` WITH ` + string(user.AuthPlugin)
+	}
+
+	return clause + ` BY ` + q.String(user.Password)
+}
+
+// require returns REQUIRE clause which resets SSL requirement of the existing account
+func require(user mysql.ReplUser) string {
+	if user.RequireSSL {
+		return ` REQUIRE SSL`
+	}
+
+	return ` REQUIRE NONE`
+}
+
+func isErrNumber(err error, number uint16) bool {
+	var e *driver.MySQLError
+
+	return errors.As(err, &e) && e.Number == number
+}
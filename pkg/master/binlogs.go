@@ -0,0 +1,57 @@
+package master
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// BinaryLog represents row of SHOW BINARY LOGS
+type BinaryLog struct {
+	Name string
+	Size int64
+}
+
+// BinaryLogs returns binlog files of master from the oldest,
+// columns after Log_name and File_size (Encrypted in 8.0) are ignored
+func (repo *Repository) BinaryLogs(ctx context.Context) ([]BinaryLog, error) {
+	rows, err := repo.db.QueryContext(ctx, `SHOW BINARY LOGS`)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get binary logs")
+	}
+
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get columns of binary logs")
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	logs := make([]BinaryLog, 0)
+
+	for rows.Next() {
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan binary log")
+		}
+
+		log := BinaryLog{Name: string(values[0])}
+
+		if len(values) > 1 {
+			log.Size, _ = strconv.ParseInt(string(values[1]), 10, 64)
+		}
+
+		logs = append(logs, log)
+	}
+
+	return logs, errors.Wrap(rows.Err(), "unable to read binary logs")
+}
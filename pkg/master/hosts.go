@@ -0,0 +1,102 @@
+package master
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/pkg/errors"
+)
+
+// SlaveHost represents row of SHOW SLAVE HOSTS (SHOW REPLICAS)
+type SlaveHost struct {
+	ServerID  int64
+	Host      string
+	Port      int
+	MasterID  int64
+	SlaveUUID string
+}
+
+// ShowSlaveHosts returns replicas registered on master,
+// SHOW REPLICAS is used on MySQL 8.0.22 and newer
+func (repo *Repository) ShowSlaveHosts(ctx context.Context, version mysql.Version) ([]SlaveHost, error) {
+	q := `SHOW SLAVE HOSTS`
+	if !version.MariaDB && version.AtLeast(8, 0, 22) {
+		q = `SHOW REPLICAS`
+	}
+
+	rows, err := repo.db.QueryxContext(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get slave hosts")
+	}
+
+	defer rows.Close()
+
+	hosts := make([]SlaveHost, 0)
+
+	for rows.Next() {
+		row := make(map[string]interface{})
+
+		err = rows.MapScan(row)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan slave host")
+		}
+
+		// column names differ between versions: Master_id/Source_Id, Slave_UUID/Replica_UUID
+		values := make(map[string]string, len(row))
+
+		for name, value := range row {
+			values[strings.ToLower(name)] = toString(value)
+		}
+
+		host := SlaveHost{
+			Host:      values["host"],
+			SlaveUUID: values["slave_uuid"] + values["replica_uuid"],
+		}
+
+		host.ServerID, _ = strconv.ParseInt(values["server_id"], 10, 64)
+		host.Port, _ = strconv.Atoi(values["port"])
+		host.MasterID, _ = strconv.ParseInt(values["master_id"]+values["source_id"], 10, 64)
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, errors.Wrap(rows.Err(), "unable to get slave hosts")
+}
+
+// BinlogDumpHosts returns client hosts of binlog dump threads,
+// replicas are found even if they do not report their address
+func (repo *Repository) BinlogDumpHosts(ctx context.Context) ([]string, error) {
+	var hosts []string
+
+	err := repo.db.SelectContext(ctx, &hosts, `
+SELECT HOST FROM information_schema.PROCESSLIST
+WHERE COMMAND IN ('Binlog Dump', 'Binlog Dump GTID')`)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get binlog dump threads")
+	}
+
+	for i, host := range hosts {
+		// port of client connection is not a port of replica
+		if j := strings.LastIndex(host, ":"); j > 0 {
+			host = host[:j]
+		}
+
+		hosts[i] = strings.Trim(host, "[]")
+	}
+
+	return hosts, nil
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,277 @@
+package master
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// LockMode represents the way of global read lock
+type LockMode int
+
+const (
+	// LockFlushTables executes FLUSH TABLES WITH READ LOCK, it blocks writes,
+	// so data read while the lock is held is consistent with the binlog position
+	LockFlushTables LockMode = iota
+	// LockInstance executes LOCK INSTANCE FOR BACKUP (MySQL 8.0.17+),
+	// the binlog position is read from performance_schema.log_status atomically.
+	// It blocks DDL only: data is consistent with the position only if it is read
+	// by a consistent snapshot transaction started while the lock is held.
+	LockInstance
+)
+
+const (
+	DefaultLockWaitTimeout = 60 * time.Second
+	DefaultLockKeepAlive   = time.Second
+
+	unlockTimeout = 10 * time.Second
+)
+
+var (
+	// ErrLockConnLost is returned by Lock.Err if connection holding the lock was lost
+	ErrLockConnLost = errors.New("connection holding the lock was lost")
+)
+
+// LockOptions represents options of Repository.ReadLock
+type LockOptions struct {
+	// Mode is LockFlushTables by default
+	Mode LockMode
+	// WaitTimeout sets lock_wait_timeout for the locking session
+	WaitTimeout time.Duration
+	// KeepAlive is interval of checking connection holding the lock
+	KeepAlive time.Duration
+}
+
+// Lock represents global read lock held on dedicated connection
+type Lock struct {
+	conn   *sql.Conn
+	mode   LockMode
+	status Status
+
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// ReadLock acquires global read lock on dedicated connection
+// and reads binlog position under the lock.
+// The lock is released on Unlock, on ctx cancellation or on connection loss.
+func (repo *Repository) ReadLock(ctx context.Context, opts LockOptions) (*Lock, error) {
+	if opts.WaitTimeout <= 0 {
+		opts.WaitTimeout = DefaultLockWaitTimeout
+	}
+
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = DefaultLockKeepAlive
+	}
+
+	conn, err := repo.db.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get connection")
+	}
+
+	lock := &Lock{
+		conn: conn,
+		mode: opts.Mode,
+		done: make(chan struct{}),
+	}
+
+	err = lock.acquire(ctx, opts)
+	if err != nil {
+		lock.release(err)
+		return nil, err
+	}
+
+	go lock.watch(ctx, opts.KeepAlive)
+
+	return lock, nil
+}
+
+// Status returns binlog position read under the lock
+func (lock *Lock) Status() Status {
+	return lock.status
+}
+
+// Mode returns the way the lock was acquired
+func (lock *Lock) Mode() LockMode {
+	return lock.mode
+}
+
+// Done returns channel which is closed when the lock is released
+func (lock *Lock) Done() <-chan struct{} {
+	return lock.done
+}
+
+// Err returns the reason of release: nil if Unlock was called,
+// context error or ErrLockConnLost
+func (lock *Lock) Err() error {
+	select {
+	case <-lock.done:
+		return lock.err
+	default:
+		return nil
+	}
+}
+
+// Unlock releases the lock and the connection,
+// returns the error of unlocking or the reason of earlier release
+func (lock *Lock) Unlock() error {
+	lock.release(nil)
+
+	return lock.err
+}
+
+func (lock *Lock) acquire(ctx context.Context, opts LockOptions) error {
+	timeout := int(opts.WaitTimeout / time.Second)
+	if timeout < 1 {
+		timeout = 1
+	}
+
+	_, err := lock.conn.ExecContext(ctx, `SET SESSION lock_wait_timeout = ?`, timeout)
+	if err != nil {
+		return errors.Wrap(err, "unable to set lock_wait_timeout")
+	}
+
+	switch lock.mode {
+	case LockFlushTables:
+		_, err = lock.conn.ExecContext(ctx, `FLUSH TABLES WITH READ LOCK`)
+		if err != nil {
+			return errors.Wrap(err, "unable to flush tables with read lock")
+		}
+
+		return lock.readStatus(ctx)
+	case LockInstance:
+		_, err = lock.conn.ExecContext(ctx, `LOCK INSTANCE FOR BACKUP`)
+		if err != nil {
+			return errors.Wrap(err, "unable to lock instance for backup")
+		}
+
+		return lock.readLogStatus(ctx)
+	default:
+		return errors.Errorf("unknown lock mode %d", lock.mode)
+	}
+}
+
+// readStatus executes SHOW MASTER STATUS on the locked connection
+func (lock *Lock) readStatus(ctx context.Context) error {
+	rows, err := lock.conn.QueryContext(ctx, `SHOW MASTER STATUS`)
+	if err != nil {
+		return errors.Wrap(err, "unable to get master status")
+	}
+
+	defer rows.Close()
+
+	var statuses []Status
+
+	err = sqlx.StructScan(rows, &statuses)
+	if err != nil {
+		return errors.Wrap(err, "unable to scan master status")
+	}
+
+	if len(statuses) == 0 {
+		return errors.New("binary logging is disabled")
+	}
+
+	lock.status = statuses[0]
+
+	return nil
+}
+
+type logStatus struct {
+	GTIDExecuted string `json:"gtid_executed"`
+	File         string `json:"binary_log_file"`
+	Position     int    `json:"binary_log_position"`
+}
+
+// readLogStatus reads binlog position from performance_schema.log_status
+func (lock *Lock) readLogStatus(ctx context.Context) error {
+	var local []byte
+
+	err := lock.conn.QueryRowContext(ctx, `SELECT LOCAL FROM performance_schema.log_status`).Scan(&local)
+	if err != nil {
+		return errors.Wrap(err, "unable to get log status")
+	}
+
+	status := logStatus{}
+
+	err = json.Unmarshal(local, &status)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse log status %s", local)
+	}
+
+	if status.File == "" {
+		return errors.New("binary logging is disabled")
+	}
+
+	lock.status = Status{
+		File:            status.File,
+		Position:        status.Position,
+		ExecutedGTIDSet: status.GTIDExecuted,
+	}
+
+	return nil
+}
+
+func (lock *Lock) watch(ctx context.Context, keepAlive time.Duration) {
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lock.done:
+			return
+		case <-ctx.Done():
+			lock.release(ctx.Err())
+			return
+		case <-ticker.C:
+			err := lock.conn.PingContext(ctx)
+			if err != nil && err != sql.ErrConnDone && ctx.Err() == nil {
+				logrus.Errorf("lost connection holding the lock: %s", err)
+				lock.release(ErrLockConnLost)
+
+				return
+			}
+		}
+	}
+}
+
+// release unlocks and closes the connection once
+func (lock *Lock) release(reason error) {
+	lock.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancel()
+
+		q := `UNLOCK TABLES`
+		if lock.mode == LockInstance {
+			q = `UNLOCK INSTANCE`
+		}
+
+		_, err := lock.conn.ExecContext(ctx, q)
+		if err != nil {
+			// the session may still hold the lock,
+			// so the connection must not return to the pool
+			_ = lock.conn.Raw(func(interface{}) error {
+				return driver.ErrBadConn
+			})
+
+			if reason == nil {
+				reason = errors.Wrapf(err, "unable to execute %s", q)
+			}
+		}
+
+		err = lock.conn.Close()
+		if err != nil && err != sql.ErrConnDone && reason == nil {
+			reason = errors.Wrap(err, "unable to close connection")
+		}
+
+		lock.err = reason
+		close(lock.done)
+	})
+}
@@ -0,0 +1,77 @@
+package master_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/testutils"
+)
+
+func TestRepository_ReadLock(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := master.New(db)
+	ctx := context.Background()
+
+	mock.ExpectExec(`SET SESSION lock_wait_timeout = ?`).WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`FLUSH TABLES WITH READ LOCK`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SHOW MASTER STATUS`).WillReturnRows(
+		sqlmock.NewRows([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}).
+			AddRow("mysql-bin.000003", 154, "", "", ""),
+	)
+	mock.ExpectExec(`UNLOCK TABLES`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	lock, err := repo.ReadLock(ctx, master.LockOptions{WaitTimeout: 5 * time.Second})
+	testutils.FatalErr(t, "repo.ReadLock", err)
+
+	testutils.AssertEqual(t, "Mode", master.LockFlushTables, lock.Mode())
+	testutils.AssertEqual(t, "File", "mysql-bin.000003", lock.Status().File)
+	testutils.AssertEqual(t, "Position", 154, lock.Status().Position)
+
+	err = lock.Unlock()
+	testutils.FatalErr(t, "lock.Unlock()", err)
+
+	<-lock.Done()
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_ReadLock_Cancel(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := master.New(db)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mock.ExpectExec(`SET SESSION lock_wait_timeout = ?`).WithArgs(60).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`LOCK INSTANCE FOR BACKUP`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT LOCAL FROM performance_schema.log_status`).WillReturnRows(
+		sqlmock.NewRows([]string{"LOCAL"}).AddRow(
+			`{"gtid_executed": "uuid:1-10", "binary_log_file": "binlog.000002", "binary_log_position": 1280}`,
+		),
+	)
+	mock.ExpectExec(`UNLOCK INSTANCE`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	lock, err := repo.ReadLock(ctx, master.LockOptions{Mode: master.LockInstance})
+	testutils.FatalErr(t, "repo.ReadLock", err)
+
+	testutils.AssertEqual(t, "Position", 1280, lock.Status().Position)
+	testutils.AssertEqual(t, "ExecutedGTIDSet", "uuid:1-10", lock.Status().ExecutedGTIDSet)
+
+	cancel()
+
+	select {
+	case <-lock.Done():
+	case <-time.After(time.Second):
+		t.Fatal("lock was not released on context cancellation")
+	}
+
+	testutils.AssertEqual(t, "Err", context.Canceled, lock.Err())
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
@@ -3,11 +3,10 @@ package master
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"log"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/quote"
 	"github.com/pkg/errors"
 )
 
@@ -28,53 +27,184 @@ func (repo *Repository) ShowStatus(ctx context.Context) (status *Status, err err
 	return
 }
 
-// SetReplUser sets replication user
+// SetReplUser creates replication user or updates the existing one
+// and grants REPLICATION SLAVE privilege to it
 func (repo *Repository) SetReplUser(ctx context.Context, user mysql.ReplUser) error {
-	q := `grant replication slave on %s.* to '%s'@'%s' identified by '%s';`
-	q = fmt.Sprintf(q, user.GetDatabase(), user.Name, user.GetHost(), user.Password)
+	err := repo.CreateReplUser(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return repo.GrantReplication(ctx, user)
+}
+
+// CreateReplUser executes CREATE USER IF NOT EXISTS for replication user,
+// password, auth plugin and SSL requirement of the existing user are changed by ALTER USER
+func (repo *Repository) CreateReplUser(ctx context.Context, user mysql.ReplUser) error {
+	err := user.Validate()
+	if err != nil {
+		return errors.Wrap(err, "invalid replication user")
+	}
+
+	exists, err := repo.ReplUserExists(ctx, user)
+	if err != nil {
+		return err
+	}
 
-	_, err := repo.db.ExecContext(ctx, q)
+	conn, quoter, err := quote.Conn(ctx, repo.db)
 	if err != nil {
-		return errors.Wrap(err, "unable to execute query")
+		return err
 	}
 
-	_, err = repo.db.ExecContext(ctx, `flush privileges;`)
+	defer conn.Close()
+
+	if exists {
+		_, err = conn.ExecContext(ctx, `ALTER USER `+account(quoter, user)+identifiedBy(quoter, user)+require(user))
+		if err != nil {
+			return errors.Wrap(err, "unable to alter replication user")
+		}
+
+		return nil
+	}
+
+	q := `CREATE USER IF NOT EXISTS ` + account(quoter, user) + identifiedBy(quoter, user)
+
+	if user.RequireSSL {
+		q += ` REQUIRE SSL`
+	}
+
+	_, err = conn.ExecContext(ctx, q)
 	if err != nil {
-		return errors.Wrap(err, "unable to flush privileges")
+		return errors.Wrap(err, "unable to create replication user")
 	}
 
 	return nil
 }
 
-// ReadLock locks read for table in database
-// returns channel for done
-func (repo *Repository) ReadLock(ctx context.Context, dbName string) (chan<- struct{}, error) {
-	// prepare sql queries
-	q := fmt.Sprintf(`use %s; flush tables with read lock;`, dbName)
+// GrantReplication grants global REPLICATION SLAVE privilege
+func (repo *Repository) GrantReplication(ctx context.Context, user mysql.ReplUser) error {
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
 
-	_, err := repo.db.ExecContext(ctx, q)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `GRANT REPLICATION SLAVE ON *.* TO `+account(quoter, user))
+	if err != nil {
+		return errors.Wrap(err, "unable to grant replication privilege")
+	}
+
+	return nil
+}
+
+// RotateReplPassword sets user.Password as the new password of replication user.
+// If retainCurrent is true, the current password stays valid
+// until DiscardOldReplPassword is called (MySQL 8.0.14+),
+// so running replicas do not lose connection while they are reconfigured.
+func (repo *Repository) RotateReplPassword(ctx context.Context, user mysql.ReplUser, retainCurrent bool) error {
+	err := user.Validate()
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to execute query")
+		return errors.Wrap(err, "invalid replication user")
 	}
 
-	done := make(chan struct{})
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
 
-	go func() {
-		// copy database connection
-		db := *repo.db
+	defer conn.Close()
 
-		// waiting for unlock tables
-		select {
-		case <-ctx.Done():
-		case <-done:
-			_, err := db.Exec(`unlock tables`)
-			if err != nil {
-				log.Printf(`unable to execute query: 'unlock tables', error: %s`, err)
-			}
-		}
-	}()
+	q := `ALTER USER IF EXISTS ` + account(quoter, user)
+
+	if retainCurrent {
+		// changing of auth plugin discards the retained password
+		q += ` IDENTIFIED BY ` + quoter.String(user.Password) + ` RETAIN CURRENT PASSWORD`
+	} else {
+		q += identifiedBy(quoter, user)
+	}
+
+	_, err = conn.ExecContext(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "unable to rotate password of replication user")
+	}
+
+	return nil
+}
+
+// DiscardOldReplPassword discards the password retained by RotateReplPassword
+func (repo *Repository) DiscardOldReplPassword(ctx context.Context, user mysql.ReplUser) error {
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `ALTER USER IF EXISTS `+account(quoter, user)+` DISCARD OLD PASSWORD`)
+	if err != nil {
+		return errors.Wrap(err, "unable to discard old password of replication user")
+	}
+
+	return nil
+}
+
+// RevokeReplication revokes REPLICATION SLAVE privilege,
+// does nothing if user or privilege does not exist
+func (repo *Repository) RevokeReplication(ctx context.Context, user mysql.ReplUser) error {
+	exists, err := repo.ReplUserExists(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `REVOKE REPLICATION SLAVE ON *.* FROM `+account(quoter, user))
+	if err != nil && !isErrNumber(err, errNonexistingGrant) {
+		return errors.Wrap(err, "unable to revoke replication privilege")
+	}
+
+	return nil
+}
+
+// DropReplUser executes DROP USER IF EXISTS for replication user
+func (repo *Repository) DropReplUser(ctx context.Context, user mysql.ReplUser) error {
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `DROP USER IF EXISTS `+account(quoter, user))
+	if err != nil {
+		return errors.Wrap(err, "unable to drop replication user")
+	}
+
+	return nil
+}
+
+// ReplUserExists checks that account of replication user exists
+func (repo *Repository) ReplUserExists(ctx context.Context, user mysql.ReplUser) (bool, error) {
+	var count int
+
+	q := `SELECT COUNT(*) FROM mysql.user WHERE User = ? AND Host = ?`
+
+	err := repo.db.GetContext(ctx, &count, q, user.Name, user.GetHost())
+	if err != nil {
+		return false, errors.Wrap(err, "unable to check replication user")
+	}
 
-	return done, nil
+	return count > 0, nil
 }
 
 // New creates a new repository
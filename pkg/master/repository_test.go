@@ -4,7 +4,8 @@ import (
 	"context"
 	"testing"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/DATA-DOG/go-sqlmock"
+	driver "github.com/go-sql-driver/mysql"
 
 	"github.com/partyzanex/repmy/pkg/master"
 	"github.com/partyzanex/repmy/pkg/mysql"
@@ -37,3 +38,97 @@ func TestRepository_SetReplUser(t *testing.T) {
 	})
 	testutils.FatalErr(t, "repo.SetReplUser(ctx, user.User{})", err)
 }
+
+func TestRepository_CreateReplUser(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := master.New(db)
+	ctx := context.Background()
+
+	user := mysql.ReplUser{
+		Name:       "repl",
+		Host:       "10.0.%",
+		Password:   `it's\secret`,
+		AuthPlugin: mysql.CachingSHA2Password,
+		RequireSSL: true,
+	}
+	exists := `SELECT COUNT(*) FROM mysql.user WHERE User = ? AND Host = ?`
+
+	mock.ExpectQuery(exists).WithArgs("repl", "10.0.%").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow("NO_BACKSLASH_ESCAPES"))
+	mock.ExpectExec(`CREATE USER IF NOT EXISTS 'repl'@'10.0.%' ` +
+		`IDENTIFIED WITH caching_sha2_password BY 'it''s\secret' REQUIRE SSL`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.CreateReplUser(ctx, user)
+	testutils.FatalErr(t, "repo.CreateReplUser", err)
+
+	user.RequireSSL = false
+
+	mock.ExpectQuery(exists).WithArgs("repl", "10.0.%").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow("NO_BACKSLASH_ESCAPES"))
+	mock.ExpectExec(`ALTER USER 'repl'@'10.0.%' ` +
+		`IDENTIFIED WITH caching_sha2_password BY 'it''s\secret' REQUIRE NONE`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.CreateReplUser(ctx, user)
+	testutils.FatalErr(t, "repo.CreateReplUser (exists)", err)
+
+	err = repo.CreateReplUser(ctx, mysql.ReplUser{Name: "repl", AuthPlugin: "sha256_password"})
+	testutils.AssertEqual(t, "unsupported plugin", err != nil, true)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_RevokeReplication(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := master.New(db)
+	ctx := context.Background()
+	user := mysql.ReplUser{Name: "repl"}
+
+	q := `SELECT COUNT(*) FROM mysql.user WHERE User = ? AND Host = ?`
+
+	mock.ExpectQuery(q).WithArgs("repl", "%").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+
+	err = repo.RevokeReplication(ctx, user)
+	testutils.FatalErr(t, "repo.RevokeReplication (not exists)", err)
+
+	mock.ExpectQuery(q).WithArgs("repl", "%").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`REVOKE REPLICATION SLAVE ON *.* FROM 'repl'@'%'`).
+		WillReturnError(&driver.MySQLError{Number: 1141, Message: "There is no such grant"})
+
+	err = repo.RevokeReplication(ctx, user)
+	testutils.FatalErr(t, "repo.RevokeReplication (no grant)", err)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_BinaryLogs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := master.New(db)
+
+	mock.ExpectQuery(`SHOW BINARY LOGS`).WillReturnRows(
+		sqlmock.NewRows([]string{"Log_name", "File_size", "Encrypted"}).
+			AddRow("binlog.000001", 1024, "No").
+			AddRow("binlog.000002", 155, "No"),
+	)
+
+	logs, err := repo.BinaryLogs(context.Background())
+	testutils.FatalErr(t, "repo.BinaryLogs", err)
+
+	testutils.AssertEqual(t, "logs", 2, len(logs))
+	testutils.AssertEqual(t, "first", master.BinaryLog{Name: "binlog.000001", Size: 1024}, logs[0])
+}
@@ -0,0 +1,208 @@
+// Package monitor watches replicas and emits alert events
+package monitor
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/pool"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultInterval = 10 * time.Second
+
+	// names of built-in rules
+	ruleUnreachable   = "unreachable"
+	ruleNotReplicated = "not_replicating"
+)
+
+// Replica represents watched replica
+type Replica struct {
+	Name string
+	DB   *sql.DB
+}
+
+// Monitor polls replicas and evaluates rules
+type Monitor struct {
+	Replicas  []Replica
+	Rules     []Rule
+	Notifiers []Notifier
+
+	// Interval between polls of replica
+	Interval time.Duration
+	// Repeat is interval of repeated notifications for firing alerts,
+	// firing alerts are notified once if zero
+	Repeat time.Duration
+
+	mu     sync.Mutex
+	alerts map[alertKey]*alert
+	prev   map[alertKey]*slave.Status
+}
+
+type alertKey struct {
+	replica, channel, rule string
+}
+
+type alert struct {
+	message  string
+	notified time.Time
+}
+
+// Run starts polling of replicas and blocks until ctx is done
+func (m *Monitor) Run(ctx context.Context) error {
+	if len(m.Replicas) == 0 {
+		return errors.New("no replicas to monitor")
+	}
+
+	if m.Interval <= 0 {
+		m.Interval = DefaultInterval
+	}
+
+	processes := &pool.ProcessPool{}
+	errs := processes.Errors()
+	logger := &pool.ProcessPool{}
+
+	logger.RunProcess(ctx, func(ctx context.Context) error {
+		for err := range errs {
+			logrus.Errorf("monitor error: %s", err)
+		}
+
+		return nil
+	}, nil)
+
+	params := &pool.Params{
+		Loop:  true,
+		Delay: m.Interval,
+	}
+
+	for _, replica := range m.Replicas {
+		processes.RunProcess(ctx, m.watch(replica), params)
+	}
+
+	processes.Wait()
+	logger.Wait()
+
+	return nil
+}
+
+func (m *Monitor) watch(replica Replica) pool.Process {
+	repo := slave.New(replica.DB)
+
+	return func(ctx context.Context) error {
+		return m.Poll(ctx, replica.Name, repo)
+	}
+}
+
+// Poll evaluates rules for the current status of replica once
+func (m *Monitor) Poll(ctx context.Context, name string, repo *slave.Repository) error {
+	m.init()
+
+	statuses, err := repo.ShowStatuses(ctx)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	m.set(ctx, alertKey{replica: name, rule: ruleUnreachable}, err != nil, errMessage(err))
+
+	if err != nil {
+		return errors.Wrapf(err, "polling of %s failed", name)
+	}
+
+	m.set(ctx, alertKey{replica: name, rule: ruleNotReplicated}, len(statuses) == 0,
+		"replication is not configured")
+
+	for i := range statuses {
+		cur := &statuses[i]
+		channel := alertKey{replica: name, channel: cur.ChannelName}
+
+		m.mu.Lock()
+		prev := m.prev[channel]
+		m.prev[channel] = cur
+		m.mu.Unlock()
+
+		for _, rule := range m.Rules {
+			firing, message := rule.Check(prev, cur)
+			key := alertKey{replica: name, channel: cur.ChannelName, rule: rule.Name()}
+
+			if r, ok := rule.(OneShot); ok && r.OneShot() {
+				if firing {
+					m.notify(ctx, key, Firing, message)
+				}
+
+				continue
+			}
+
+			m.set(ctx, key, firing, message)
+		}
+	}
+
+	return nil
+}
+
+func (m *Monitor) init() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.alerts == nil {
+		m.alerts = make(map[alertKey]*alert)
+		m.prev = make(map[alertKey]*slave.Status)
+	}
+}
+
+// set updates state of alert and sends notifications on changes
+func (m *Monitor) set(ctx context.Context, key alertKey, firing bool, message string) {
+	m.mu.Lock()
+	active, ok := m.alerts[key]
+	now := time.Now()
+
+	state := State("")
+
+	switch {
+	case firing && !ok:
+		m.alerts[key] = &alert{message: message, notified: now}
+		state = Firing
+	case firing && (active.message != message || m.Repeat > 0 && now.Sub(active.notified) >= m.Repeat):
+		active.message, active.notified = message, now
+		state = Firing
+	case !firing && ok:
+		delete(m.alerts, key)
+		state, message = Resolved, "resolved: "+active.message
+	}
+
+	m.mu.Unlock()
+
+	if state != "" {
+		m.notify(ctx, key, state, message)
+	}
+}
+
+func (m *Monitor) notify(ctx context.Context, key alertKey, state State, message string) {
+	event := Event{
+		Time:    time.Now(),
+		Replica: key.replica,
+		Channel: key.channel,
+		Rule:    key.rule,
+		State:   state,
+		Message: message,
+	}
+
+	for _, notifier := range m.Notifiers {
+		err := notifier.Notify(ctx, event)
+		if err != nil {
+			logrus.Errorf("unable to notify about %s/%s: %s", event.Replica, event.Rule, err)
+		}
+	}
+}
+
+func errMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
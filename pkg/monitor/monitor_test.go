@@ -0,0 +1,98 @@
+package monitor_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/monitor"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/partyzanex/testutils"
+)
+
+type events struct {
+	mu   sync.Mutex
+	list []monitor.Event
+}
+
+func (e *events) Notify(_ context.Context, event monitor.Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.list = append(e.list, event)
+
+	return nil
+}
+
+func (e *events) take() []monitor.Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := e.list
+	e.list = nil
+
+	return list
+}
+
+var statusColumns = []string{
+	"Slave_IO_Running", "Slave_SQL_Running", "Seconds_Behind_Master",
+	"Last_SQL_Errno", "Last_SQL_Error", "Last_SQL_Error_Timestamp", "Channel_Name",
+}
+
+func TestMonitor_Poll(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	notifier := &events{}
+	m := &monitor.Monitor{
+		Rules:     monitor.DefaultRules(time.Minute),
+		Notifiers: []monitor.Notifier{notifier},
+	}
+
+	repo := slave.New(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery("show slave status").WillReturnRows(
+		sqlmock.NewRows(statusColumns).AddRow("Yes", "Yes", 5, 0, "", "", ""),
+	)
+
+	err = m.Poll(ctx, "replica1", repo)
+	testutils.FatalErr(t, "m.Poll", err)
+	testutils.AssertEqual(t, "no events", 0, len(notifier.take()))
+
+	mock.ExpectQuery("show slave status").WillReturnRows(
+		sqlmock.NewRows(statusColumns).AddRow("Yes", "No", nil, 1062, "Duplicate entry", "201019 10:00:00", ""),
+	)
+
+	err = m.Poll(ctx, "replica1", repo)
+	testutils.FatalErr(t, "m.Poll", err)
+
+	rules := map[string]monitor.State{}
+	for _, event := range notifier.take() {
+		rules[event.Rule] = event.State
+	}
+
+	testutils.AssertEqual(t, "events", 2, len(rules))
+	testutils.AssertEqual(t, "sql_thread_stopped", monitor.Firing, rules["sql_thread_stopped"])
+	testutils.AssertEqual(t, "error_increasing", monitor.Firing, rules["error_increasing"])
+
+	mock.ExpectQuery("show slave status").WillReturnRows(
+		sqlmock.NewRows(statusColumns).AddRow("Yes", "Yes", 120, 1062, "Duplicate entry", "201019 10:00:00", ""),
+	)
+
+	err = m.Poll(ctx, "replica1", repo)
+	testutils.FatalErr(t, "m.Poll", err)
+
+	rules = map[string]monitor.State{}
+	for _, event := range notifier.take() {
+		rules[event.Rule] = event.State
+	}
+
+	testutils.AssertEqual(t, "events", 2, len(rules))
+	testutils.AssertEqual(t, "sql_thread_stopped", monitor.Resolved, rules["sql_thread_stopped"])
+	testutils.AssertEqual(t, "lag_above", monitor.Firing, rules["lag_above"])
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
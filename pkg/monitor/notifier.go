@@ -0,0 +1,136 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// State represents state of alert
+type State string
+
+const (
+	Firing   State = "firing"
+	Resolved State = "resolved"
+)
+
+// Event represents alert event
+type Event struct {
+	Time    time.Time `json:"time"`
+	Replica string    `json:"replica"`
+	Channel string    `json:"channel"`
+	Rule    string    `json:"rule"`
+	State   State     `json:"state"`
+	Message string    `json:"message"`
+}
+
+// Notifier delivers alert events
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// LogNotifier writes events to the log
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(_ context.Context, event Event) error {
+	entry := logrus.WithFields(logrus.Fields{
+		"replica": event.Replica,
+		"channel": event.Channel,
+		"rule":    event.Rule,
+		"state":   event.State,
+	})
+
+	if event.State == Resolved {
+		entry.Info(event.Message)
+	} else {
+		entry.Warn(event.Message)
+	}
+
+	return nil
+}
+
+// DefaultWebhookTimeout is timeout of request of WebhookNotifier without Client
+const DefaultWebhookTimeout = 10 * time.Second
+
+// webhookClient keeps unresponsive webhook from blocking notifications
+var webhookClient = &http.Client{Timeout: DefaultWebhookTimeout}
+
+// WebhookNotifier sends events as JSON by POST request
+type WebhookNotifier struct {
+	URL string
+	// Client sends requests, client with DefaultWebhookTimeout is used if it is nil
+	Client *http.Client
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal event")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "unable to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = webhookClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrapf(err, "unable to send event to %s", n.URL)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("webhook %s responded with %s", n.URL, resp.Status)
+	}
+
+	return nil
+}
+
+// ExecNotifier runs command for every event,
+// the event is passed as JSON to stdin and as REPMY_* environment variables
+type ExecNotifier struct {
+	Command string
+	Args    []string
+}
+
+func (n ExecNotifier) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal event")
+	}
+
+	stderr := &bytes.Buffer{}
+
+	cmd := exec.CommandContext(ctx, n.Command, n.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(),
+		"REPMY_REPLICA="+event.Replica,
+		"REPMY_CHANNEL="+event.Channel,
+		"REPMY_RULE="+event.Rule,
+		"REPMY_STATE="+string(event.State),
+		"REPMY_MESSAGE="+event.Message,
+	)
+
+	err = cmd.Run()
+	if err != nil {
+		return errors.Wrapf(err, "command %s failed: %s", n.Command, stderr)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/slave"
+)
+
+// Rule evaluates replication status of channel,
+// prev is nil on the first check of the channel
+type Rule interface {
+	Name() string
+	Check(prev, cur *slave.Status) (firing bool, message string)
+}
+
+// OneShot is implemented by rules which report events instead of states,
+// events of these rules are never resolved
+type OneShot interface {
+	OneShot() bool
+}
+
+// IOThreadStopped fires when replication IO thread is not running
+type IOThreadStopped struct{}
+
+func (IOThreadStopped) Name() string {
+	return "io_thread_stopped"
+}
+
+func (IOThreadStopped) Check(_, cur *slave.Status) (bool, string) {
+	if cur.IORunning() {
+		return false, ""
+	}
+
+	return true, fmt.Sprintf("IO thread is %q: [%d] %s",
+		cur.SlaveIORunning, cur.LastIOErrno, cur.LastIOError)
+}
+
+// SQLThreadStopped fires when replication SQL thread is not running
+type SQLThreadStopped struct{}
+
+func (SQLThreadStopped) Name() string {
+	return "sql_thread_stopped"
+}
+
+func (SQLThreadStopped) Check(_, cur *slave.Status) (bool, string) {
+	if cur.SQLRunning() {
+		return false, ""
+	}
+
+	return true, fmt.Sprintf("SQL thread is %q: [%d] %s",
+		cur.SlaveSQLRunning, cur.LastSQLErrno, cur.LastSQLError)
+}
+
+// LagAbove fires when Seconds_Behind_Master is greater than Threshold
+type LagAbove struct {
+	Threshold time.Duration
+}
+
+func (LagAbove) Name() string {
+	return "lag_above"
+}
+
+func (r LagAbove) Check(_, cur *slave.Status) (bool, string) {
+	if !cur.SecondsBehindMaster.Valid {
+		return false, ""
+	}
+
+	lag := time.Duration(cur.SecondsBehindMaster.Int) * time.Second
+	if lag <= r.Threshold {
+		return false, ""
+	}
+
+	return true, fmt.Sprintf("replica is %s behind master (threshold %s)", lag, r.Threshold)
+}
+
+// ErrorIncreasing fires every time when a new IO or SQL error appears
+type ErrorIncreasing struct{}
+
+func (ErrorIncreasing) Name() string {
+	return "error_increasing"
+}
+
+func (ErrorIncreasing) OneShot() bool {
+	return true
+}
+
+func (ErrorIncreasing) Check(prev, cur *slave.Status) (bool, string) {
+	var errs []string
+
+	if cur.LastIOErrno != 0 && (prev == nil ||
+		prev.LastIOErrno != cur.LastIOErrno ||
+		prev.LastIOErrorTimestamp != cur.LastIOErrorTimestamp) {
+		errs = append(errs, fmt.Sprintf("IO error at %s: [%d] %s",
+			cur.LastIOErrorTimestamp, cur.LastIOErrno, cur.LastIOError))
+	}
+
+	if cur.LastSQLErrno != 0 && (prev == nil ||
+		prev.LastSQLErrno != cur.LastSQLErrno ||
+		prev.LastSQLErrorTimestamp != cur.LastSQLErrorTimestamp) {
+		errs = append(errs, fmt.Sprintf("SQL error at %s: [%d] %s",
+			cur.LastSQLErrorTimestamp, cur.LastSQLErrno, cur.LastSQLError))
+	}
+
+	if len(errs) == 0 {
+		return false, ""
+	}
+
+	return true, strings.Join(errs, "; ")
+}
+
+// GTIDGap fires when executed GTID set of master UUID has holes
+type GTIDGap struct{}
+
+func (GTIDGap) Name() string {
+	return "gtid_gap"
+}
+
+func (GTIDGap) Check(_, cur *slave.Status) (bool, string) {
+	if cur.MasterUUID == "" || cur.ExecutedGTIDSet == "" {
+		return false, ""
+	}
+
+	executed, err := gtid.Parse(cur.ExecutedGTIDSet)
+	if err != nil {
+		return true, err.Error()
+	}
+
+	gaps := executed.Gaps(cur.MasterUUID)
+	if len(gaps) == 0 {
+		return false, ""
+	}
+
+	missing := gtid.Set{}
+	for _, gap := range gaps {
+		missing.AddInterval(cur.MasterUUID, gap)
+	}
+
+	return true, fmt.Sprintf("executed GTID set has gaps: %s", missing)
+}
+
+// DefaultRules returns all rules with lag threshold
+func DefaultRules(maxLag time.Duration) []Rule {
+	return []Rule{
+		IOThreadStopped{},
+		SQLThreadStopped{},
+		LagAbove{Threshold: maxLag},
+		ErrorIncreasing{},
+		GTIDGap{},
+	}
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/go-sql-driver/mysql"
@@ -9,22 +10,29 @@ import (
 	_ "github.com/davecgh/go-spew/spew"
 )
 
+// AuthPlugin represents authentication plugin of mysql account
+type AuthPlugin string
+
+const (
+	// CachingSHA2Password is default authentication plugin since MySQL 8.0
+	CachingSHA2Password AuthPlugin = "caching_sha2_password"
+	// NativePassword is default authentication plugin for MySQL 5.x
+	NativePassword AuthPlugin = "mysql_native_password"
+)
+
 // ReplUser represents user for replication
 type ReplUser struct {
 	Name       string
 	Host       string
 	Password   string
-	Database   string
 	MasterHost string
-}
-
-// GetDatabase returns valid database name or '*'
-func (u ReplUser) GetDatabase() string {
-	if u.Database == "" {
-		return "*"
-	}
-
-	return u.Database
+	// MasterPort is omitted in CHANGE MASTER TO if zero
+	MasterPort int
+	// AuthPlugin used in IDENTIFIED WITH clause,
+	// server default plugin is used if empty
+	AuthPlugin AuthPlugin
+	// RequireSSL adds REQUIRE SSL to the account
+	RequireSSL bool
 }
 
 // GetHost valid hostname for mysql user or '%'
@@ -36,7 +44,22 @@ func (u ReplUser) GetHost() string {
 	return u.Host
 }
 
-// SetMasterHost parses master host from DSN and sets to MasterHost
+// Validate checks that user can be created
+func (u ReplUser) Validate() error {
+	if u.Name == "" {
+		return errors.New("empty user name")
+	}
+
+	switch u.AuthPlugin {
+	case "", CachingSHA2Password, NativePassword:
+	default:
+		return errors.Errorf("unsupported auth plugin %q", u.AuthPlugin)
+	}
+
+	return nil
+}
+
+// SetMasterHost parses master host and port from DSN and sets to MasterHost and MasterPort
 func (u *ReplUser) SetMasterHost(dsn string) error {
 	cfg, err := mysql.ParseDSN(dsn)
 	if err != nil {
@@ -49,8 +72,14 @@ func (u *ReplUser) SetMasterHost(dsn string) error {
 		return errors.New("invalid DSN address")
 	}
 
-	// set only hostname
 	u.MasterHost = parts[0]
 
+	if len(parts) > 1 {
+		u.MasterPort, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return errors.Wrap(err, "invalid port in DSN address")
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,93 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Version represents version of mysql server
+type Version struct {
+	Major, Minor, Patch int
+	// MariaDB is true for MariaDB servers
+	MariaDB bool
+	// Raw is the result of SELECT VERSION()
+	Raw string
+}
+
+// ParseVersion parses values like '8.0.23', '5.7.31-log', '10.5.8-MariaDB-1:10.5.8'
+func ParseVersion(s string) (Version, error) {
+	v := Version{
+		Raw:     s,
+		MariaDB: strings.Contains(strings.ToLower(s), "mariadb"),
+	}
+
+	// cut suffix
+	number := s
+	if i := strings.IndexFunc(s, func(r rune) bool {
+		return r != '.' && (r < '0' || r > '9')
+	}); i >= 0 {
+		number = s[:i]
+	}
+
+	parts := strings.Split(number, ".")
+	if len(parts) < 2 {
+		return v, errors.Errorf("invalid version %q", s)
+	}
+
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+
+	for i, part := range parts {
+		if i >= len(nums) {
+			break
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return v, errors.Wrapf(err, "invalid version %q", s)
+		}
+
+		*nums[i] = n
+	}
+
+	return v, nil
+}
+
+// AtLeast returns true if version is greater or equal than major.minor.patch
+func (v Version) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+
+	return v.Patch >= patch
+}
+
+// String returns version in major.minor.patch format
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Queryer is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type Queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// ServerVersion returns parsed result of SELECT VERSION()
+func ServerVersion(ctx context.Context, db Queryer) (Version, error) {
+	var raw string
+
+	err := db.QueryRowContext(ctx, `SELECT VERSION()`).Scan(&raw)
+	if err != nil {
+		return Version{}, errors.Wrap(err, "unable to get server version")
+	}
+
+	return ParseVersion(raw)
+}
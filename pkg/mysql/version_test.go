@@ -0,0 +1,38 @@
+package mysql_test
+
+import (
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/testutils"
+)
+
+func TestParseVersion(t *testing.T) {
+	data := []struct {
+		Input   string
+		Version string
+		MariaDB bool
+		Err     bool
+	}{
+		{Input: "8.0.23", Version: "8.0.23"},
+		{Input: "5.7.31-log", Version: "5.7.31"},
+		{Input: "8.0.22-13", Version: "8.0.22"},
+		{Input: "10.5.8-MariaDB-1:10.5.8+maria~focal", Version: "10.5.8", MariaDB: true},
+		{Input: "invalid", Err: true},
+	}
+
+	for _, item := range data {
+		v, err := mysql.ParseVersion(item.Input)
+		testutils.AssertEqual(t, item.Input+" err", item.Err, err != nil)
+
+		if err == nil {
+			testutils.AssertEqual(t, item.Input, item.Version, v.String())
+			testutils.AssertEqual(t, item.Input+" MariaDB", item.MariaDB, v.MariaDB)
+		}
+	}
+
+	v, _ := mysql.ParseVersion("8.0.17")
+	testutils.AssertEqual(t, "8.0.17 >= 8.0.17", true, v.AtLeast(8, 0, 17))
+	testutils.AssertEqual(t, "8.0.17 >= 8.0.18", false, v.AtLeast(8, 0, 18))
+	testutils.AssertEqual(t, "8.0.17 >= 5.7.40", true, v.AtLeast(5, 7, 40))
+}
@@ -0,0 +1,261 @@
+// Package node represents mysql server taking part in replication topology
+package node
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/server"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultPort = 3306
+
+	pollInterval = 500 * time.Millisecond
+)
+
+// Node represents mysql server with address reachable by replicas
+type Node struct {
+	Name string
+	Host string
+	Port int
+	DB   *sql.DB
+}
+
+// Open opens connection to server by DSN, host and port of node are taken from DSN
+func Open(name, dsn string) (*Node, error) {
+	cfg, err := driver.ParseDSN(dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse DSN")
+	}
+
+	host, port, err := SplitAddr(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open database")
+	}
+
+	if name == "" {
+		name = cfg.Addr
+	}
+
+	return &Node{Name: name, Host: host, Port: port, DB: db}, nil
+}
+
+// SplitAddr splits address to host and port, default port is used if omitted
+func SplitAddr(addr string) (string, int, error) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, DefaultPort, nil
+	}
+
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "invalid port in address %s", addr)
+	}
+
+	return host, port, nil
+}
+
+// Addr returns host:port of node
+func (n *Node) Addr() string {
+	return net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+}
+
+// String returns name and address of node
+func (n *Node) String() string {
+	if n.Name == n.Addr() {
+		return n.Name
+	}
+
+	return fmt.Sprintf("%s (%s)", n.Name, n.Addr())
+}
+
+// Master returns master repository of node
+func (n *Node) Master() *master.Repository {
+	return master.New(n.DB)
+}
+
+// Slave returns slave repository of node
+func (n *Node) Slave() *slave.Repository {
+	return slave.New(n.DB)
+}
+
+// Server returns server repository of node
+func (n *Node) Server() *server.Repository {
+	return server.New(n.DB)
+}
+
+// ReplUser returns user with address of node as master
+func (n *Node) ReplUser(user mysql.ReplUser) mysql.ReplUser {
+	user.MasterHost = n.Host
+	user.MasterPort = n.Port
+
+	return user
+}
+
+// Close closes connection to server
+func (n *Node) Close() error {
+	return n.DB.Close()
+}
+
+// Repoint changes master of replica and starts replication,
+// GTID auto-positioning is used if useGTID is true, otherwise coordinates
+func (n *Node) Repoint(ctx context.Context, user mysql.ReplUser, coordinates master.Status, useGTID bool) error {
+	repo := n.Slave()
+
+	err := repo.Stop(ctx)
+	if err != nil {
+		return err
+	}
+
+	if useGTID {
+		err = repo.ChangeMasterAutoPosition(ctx, user)
+	} else {
+		err = repo.ChangeMaster(ctx, coordinates, user)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return repo.Start(ctx)
+}
+
+// WaitExecuted blocks until replica has executed all transactions of target,
+// GTID sets are compared if useGTID is true, otherwise binlog coordinates
+func (n *Node) WaitExecuted(ctx context.Context, target master.Status, useGTID bool) error {
+	var (
+		want gtid.Set
+		err  error
+	)
+
+	if useGTID {
+		want, err = gtid.Parse(target.ExecutedGTIDSet)
+		if err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := n.Slave().ShowStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		if status.LastSQLErrno != 0 {
+			return errors.Errorf("replica %s has SQL error [%d] %s", n, status.LastSQLErrno, status.LastSQLError)
+		}
+
+		if useGTID {
+			executed, err := gtid.Parse(status.ExecutedGTIDSet)
+			if err != nil {
+				return err
+			}
+
+			if executed.Contains(want) {
+				return nil
+			}
+		} else if Reached(status.RelayMasterLogFile, status.ExecMasterLogPos, target.File, target.Position) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "replica %s has not reached %s:%d %s",
+				n, target.File, target.Position, target.ExecutedGTIDSet)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reached returns true if binlog coordinates file:pos are not behind targetFile:targetPos,
+// names of binlog files with the same basename are ordered by their numeric suffix
+func Reached(file string, pos int, targetFile string, targetPos int) bool {
+	if file != targetFile {
+		base, index, ok := splitFile(file)
+		targetBase, targetIndex, targetOK := splitFile(targetFile)
+
+		return ok && targetOK && base == targetBase && index > targetIndex
+	}
+
+	return pos >= targetPos
+}
+
+// splitFile returns basename and numeric suffix of binlog file, ex. 'mysql-bin' and 3 for 'mysql-bin.000003',
+// suffix grows beyond its width after 999999
+func splitFile(file string) (string, uint64, bool) {
+	i := strings.LastIndex(file, ".")
+	if i < 0 {
+		return "", 0, false
+	}
+
+	index, err := strconv.ParseUint(file[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return file[:i], index, true
+}
+
+// WaitRelayApplied blocks until SQL thread of replica has applied all received relay logs
+func (n *Node) WaitRelayApplied(ctx context.Context) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := n.Slave().ShowStatus(ctx)
+		if err != nil {
+			return err
+		}
+
+		if status.LastSQLErrno != 0 {
+			return errors.Errorf("replica %s has SQL error [%d] %s", n, status.LastSQLErrno, status.LastSQLError)
+		}
+
+		applied := status.RelayMasterLogFile == status.MasterLogFile &&
+			status.ExecMasterLogPos >= status.ReadMasterLogPos
+
+		if status.RetrievedGTIDSet != "" {
+			retrieved, err := gtid.Parse(status.RetrievedGTIDSet)
+			if err != nil {
+				return err
+			}
+
+			executed, err := gtid.Parse(status.ExecutedGTIDSet)
+			if err != nil {
+				return err
+			}
+
+			applied = executed.Contains(retrieved)
+		}
+
+		if applied {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "replica %s has not applied relay logs", n)
+		case <-ticker.C:
+		}
+	}
+}
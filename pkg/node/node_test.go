@@ -0,0 +1,29 @@
+package node_test
+
+import (
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/testutils"
+)
+
+func TestReached(t *testing.T) {
+	data := map[string]struct {
+		file, target   string
+		pos, targetPos int
+		reached        bool
+	}{
+		"same file":      {file: "mysql-bin.000003", pos: 120, target: "mysql-bin.000003", targetPos: 120, reached: true},
+		"behind":         {file: "mysql-bin.000003", pos: 4, target: "mysql-bin.000003", targetPos: 120},
+		"next file":      {file: "mysql-bin.000004", pos: 4, target: "mysql-bin.000003", targetPos: 120, reached: true},
+		"previous file":  {file: "mysql-bin.000002", pos: 900, target: "mysql-bin.000003", targetPos: 120},
+		"wider suffix":   {file: "mysql-bin.1000000", pos: 4, target: "mysql-bin.999999", targetPos: 120, reached: true},
+		"narrower":       {file: "mysql-bin.999999", pos: 900, target: "mysql-bin.1000000", targetPos: 4},
+		"other basename": {file: "binlog.000004", pos: 4, target: "mysql-bin.000003", targetPos: 120},
+		"no suffix":      {file: "mysql-bin", pos: 4, target: "mysql-bin.000003", targetPos: 120},
+	}
+
+	for name, test := range data {
+		testutils.AssertEqual(t, name, test.reached, node.Reached(test.file, test.pos, test.target, test.targetPos))
+	}
+}
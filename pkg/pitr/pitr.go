@@ -0,0 +1,598 @@
+// Package pitr restores dump written by repmydump and replays binlog files
+// backed up by package backup up to a point in time
+package pitr
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/backup"
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// rowsStmtEnd is flag of the last rows event of statement
+const rowsStmtEnd = 0x0001
+
+// Stop represents the point recovery stops at, transactions are never applied partially
+type Stop struct {
+	// Time stops before the first transaction started after it
+	Time time.Time
+	// File and Position stop before the first transaction starting at or after the position
+	File     string
+	Position uint32
+	// GTID stops after transaction with GTID
+	GTID string
+}
+
+// IsZero returns true if recovery replays all backed up binlogs
+func (s Stop) IsZero() bool {
+	return s.Time.IsZero() && s.File == "" && s.GTID == ""
+}
+
+// Result represents the last applied transaction
+type Result struct {
+	Transactions int
+	File         string
+	Position     uint32
+	Timestamp    time.Time
+	GTID         string
+}
+
+// Recovery loads dump to Target and replays binlogs from recorded coordinates of dump.
+// Row events are applied by BINLOG statements, session variables of statements and GTIDs
+// of transactions are set by SET statements as mysqlbinlog output does,
+// so the user of Target needs BINLOG_ADMIN or SUPER privilege.
+type Recovery struct {
+	Target    *sql.DB
+	DumpDir   string
+	BackupDir string
+	Stop      Stop
+	// SkipLoad replays binlogs only, dump must be loaded already
+	SkipLoad bool
+	Verbose  bool
+}
+
+// replay represents state of replaying on one session
+type replay struct {
+	conn    *sql.Conn
+	stop    Stop
+	stopIdx int
+	// start and executed are coordinates of dump, events before them are skipped
+	start    dump.Coordinates
+	executed gtid.Set
+	result   Result
+
+	schema  string
+	pending []string
+	inTrx   bool
+	skip    bool
+	gtid    string
+	done    bool
+
+	// useGTID is true if GTIDs of transactions are preserved by GTID_NEXT
+	useGTID bool
+	// session holds values of session variables set by SET statements
+	session map[string]string
+	// collations are introducer and collation of strings by collation id
+	collations map[uint32]string
+}
+
+// Run restores dump and replays binlogs
+func (r *Recovery) Run(ctx context.Context) (*Result, error) {
+	coordinates, err := dump.ReadCoordinates(r.DumpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := backup.LoadManifest(r.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+
+	first := manifest.Find(coordinates.File)
+	if first < 0 {
+		return nil, errors.Errorf("binlog %s of dump coordinates is not backed up", coordinates.File)
+	}
+
+	p := &replay{
+		stop:       r.Stop,
+		stopIdx:    len(manifest.Files),
+		start:      coordinates,
+		session:    make(map[string]string),
+		collations: make(map[uint32]string),
+	}
+
+	if coordinates.ExecutedGTIDSet != "" {
+		p.executed, err = gtid.Parse(coordinates.ExecutedGTIDSet)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid GTID set of dump coordinates")
+		}
+	}
+
+	err = p.check(manifest, first)
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.SkipLoad {
+		logrus.Infof("loading dump %s", r.DumpDir)
+
+		err = dump.Load(ctx, r.Target, r.DumpDir, r.Verbose)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.conn, err = r.Target.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get connection")
+	}
+
+	defer p.conn.Close()
+
+	p.useGTID, err = gtidMode(ctx, p.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := first; i < len(manifest.Files) && !p.done; i++ {
+		// the stop binlog is replayed up to its end
+		if p.stop.File != "" && i > p.stopIdx {
+			p.done = true
+			break
+		}
+
+		file := manifest.Files[i]
+
+		if r.Verbose {
+			logrus.Infof("replaying %s", file.Name)
+		}
+
+		err = p.replayFile(ctx, r.BackupDir, file, i)
+		if err != nil {
+			return &p.result, errors.Wrapf(err, "binlog %s", file.Name)
+		}
+	}
+
+	// the last backed up file may end inside of transaction
+	if p.inTrx {
+		_, err = p.conn.ExecContext(ctx, `ROLLBACK`)
+		if err != nil {
+			return &p.result, errors.Wrap(err, "unable to rollback incomplete transaction")
+		}
+	}
+
+	if p.useGTID {
+		_, err = p.conn.ExecContext(ctx, `SET GTID_NEXT = 'AUTOMATIC'`)
+		if err != nil {
+			return &p.result, errors.Wrap(err, "unable to reset GTID_NEXT")
+		}
+	}
+
+	if !p.stop.IsZero() && !p.done {
+		return &p.result, errors.New("backed up binlogs end before the stop point, it is not reached")
+	}
+
+	return &p.result, nil
+}
+
+// check returns error if stop point is not in backed up binlogs starting from index first
+// or it is before coordinates of dump
+func (p *replay) check(manifest *backup.Manifest, first int) error {
+	stop := p.stop
+
+	if stop.File != "" {
+		p.stopIdx = manifest.Find(stop.File)
+		if p.stopIdx < 0 {
+			return errors.Errorf("stop binlog %s is not backed up", stop.File)
+		}
+
+		if p.stopIdx < first || p.stopIdx == first && int(stop.Position) < p.start.Position {
+			return errors.Errorf("stop position %s:%d is before coordinates of dump %s:%d",
+				stop.File, stop.Position, p.start.File, p.start.Position)
+		}
+
+		if stop.Position > manifest.Files[p.stopIdx].End {
+			return errors.Errorf("stop position %s:%d is after the end %d of backed up binlog",
+				stop.File, stop.Position, manifest.Files[p.stopIdx].End)
+		}
+	}
+
+	if stop.GTID != "" {
+		set, err := gtid.Parse(stop.GTID)
+		if err != nil || set.Count() != 1 {
+			return errors.Errorf("invalid stop GTID %q, expected 'uuid:number'", stop.GTID)
+		}
+
+		// GTID is compared with GTIDs of events in canonical form
+		p.stop.GTID = set.String()
+
+		if p.executed != nil && p.executed.Contains(set) {
+			return errors.Errorf("stop transaction %s is in dump already", stop.GTID)
+		}
+
+		backed := gtid.Set{}
+
+		for _, file := range manifest.Files[first:] {
+			if file.GTIDs == "" {
+				continue
+			}
+
+			fileSet, err := gtid.Parse(file.GTIDs)
+			if err != nil {
+				return errors.Wrapf(err, "invalid GTIDs of %s in manifest", file.Name)
+			}
+
+			backed = backed.Union(fileSet)
+		}
+
+		if !backed.Contains(set) {
+			return errors.Errorf("stop transaction %s is not in backed up binlogs", stop.GTID)
+		}
+	}
+
+	if !stop.Time.IsZero() {
+		last := manifest.Files[len(manifest.Files)-1]
+
+		// transactions committed after the last backed up one and before stop time may exist
+		if !stop.Time.Before(last.LastTimestamp) {
+			return errors.Errorf("stop time %s is not before the last backed up event at %s",
+				stop.Time.Format(time.RFC3339), last.LastTimestamp.Format(time.RFC3339))
+		}
+
+		if stop.Time.Before(manifest.Files[first].FirstTimestamp) {
+			return errors.Errorf("stop time %s is before binlog %s of dump coordinates",
+				stop.Time.Format(time.RFC3339), manifest.Files[first].Name)
+		}
+	}
+
+	return nil
+}
+
+// gtidMode returns true if GTID_NEXT can be set to GTID of transaction on target,
+// gtid_mode is absent on MariaDB
+func gtidMode(ctx context.Context, conn *sql.Conn) (bool, error) {
+	var name, mode string
+
+	err := conn.QueryRowContext(ctx, `SHOW GLOBAL VARIABLES LIKE 'gtid_mode'`).Scan(&name, &mode)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, errors.Wrap(err, "unable to read gtid_mode")
+	}
+
+	return mode != "OFF", nil
+}
+
+func (p *replay) replayFile(ctx context.Context, dir string, file backup.File, idx int) error {
+	f, err := file.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	reader, err := binlog.NewFileReader(f)
+	if err != nil {
+		return err
+	}
+
+	for !p.done {
+		e, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = p.apply(ctx, e, file.Name, idx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply applies event, transactions before coordinates of dump are skipped
+func (p *replay) apply(ctx context.Context, e *binlog.Event, file string, idx int) error {
+	start := e.Header.LogPos - e.Header.EventSize
+
+	switch body := e.Body.(type) {
+	case *binlog.FormatDescriptionEvent:
+		// BINLOG statements of the session are parsed in format of the file
+		p.schema = ""
+		return p.binlog(ctx, base64.StdEncoding.EncodeToString(e.Raw))
+	case *binlog.GTIDEvent:
+		if p.stopBefore(e, file, idx, start) {
+			return nil
+		}
+
+		p.gtid = ""
+		if body.UUID != "" {
+			p.gtid = body.String()
+		}
+
+		p.skip = file == p.start.File && start < uint32(p.start.Position) ||
+			p.executed != nil && p.gtid != "" && p.executed.Contains(gtid.MustParse(p.gtid))
+
+		if !p.skip && p.useGTID && p.gtid != "" {
+			_, err := p.conn.ExecContext(ctx, "SET GTID_NEXT = "+quote.String(p.gtid))
+			if err != nil {
+				return errors.Wrapf(err, "unable to set GTID_NEXT to %s", p.gtid)
+			}
+		}
+	case *binlog.IntvarEvent, *binlog.RandEvent, *binlog.UserVarEvent:
+		if p.skip {
+			return nil
+		}
+
+		return p.variable(ctx, body)
+	case *binlog.QueryEvent:
+		if body.Query == "BEGIN" && p.gtid == "" {
+			if p.stopBefore(e, file, idx, start) {
+				return nil
+			}
+
+			p.skip = file == p.start.File && start < uint32(p.start.Position)
+		}
+
+		if p.skip || body.ErrorCode != 0 {
+			return nil
+		}
+
+		return p.query(ctx, e, body, file)
+	case *binlog.TableMapEvent:
+		if !p.skip {
+			p.pending = append(p.pending, base64.StdEncoding.EncodeToString(e.Raw))
+		}
+	case *binlog.RowsEvent:
+		if p.skip {
+			return nil
+		}
+
+		p.pending = append(p.pending, base64.StdEncoding.EncodeToString(e.Raw))
+
+		if body.Flags&rowsStmtEnd != 0 {
+			err := p.binlog(ctx, p.pending...)
+			p.pending = p.pending[:0]
+
+			return err
+		}
+	case *binlog.XIDEvent:
+		if p.skip {
+			return nil
+		}
+
+		_, err := p.conn.ExecContext(ctx, `COMMIT`)
+		if err != nil {
+			return errors.Wrap(err, "unable to commit")
+		}
+
+		p.committed(e, file)
+	}
+
+	return nil
+}
+
+// stopBefore returns true if transaction starting at event must not be applied
+func (p *replay) stopBefore(e *binlog.Event, file string, idx int, start uint32) bool {
+	ts := time.Unix(int64(e.Header.Timestamp), 0)
+
+	if !p.stop.Time.IsZero() && ts.After(p.stop.Time) ||
+		p.stop.File != "" && (idx > p.stopIdx || idx == p.stopIdx && start >= p.stop.Position) {
+		logrus.Infof("stop before transaction at %s:%d of %s", file, start, ts.UTC().Format(time.RFC3339))
+
+		p.done = true
+	}
+
+	return p.done
+}
+
+// query executes statement of QUERY event in its default database
+func (p *replay) query(ctx context.Context, e *binlog.Event, body *binlog.QueryEvent, file string) error {
+	q := body.Query
+
+	switch strings.ToUpper(q) {
+	case "BEGIN":
+		p.inTrx = true
+	case "COMMIT":
+		p.inTrx = false
+	default:
+		err := p.setSession(ctx, body.Status)
+		if err != nil {
+			return err
+		}
+
+		if body.Schema != "" && body.Schema != p.schema {
+			_, err := p.conn.ExecContext(ctx, "USE "+quote.Ident(body.Schema))
+			if err != nil {
+				return errors.Wrapf(err, "unable to use %s", body.Schema)
+			}
+
+			p.schema = body.Schema
+		}
+
+		_, err = p.conn.ExecContext(ctx, `SET TIMESTAMP = ?`, e.Header.Timestamp)
+		if err != nil {
+			return errors.Wrap(err, "unable to set timestamp")
+		}
+	}
+
+	_, err := p.conn.ExecContext(ctx, q)
+	if err != nil {
+		return errors.Wrapf(err, "unable to execute %s", q)
+	}
+
+	// DDL and statements outside of transaction are committed implicitly
+	if !p.inTrx {
+		p.committed(e, file)
+	}
+
+	return nil
+}
+
+// setSession sets session variables of statement which differ from values set before
+func (p *replay) setSession(ctx context.Context, status binlog.QueryStatus) error {
+	values := make([][2]string, 0, 9)
+
+	if status.Flags2 != nil {
+		flags := *status.Flags2
+		values = append(values,
+			[2]string{"foreign_key_checks", flag(flags&binlog.OptionNoForeignKeyChecks == 0)},
+			[2]string{"sql_auto_is_null", flag(flags&binlog.OptionAutoIsNull != 0)},
+			[2]string{"unique_checks", flag(flags&binlog.OptionRelaxedUniqueChecks == 0)},
+			[2]string{"autocommit", flag(flags&binlog.OptionNotAutocommit == 0)},
+		)
+	}
+
+	if status.SQLMode != nil {
+		values = append(values, [2]string{"sql_mode", strconv.FormatUint(*status.SQLMode, 10)})
+	}
+
+	if status.AutoIncrement != nil {
+		values = append(values,
+			[2]string{"auto_increment_increment", strconv.Itoa(int(status.AutoIncrement[0]))},
+			[2]string{"auto_increment_offset", strconv.Itoa(int(status.AutoIncrement[1]))},
+		)
+	}
+
+	if status.Charset != nil {
+		values = append(values,
+			[2]string{"character_set_client", strconv.Itoa(int(status.Charset[0]))},
+			[2]string{"collation_connection", strconv.Itoa(int(status.Charset[1]))},
+			[2]string{"collation_server", strconv.Itoa(int(status.Charset[2]))},
+		)
+	}
+
+	if status.TimeZone != nil {
+		values = append(values, [2]string{"time_zone", quote.String(*status.TimeZone)})
+	}
+
+	sets := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if p.session[v[0]] != v[1] {
+			sets = append(sets, "@@session."+v[0]+" = "+v[1])
+		}
+	}
+
+	if len(sets) == 0 {
+		return nil
+	}
+
+	q := "SET " + strings.Join(sets, ", ")
+
+	_, err := p.conn.ExecContext(ctx, q)
+	if err != nil {
+		return errors.Wrapf(err, "unable to execute %s", q)
+	}
+
+	for _, v := range values {
+		p.session[v[0]] = v[1]
+	}
+
+	return nil
+}
+
+// variable sets value used by the following statement of statement based binlog
+func (p *replay) variable(ctx context.Context, body interface{}) error {
+	var q string
+
+	switch v := body.(type) {
+	case *binlog.IntvarEvent:
+		name := "INSERT_ID"
+		if v.Type == binlog.LastInsertID {
+			name = "LAST_INSERT_ID"
+		}
+
+		q = fmt.Sprintf("SET %s = %d", name, v.Value)
+	case *binlog.RandEvent:
+		q = fmt.Sprintf("SET @@RAND_SEED1 = %d, @@RAND_SEED2 = %d", v.Seed1, v.Seed2)
+	case *binlog.UserVarEvent:
+		value := v.Value
+
+		switch {
+		case v.Null:
+			value = "NULL"
+		case v.Type == binlog.StringResult:
+			collation, err := p.collation(ctx, v.Charset)
+			if err != nil {
+				return err
+			}
+
+			value = fmt.Sprintf(collation, hex.EncodeToString([]byte(v.Value)))
+		}
+
+		q = "SET @" + quote.Ident(v.Name) + " := " + value
+	}
+
+	_, err := p.conn.ExecContext(ctx, q)
+
+	return errors.Wrapf(err, "unable to execute %s", q)
+}
+
+// collation returns format of string literal in collation with id
+func (p *replay) collation(ctx context.Context, id uint32) (string, error) {
+	if format, ok := p.collations[id]; ok {
+		return format, nil
+	}
+
+	var charset, collation string
+
+	err := p.conn.QueryRowContext(ctx,
+		`SELECT CHARACTER_SET_NAME, COLLATION_NAME FROM information_schema.COLLATIONS WHERE ID = ?`, id).
+		Scan(&charset, &collation)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get collation %d", id)
+	}
+
+	format := "_" + charset + " 0x%s COLLATE " + quote.Ident(collation)
+	p.collations[id] = format
+
+	return format, nil
+}
+
+func flag(on bool) string {
+	if on {
+		return "1"
+	}
+
+	return "0"
+}
+
+// binlog executes BINLOG statement with base64 encoded events
+func (p *replay) binlog(ctx context.Context, events ...string) error {
+	_, err := p.conn.ExecContext(ctx, "BINLOG '"+strings.Join(events, "\n")+"'")
+	return errors.Wrap(err, "unable to execute BINLOG statement")
+}
+
+func (p *replay) committed(e *binlog.Event, file string) {
+	p.inTrx = false
+	p.result.Transactions++
+	p.result.File, p.result.Position = file, e.Header.LogPos
+	p.result.Timestamp = time.Unix(int64(e.Header.Timestamp), 0).UTC()
+	p.result.GTID = p.gtid
+
+	if p.stop.GTID != "" && p.gtid == p.stop.GTID {
+		logrus.Infof("stop after transaction %s", p.gtid)
+
+		p.done = true
+	}
+}
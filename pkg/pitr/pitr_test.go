@@ -0,0 +1,192 @@
+package pitr_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/backup"
+	"github.com/partyzanex/repmy/pkg/binlog"
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/internal/binlogtest"
+	"github.com/partyzanex/repmy/pkg/pitr"
+	"github.com/partyzanex/testutils"
+)
+
+var (
+	le  = binlogtest.LE
+	str = binlogtest.Str
+)
+
+// transaction writes inserting transaction with GTID binlogtest.UUID:gno to table db.t (id INT)
+func transaction(w *binlogtest.Writer, gno uint64, timestamp uint32) []byte {
+	w.Timestamp = timestamp
+
+	return bytes.Join([][]byte{
+		w.Event(binlog.GTIDEventType, []byte{1}, binlogtest.SID(), le(gno, 8), []byte{2}, le(0, 8), le(1, 8)),
+		w.Event(binlog.QueryEventType, le(10, 4), le(0, 4), []byte{2}, le(0, 2), le(0, 2), []byte("db\x00BEGIN")),
+		w.Event(binlog.TableMapEventType,
+			le(42, 6), le(1, 2), str("db"), []byte{0}, str("t"), []byte{0}, []byte{1, 3}, []byte{0}, []byte{0}),
+		w.Event(binlog.WriteRowsEventType, le(42, 6), le(1, 2), le(2, 2), []byte{1, 1}, []byte{0}, le(gno, 4)),
+		w.Event(binlog.XIDEventType, le(gno, 8)),
+	}, nil)
+}
+
+// testBackup writes gzipped binlog with three transactions and dump coordinates after the first one
+func testBackup(t *testing.T, dumpDir, backupDir string) {
+	w := &binlogtest.Writer{Pos: 4, Timestamp: 1600000000}
+
+	data := bytes.Join([][]byte{
+		[]byte(binlog.Magic),
+		w.FormatDescription(1),
+		transaction(w, 1, 1600000000),
+	}, nil)
+
+	coordinates := dump.Coordinates{File: "binlog.000001", Position: int(w.Pos), ExecutedGTIDSet: binlogtest.UUID + ":1"}
+
+	data = append(data, transaction(w, 2, 1600000100)...)
+	data = append(data, transaction(w, 3, 1600000200)...)
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	_, _ = gz.Write(data)
+	testutils.FatalErr(t, "gzip", gz.Close())
+
+	testutils.FatalErr(t, "WriteFile",
+		ioutil.WriteFile(filepath.Join(backupDir, "binlog.000001.gz"), buf.Bytes(), 0644))
+
+	manifest := &backup.Manifest{Files: []backup.File{
+		{
+			Name: "binlog.000001", Path: "binlog.000001.gz", End: w.Pos, Complete: true,
+			GTIDs:          binlogtest.UUID + ":1-3",
+			FirstTimestamp: time.Unix(1600000000, 0).UTC(),
+			LastTimestamp:  time.Unix(1600000200, 0).UTC(),
+		},
+	}}
+	testutils.FatalErr(t, "Save", manifest.Save(backupDir))
+	testutils.FatalErr(t, "WriteCoordinates", dump.WriteCoordinates(dumpDir, coordinates))
+}
+
+func TestRecovery_Run(t *testing.T) {
+	data := map[string]struct {
+		stop         pitr.Stop
+		transactions int
+		gtid         string
+	}{
+		"all":      {transactions: 2, gtid: binlogtest.UUID + ":3"},
+		"gtid":     {stop: pitr.Stop{GTID: binlogtest.UUID + ":2"}, transactions: 1, gtid: binlogtest.UUID + ":2"},
+		"datetime": {stop: pitr.Stop{Time: time.Unix(1600000150, 0)}, transactions: 1, gtid: binlogtest.UUID + ":2"},
+	}
+
+	for name, test := range data {
+		t.Run(name, func(t *testing.T) {
+			dumpDir, err := ioutil.TempDir("", "dump")
+			testutils.FatalErr(t, "TempDir", err)
+
+			defer os.RemoveAll(dumpDir)
+
+			backupDir, err := ioutil.TempDir("", "backup")
+			testutils.FatalErr(t, "TempDir", err)
+
+			defer os.RemoveAll(backupDir)
+
+			testBackup(t, dumpDir, backupDir)
+
+			db, mock, err := sqlmock.New()
+			testutils.FatalErr(t, "sqlmock.New", err)
+
+			defer db.Close()
+
+			mock.ExpectQuery(`^SHOW GLOBAL VARIABLES LIKE 'gtid_mode'$`).
+				WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("gtid_mode", "ON"))
+			// format description event, then GTID, BEGIN, rows and COMMIT of every applied transaction
+			mock.ExpectExec(`^BINLOG '`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+			for i := 0; i < test.transactions; i++ {
+				mock.ExpectExec(`^SET GTID_NEXT = '` + binlogtest.UUID + `:` + strconv.Itoa(i+2) + `'$`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`^BEGIN$`).WillReturnResult(sqlmock.NewResult(0, 0))
+				// table map and rows events in one statement, sqlmock collapses new line to space
+				mock.ExpectExec(`^BINLOG '\S+ \S+'$`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`^COMMIT$`).WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+
+			mock.ExpectExec(`^SET GTID_NEXT = 'AUTOMATIC'$`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+			recovery := &pitr.Recovery{
+				Target:    db,
+				DumpDir:   dumpDir,
+				BackupDir: backupDir,
+				Stop:      test.stop,
+				SkipLoad:  true,
+			}
+
+			result, err := recovery.Run(context.Background())
+			testutils.FatalErr(t, "Run", err)
+			testutils.FatalErr(t, "ExpectationsWereMet", mock.ExpectationsWereMet())
+
+			testutils.AssertEqual(t, "transactions", test.transactions, result.Transactions)
+			testutils.AssertEqual(t, "gtid", test.gtid, result.GTID)
+			testutils.AssertEqual(t, "file", "binlog.000001", result.File)
+		})
+	}
+}
+
+func TestRecovery_Run_NotBackedUp(t *testing.T) {
+	dumpDir, err := ioutil.TempDir("", "dump")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dumpDir)
+
+	err = dump.WriteCoordinates(dumpDir, dump.Coordinates{File: "binlog.000007", Position: 4})
+	testutils.FatalErr(t, "WriteCoordinates", err)
+
+	recovery := &pitr.Recovery{DumpDir: dumpDir, BackupDir: dumpDir, SkipLoad: true}
+
+	_, err = recovery.Run(context.Background())
+	testutils.AssertEqual(t, "error", true, err != nil)
+}
+
+func TestRecovery_Run_InvalidStop(t *testing.T) {
+	data := map[string]pitr.Stop{
+		"gtid in dump":       {GTID: binlogtest.UUID + ":1"},
+		"gtid not backed up": {GTID: binlogtest.UUID + ":4"},
+		"gtid range":         {GTID: binlogtest.UUID + ":2-3"},
+		"datetime after end": {Time: time.Unix(1600000300, 0)},
+		"position after end": {File: "binlog.000001", Position: 1 << 30},
+	}
+
+	for name, stop := range data {
+		t.Run(name, func(t *testing.T) {
+			dumpDir, err := ioutil.TempDir("", "dump")
+			testutils.FatalErr(t, "TempDir", err)
+
+			defer os.RemoveAll(dumpDir)
+
+			backupDir, err := ioutil.TempDir("", "backup")
+			testutils.FatalErr(t, "TempDir", err)
+
+			defer os.RemoveAll(backupDir)
+
+			testBackup(t, dumpDir, backupDir)
+
+			db, mock, err := sqlmock.New()
+			testutils.FatalErr(t, "sqlmock.New", err)
+
+			defer db.Close()
+
+			recovery := &pitr.Recovery{Target: db, DumpDir: dumpDir, BackupDir: backupDir, Stop: stop, SkipLoad: true}
+
+			_, err = recovery.Run(context.Background())
+			testutils.AssertEqual(t, "error", true, err != nil)
+			testutils.FatalErr(t, "ExpectationsWereMet", mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -35,7 +35,7 @@ type task struct {
 	Result *int64
 }
 
-func (t task) ID() int {
+func (t task) ID() interface{} {
 	return t.UID
 }
 
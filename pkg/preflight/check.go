@@ -0,0 +1,195 @@
+// Package preflight validates master and replica before replication is configured
+package preflight
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/server"
+)
+
+// Status represents outcome of check
+type Status string
+
+const (
+	Passed  Status = "PASS"
+	Warning Status = "WARN"
+	Failed  Status = "FAIL"
+)
+
+// Result represents outcome of one check with a hint how to fix it
+type Result struct {
+	Name    string
+	Status  Status
+	Message string
+	Hint    string
+}
+
+// Report represents results of all checks
+type Report []Result
+
+// Failed returns true if any check is failed
+func (r Report) Failed() bool {
+	for _, result := range r {
+		if result.Status == Failed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WriteTo writes report as text, hints are written for warnings and failures
+func (r Report) WriteTo(w io.Writer) (int64, error) {
+	b := &strings.Builder{}
+
+	for _, result := range r {
+		fmt.Fprintf(b, "[%s] %s: %s\n", result.Status, result.Name, result.Message)
+
+		if result.Status != Passed && result.Hint != "" {
+			fmt.Fprintf(b, "       hint: %s\n", result.Hint)
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err
+}
+
+func pass(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Passed, Message: fmt.Sprintf(format, args...)}
+}
+
+func warn(name, hint, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Warning, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+func fail(name, hint, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: Failed, Message: fmt.Sprintf(format, args...), Hint: hint}
+}
+
+// Check validates global variables of master and replica,
+// replicaMaxPacket is slave_max_allowed_packet (replica_max_allowed_packet) of replica
+func Check(master, replica *server.Info, replicaMaxPacket int64) Report {
+	return Report{
+		checkBinlog(master),
+		checkServerID(master, replica),
+		checkServerUUID(master, replica),
+		checkReadOnly(replica),
+		checkGTIDMode(master, replica),
+		checkVersions(master, replica),
+		checkMaxAllowedPacket(master, replica, replicaMaxPacket),
+	}
+}
+
+func checkBinlog(master *server.Info) Result {
+	const name = "binary log"
+
+	if !master.LogBin {
+		return fail(name, "add log_bin and server_id to [mysqld] section of master config and restart it",
+			"log_bin is disabled on master")
+	}
+
+	if !strings.EqualFold(master.BinlogFormat, "ROW") {
+		return fail(name, "SET PERSIST binlog_format = 'ROW' (or set it in config) on master",
+			"binlog_format is %s on master, ROW is required", master.BinlogFormat)
+	}
+
+	return pass(name, "log_bin is enabled, binlog_format is ROW")
+}
+
+func checkServerID(master, replica *server.Info) Result {
+	const name = "server_id"
+
+	switch {
+	case master.ServerID == 0:
+		return fail(name, "set unique server_id in master config", "server_id is not set on master")
+	case replica.ServerID == 0:
+		return fail(name, "set unique server_id in replica config", "server_id is not set on replica")
+	case master.ServerID == replica.ServerID:
+		return fail(name, "SET PERSIST server_id to a unique value on replica",
+			"master and replica have the same server_id %d", master.ServerID)
+	}
+
+	return pass(name, "master %d, replica %d", master.ServerID, replica.ServerID)
+}
+
+func checkServerUUID(master, replica *server.Info) Result {
+	const name = "server_uuid"
+
+	if master.ServerUUID != "" && master.ServerUUID == replica.ServerUUID {
+		return fail(name, "remove auto.cnf from data directory of replica and restart it, a new UUID is generated",
+			"master and replica have the same server_uuid %s, data directory was cloned with auto.cnf", master.ServerUUID)
+	}
+
+	return pass(name, "server_uuid differs")
+}
+
+func checkReadOnly(replica *server.Info) Result {
+	const name = "read_only"
+
+	if !replica.ReadOnly {
+		return warn(name, "SET PERSIST read_only = ON (and super_read_only = ON) on replica",
+			"replica is writable, writes to replica create errant transactions")
+	}
+
+	return pass(name, "replica is read only")
+}
+
+func checkGTIDMode(master, replica *server.Info) Result {
+	const name = "gtid_mode"
+
+	if master.Version.MariaDB || replica.Version.MariaDB {
+		return pass(name, "MariaDB GTID does not depend on gtid_mode")
+	}
+
+	if !strings.EqualFold(master.GTIDMode, replica.GTIDMode) {
+		return fail(name, "set the same gtid_mode and enforce_gtid_consistency on both servers",
+			"gtid_mode is %s on master and %s on replica", master.GTIDMode, replica.GTIDMode)
+	}
+
+	return pass(name, "gtid_mode is %s on both servers", master.GTIDMode)
+}
+
+func checkVersions(master, replica *server.Info) Result {
+	const name = "version"
+
+	mv, rv := master.Version, replica.Version
+
+	if mv.MariaDB != rv.MariaDB {
+		return warn(name, "replication between MySQL and MariaDB is supported only for old versions of MySQL",
+			"master is %s, replica is %s", mv, rv)
+	}
+
+	if !rv.AtLeast(mv.Major, mv.Minor, 0) {
+		return fail(name, "upgrade replica, replication from newer master to older replica is not supported",
+			"replica %s is older than master %s", rv, mv)
+	}
+
+	if rv.Major > mv.Major+1 {
+		return warn(name, "replicate through an intermediate version",
+			"replica %s is more than one major version newer than master %s", rv, mv)
+	}
+
+	return pass(name, "master %s, replica %s", mv, rv)
+}
+
+func checkMaxAllowedPacket(master, replica *server.Info, replicaMaxPacket int64) Result {
+	const name = "max_allowed_packet"
+
+	hint := fmt.Sprintf("SET PERSIST max_allowed_packet = %d on replica", master.MaxAllowedPacket)
+
+	if replica.MaxAllowedPacket < master.MaxAllowedPacket {
+		return fail(name, hint, "max_allowed_packet of replica %d is less than %d of master",
+			replica.MaxAllowedPacket, master.MaxAllowedPacket)
+	}
+
+	if replicaMaxPacket > 0 && replicaMaxPacket < master.MaxAllowedPacket {
+		return fail(name, fmt.Sprintf("SET PERSIST replica_max_allowed_packet = %d on replica", master.MaxAllowedPacket),
+			"replica_max_allowed_packet of replica %d is less than max_allowed_packet %d of master",
+			replicaMaxPacket, master.MaxAllowedPacket)
+	}
+
+	return pass(name, "master %d, replica %d", master.MaxAllowedPacket, replica.MaxAllowedPacket)
+}
@@ -0,0 +1,84 @@
+package preflight_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/preflight"
+	"github.com/partyzanex/repmy/pkg/server"
+	"github.com/partyzanex/testutils"
+)
+
+func info(id int64, uuid, version string) *server.Info {
+	v, err := mysql.ParseVersion(version)
+	if err != nil {
+		panic(err)
+	}
+
+	return &server.Info{
+		ServerID:         id,
+		ServerUUID:       uuid,
+		Version:          v,
+		ReadOnly:         true,
+		LogBin:           true,
+		BinlogFormat:     "ROW",
+		GTIDMode:         "ON",
+		MaxAllowedPacket: 64 << 20,
+	}
+}
+
+func statuses(report preflight.Report) map[string]preflight.Status {
+	result := make(map[string]preflight.Status, len(report))
+
+	for _, r := range report {
+		result[r.Name] = r.Status
+	}
+
+	return result
+}
+
+func TestCheck(t *testing.T) {
+	master, replica := info(1, "uuid-1", "8.0.28"), info(2, "uuid-2", "8.0.30")
+
+	report := preflight.Check(master, replica, 1<<30)
+	testutils.AssertEqual(t, "failed", false, report.Failed())
+
+	for name, status := range statuses(report) {
+		testutils.AssertEqual(t, name, preflight.Passed, status)
+	}
+
+	master.BinlogFormat = "MIXED"
+	replica.ServerID = 1
+	replica.ServerUUID = "uuid-1"
+	replica.ReadOnly = false
+	replica.GTIDMode = "OFF"
+	replica.Version, _ = mysql.ParseVersion("5.7.30")
+	replica.MaxAllowedPacket = 4 << 20
+
+	report = preflight.Check(master, replica, 1<<30)
+	testutils.AssertEqual(t, "failed", true, report.Failed())
+
+	got := statuses(report)
+	testutils.AssertEqual(t, "binary log", preflight.Failed, got["binary log"])
+	testutils.AssertEqual(t, "server_id", preflight.Failed, got["server_id"])
+	testutils.AssertEqual(t, "server_uuid", preflight.Failed, got["server_uuid"])
+	testutils.AssertEqual(t, "read_only", preflight.Warning, got["read_only"])
+	testutils.AssertEqual(t, "gtid_mode", preflight.Failed, got["gtid_mode"])
+	testutils.AssertEqual(t, "version", preflight.Failed, got["version"])
+	testutils.AssertEqual(t, "max_allowed_packet", preflight.Failed, got["max_allowed_packet"])
+
+	buf := &bytes.Buffer{}
+
+	_, err := report.WriteTo(buf)
+	testutils.FatalErr(t, "WriteTo", err)
+	testutils.AssertEqual(t, "hint", true, strings.Contains(buf.String(), "hint: remove auto.cnf"))
+}
+
+func TestCheck_ReplicaMaxPacket(t *testing.T) {
+	master, replica := info(1, "uuid-1", "8.0.28"), info(2, "uuid-2", "8.0.28")
+
+	report := preflight.Check(master, replica, 16<<20)
+	testutils.AssertEqual(t, "max_allowed_packet", preflight.Failed, statuses(report)["max_allowed_packet"])
+}
@@ -0,0 +1,221 @@
+package preflight
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/server"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+)
+
+// DefaultProbeTimeout limits waiting for replica to connect to master as replication user
+const DefaultProbeTimeout = 10 * time.Second
+
+// Dialer opens connection to master as replication user
+type Dialer func(user mysql.ReplUser, addr string) (*sql.DB, error)
+
+// Checker validates master and replica pair
+type Checker struct {
+	Master  *node.Node
+	Replica *node.Node
+	// User is checked if name is not empty
+	User mysql.ReplUser
+	// Dial is used to connect as replication user from host of repmy
+	// if replica can not check it, DialReplUser is used if nil
+	Dial Dialer
+	// ProbeTimeout is DefaultProbeTimeout if zero
+	ProbeTimeout time.Duration
+}
+
+// DialReplUser opens tcp connection to addr with credentials of user
+func DialReplUser(user mysql.ReplUser, addr string) (*sql.DB, error) {
+	cfg := driver.NewConfig()
+	cfg.User = user.Name
+	cfg.Passwd = user.Password
+	cfg.Net = "tcp"
+	cfg.Addr = addr
+	cfg.AllowNativePasswords = true
+
+	if user.RequireSSL {
+		cfg.TLSConfig = "skip-verify"
+	}
+
+	return sql.Open("mysql", cfg.FormatDSN())
+}
+
+// Run runs all checks, error is returned only if servers are unreachable
+func (c *Checker) Run(ctx context.Context) (Report, error) {
+	masterInfo, err := c.Master.Server().Info(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "master %s", c.Master)
+	}
+
+	replicaInfo, err := c.Replica.Server().Info(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "replica %s", c.Replica)
+	}
+
+	vars, err := c.Replica.Server().Variables(ctx, "slave_max_allowed_packet", "replica_max_allowed_packet")
+	if err != nil {
+		return nil, errors.Wrapf(err, "replica %s", c.Replica)
+	}
+
+	packet := vars["replica_max_allowed_packet"]
+	if packet == "" {
+		packet = vars["slave_max_allowed_packet"]
+	}
+
+	replicaMaxPacket, _ := strconv.ParseInt(packet, 10, 64)
+
+	report := Check(masterInfo, replicaInfo, replicaMaxPacket)
+
+	if c.User.Name != "" {
+		report = append(report, c.checkUser(ctx, replicaInfo), c.checkUserHost(ctx))
+	}
+
+	return report, nil
+}
+
+// checkUser checks that replica connects to master as replication user through
+// temporary channel. If the channel can not be created, the user is checked from host
+// of repmy and passed check is reported as warning, because it is not verified from replica.
+func (c *Checker) checkUser(ctx context.Context, replicaInfo *server.Info) Result {
+	const name = "replication user"
+
+	user := c.User
+	if user.MasterHost == "" {
+		user = c.Master.ReplUser(user)
+	}
+
+	reason := "MariaDB has no replication channels"
+
+	if !replicaInfo.Version.MariaDB {
+		err := c.probe(ctx, user)
+
+		var connErr *slave.ConnectionError
+
+		if errors.As(err, &connErr) {
+			return fail(name, "check password, authentication plugin, SSL and network access from replica to master",
+				"replica %s unable to connect to %s as %s: %s", c.Replica, user.MasterHost, user.Name, connErr)
+		}
+
+		if err == nil {
+			connected := fmt.Sprintf("replica %s connects to %s as %s", c.Replica, user.MasterHost, user.Name)
+
+			result := c.checkGrants(ctx)
+			if result.Status == Passed {
+				return pass(name, "%s with REPLICATION SLAVE", connected)
+			}
+
+			return warn(name, result.Hint, "%s, privileges are not verified: %s", connected, result.Message)
+		}
+
+		reason = err.Error()
+	}
+
+	result := c.checkGrants(ctx)
+	if result.Status == Passed {
+		return warn(name, "connection from replica is not verified, check it on replica",
+			"%s, from host of repmy only: %s", result.Message, reason)
+	}
+
+	return result
+}
+
+// probe starts IO thread of temporary channel on replica at the current position of master
+func (c *Checker) probe(ctx context.Context, user mysql.ReplUser) error {
+	status, err := c.Master.Master().ShowStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	timeout := c.ProbeTimeout
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+
+	return c.Replica.Slave().ProbeConnection(ctx, *status, user, timeout)
+}
+
+// checkGrants connects to master as replication user from host of repmy and checks its privileges
+func (c *Checker) checkGrants(ctx context.Context) Result {
+	const name = "replication user"
+
+	dial := c.Dial
+	if dial == nil {
+		dial = DialReplUser
+	}
+
+	db, err := dial(c.User, c.Master.Addr())
+	if err != nil {
+		return fail(name, "check DSN of master", "unable to connect: %s", err)
+	}
+
+	defer db.Close()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		return fail(name, "check password, authentication plugin and network access to master",
+			"%s unable to connect to %s from host of repmy: %s", c.User.Name, c.Master.Addr(), err)
+	}
+
+	rows, err := db.QueryContext(ctx, `SHOW GRANTS FOR CURRENT_USER()`)
+	if err != nil {
+		return fail(name, "", "unable to read grants: %s", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var grant string
+
+		err = rows.Scan(&grant)
+		if err != nil {
+			return fail(name, "", "unable to read grants: %s", err)
+		}
+
+		upper := strings.ToUpper(grant)
+
+		if strings.Contains(upper, "REPLICATION SLAVE") || strings.Contains(upper, "ALL PRIVILEGES") {
+			return pass(name, "%s connects to %s and has REPLICATION SLAVE", c.User.Name, c.Master.Addr())
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fail(name, "", "unable to read grants: %s", err)
+	}
+
+	return fail(name, "GRANT REPLICATION SLAVE ON *.* TO the replication user on master",
+		"%s has no REPLICATION SLAVE privilege", c.User.Name)
+}
+
+// checkUserHost checks that host of replica matches host of replication account,
+// master may see replica by another name or address, so mismatch is a warning
+func (c *Checker) checkUserHost(ctx context.Context) Result {
+	const name = "replication user host"
+
+	var count int
+
+	err := c.Master.DB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM mysql.user WHERE User = ? AND ? LIKE Host`,
+		c.User.Name, c.Replica.Host,
+	).Scan(&count)
+	if err != nil {
+		return warn(name, "grant SELECT on mysql.user to run this check", "unable to check: %s", err)
+	}
+
+	if count == 0 {
+		return warn(name, "create the replication user for host of replica or use '%'",
+			"no account %s matches host %s of replica", c.User.Name, c.Replica.Host)
+	}
+
+	return pass(name, "account %s matches host %s of replica", c.User.Name, c.Replica.Host)
+}
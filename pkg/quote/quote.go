@@ -0,0 +1,138 @@
+// Package quote escapes SQL identifiers and string literals for MySQL
+package quote
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	identReplacer = strings.NewReplacer("`", "``")
+
+	// escapes of mysql_real_escape_string
+	backslashReplacer = strings.NewReplacer(
+		"\\", "\\\\",
+		"'", "\\'",
+		"\"", "\\\"",
+		"\x00", "\\0",
+		"\n", "\\n",
+		"\r", "\\r",
+		"\x1a", "\\Z",
+	)
+
+	// only the quote is special in NO_BACKSLASH_ESCAPES mode
+	noBackslashReplacer = strings.NewReplacer("'", "''")
+)
+
+// Ident returns identifier in backticks, backticks inside are doubled
+func Ident(name string) string {
+	return "`" + identReplacer.Replace(name) + "`"
+}
+
+// Idents returns comma separated list of quoted identifiers
+func Idents(names ...string) string {
+	quoted := make([]string, len(names))
+
+	for i, name := range names {
+		quoted[i] = Ident(name)
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+// Qualified returns quoted name qualified by quoted database name `db`.`name`,
+// returns only quoted name if db is empty
+func Qualified(db, name string) string {
+	if db == "" {
+		return Ident(name)
+	}
+
+	return Ident(db) + "." + Ident(name)
+}
+
+// String returns string literal in single quotes
+// for default SQL mode (with backslash escapes)
+func String(s string) string {
+	return Quoter{}.String(s)
+}
+
+// Quoter escapes string literals for the SQL mode of session
+type Quoter struct {
+	// NoBackslashEscapes is true if NO_BACKSLASH_ESCAPES is enabled
+	NoBackslashEscapes bool
+}
+
+// String returns string literal in single quotes
+func (q Quoter) String(s string) string {
+	if q.NoBackslashEscapes {
+		return "'" + noBackslashReplacer.Replace(s) + "'"
+	}
+
+	return "'" + backslashReplacer.Replace(s) + "'"
+}
+
+// Ident returns identifier in backticks, same as Ident
+func (Quoter) Ident(name string) string {
+	return Ident(name)
+}
+
+// Account returns quoted account name 'user'@'host'
+func (q Quoter) Account(user, host string) string {
+	return q.String(user) + "@" + q.String(host)
+}
+
+// ParseSQLMode creates Quoter for value of @@sql_mode
+func ParseSQLMode(mode string) Quoter {
+	q := Quoter{}
+
+	for _, m := range strings.Split(mode, ",") {
+		if strings.EqualFold(strings.TrimSpace(m), "NO_BACKSLASH_ESCAPES") {
+			q.NoBackslashEscapes = true
+		}
+	}
+
+	return q
+}
+
+// Queryer is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type Queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Detect creates Quoter for SQL mode of the session
+func Detect(ctx context.Context, db Queryer) (Quoter, error) {
+	var mode string
+
+	err := db.QueryRowContext(ctx, `SELECT @@SESSION.sql_mode`).Scan(&mode)
+	if err != nil {
+		return Quoter{}, errors.Wrap(err, "unable to get sql_mode")
+	}
+
+	return ParseSQLMode(mode), nil
+}
+
+// Conner is implemented by *sql.DB and *sqlx.DB
+type Conner interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// Conn returns dedicated connection of db and Quoter for SQL mode of its session,
+// statements quoted by Quoter must be executed on the returned connection,
+// the connection must be closed by caller
+func Conn(ctx context.Context, db Conner) (*sql.Conn, Quoter, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, Quoter{}, errors.Wrap(err, "unable to get connection")
+	}
+
+	q, err := Detect(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, q, err
+	}
+
+	return conn, q, nil
+}
@@ -0,0 +1,119 @@
+package quote_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/partyzanex/testutils"
+)
+
+func TestIdent(t *testing.T) {
+	testutils.AssertEqual(t, "plain", "`table`", quote.Ident("table"))
+	testutils.AssertEqual(t, "backtick", "`ta``ble`", quote.Ident("ta`ble"))
+	testutils.AssertEqual(t, "idents", "`a`, `b```", quote.Idents("a", "b`"))
+	testutils.AssertEqual(t, "qualified", "`db`.`t`", quote.Qualified("db", "t"))
+	testutils.AssertEqual(t, "not qualified", "`t`", quote.Qualified("", "t"))
+}
+
+func TestQuoter_String(t *testing.T) {
+	testutils.AssertEqual(t, "default", `'it\'s \\ \n'`, quote.String("it's \\ \n"))
+
+	q := quote.ParseSQLMode("STRICT_TRANS_TABLES,NO_BACKSLASH_ESCAPES")
+	testutils.AssertEqual(t, "NoBackslashEscapes", true, q.NoBackslashEscapes)
+	testutils.AssertEqual(t, "no backslash", `'it''s \ '`, q.String(`it's \ `))
+	testutils.AssertEqual(t, "account", `'repl'@'%'`, q.Account("repl", "%"))
+}
+
+func FuzzIdent(f *testing.F) {
+	for _, seed := range []string{"", "table", "ta`ble", "``", "a b.c", "\x00"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		got, ok := unquoteIdent(quote.Ident(name))
+		if !ok || got != name {
+			t.Fatalf("Ident(%q) is not reversible: %q", name, got)
+		}
+	})
+}
+
+func FuzzString(f *testing.F) {
+	for _, seed := range []string{"", "it's", `\'`, "\x00\n\r\x1a\"", "''"} {
+		f.Add(seed, false)
+		f.Add(seed, true)
+	}
+
+	f.Fuzz(func(t *testing.T, s string, noBackslash bool) {
+		q := quote.Quoter{NoBackslashEscapes: noBackslash}
+
+		got, ok := unquoteString(q.String(s), noBackslash)
+		if !ok || got != s {
+			t.Fatalf("String(%q) is not reversible: %q", s, got)
+		}
+	})
+}
+
+// unquoteIdent reads identifier like the MySQL lexer does
+func unquoteIdent(s string) (string, bool) {
+	if len(s) < 2 || s[0] != '`' || s[len(s)-1] != '`' {
+		return "", false
+	}
+
+	b := strings.Builder{}
+	body := s[1 : len(s)-1]
+
+	for i := 0; i < len(body); i++ {
+		if body[i] == '`' {
+			// a single backtick terminates identifier
+			if i+1 >= len(body) || body[i+1] != '`' {
+				return "", false
+			}
+
+			i++
+		}
+
+		b.WriteByte(body[i])
+	}
+
+	return b.String(), true
+}
+
+// unquoteString reads string literal like the MySQL lexer does
+func unquoteString(s string, noBackslash bool) (string, bool) {
+	if len(s) < 2 || s[0] != '\'' || s[len(s)-1] != '\'' {
+		return "", false
+	}
+
+	escapes := map[byte]byte{'0': 0, 'n': '\n', 'r': '\r', 'Z': '\x1a'}
+	b := strings.Builder{}
+	body := s[1 : len(s)-1]
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+
+		switch {
+		case c == '\\' && !noBackslash:
+			if i+1 >= len(body) {
+				return "", false
+			}
+
+			i++
+			c = body[i]
+
+			if e, ok := escapes[c]; ok {
+				c = e
+			}
+		case c == '\'':
+			if i+1 >= len(body) || body[i+1] != '\'' {
+				return "", false
+			}
+
+			i++
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String(), true
+}
@@ -0,0 +1,341 @@
+package schema
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/quote"
+)
+
+// Kind represents kind of difference
+type Kind string
+
+const (
+	MissingTable Kind = "missing table"
+	ExtraTable   Kind = "extra table"
+	Column       Kind = "column"
+	Index        Kind = "index"
+	Constraint   Kind = "constraint"
+	Engine       Kind = "engine"
+	Charset      Kind = "charset"
+	Options      Kind = "options"
+	ViewDDL      Kind = "view"
+)
+
+// Difference represents difference of target schema from source schema,
+// Source or Target is empty if the object is absent on the server
+type Difference struct {
+	Table  string
+	Kind   Kind
+	Name   string
+	Source string
+	Target string
+}
+
+// Compare returns differences of target tables from source tables ordered by table name
+func Compare(source, target map[string]*Table) []Difference {
+	diffs := make([]Difference, 0)
+
+	for _, name := range names(source, target) {
+		s, t := source[name], target[name]
+
+		switch {
+		case t == nil:
+			diffs = append(diffs, Difference{Table: name, Kind: MissingTable, Source: s.DDL})
+		case s == nil:
+			diffs = append(diffs, Difference{Table: name, Kind: ExtraTable, Target: t.DDL})
+		case s.View || t.View:
+			if s.DDL != t.DDL {
+				diffs = append(diffs, Difference{Table: name, Kind: ViewDDL, Source: s.DDL, Target: t.DDL})
+			}
+		default:
+			diffs = append(diffs, compareTables(s, t)...)
+		}
+	}
+
+	return diffs
+}
+
+func compareTables(s, t *Table) []Difference {
+	diffs := compareColumns(s, t)
+	diffs = append(diffs, compareDefinitions(s.Name, Index, s.Indexes, t.Indexes)...)
+	diffs = append(diffs, compareDefinitions(s.Name, Constraint, s.Constraints, t.Constraints)...)
+
+	if s.Engine != t.Engine {
+		diffs = append(diffs, Difference{Table: s.Name, Kind: Engine, Source: s.Engine, Target: t.Engine})
+	}
+
+	if s.Charset != t.Charset || s.Collation != t.Collation {
+		diffs = append(diffs, Difference{Table: s.Name, Kind: Charset,
+			Source: charset(s.Charset, s.Collation), Target: charset(t.Charset, t.Collation)})
+	}
+
+	if s.Options != t.Options {
+		diffs = append(diffs, Difference{Table: s.Name, Kind: Options, Source: s.Options, Target: t.Options})
+	}
+
+	return diffs
+}
+
+// compareColumns compares definitions and order of columns, columns out of the longest common
+// sequence of both tables are moved, their Source and Target have AFTER or FIRST clause
+func compareColumns(s, t *Table) []Difference {
+	var diffs []Difference
+
+	ordered := inOrder(s.Columns, t.Columns)
+
+	for _, def := range s.Columns {
+		other := t.Column(def.Name)
+
+		switch {
+		case other == nil:
+			diffs = append(diffs, Difference{Table: s.Name, Kind: Column, Name: def.Name, Source: def.SQL})
+		case !ordered[def.Name]:
+			diffs = append(diffs, Difference{Table: s.Name, Kind: Column, Name: def.Name,
+				Source: def.SQL + position(s, def.Name), Target: other.SQL + position(t, def.Name)})
+		case other.SQL != def.SQL:
+			diffs = append(diffs, Difference{Table: s.Name, Kind: Column, Name: def.Name, Source: def.SQL, Target: other.SQL})
+		}
+	}
+
+	for _, def := range t.Columns {
+		if s.Column(def.Name) == nil {
+			diffs = append(diffs, Difference{Table: s.Name, Kind: Column, Name: def.Name, Target: def.SQL})
+		}
+	}
+
+	return diffs
+}
+
+// inOrder returns names of the longest sequence of columns present in both tables in the same order
+func inOrder(source, target []Definition) map[string]bool {
+	s, t := common(source, target), common(target, source)
+
+	// lengths[i][j] is length of common sequence of s[i:] and t[j:]
+	lengths := make([][]int, len(s)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(t)+1)
+	}
+
+	for i := len(s) - 1; i >= 0; i-- {
+		for j := len(t) - 1; j >= 0; j-- {
+			switch {
+			case strings.EqualFold(s[i], t[j]):
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	ordered := make(map[string]bool)
+
+	for i, j := 0, 0; i < len(s) && j < len(t); {
+		switch {
+		case strings.EqualFold(s[i], t[j]):
+			ordered[s[i]] = true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return ordered
+}
+
+// common returns names of defs present in others
+func common(defs, others []Definition) []string {
+	names := make([]string, 0, len(defs))
+
+	for _, def := range defs {
+		if find(others, def.Name) != nil {
+			names = append(names, def.Name)
+		}
+	}
+
+	return names
+}
+
+func compareDefinitions(table string, kind Kind, source, target []Definition) []Difference {
+	var diffs []Difference
+
+	for _, def := range source {
+		other := find(target, def.Name)
+
+		switch {
+		case other == nil:
+			diffs = append(diffs, Difference{Table: table, Kind: kind, Name: def.Name, Source: def.SQL})
+		case other.SQL != def.SQL:
+			diffs = append(diffs, Difference{Table: table, Kind: kind, Name: def.Name, Source: def.SQL, Target: other.SQL})
+		}
+	}
+
+	for _, def := range target {
+		if find(source, def.Name) == nil {
+			diffs = append(diffs, Difference{Table: table, Kind: kind, Name: def.Name, Target: def.SQL})
+		}
+	}
+
+	return diffs
+}
+
+func charset(charset, collation string) string {
+	if collation == "" {
+		return charset
+	}
+
+	return charset + " " + collation
+}
+
+func names(source, target map[string]*Table) []string {
+	names := make([]string, 0, len(source))
+
+	for name := range source {
+		names = append(names, name)
+	}
+
+	for name := range target {
+		if _, ok := source[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Reconcile returns statements which make target tables equal to source tables,
+// diffs must be returned by Compare for the same source
+func Reconcile(source map[string]*Table, diffs []Difference) []string {
+	statements := make([]string, 0)
+	clauses := make(map[string][]string)
+	order := make([]string, 0)
+
+	for _, d := range diffs {
+		table := quote.Ident(d.Table)
+
+		switch d.Kind {
+		case MissingTable:
+			statements = append(statements, d.Source)
+			continue
+		case ExtraTable:
+			statements = append(statements, dropTable(d))
+			continue
+		case ViewDDL:
+			statements = append(statements, "DROP VIEW IF EXISTS "+table, d.Source)
+			continue
+		}
+
+		if _, ok := clauses[d.Table]; !ok {
+			order = append(order, d.Table)
+		}
+
+		clauses[d.Table] = append(clauses[d.Table], alterClauses(source[d.Table], d)...)
+	}
+
+	for _, name := range order {
+		if len(clauses[name]) == 0 {
+			continue
+		}
+
+		statements = append(statements, "ALTER TABLE "+quote.Ident(name)+" "+strings.Join(clauses[name], ", "))
+	}
+
+	return statements
+}
+
+func dropTable(d Difference) string {
+	if !strings.HasPrefix(d.Target, "CREATE TABLE") {
+		return "DROP VIEW " + quote.Ident(d.Table)
+	}
+
+	return "DROP TABLE " + quote.Ident(d.Table)
+}
+
+func alterClauses(s *Table, d Difference) []string {
+	switch d.Kind {
+	case Column:
+		switch {
+		case d.Target == "":
+			return []string{"ADD COLUMN " + d.Source + position(s, d.Name)}
+		case d.Source == "":
+			return []string{"DROP COLUMN " + quote.Ident(d.Name)}
+		default:
+			// Source of moved column has position
+			return []string{"MODIFY COLUMN " + d.Source}
+		}
+	case Index:
+		switch {
+		case d.Target == "":
+			return []string{"ADD " + d.Source}
+		case d.Source == "":
+			return []string{dropIndex(d.Name)}
+		default:
+			return []string{dropIndex(d.Name), "ADD " + d.Source}
+		}
+	case Constraint:
+		switch {
+		case d.Target == "":
+			return []string{"ADD " + d.Source}
+		case d.Source == "":
+			return []string{dropConstraint(d.Name, d.Target)}
+		default:
+			return []string{dropConstraint(d.Name, d.Target), "ADD " + d.Source}
+		}
+	case Engine:
+		return []string{"ENGINE=" + d.Source}
+	case Charset:
+		clause := "DEFAULT CHARSET=" + s.Charset
+		if s.Collation != "" {
+			clause += " COLLATE=" + s.Collation
+		}
+
+		return []string{clause}
+	case Options:
+		// removed options can not be reset in general way
+		if s.Options != "" {
+			return []string{s.Options}
+		}
+	}
+
+	return nil
+}
+
+// position returns AFTER or FIRST clause keeping column order of source
+func position(s *Table, column string) string {
+	for i, def := range s.Columns {
+		if def.Name != column {
+			continue
+		}
+
+		if i == 0 {
+			return " FIRST"
+		}
+
+		return " AFTER " + quote.Ident(s.Columns[i-1].Name)
+	}
+
+	return ""
+}
+
+func dropIndex(name string) string {
+	if name == "PRIMARY" {
+		return "DROP PRIMARY KEY"
+	}
+
+	return "DROP INDEX " + quote.Ident(name)
+}
+
+func dropConstraint(name, def string) string {
+	if strings.Contains(def, " FOREIGN KEY ") {
+		return "DROP FOREIGN KEY " + quote.Ident(name)
+	}
+
+	return "DROP CHECK " + quote.Ident(name)
+}
@@ -0,0 +1,45 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/pkg/errors"
+)
+
+// Load returns parsed tables and views of the current database of db,
+// only listed tables are loaded if tables is not empty
+func Load(ctx context.Context, db *sql.DB, tables ...string) (map[string]*Table, error) {
+	repo := dump.New(db)
+
+	list, err := repo.GetTables(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get tables")
+	}
+
+	selected := make(map[string]bool, len(tables))
+	for _, name := range tables {
+		selected[name] = true
+	}
+
+	result := make(map[string]*Table, len(list))
+
+	for _, table := range list {
+		if len(selected) > 0 && !selected[table.Name] {
+			continue
+		}
+
+		ddl, err := repo.GetCreateTable(ctx, *table)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get DDL of %s", table.Name)
+		}
+
+		result[table.Name], err = Parse(table.Name, ddl)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package schema_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/schema"
+	"github.com/partyzanex/testutils"
+)
+
+const masterDDL = "CREATE TABLE `users` (\n" +
+	"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+	"  `email` varchar(255) NOT NULL,\n" +
+	"  `name` varchar(64) DEFAULT 'a  b',\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `email` (`email`)\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=120 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci"
+
+const replicaDDL = "CREATE TABLE `users` (\n" +
+	"  `id`   int NOT NULL AUTO_INCREMENT,\n" +
+	"  `email` varchar(128) NOT NULL,\n" +
+	"  `hotfix` int DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `email` (`email`)\n" +
+	") ENGINE=MyISAM AUTO_INCREMENT=7 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci"
+
+func TestParse(t *testing.T) {
+	table, err := schema.Parse("users", masterDDL)
+	testutils.FatalErr(t, "Parse", err)
+
+	testutils.AssertEqual(t, "columns", 3, len(table.Columns))
+	testutils.AssertEqual(t, "column", "`name` varchar(64) DEFAULT 'a  b'", table.Column("name").SQL)
+	testutils.AssertEqual(t, "primary", "PRIMARY KEY (`id`)", table.Index("PRIMARY").SQL)
+	testutils.AssertEqual(t, "unique", "UNIQUE KEY `email` (`email`)", table.Index("email").SQL)
+	testutils.AssertEqual(t, "engine", "InnoDB", table.Engine)
+	testutils.AssertEqual(t, "charset", "utf8mb4", table.Charset)
+	testutils.AssertEqual(t, "collation", "utf8mb4_0900_ai_ci", table.Collation)
+	testutils.AssertEqual(t, "options", "", table.Options)
+	testutils.AssertEqual(t, "auto increment", false, strings.Contains(table.DDL, "AUTO_INCREMENT=120"))
+
+	other, err := schema.Parse("users", strings.Replace(masterDDL, "AUTO_INCREMENT=120", "AUTO_INCREMENT=5", 1))
+	testutils.FatalErr(t, "Parse", err)
+	testutils.AssertEqual(t, "ddl", table.DDL, other.DDL)
+}
+
+func TestCompare(t *testing.T) {
+	master, err := schema.Parse("users", masterDDL)
+	testutils.FatalErr(t, "Parse", err)
+
+	replica, err := schema.Parse("users", replicaDDL)
+	testutils.FatalErr(t, "Parse", err)
+
+	orders, err := schema.Parse("orders", "CREATE TABLE `orders` (\n  `id` int NOT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB")
+	testutils.FatalErr(t, "Parse", err)
+
+	source := map[string]*schema.Table{"users": master, "orders": orders}
+	target := map[string]*schema.Table{"users": replica}
+
+	diffs := schema.Compare(source, target)
+
+	kinds := make([]string, len(diffs))
+	for i, d := range diffs {
+		kinds[i] = string(d.Kind) + ":" + d.Name
+	}
+
+	testutils.AssertEqual(t, "diffs",
+		"missing table:,column:email,column:name,column:hotfix,index:email,engine:", strings.Join(kinds, ","))
+
+	statements := schema.Reconcile(source, diffs)
+	testutils.AssertEqual(t, "statements", 2, len(statements))
+	testutils.AssertEqual(t, "create", orders.DDL, statements[0])
+	testutils.AssertEqual(t, "alter", "ALTER TABLE `users` MODIFY COLUMN `email` varchar(255) NOT NULL, "+
+		"ADD COLUMN `name` varchar(64) DEFAULT 'a  b' AFTER `email`, DROP COLUMN `hotfix`, "+
+		"DROP INDEX `email`, ADD UNIQUE KEY `email` (`email`), ENGINE=InnoDB", statements[1])
+}
+
+func TestCompare_ForeignKey(t *testing.T) {
+	ddl := "CREATE TABLE `orders` (\n" +
+		"  `id` int NOT NULL,\n" +
+		"  `user_id` int NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `fk_user` (`user_id`),\n" +
+		"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`)%s\n" +
+		") ENGINE=InnoDB"
+
+	master, err := schema.Parse("orders", fmt.Sprintf(ddl, " ON DELETE CASCADE"))
+	testutils.FatalErr(t, "Parse", err)
+
+	replica, err := schema.Parse("orders", fmt.Sprintf(ddl, ""))
+	testutils.FatalErr(t, "Parse", err)
+
+	testutils.AssertEqual(t, "index", "KEY `fk_user` (`user_id`)", master.Index("fk_user").SQL)
+	testutils.AssertEqual(t, "constraint", true, strings.HasPrefix(master.Constraint("fk_user").SQL, "CONSTRAINT"))
+
+	source := map[string]*schema.Table{"orders": master}
+	diffs := schema.Compare(source, map[string]*schema.Table{"orders": replica})
+
+	testutils.AssertEqual(t, "diffs", 1, len(diffs))
+	testutils.AssertEqual(t, "kind", schema.Constraint, diffs[0].Kind)
+
+	statements := schema.Reconcile(source, diffs)
+	testutils.AssertEqual(t, "alter", "ALTER TABLE `orders` DROP FOREIGN KEY `fk_user`, "+
+		"ADD CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE",
+		strings.Join(statements, ";"))
+}
+
+func TestCompare_ColumnOrder(t *testing.T) {
+	ddl := "CREATE TABLE `users` (\n%s,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB"
+	columns := map[string]string{
+		"id":    "  `id` int NOT NULL",
+		"email": "  `email` varchar(255) NOT NULL",
+		"name":  "  `name` varchar(64) DEFAULT NULL",
+		"age":   "  `age` int DEFAULT NULL",
+	}
+
+	parse := func(names ...string) *schema.Table {
+		defs := make([]string, len(names))
+		for i, name := range names {
+			defs[i] = columns[name]
+		}
+
+		table, err := schema.Parse("users", fmt.Sprintf(ddl, strings.Join(defs, ",\n")))
+		testutils.FatalErr(t, "Parse", err)
+
+		return table
+	}
+
+	source := map[string]*schema.Table{"users": parse("id", "email", "name", "age")}
+	diffs := schema.Compare(source, map[string]*schema.Table{"users": parse("age", "id", "name", "email")})
+
+	testutils.AssertEqual(t, "diffs", 2, len(diffs))
+	testutils.AssertEqual(t, "moved", "`email` varchar(255) NOT NULL AFTER `id`", diffs[0].Source)
+	testutils.AssertEqual(t, "target", "`email` varchar(255) NOT NULL AFTER `name`", diffs[0].Target)
+
+	statements := schema.Reconcile(source, diffs)
+	testutils.AssertEqual(t, "alter", "ALTER TABLE `users` MODIFY COLUMN `email` varchar(255) NOT NULL AFTER `id`, "+
+		"MODIFY COLUMN `age` int DEFAULT NULL AFTER `name`", strings.Join(statements, ";"))
+}
@@ -0,0 +1,185 @@
+// Package schema detects drift of table definitions between servers
+package schema
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Definition represents column, index or constraint of table
+type Definition struct {
+	Name string
+	// SQL is normalized definition as it is written in CREATE TABLE
+	SQL string
+}
+
+// Table represents normalized definition of table or view
+type Table struct {
+	Name    string
+	View    bool
+	Columns []Definition
+	Indexes []Definition
+	// Constraints are foreign keys and checks, they may have the same names as indexes
+	Constraints []Definition
+	Engine      string
+	Charset     string
+	Collation   string
+	// Options are the rest of table options and partitioning
+	Options string
+	// DDL is normalized CREATE statement without AUTO_INCREMENT counter
+	DDL string
+}
+
+var (
+	autoIncrement = regexp.MustCompile(`\s*AUTO_INCREMENT=\d+`)
+	engineOption  = regexp.MustCompile(`ENGINE=(\S+)`)
+	charsetOption = regexp.MustCompile(`(?:DEFAULT )?CHARSET=(\S+)`)
+	collateOption = regexp.MustCompile(`(?:DEFAULT )?COLLATE=(\S+)`)
+	identifier    = regexp.MustCompile("`((?:[^`]|``)+)`")
+)
+
+// Parse parses output of SHOW CREATE TABLE, definition of view is kept as normalized DDL
+func Parse(name, ddl string) (*Table, error) {
+	t := &Table{Name: name}
+
+	upper := strings.ToUpper(strings.TrimSpace(ddl))
+	if !strings.HasPrefix(upper, "CREATE TABLE") {
+		if !strings.Contains(upper, " VIEW ") {
+			return nil, errors.Errorf("unexpected DDL of %s", name)
+		}
+
+		t.View, t.DDL = true, normalize(ddl)
+
+		return t, nil
+	}
+
+	lines := strings.Split(strings.TrimSpace(ddl), "\n")
+	if len(lines) < 3 {
+		return nil, errors.Errorf("unexpected DDL of table %s", name)
+	}
+
+	// definitions are written one per line between "CREATE TABLE `t` (" and ") ENGINE=..."
+	end := len(lines) - 1
+	for end > 0 && !strings.HasPrefix(lines[end], ")") {
+		end--
+	}
+
+	if end == 0 {
+		return nil, errors.Errorf("unexpected DDL of table %s", name)
+	}
+
+	for _, line := range lines[1:end] {
+		def := normalize(strings.TrimSuffix(strings.TrimSpace(line), ","))
+
+		switch {
+		case strings.HasPrefix(def, "`"):
+			t.Columns = append(t.Columns, Definition{Name: unquote(def), SQL: def})
+		case strings.HasPrefix(def, "CONSTRAINT "):
+			t.Constraints = append(t.Constraints, Definition{Name: unquote(def), SQL: def})
+		default:
+			t.Indexes = append(t.Indexes, Definition{Name: indexName(def), SQL: def})
+		}
+	}
+
+	options := autoIncrement.ReplaceAllString(strings.Join(lines[end:], " "), "")
+	options = normalize(strings.TrimPrefix(options, ")"))
+
+	if m := engineOption.FindStringSubmatch(options); m != nil {
+		t.Engine = m[1]
+	}
+
+	if m := charsetOption.FindStringSubmatch(options); m != nil {
+		t.Charset = m[1]
+	}
+
+	if m := collateOption.FindStringSubmatch(options); m != nil {
+		t.Collation = m[1]
+	}
+
+	for _, re := range []*regexp.Regexp{engineOption, charsetOption, collateOption} {
+		options = re.ReplaceAllString(options, "")
+	}
+
+	t.Options = normalize(options)
+	t.DDL = normalize(autoIncrement.ReplaceAllString(ddl, ""))
+
+	return t, nil
+}
+
+// Column returns column by name or nil
+func (t *Table) Column(name string) *Definition {
+	return find(t.Columns, name)
+}
+
+// Index returns index by name or nil
+func (t *Table) Index(name string) *Definition {
+	return find(t.Indexes, name)
+}
+
+// Constraint returns foreign key or check constraint by name or nil
+func (t *Table) Constraint(name string) *Definition {
+	return find(t.Constraints, name)
+}
+
+func find(defs []Definition, name string) *Definition {
+	for i := range defs {
+		if strings.EqualFold(defs[i].Name, name) {
+			return &defs[i]
+		}
+	}
+
+	return nil
+}
+
+// indexName returns name of index, PRIMARY for primary key
+func indexName(def string) string {
+	if strings.HasPrefix(def, "PRIMARY KEY") {
+		return "PRIMARY"
+	}
+
+	return unquote(def)
+}
+
+// unquote returns the first quoted identifier of definition
+func unquote(def string) string {
+	m := identifier.FindStringSubmatch(def)
+	if m == nil {
+		return def
+	}
+
+	return strings.ReplaceAll(m[1], "``", "`")
+}
+
+// normalize collapses whitespace outside of quoted strings and identifiers
+func normalize(s string) string {
+	var (
+		b     strings.Builder
+		quote rune
+		space bool
+	)
+
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			space = true
+			continue
+		}
+
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,253 @@
+package semi
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+)
+
+const (
+	// WaitAfterSync waits for replica acknowledgment before commit in storage engine
+	WaitAfterSync = "AFTER_SYNC"
+	// WaitAfterCommit waits for replica acknowledgment after commit in storage engine
+	WaitAfterCommit = "AFTER_COMMIT"
+)
+
+// Plugins represents names of semisync plugins and prefixes of their variables,
+// MySQL 8.0.26+ uses source/replica terms
+type Plugins struct {
+	Source, SourceLib   string
+	Replica, ReplicaLib string
+	// ReplicaCount is suffix of variable wait_for_slave_count
+	ReplicaCount string
+}
+
+var (
+	legacyPlugins = Plugins{
+		Source:       "rpl_semi_sync_master",
+		SourceLib:    "semisync_master.so",
+		Replica:      "rpl_semi_sync_slave",
+		ReplicaLib:   "semisync_slave.so",
+		ReplicaCount: "wait_for_slave_count",
+	}
+
+	plugins = Plugins{
+		Source:       "rpl_semi_sync_source",
+		SourceLib:    "semisync_source.so",
+		Replica:      "rpl_semi_sync_replica",
+		ReplicaLib:   "semisync_replica.so",
+		ReplicaCount: "wait_for_replica_count",
+	}
+)
+
+// PluginsFor returns names of semisync plugins for server version
+func PluginsFor(version mysql.Version) Plugins {
+	if !version.MariaDB && version.AtLeast(8, 0, 26) {
+		return plugins
+	}
+
+	return legacyPlugins
+}
+
+// SourceConfig represents settings of semisync on master
+type SourceConfig struct {
+	// Timeout of waiting for acknowledgment before falling back to async replication
+	Timeout time.Duration
+	// WaitPoint is WaitAfterSync or WaitAfterCommit
+	WaitPoint string
+	// WaitForReplicaCount is number of acknowledgments required for commit
+	WaitForReplicaCount int
+}
+
+// Validate checks values of config
+func (cfg SourceConfig) Validate() error {
+	switch strings.ToUpper(cfg.WaitPoint) {
+	case "", WaitAfterSync, WaitAfterCommit:
+	default:
+		return errors.Errorf("invalid wait point %q", cfg.WaitPoint)
+	}
+
+	if cfg.Timeout < 0 || cfg.WaitForReplicaCount < 0 {
+		return errors.New("timeout and replica count must not be negative")
+	}
+
+	return nil
+}
+
+// Plugins returns names of semisync plugins for the server: plugins installed
+// before upgrade to 8.0.26+ keep legacy names, so names of installed plugins
+// take precedence over names for server version
+func (repo *Repository) Plugins(ctx context.Context) (Plugins, error) {
+	version, err := mysql.ServerVersion(ctx, repo.db)
+	if err != nil {
+		return Plugins{}, err
+	}
+
+	var installed []string
+
+	err = repo.db.SelectContext(ctx, &installed, `SELECT PLUGIN_NAME FROM information_schema.PLUGINS
+WHERE PLUGIN_NAME LIKE 'rpl\_semi\_sync\_%' AND PLUGIN_STATUS = 'ACTIVE'`)
+	if err != nil {
+		return Plugins{}, errors.Wrap(err, "unable to get installed semisync plugins")
+	}
+
+	p := PluginsFor(version)
+
+	for _, name := range installed {
+		for _, known := range []Plugins{legacyPlugins, plugins} {
+			switch name {
+			case known.Source:
+				p.Source, p.SourceLib, p.ReplicaCount = known.Source, known.SourceLib, known.ReplicaCount
+			case known.Replica:
+				p.Replica, p.ReplicaLib = known.Replica, known.ReplicaLib
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// InstallSource installs semisync plugin of master if it is not installed
+func (repo *Repository) InstallSource(ctx context.Context) error {
+	p, err := repo.Plugins(ctx)
+	if err != nil {
+		return err
+	}
+
+	return repo.install(ctx, p.Source, p.SourceLib)
+}
+
+// InstallReplica installs semisync plugin of replica if it is not installed
+func (repo *Repository) InstallReplica(ctx context.Context) error {
+	p, err := repo.Plugins(ctx)
+	if err != nil {
+		return err
+	}
+
+	return repo.install(ctx, p.Replica, p.ReplicaLib)
+}
+
+func (repo *Repository) install(ctx context.Context, name, lib string) error {
+	var count int
+
+	q := `SELECT COUNT(*) FROM information_schema.PLUGINS WHERE PLUGIN_NAME = ? AND PLUGIN_STATUS = 'ACTIVE'`
+
+	err := repo.db.GetContext(ctx, &count, q, name)
+	if err != nil {
+		return errors.Wrapf(err, "unable to check plugin %s", name)
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	_, err = repo.db.ExecContext(ctx, `INSTALL PLUGIN `+name+` SONAME '`+lib+`'`)
+	if err != nil {
+		return errors.Wrapf(err, "unable to install plugin %s", name)
+	}
+
+	return nil
+}
+
+// EnableSource enables semisync on master with cfg
+func (repo *Repository) EnableSource(ctx context.Context, cfg SourceConfig) error {
+	err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+
+	p, err := repo.Plugins(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Timeout > 0 {
+		err = repo.setGlobal(ctx, p.Source+"_timeout", int64(cfg.Timeout/time.Millisecond))
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.WaitPoint != "" {
+		err = repo.setGlobal(ctx, p.Source+"_wait_point", strings.ToUpper(cfg.WaitPoint))
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.WaitForReplicaCount > 0 {
+		err = repo.setGlobal(ctx, p.Source+"_"+p.ReplicaCount, cfg.WaitForReplicaCount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return repo.setGlobal(ctx, p.Source+"_enabled", 1)
+}
+
+// DisableSource disables semisync on master
+func (repo *Repository) DisableSource(ctx context.Context) error {
+	p, err := repo.Plugins(ctx)
+	if err != nil {
+		return err
+	}
+
+	return repo.setGlobal(ctx, p.Source+"_enabled", 0)
+}
+
+// EnableReplica enables semisync on replica and restarts IO thread if it is running,
+// so the replica negotiates semisync with master
+func (repo *Repository) EnableReplica(ctx context.Context) error {
+	return repo.setReplica(ctx, 1)
+}
+
+// DisableReplica disables semisync on replica and restarts IO thread if it is running
+func (repo *Repository) DisableReplica(ctx context.Context) error {
+	return repo.setReplica(ctx, 0)
+}
+
+func (repo *Repository) setReplica(ctx context.Context, enabled int) error {
+	p, err := repo.Plugins(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = repo.setGlobal(ctx, p.Replica+"_enabled", enabled)
+	if err != nil {
+		return err
+	}
+
+	replica := slave.New(repo.db.DB)
+
+	status, err := replica.ShowStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	// stopped thread negotiates semisync when it is started by user,
+	// connecting thread is running and retries connection to master
+	if !status.IORunning() && status.SlaveIORunning != "Connecting" {
+		return nil
+	}
+
+	err = replica.StopIOThread(ctx)
+	if err != nil {
+		return err
+	}
+
+	return replica.StartIOThread(ctx)
+}
+
+// setGlobal sets global variable, name must not be user input
+func (repo *Repository) setGlobal(ctx context.Context, name string, value interface{}) error {
+	_, err := repo.db.ExecContext(ctx, `SET GLOBAL `+name+` = ?`, value)
+	if err != nil {
+		return errors.Wrapf(err, "unable to set %s", name)
+	}
+
+	return nil
+}
@@ -3,7 +3,9 @@ package semi_test
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/go-sql-driver/mysql"
 
 	"github.com/partyzanex/repmy/pkg/semi"
@@ -23,3 +25,118 @@ func TestRepository_Show(t *testing.T) {
 
 	testutils.AssertEqualFatal(t, "count", len(results) > 0, true)
 }
+
+func TestParseStatus(t *testing.T) {
+	status := semi.ParseStatus([]semi.Variable{
+		{Name: "Rpl_semi_sync_source_status", Value: "ON"},
+		{Name: "Rpl_semi_sync_source_clients", Value: "2"},
+		{Name: "Rpl_semi_sync_source_yes_tx", Value: "120"},
+		{Name: "Rpl_semi_sync_source_no_tx", Value: "3"},
+		{Name: "Rpl_semi_sync_source_tx_avg_wait_time", Value: "1500"},
+		{Name: "Rpl_semi_sync_replica_status", Value: "OFF"},
+	})
+
+	testutils.AssertEqual(t, "SourceOn", true, status.SourceOn)
+	testutils.AssertEqual(t, "ReplicaOn", false, status.ReplicaOn)
+	testutils.AssertEqual(t, "Clients", int64(2), status.Clients)
+	testutils.AssertEqual(t, "YesTx", int64(120), status.YesTx)
+	testutils.AssertEqual(t, "NoTx", int64(3), status.NoTx)
+	testutils.AssertEqual(t, "TxAvgWaitTime", 1500*time.Microsecond, status.TxAvgWaitTime)
+	testutils.AssertEqual(t, "Negotiated(2)", true, status.Negotiated(2))
+	testutils.AssertEqual(t, "Negotiated(3)", false, status.Negotiated(3))
+}
+
+const pluginsQuery = `SELECT PLUGIN_NAME FROM information_schema.PLUGINS
+WHERE PLUGIN_NAME LIKE 'rpl\_semi\_sync\_%' AND PLUGIN_STATUS = 'ACTIVE'`
+
+func TestRepository_EnableSource(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := semi.New(db)
+	ctx := context.Background()
+
+	// legacy plugin is kept after upgrade to 8.0.26+
+	version := sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.30")
+
+	mock.ExpectQuery(`SELECT VERSION()`).WillReturnRows(version)
+	mock.ExpectQuery(pluginsQuery).
+		WillReturnRows(sqlmock.NewRows([]string{"PLUGIN_NAME"}).AddRow("rpl_semi_sync_master"))
+	mock.ExpectExec(`SET GLOBAL rpl_semi_sync_master_timeout = ?`).WithArgs(10000).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET GLOBAL rpl_semi_sync_master_wait_point = ?`).WithArgs("AFTER_SYNC").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET GLOBAL rpl_semi_sync_master_wait_for_slave_count = ?`).WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET GLOBAL rpl_semi_sync_master_enabled = ?`).WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.EnableSource(ctx, semi.SourceConfig{
+		Timeout:             10 * time.Second,
+		WaitPoint:           "after_sync",
+		WaitForReplicaCount: 2,
+	})
+	testutils.FatalErr(t, "repo.EnableSource", err)
+
+	err = repo.EnableSource(ctx, semi.SourceConfig{WaitPoint: "after_flush"})
+	testutils.AssertEqual(t, "invalid wait point", true, err != nil)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_InstallReplica(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := semi.New(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT VERSION()`).
+		WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.28"))
+	mock.ExpectQuery(pluginsQuery).WillReturnRows(sqlmock.NewRows([]string{"PLUGIN_NAME"}))
+	mock.ExpectQuery(`SELECT COUNT(*) FROM information_schema.PLUGINS WHERE PLUGIN_NAME = ? AND PLUGIN_STATUS = 'ACTIVE'`).
+		WithArgs("rpl_semi_sync_replica").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectExec(`INSTALL PLUGIN rpl_semi_sync_replica SONAME 'semisync_replica.so'`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.InstallReplica(ctx)
+	testutils.FatalErr(t, "repo.InstallReplica", err)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_EnableReplica(t *testing.T) {
+	data := map[string]struct {
+		running string
+		restart bool
+	}{
+		"running":    {running: "Yes", restart: true},
+		"connecting": {running: "Connecting", restart: true},
+		"stopped":    {running: "No"},
+	}
+
+	for name, test := range data {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			testutils.FatalErr(t, "sqlmock.New()", err)
+
+			mock.ExpectQuery(`SELECT VERSION()`).
+				WillReturnRows(sqlmock.NewRows([]string{"VERSION()"}).AddRow("8.0.30"))
+			mock.ExpectQuery(pluginsQuery).
+				WillReturnRows(sqlmock.NewRows([]string{"PLUGIN_NAME"}).AddRow("rpl_semi_sync_replica"))
+			mock.ExpectExec(`SET GLOBAL rpl_semi_sync_replica_enabled = ?`).WithArgs(1).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`show slave status`).
+				WillReturnRows(sqlmock.NewRows([]string{"Slave_IO_Running"}).AddRow(test.running))
+
+			if test.restart {
+				mock.ExpectExec(`STOP SLAVE IO_THREAD`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`START SLAVE IO_THREAD`).WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+
+			testutils.FatalErr(t, "repo.EnableReplica", semi.New(db).EnableReplica(context.Background()))
+			testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+		})
+	}
+}
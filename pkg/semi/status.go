@@ -0,0 +1,108 @@
+package semi
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status represents parsed status variables of semisync plugins
+type Status struct {
+	// SourceOn is true if semisync replication is operational on master
+	SourceOn bool
+	// ReplicaOn is true if semisync replication is operational on replica
+	ReplicaOn bool
+
+	// Clients is number of semisync replicas
+	Clients int64
+	// YesTx is number of commits acknowledged by replicas
+	YesTx int64
+	// NoTx is number of commits not acknowledged by replicas
+	NoTx int64
+	// NoTimes is number of times when master turned off semisync
+	NoTimes int64
+
+	TxWaits        int64
+	TxWaitTime     time.Duration
+	TxAvgWaitTime  time.Duration
+	NetWaits       int64
+	NetWaitTime    time.Duration
+	NetAvgWaitTime time.Duration
+	WaitSessions   int64
+
+	TimefuncFailures    int64
+	WaitPosBacktraverse int64
+}
+
+// Negotiated returns true if master replicates semi-synchronously with at least n replicas
+func (s Status) Negotiated(n int) bool {
+	return s.SourceOn && s.Clients >= int64(n)
+}
+
+// Status returns typed semisync status
+func (repo *Repository) Status(ctx context.Context) (*Status, error) {
+	variables, err := repo.Show(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseStatus(variables), nil
+}
+
+// ParseStatus parses variables of SHOW STATUS LIKE '%semi%',
+// both master/slave and source/replica names are supported
+func ParseStatus(variables []Variable) *Status {
+	status := &Status{}
+
+	counters := map[string]*int64{
+		"clients":               &status.Clients,
+		"yes_tx":                &status.YesTx,
+		"no_tx":                 &status.NoTx,
+		"no_times":              &status.NoTimes,
+		"tx_waits":              &status.TxWaits,
+		"net_waits":             &status.NetWaits,
+		"wait_sessions":         &status.WaitSessions,
+		"timefunc_failures":     &status.TimefuncFailures,
+		"wait_pos_backtraverse": &status.WaitPosBacktraverse,
+	}
+
+	// values are in microseconds
+	durations := map[string]*time.Duration{
+		"tx_wait_time":      &status.TxWaitTime,
+		"tx_avg_wait_time":  &status.TxAvgWaitTime,
+		"net_wait_time":     &status.NetWaitTime,
+		"net_avg_wait_time": &status.NetAvgWaitTime,
+	}
+
+	for _, variable := range variables {
+		name := strings.ToLower(variable.Name)
+
+		switch name {
+		case "rpl_semi_sync_master_status", "rpl_semi_sync_source_status":
+			status.SourceOn = strings.EqualFold(variable.Value, "ON")
+			continue
+		case "rpl_semi_sync_slave_status", "rpl_semi_sync_replica_status":
+			status.ReplicaOn = strings.EqualFold(variable.Value, "ON")
+			continue
+		}
+
+		name = strings.TrimPrefix(name, "rpl_semi_sync_master_")
+		name = strings.TrimPrefix(name, "rpl_semi_sync_source_")
+
+		value, err := strconv.ParseInt(variable.Value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if c, ok := counters[name]; ok {
+			*c = value
+		}
+
+		if d, ok := durations[name]; ok {
+			*d = time.Duration(value) * time.Microsecond
+		}
+	}
+
+	return status
+}
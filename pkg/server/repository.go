@@ -0,0 +1,244 @@
+// Package server reads and changes global state of mysql server
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/pkg/errors"
+)
+
+// Repository represents repository layer for server variables
+type Repository struct {
+	db *sqlx.DB
+}
+
+// New creates a new repository
+func New(db *sql.DB) *Repository {
+	return &Repository{
+		db: sqlx.NewDb(db, "mysql"),
+	}
+}
+
+// Info represents global variables important for replication
+type Info struct {
+	ServerID         int64
+	ServerUUID       string
+	Version          mysql.Version
+	Hostname         string
+	Port             int
+	ReportHost       string
+	ReportPort       int
+	ReadOnly         bool
+	SuperReadOnly    bool
+	LogBin           bool
+	LogSlaveUpdates  bool
+	BinlogFormat     string
+	GTIDMode         string
+	GTIDExecuted     string
+	MaxAllowedPacket int64
+}
+
+// GTIDEnabled returns true if gtid_mode is ON
+func (info Info) GTIDEnabled() bool {
+	return strings.EqualFold(info.GTIDMode, "ON")
+}
+
+var infoVariables = []string{
+	"server_id", "server_uuid", "version", "hostname", "port", "report_host", "report_port",
+	"read_only", "super_read_only", "log_bin", "log_slave_updates", "log_replica_updates",
+	"binlog_format", "gtid_mode", "gtid_executed", "max_allowed_packet",
+}
+
+// Info returns global variables important for replication,
+// variables which are not supported by the server are empty
+func (repo *Repository) Info(ctx context.Context) (*Info, error) {
+	vars, err := repo.Variables(ctx, infoVariables...)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{
+		ServerUUID:      vars["server_uuid"],
+		Hostname:        vars["hostname"],
+		ReportHost:      vars["report_host"],
+		ReadOnly:        isOn(vars["read_only"]),
+		SuperReadOnly:   isOn(vars["super_read_only"]),
+		LogBin:          isOn(vars["log_bin"]),
+		LogSlaveUpdates: isOn(vars["log_slave_updates"]) || isOn(vars["log_replica_updates"]),
+		BinlogFormat:    vars["binlog_format"],
+		GTIDMode:        vars["gtid_mode"],
+		GTIDExecuted:    vars["gtid_executed"],
+	}
+
+	info.ServerID, _ = strconv.ParseInt(vars["server_id"], 10, 64)
+	info.Port, _ = strconv.Atoi(vars["port"])
+	info.ReportPort, _ = strconv.Atoi(vars["report_port"])
+	info.MaxAllowedPacket, _ = strconv.ParseInt(vars["max_allowed_packet"], 10, 64)
+
+	info.Version, err = mysql.ParseVersion(vars["version"])
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+type variable struct {
+	Name  string `db:"Variable_name"`
+	Value string `db:"Value"`
+}
+
+// Variables returns values of global variables by names
+func (repo *Repository) Variables(ctx context.Context, names ...string) (map[string]string, error) {
+	q, args, err := sqlx.In(`SHOW GLOBAL VARIABLES WHERE Variable_name IN (?)`, names)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build query")
+	}
+
+	var rows []variable
+
+	err = repo.db.SelectContext(ctx, &rows, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get global variables")
+	}
+
+	vars := make(map[string]string, len(rows))
+
+	for _, row := range rows {
+		vars[strings.ToLower(row.Name)] = row.Value
+	}
+
+	return vars, nil
+}
+
+// SetReadOnly sets read_only and super_read_only,
+// super_read_only is changed only if the server supports it
+func (repo *Repository) SetReadOnly(ctx context.Context, readOnly, superReadOnly bool) error {
+	vars, err := repo.Variables(ctx, "super_read_only")
+	if err != nil {
+		return err
+	}
+
+	_, supported := vars["super_read_only"]
+
+	// super_read_only=ON implies read_only=ON,
+	// read_only=OFF implies super_read_only=OFF
+	if supported && !superReadOnly {
+		err = repo.setGlobal(ctx, "super_read_only", 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = repo.setGlobal(ctx, "read_only", boolInt(readOnly))
+	if err != nil {
+		return err
+	}
+
+	if supported && superReadOnly {
+		return repo.setGlobal(ctx, "super_read_only", 1)
+	}
+
+	return nil
+}
+
+// GTIDExecuted returns @@GLOBAL.gtid_executed
+func (repo *Repository) GTIDExecuted(ctx context.Context) (string, error) {
+	var executed string
+
+	err := repo.db.GetContext(ctx, &executed, `SELECT @@GLOBAL.gtid_executed`)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get gtid_executed")
+	}
+
+	return executed, nil
+}
+
+// SetGTIDPurged executes RESET MASTER and sets gtid_purged, so the server
+// with loaded dump skips transactions of set when it replicates from master.
+// Binary logs and gtid_executed of the server are discarded.
+func (repo *Repository) SetGTIDPurged(ctx context.Context, set string) error {
+	_, err := repo.db.ExecContext(ctx, `RESET MASTER`)
+	if err != nil {
+		return errors.Wrap(err, "unable to reset master")
+	}
+
+	_, err = repo.db.ExecContext(ctx, `SET GLOBAL gtid_purged = ?`, set)
+	if err != nil {
+		return errors.Wrap(err, "unable to set gtid_purged")
+	}
+
+	return nil
+}
+
+// InjectEmpty commits empty transaction for every GTID of set,
+// so the server skips these transactions when they are replicated
+func (repo *Repository) InjectEmpty(ctx context.Context, set gtid.Set) error {
+	conn, err := repo.db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get connection")
+	}
+
+	defer conn.Close()
+
+	err = set.Each(func(uuid string, gno int64) error {
+		next := fmt.Sprintf("%s:%d", uuid, gno)
+
+		_, err := conn.ExecContext(ctx, `SET GTID_NEXT = ?`, next)
+		if err != nil {
+			return errors.Wrapf(err, "unable to set GTID_NEXT = %s", next)
+		}
+
+		_, err = conn.ExecContext(ctx, `BEGIN`)
+		if err == nil {
+			_, err = conn.ExecContext(ctx, `COMMIT`)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "unable to commit empty transaction %s", next)
+		}
+
+		return nil
+	})
+
+	_, resetErr := conn.ExecContext(context.Background(), `SET GTID_NEXT = 'AUTOMATIC'`)
+	if err == nil && resetErr != nil {
+		err = errors.Wrap(resetErr, "unable to reset GTID_NEXT")
+	}
+
+	return err
+}
+
+// setGlobal sets global variable, name must not be user input
+func (repo *Repository) setGlobal(ctx context.Context, name string, value interface{}) error {
+	_, err := repo.db.ExecContext(ctx, `SET GLOBAL `+name+` = ?`, value)
+	if err != nil {
+		return errors.Wrapf(err, "unable to set %s", name)
+	}
+
+	return nil
+}
+
+func isOn(value string) bool {
+	switch strings.ToUpper(value) {
+	case "ON", "1", "YES", "TRUE":
+		return true
+	}
+
+	return false
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
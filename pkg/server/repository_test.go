@@ -0,0 +1,92 @@
+package server_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/server"
+	"github.com/partyzanex/testutils"
+)
+
+func TestRepository_Info(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := server.New(db)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES WHERE Variable_name IN`).WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("server_id", "12").
+			AddRow("version", "8.0.28").
+			AddRow("read_only", "ON").
+			AddRow("log_bin", "ON").
+			AddRow("log_replica_updates", "ON").
+			AddRow("gtid_mode", "ON").
+			AddRow("port", "3307"),
+	)
+
+	info, err := repo.Info(context.Background())
+	testutils.FatalErr(t, "repo.Info", err)
+
+	testutils.AssertEqual(t, "ServerID", int64(12), info.ServerID)
+	testutils.AssertEqual(t, "Port", 3307, info.Port)
+	testutils.AssertEqual(t, "ReadOnly", true, info.ReadOnly)
+	testutils.AssertEqual(t, "SuperReadOnly", false, info.SuperReadOnly)
+	testutils.AssertEqual(t, "LogSlaveUpdates", true, info.LogSlaveUpdates)
+	testutils.AssertEqual(t, "GTIDEnabled", true, info.GTIDEnabled())
+	testutils.AssertEqual(t, "Version", "8.0.28", info.Version.String())
+}
+
+func TestRepository_SetReadOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := server.New(db)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES WHERE Variable_name IN`).WithArgs("super_read_only").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("super_read_only", "ON"))
+	mock.ExpectExec(`SET GLOBAL super_read_only = \?`).WithArgs(0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET GLOBAL read_only = \?`).WithArgs(0).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.SetReadOnly(context.Background(), false, false)
+	testutils.FatalErr(t, "repo.SetReadOnly", err)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_InjectEmpty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := server.New(db)
+	uuid := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+	for _, gno := range []string{"4", "5"} {
+		mock.ExpectExec(`SET GTID_NEXT = \?`).WithArgs(uuid + ":" + gno).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`BEGIN`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`COMMIT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	mock.ExpectExec(`SET GTID_NEXT = 'AUTOMATIC'`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.InjectEmpty(context.Background(), gtid.MustParse(uuid+":4-5"))
+	testutils.FatalErr(t, "repo.InjectEmpty", err)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_SetGTIDPurged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := server.New(db)
+	set := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100"
+
+	mock.ExpectExec(`RESET MASTER`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET GLOBAL gtid_purged = \?`).WithArgs(set).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	testutils.FatalErr(t, "repo.SetGTIDPurged", repo.SetGTIDPurged(context.Background(), set))
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
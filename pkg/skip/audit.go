@@ -0,0 +1,73 @@
+package skip
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Entry represents record of audit log
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Operator string    `json:"operator"`
+	Replica  string    `json:"replica"`
+	Errno    int       `json:"errno"`
+	Message  string    `json:"message"`
+	Class    string    `json:"class"`
+	File     string    `json:"file"`
+	Position int       `json:"position"`
+	GTID     string    `json:"gtid,omitempty"`
+	// Action is "skip-counter" or "inject-empty"
+	Action string `json:"action"`
+	// Result is "refused", "confirmed", "skipped" or error
+	Result string `json:"result"`
+}
+
+// NewEntry returns entry of failure on replica
+func NewEntry(replica string, f *Failure) Entry {
+	action := "skip-counter"
+	if f.UseGTID {
+		action = "inject-empty"
+	}
+
+	operator := os.Getenv("SUDO_USER")
+	if operator == "" {
+		operator = os.Getenv("USER")
+	}
+
+	return Entry{
+		Time:     time.Now().UTC(),
+		Operator: operator,
+		Replica:  replica,
+		Errno:    f.Errno,
+		Message:  f.Message,
+		Class:    f.Class,
+		File:     f.File,
+		Position: f.Position,
+		GTID:     f.GTID,
+		Action:   action,
+	}
+}
+
+// AppendAudit appends entry as JSON line to audit log at path
+func AppendAudit(path string, e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode audit entry")
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "unable to open audit log")
+	}
+
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "unable to write audit log")
+	}
+
+	return errors.Wrap(f.Close(), "unable to write audit log")
+}
@@ -0,0 +1,187 @@
+// Package skip remedies replication errors by skipping the failed transaction
+package skip
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/gtid"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/pkg/errors"
+)
+
+// classes of replication errors
+const (
+	ClassTransient    = "transient"
+	ClassRelayLog     = "relay-log"
+	ClassMissingRow   = "missing-row"
+	ClassDuplicateKey = "duplicate-key"
+	ClassSchema       = "schema"
+	ClassOther        = "other"
+)
+
+// DefaultUnsafe are classes of errors which are not remedied by skipping:
+// transient errors are retried by restart of SQL thread,
+// corrupted relay log is fetched again from master
+var DefaultUnsafe = []string{ClassTransient, ClassRelayLog}
+
+var classes = map[int]string{
+	1205: ClassTransient,    // ER_LOCK_WAIT_TIMEOUT
+	1213: ClassTransient,    // ER_LOCK_DEADLOCK
+	1594: ClassRelayLog,     // ER_SLAVE_RELAY_LOG_READ_FAILURE
+	1595: ClassRelayLog,     // ER_SLAVE_RELAY_LOG_WRITE_FAILURE
+	1032: ClassMissingRow,   // ER_KEY_NOT_FOUND
+	1062: ClassDuplicateKey, // ER_DUP_ENTRY
+	1586: ClassDuplicateKey, // ER_DUP_ENTRY_WITH_KEY_NAME
+	1049: ClassSchema,       // ER_BAD_DB_ERROR
+	1051: ClassSchema,       // ER_BAD_TABLE_ERROR
+	1054: ClassSchema,       // ER_BAD_FIELD_ERROR
+	1146: ClassSchema,       // ER_NO_SUCH_TABLE
+	1050: ClassSchema,       // ER_TABLE_EXISTS_ERROR
+}
+
+// Classify returns class of replication error number
+func Classify(errno int) string {
+	if class, ok := classes[errno]; ok {
+		return class
+	}
+
+	return ClassOther
+}
+
+// Policy refuses to skip errors of unsafe classes or numbers
+type Policy struct {
+	// Unsafe contains names of classes and error numbers
+	Unsafe []string
+}
+
+// Check returns error if errno must not be skipped
+func (p Policy) Check(errno int) error {
+	class := Classify(errno)
+
+	for _, unsafe := range p.Unsafe {
+		unsafe = strings.TrimSpace(unsafe)
+
+		if unsafe == class || unsafe == strconv.Itoa(errno) {
+			return errors.Errorf("error %d of class %s is configured as unsafe to skip", errno, class)
+		}
+	}
+
+	return nil
+}
+
+// Failure represents stopped SQL thread of replica
+type Failure struct {
+	Errno   int
+	Message string
+	Class   string
+	// File and Position are coordinates of the failed event in binlog of master
+	File     string
+	Position int
+	// GTID of failed transaction, empty for file-based replication
+	GTID    string
+	UseGTID bool
+}
+
+// String returns description of failure
+func (f *Failure) String() string {
+	s := fmt.Sprintf("[%d] (%s) %s\nat %s:%d", f.Errno, f.Class, f.Message, f.File, f.Position)
+
+	if f.GTID != "" {
+		s += " transaction " + f.GTID
+	}
+
+	return s
+}
+
+// Inspect returns failure of SQL thread of replica or nil if it has no error
+func Inspect(ctx context.Context, replica *node.Node) (*Failure, error) {
+	status, err := replica.Slave().ShowStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.LastSQLErrno == 0 {
+		return nil, nil
+	}
+
+	info, err := replica.Server().Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Failure{
+		Errno:    status.LastSQLErrno,
+		Message:  status.LastSQLError,
+		Class:    Classify(status.LastSQLErrno),
+		File:     status.RelayMasterLogFile,
+		Position: status.ExecMasterLogPos,
+		UseGTID:  info.GTIDEnabled(),
+	}
+
+	workers, err := replica.Slave().WorkerStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the coordinator reports error of worker, the worker knows the transaction
+	for _, w := range workers {
+		if w.LastErrorNumber == 0 {
+			continue
+		}
+
+		f.Errno, f.Message, f.Class = w.LastErrorNumber, w.LastErrorMessage, Classify(w.LastErrorNumber)
+		f.GTID = w.Transaction()
+
+		break
+	}
+
+	if f.UseGTID && f.GTID == "" {
+		return nil, errors.Errorf("unable to find GTID of failed transaction on %s", replica)
+	}
+
+	return f, nil
+}
+
+// Skip skips the failed transaction and starts replication: empty transaction
+// is committed with GTID of failed one for GTID replication,
+// otherwise sql_slave_skip_counter is used
+func Skip(ctx context.Context, replica *node.Node, f *Failure) error {
+	// the failure is inspected again, replica could be restarted after confirmation
+	current, err := Inspect(ctx, replica)
+	if err != nil {
+		return err
+	}
+
+	if current == nil || current.File != f.File || current.Position != f.Position || current.GTID != f.GTID {
+		return errors.Errorf("failure of %s has changed, inspect it again", replica)
+	}
+
+	repo := replica.Slave()
+
+	err = repo.Stop(ctx)
+	if err != nil {
+		return err
+	}
+
+	if f.UseGTID {
+		set, err := gtid.Parse(f.GTID)
+		if err != nil {
+			return err
+		}
+
+		err = replica.Server().InjectEmpty(ctx, set)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = repo.SetSkipCounter(ctx, 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return repo.Start(ctx)
+}
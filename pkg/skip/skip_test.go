@@ -0,0 +1,103 @@
+package skip_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/skip"
+	"github.com/partyzanex/testutils"
+)
+
+const testGTID = "3e11fa47-71ca-11e1-9e33-c80aa9429562:42"
+
+func TestPolicy_Check(t *testing.T) {
+	policy := skip.Policy{Unsafe: append([]string{"1062"}, skip.DefaultUnsafe...)}
+
+	data := map[int]bool{
+		1213: false, // deadlock
+		1594: false, // relay log
+		1062: false, // by number
+		1032: true,
+		1146: true,
+		9999: true,
+	}
+
+	for errno, allowed := range data {
+		testutils.AssertEqual(t, skip.Classify(errno), allowed, policy.Check(errno) == nil)
+	}
+}
+
+func expectFailure(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`show slave status`).WillReturnRows(sqlmock.NewRows(
+		[]string{"Last_SQL_Errno", "Last_SQL_Error", "Relay_Master_Log_File", "Exec_Master_Log_Pos"}).
+		AddRow(1032, "Coordinator stopped because there were error(s) in the worker(s)", "binlog.000003", 8812))
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES`).WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).
+		AddRow("version", "8.0.30").AddRow("gtid_mode", "ON"))
+	mock.ExpectQuery(`replication_applier_status_by_worker`).WillReturnRows(sqlmock.NewRows(
+		[]string{"WORKER_ID", "LAST_ERROR_NUMBER", "LAST_ERROR_MESSAGE", "APPLYING_TRANSACTION"}).
+		AddRow(1, 0, "", "").
+		AddRow(2, 1032, "Could not execute Update_rows event on table db.t; Can't find record in 't'", testGTID))
+}
+
+func TestSkip_GTID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	replica := &node.Node{Name: "replica", Host: "10.0.0.2", Port: 3306, DB: db}
+	ctx := context.Background()
+
+	expectFailure(mock)
+
+	failure, err := skip.Inspect(ctx, replica)
+	testutils.FatalErr(t, "Inspect", err)
+	testutils.AssertEqual(t, "errno", 1032, failure.Errno)
+	testutils.AssertEqual(t, "class", skip.ClassMissingRow, failure.Class)
+	testutils.AssertEqual(t, "gtid", testGTID, failure.GTID)
+	testutils.AssertEqual(t, "message", true, strings.Contains(failure.Message, "Update_rows"))
+
+	expectFailure(mock)
+	mock.ExpectExec(`STOP SLAVE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET GTID_NEXT = \?`).WithArgs(testGTID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`BEGIN`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`COMMIT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SET GTID_NEXT = 'AUTOMATIC'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`START SLAVE`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	testutils.FatalErr(t, "Skip", skip.Skip(ctx, replica, failure))
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestAppendAudit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	testutils.FatalErr(t, "TempDir", err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	failure := &skip.Failure{Errno: 1062, Class: skip.ClassDuplicateKey, File: "binlog.000003", Position: 120}
+
+	for _, result := range []string{"confirmed", "skipped"} {
+		entry := skip.NewEntry("replica", failure)
+		entry.Result = result
+
+		testutils.FatalErr(t, "AppendAudit", skip.AppendAudit(path, entry))
+	}
+
+	data, err := ioutil.ReadFile(path)
+	testutils.FatalErr(t, "ReadFile", err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	testutils.AssertEqual(t, "entries", 2, len(lines))
+
+	entry := skip.Entry{}
+	testutils.FatalErr(t, "Unmarshal", json.Unmarshal([]byte(lines[1]), &entry))
+	testutils.AssertEqual(t, "action", "skip-counter", entry.Action)
+	testutils.AssertEqual(t, "result", "skipped", entry.Result)
+}
@@ -0,0 +1,77 @@
+package slave
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WorkerStatus represents row of performance_schema.replication_applier_status_by_worker
+type WorkerStatus struct {
+	ChannelName        string         `db:"CHANNEL_NAME"`
+	WorkerID           int64          `db:"WORKER_ID"`
+	LastErrorNumber    int            `db:"LAST_ERROR_NUMBER"`
+	LastErrorMessage   string         `db:"LAST_ERROR_MESSAGE"`
+	LastErrorTimestamp sql.NullString `db:"LAST_ERROR_TIMESTAMP"`
+	// ApplyingTransaction is GTID of transaction applied by worker (MySQL 8.0)
+	ApplyingTransaction sql.NullString `db:"APPLYING_TRANSACTION"`
+	// LastSeenTransaction is GTID of the last transaction seen by worker (MySQL 5.7)
+	LastSeenTransaction sql.NullString `db:"LAST_SEEN_TRANSACTION"`
+}
+
+// Transaction returns GTID of transaction applied by worker, empty for anonymous transactions
+func (w WorkerStatus) Transaction() string {
+	for _, gtid := range []sql.NullString{w.ApplyingTransaction, w.LastSeenTransaction} {
+		if gtid.String != "" && gtid.String != "ANONYMOUS" {
+			return gtid.String
+		}
+	}
+
+	return ""
+}
+
+// WorkerStatuses returns status of applier workers, one row is returned for single-threaded replica
+func (repo *Repository) WorkerStatuses(ctx context.Context) (statuses []WorkerStatus, err error) {
+	// columns differ between versions
+	err = repo.db.Unsafe().SelectContext(ctx, &statuses,
+		`SELECT * FROM performance_schema.replication_applier_status_by_worker`)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get applier status by worker")
+	}
+
+	return
+}
+
+// AppliedCommitTime returns original commit time on master of the last applied transaction (MySQL 8.0.2+),
+// zero time is returned if replica has not applied transactions yet
+func (repo *Repository) AppliedCommitTime(ctx context.Context) (time.Time, error) {
+	var ts sql.NullFloat64
+
+	err := repo.db.GetContext(ctx, &ts, `SELECT UNIX_TIMESTAMP(MAX(LAST_APPLIED_TRANSACTION_ORIGINAL_COMMIT_TIMESTAMP))
+FROM performance_schema.replication_applier_status_by_worker`)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "unable to get commit time of applied transaction")
+	}
+
+	// 0 is stored before the first transaction
+	if ts.Float64 <= 0 {
+		return time.Time{}, nil
+	}
+
+	sec := int64(ts.Float64)
+
+	return time.Unix(sec, int64((ts.Float64-float64(sec))*1e9)).UTC(), nil
+}
+
+// SetSkipCounter sets sql_slave_skip_counter, replica skips n events groups
+// when SQL thread is started. It is not allowed with gtid_mode=ON.
+func (repo *Repository) SetSkipCounter(ctx context.Context, n int) error {
+	_, err := repo.db.ExecContext(ctx, `SET GLOBAL sql_slave_skip_counter = ?`, n)
+	if err != nil {
+		return errors.Wrap(err, "unable to set sql_slave_skip_counter")
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package slave
+
+import (
+	"context"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+)
+
+// Rewrite represents rule of replicate-rewrite-db
+type Rewrite struct {
+	From, To string
+}
+
+// Filters represents replication filters of replica
+type Filters struct {
+	DoDB            []string
+	IgnoreDB        []string
+	DoTable         []string
+	IgnoreTable     []string
+	WildDoTable     []string
+	WildIgnoreTable []string
+	RewriteDB       []Rewrite
+}
+
+// IsEmpty returns true if replica replicates everything
+func (f Filters) IsEmpty() bool {
+	return len(f.DoDB)+len(f.IgnoreDB)+len(f.DoTable)+len(f.IgnoreTable)+
+		len(f.WildDoTable)+len(f.WildIgnoreTable)+len(f.RewriteDB) == 0
+}
+
+// Validate checks that table filters are qualified by database
+func (f Filters) Validate() error {
+	for _, table := range append(append([]string{}, f.DoTable...), f.IgnoreTable...) {
+		if i := strings.Index(table, "."); i <= 0 || i == len(table)-1 {
+			return errors.Errorf("table filter %q is not in 'db.table' format", table)
+		}
+	}
+
+	for _, r := range f.RewriteDB {
+		if r.From == "" || r.To == "" {
+			return errors.Errorf("invalid rewrite-db rule (%s,%s)", r.From, r.To)
+		}
+	}
+
+	return nil
+}
+
+// Filters returns parsed filters of replica
+func (s Status) Filters() Filters {
+	return Filters{
+		DoDB:            splitList(s.ReplicateDoDB),
+		IgnoreDB:        splitList(s.ReplicateIgnoreDB),
+		DoTable:         splitList(s.ReplicateDoTable),
+		IgnoreTable:     splitList(s.ReplicateIgnoreTable),
+		WildDoTable:     splitList(s.ReplicateWildDoTable),
+		WildIgnoreTable: splitList(s.ReplicateWildIgnoreTable),
+		RewriteDB:       parseRewrites(s.ReplicateRewriteDB),
+	}
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, ",")
+}
+
+// parseRewrites parses value like '(db1,db2),(db3,db4)'
+func parseRewrites(s string) []Rewrite {
+	var rewrites []Rewrite
+
+	for _, pair := range strings.Split(s, "),") {
+		pair = strings.Trim(pair, "()")
+
+		i := strings.Index(pair, ",")
+		if i < 0 {
+			continue
+		}
+
+		rewrites = append(rewrites, Rewrite{From: pair[:i], To: pair[i+1:]})
+	}
+
+	return rewrites
+}
+
+// Warnings returns combinations of filters which do not work as expected
+// with binlogFormat of master, empty format is treated as STATEMENT
+func (f Filters) Warnings(binlogFormat string) []string {
+	var warnings []string
+
+	statement := !strings.EqualFold(binlogFormat, "ROW")
+
+	if len(f.DoDB)+len(f.IgnoreDB) > 0 {
+		if statement {
+			warnings = append(warnings, "database filters are checked against the default database (USE) "+
+				"for statements, so statements on other databases are replicated or ignored by mistake, "+
+				"prefer wild table filters like 'db.%'")
+		} else {
+			warnings = append(warnings, "DDL is logged as statement in any format, "+
+				"database filters are checked against the default database (USE) for it")
+		}
+	}
+
+	if len(f.DoDB) > 0 && len(f.DoTable)+len(f.WildDoTable) > 0 {
+		warnings = append(warnings, "database filters are checked before table filters, "+
+			"tables of databases outside of do-db are ignored")
+	}
+
+	if len(f.RewriteDB) > 0 && statement {
+		warnings = append(warnings, "rewrite-db changes only the default database of statements, "+
+			"statements with qualified table names are applied to the original database")
+	}
+
+	if len(f.RewriteDB) > 0 && len(f.DoDB)+len(f.IgnoreDB)+len(f.DoTable)+len(f.IgnoreTable) > 0 {
+		warnings = append(warnings, "filters are checked after rewrite-db, use the rewritten database names")
+	}
+
+	return warnings
+}
+
+// ChangeFilters replaces all replication filters by f with CHANGE REPLICATION FILTER,
+// filters of channel are changed if it is not empty (MySQL 8.0),
+// SQL thread is stopped during the change and started again if it was running
+func (repo *Repository) ChangeFilters(ctx context.Context, f Filters, channel string) error {
+	err := f.Validate()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := repo.ShowStatuses(ctx)
+	if err != nil {
+		return err
+	}
+
+	running := false
+
+	for _, status := range statuses {
+		if status.ChannelName == channel && status.SQLRunning() {
+			running = true
+		}
+	}
+
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	q := `CHANGE REPLICATION FILTER ` + filterClauses(quoter, f)
+
+	forChannel := ""
+	if channel != "" {
+		forChannel = ` FOR CHANNEL ` + quoter.String(channel)
+	}
+
+	if running {
+		_, err = conn.ExecContext(ctx, `STOP SLAVE SQL_THREAD`+forChannel)
+		if err != nil {
+			return errors.Wrap(err, "unable to stop slave SQL thread")
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, q+forChannel)
+	if err != nil {
+		err = errors.Wrap(err, "unable to change replication filter")
+	}
+
+	if running {
+		_, errStart := conn.ExecContext(ctx, `START SLAVE SQL_THREAD`+forChannel)
+		if errStart != nil && err == nil {
+			err = errors.Wrap(errStart, "unable to start slave SQL thread")
+		}
+	}
+
+	return err
+}
+
+// filterClauses returns rules of CHANGE REPLICATION FILTER, tables of f are validated
+func filterClauses(q quote.Quoter, f Filters) string {
+	tables := func(names []string) string {
+		quoted := make([]string, len(names))
+
+		for i, name := range names {
+			j := strings.Index(name, ".")
+			quoted[i] = quote.Qualified(name[:j], name[j+1:])
+		}
+
+		return strings.Join(quoted, ", ")
+	}
+
+	patterns := func(values []string) string {
+		quoted := make([]string, len(values))
+
+		for i, value := range values {
+			quoted[i] = q.String(value)
+		}
+
+		return strings.Join(quoted, ", ")
+	}
+
+	rewrites := make([]string, len(f.RewriteDB))
+
+	for i, r := range f.RewriteDB {
+		rewrites[i] = "(" + quote.Ident(r.From) + ", " + quote.Ident(r.To) + ")"
+	}
+
+	return strings.Join([]string{
+		`REPLICATE_DO_DB = (` + quote.Idents(f.DoDB...) + `)`,
+		`REPLICATE_IGNORE_DB = (` + quote.Idents(f.IgnoreDB...) + `)`,
+		`REPLICATE_DO_TABLE = (` + tables(f.DoTable) + `)`,
+		`REPLICATE_IGNORE_TABLE = (` + tables(f.IgnoreTable) + `)`,
+		`REPLICATE_WILD_DO_TABLE = (` + patterns(f.WildDoTable) + `)`,
+		`REPLICATE_WILD_IGNORE_TABLE = (` + patterns(f.WildIgnoreTable) + `)`,
+		`REPLICATE_REWRITE_DB = (` + strings.Join(rewrites, ", ") + `)`,
+	}, ", ")
+}
@@ -0,0 +1,65 @@
+package slave_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/partyzanex/testutils"
+)
+
+func TestStatus_Filters(t *testing.T) {
+	status := slave.Status{
+		ReplicateDoDB:        "tenant1,tenant2",
+		ReplicateIgnoreTable: "tenant1.audit",
+		ReplicateWildDoTable: "reports%.%",
+		ReplicateRewriteDB:   "(tenant1,reports1),(tenant2,reports2)",
+	}
+
+	f := status.Filters()
+	testutils.AssertEqual(t, "do-db", "[tenant1 tenant2]", fmt.Sprint(f.DoDB))
+	testutils.AssertEqual(t, "ignore-db", 0, len(f.IgnoreDB))
+	testutils.AssertEqual(t, "ignore-table", "[tenant1.audit]", fmt.Sprint(f.IgnoreTable))
+	testutils.AssertEqual(t, "wild-do-table", "[reports%.%]", fmt.Sprint(f.WildDoTable))
+	testutils.AssertEqual(t, "rewrite-db", "[{tenant1 reports1} {tenant2 reports2}]", fmt.Sprint(f.RewriteDB))
+	testutils.AssertEqual(t, "empty", true, slave.Status{}.Filters().IsEmpty())
+
+	testutils.AssertEqual(t, "statement warnings", 4, len(f.Warnings("STATEMENT")))
+	testutils.AssertEqual(t, "row warnings", 3, len(f.Warnings("ROW")))
+	testutils.AssertEqual(t, "wild only", 0, len(slave.Filters{WildDoTable: []string{"db.%"}}.Warnings("MIXED")))
+}
+
+func TestRepository_ChangeFilters(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := slave.New(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`show slave status`).WillReturnRows(
+		sqlmock.NewRows([]string{"Slave_SQL_Running", "Channel_Name"}).AddRow("No", "").AddRow("Yes", "tenants"))
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`STOP SLAVE SQL_THREAD FOR CHANNEL 'tenants'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CHANGE REPLICATION FILTER REPLICATE_DO_DB = (`tenant1`), REPLICATE_IGNORE_DB = (), " +
+		"REPLICATE_DO_TABLE = (), REPLICATE_IGNORE_TABLE = (`tenant1`.`audit`), " +
+		"REPLICATE_WILD_DO_TABLE = ('reports%.%'), REPLICATE_WILD_IGNORE_TABLE = (), " +
+		"REPLICATE_REWRITE_DB = ((`tenant1`, `reports1`)) FOR CHANNEL 'tenants'").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`START SLAVE SQL_THREAD FOR CHANNEL 'tenants'`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.ChangeFilters(ctx, slave.Filters{
+		DoDB:        []string{"tenant1"},
+		IgnoreTable: []string{"tenant1.audit"},
+		WildDoTable: []string{"reports%.%"},
+		RewriteDB:   []slave.Rewrite{{From: "tenant1", To: "reports1"}},
+	}, "tenants")
+	testutils.FatalErr(t, "repo.ChangeFilters", err)
+
+	err = repo.ChangeFilters(ctx, slave.Filters{DoTable: []string{"audit"}}, "")
+	testutils.AssertEqual(t, "unqualified table", true, err != nil)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
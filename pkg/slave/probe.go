@@ -0,0 +1,89 @@
+package slave
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+)
+
+// ProbeChannel is name of temporary channel created by ProbeConnection
+const ProbeChannel = "repmy_probe"
+
+// probeInterval is interval of polling status of probe channel
+var probeInterval = 200 * time.Millisecond
+
+// ConnectionError represents error of IO thread connecting to master
+type ConnectionError struct {
+	Errno   int
+	Message string
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("[%d] %s", e.Errno, e.Message)
+}
+
+// ProbeConnection checks that replica connects to master as user: IO thread of temporary
+// channel is started at coordinates of status, so only new events are received.
+// *ConnectionError is returned if IO thread fails to connect, other errors mean
+// that the channel can not be created, for example multi-source replication is not supported.
+// The channel is removed before return.
+func (repo *Repository) ProbeConnection(ctx context.Context, status master.Status, user mysql.ReplUser,
+	timeout time.Duration) error {
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	channel := ` FOR CHANNEL ` + quoter.String(ProbeChannel)
+
+	_, err = conn.ExecContext(ctx, changeMasterQuery(quoter, status, user)+channel)
+	if err != nil {
+		return errors.Wrap(err, "unable to create probe channel")
+	}
+
+	defer func() {
+		// the channel is removed even if ctx is canceled
+		_, _ = conn.ExecContext(context.Background(), `STOP SLAVE`+channel)
+		_, _ = conn.ExecContext(context.Background(), `RESET SLAVE ALL`+channel)
+	}()
+
+	_, err = conn.ExecContext(ctx, `START SLAVE IO_THREAD`+channel)
+	if err != nil {
+		return errors.Wrap(err, "unable to start IO thread of probe channel")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	for {
+		status := &Status{}
+
+		err = repo.db.Unsafe().GetContext(ctx, status, `SHOW SLAVE STATUS`+channel)
+		if err != nil {
+			return errors.Wrap(err, "unable to get status of probe channel")
+		}
+
+		switch {
+		case status.LastIOErrno != 0:
+			return &ConnectionError{Errno: status.LastIOErrno, Message: status.LastIOError}
+		case status.IORunning():
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return &ConnectionError{Message: fmt.Sprintf("IO thread is %q after %s", status.SlaveIORunning, timeout)}
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package slave_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/partyzanex/testutils"
+)
+
+func TestRepository_ProbeConnection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := slave.New(db)
+	ctx := context.Background()
+	user := mysql.ReplUser{Name: "repl", Password: "secret", MasterHost: "10.0.0.1", MasterPort: 3306}
+	status := master.Status{File: "binlog.000003", Position: 154}
+	columns := []string{"Slave_IO_Running", "Last_IO_Errno", "Last_IO_Error"}
+
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`MASTER_LOG_POS=154 FOR CHANNEL 'repmy_probe'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`START SLAVE IO_THREAD FOR CHANNEL 'repmy_probe'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SHOW SLAVE STATUS FOR CHANNEL 'repmy_probe'`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("Connecting", 1045, "Access denied for user 'repl'"))
+	mock.ExpectExec(`STOP SLAVE FOR CHANNEL 'repmy_probe'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RESET SLAVE ALL FOR CHANNEL 'repmy_probe'`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.ProbeConnection(ctx, status, user, time.Second)
+
+	var connErr *slave.ConnectionError
+
+	testutils.AssertEqual(t, "connection error", true, errors.As(err, &connErr))
+	testutils.AssertEqual(t, "errno", 1045, connErr.Errno)
+
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`FOR CHANNEL 'repmy_probe'`).
+		WillReturnError(errors.New("Slave channel 'repmy_probe' does not exist"))
+
+	err = repo.ProbeConnection(ctx, status, user, time.Second)
+	testutils.AssertEqual(t, "not a connection error", false, errors.As(err, &connErr))
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
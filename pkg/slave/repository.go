@@ -4,10 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/partyzanex/repmy/pkg/master"
 	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/quote"
 	"github.com/pkg/errors"
 )
 
@@ -27,7 +29,8 @@ func New(db *sql.DB) *Repository {
 func (repo *Repository) ShowStatus(ctx context.Context) (status *Status, err error) {
 	status = &Status{}
 
-	err = repo.db.GetContext(ctx, status, `show slave status`)
+	// newer servers return columns which are not mapped to Status
+	err = repo.db.Unsafe().GetContext(ctx, status, `show slave status`)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to get slave status")
 	}
@@ -35,20 +38,81 @@ func (repo *Repository) ShowStatus(ctx context.Context) (status *Status, err err
 	return
 }
 
-// ChangeMaster executes CHANGE MASTER TO query
+// ShowStatuses returns Status of every replication channel,
+// returns empty slice if replication is not configured
+func (repo *Repository) ShowStatuses(ctx context.Context) (statuses []Status, err error) {
+	err = repo.db.Unsafe().SelectContext(ctx, &statuses, `show slave status`)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get slave status")
+	}
+
+	return
+}
+
+// ChangeMaster executes CHANGE MASTER TO query with binlog coordinates of status
 func (repo *Repository) ChangeMaster(ctx context.Context, status master.Status, user mysql.ReplUser) error {
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, changeMasterQuery(quoter, status, user))
+	if err != nil {
+		return errors.Wrap(err, "unable to change master")
+	}
+
+	return nil
+}
+
+// changeMasterQuery returns CHANGE MASTER TO query with binlog coordinates of status
+func changeMasterQuery(quoter quote.Quoter, status master.Status, user mysql.ReplUser) string {
 	q := `
 CHANGE MASTER TO 
-	MASTER_HOST='%s', 
-	MASTER_USER='%s', 
-	MASTER_PASSWORD='%s', 
-	MASTER_LOG_FILE='%s', 
-	MASTER_LOG_POS=%d;
+	MASTER_HOST=%s, %s
+	MASTER_USER=%s, 
+	MASTER_PASSWORD=%s, %s
+	MASTER_LOG_FILE=%s, 
+	MASTER_LOG_POS=%d`
+
+	return fmt.Sprintf(q,
+		quoter.String(user.MasterHost),
+		masterPort(user),
+		quoter.String(user.Name),
+		quoter.String(user.Password),
+		masterAuth(user),
+		quoter.String(status.File),
+		status.Position,
+	)
+}
+
+// ChangeMasterAutoPosition executes CHANGE MASTER TO query with MASTER_AUTO_POSITION=1
+func (repo *Repository) ChangeMasterAutoPosition(ctx context.Context, user mysql.ReplUser) error {
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	q := `
+CHANGE MASTER TO 
+	MASTER_HOST=%s, %s
+	MASTER_USER=%s, 
+	MASTER_PASSWORD=%s, %s
+	MASTER_AUTO_POSITION=1;
 `
 
-	q = fmt.Sprintf(q, user.MasterHost, user.Name, user.Password, status.File, status.Position)
+	q = fmt.Sprintf(q,
+		quoter.String(user.MasterHost),
+		masterPort(user),
+		quoter.String(user.Name),
+		quoter.String(user.Password),
+		masterAuth(user),
+	)
 
-	_, err := repo.db.ExecContext(ctx, q)
+	_, err = conn.ExecContext(ctx, q)
 	if err != nil {
 		return errors.Wrap(err, "unable to change master")
 	}
@@ -56,6 +120,61 @@ CHANGE MASTER TO
 	return nil
 }
 
+// SetDelay sets MASTER_DELAY of replica, SQL thread is stopped during the change
+// and started again if it was running
+func (repo *Repository) SetDelay(ctx context.Context, delay time.Duration) error {
+	status, err := repo.ShowStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	if status.SQLRunning() {
+		err = repo.StopSQLThread(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = repo.db.ExecContext(ctx, fmt.Sprintf(`CHANGE MASTER TO MASTER_DELAY = %d`, int64(delay/time.Second)))
+	if err != nil {
+		err = errors.Wrap(err, "unable to change master delay")
+	}
+
+	if status.SQLRunning() {
+		errStart := repo.StartSQLThread(ctx)
+		if err == nil {
+			err = errStart
+		}
+	}
+
+	return err
+}
+
+func masterPort(user mysql.ReplUser) string {
+	if user.MasterPort == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("\n\tMASTER_PORT=%d, ", user.MasterPort)
+}
+
+// masterAuth returns options required to connect with account created by
+// master.Repository.CreateReplUser: SSL for REQUIRE SSL and RSA public key
+// of master for caching_sha2_password over unencrypted connection
+func masterAuth(user mysql.ReplUser) string {
+	var options string
+
+	if user.RequireSSL {
+		options += "\n\tMASTER_SSL=1, "
+	}
+
+	if user.AuthPlugin == mysql.CachingSHA2Password {
+		options += "\n\tGET_MASTER_PUBLIC_KEY=1, "
+	}
+
+	return options
+}
+
 // Start executes START SLAVE
 func (repo *Repository) Start(ctx context.Context) error {
 	_, err := repo.db.ExecContext(ctx, `START SLAVE`)
@@ -76,6 +195,46 @@ func (repo *Repository) Stop(ctx context.Context) error {
 	return nil
 }
 
+// StartIOThread executes START SLAVE IO_THREAD
+func (repo *Repository) StartIOThread(ctx context.Context) error {
+	_, err := repo.db.ExecContext(ctx, `START SLAVE IO_THREAD`)
+	if err != nil {
+		return errors.Wrap(err, "unable to start slave IO thread")
+	}
+
+	return nil
+}
+
+// StopIOThread executes STOP SLAVE IO_THREAD
+func (repo *Repository) StopIOThread(ctx context.Context) error {
+	_, err := repo.db.ExecContext(ctx, `STOP SLAVE IO_THREAD`)
+	if err != nil {
+		return errors.Wrap(err, "unable to stop slave IO thread")
+	}
+
+	return nil
+}
+
+// StartSQLThread executes START SLAVE SQL_THREAD
+func (repo *Repository) StartSQLThread(ctx context.Context) error {
+	_, err := repo.db.ExecContext(ctx, `START SLAVE SQL_THREAD`)
+	if err != nil {
+		return errors.Wrap(err, "unable to start slave SQL thread")
+	}
+
+	return nil
+}
+
+// StopSQLThread executes STOP SLAVE SQL_THREAD
+func (repo *Repository) StopSQLThread(ctx context.Context) error {
+	_, err := repo.db.ExecContext(ctx, `STOP SLAVE SQL_THREAD`)
+	if err != nil {
+		return errors.Wrap(err, "unable to stop slave SQL thread")
+	}
+
+	return nil
+}
+
 // Reset executes RESET SLAVE
 func (repo *Repository) Reset(ctx context.Context) error {
 	_, err := repo.db.ExecContext(ctx, `RESET SLAVE`)
@@ -85,3 +244,13 @@ func (repo *Repository) Reset(ctx context.Context) error {
 
 	return nil
 }
+
+// ResetAll executes RESET SLAVE ALL, it removes replication configuration
+func (repo *Repository) ResetAll(ctx context.Context) error {
+	_, err := repo.db.ExecContext(ctx, `RESET SLAVE ALL`)
+	if err != nil {
+		return errors.Wrap(err, "unable to reset slave")
+	}
+
+	return nil
+}
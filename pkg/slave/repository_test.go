@@ -8,6 +8,7 @@ import (
 	_ "github.com/davecgh/go-spew/spew"
 	_ "github.com/partyzanex/repmy/pkg/mysql"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/partyzanex/repmy/pkg/master"
 	"github.com/partyzanex/repmy/pkg/mysql"
 	"github.com/partyzanex/repmy/pkg/slave"
@@ -67,3 +68,27 @@ func TestRepository_ShowStatus(t *testing.T) {
 	testutils.AssertEqual(t, "SlaveIORunning", slaveStatus.SlaveIORunning, "Yes")
 	testutils.AssertEqual(t, "SlaveSQLRunning", slaveStatus.SlaveSQLRunning, "Yes")
 }
+
+func TestRepository_ChangeMasterAutoPosition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := slave.New(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`MASTER_PASSWORD='secret',\s+MASTER_SSL=1,\s+GET_MASTER_PUBLIC_KEY=1,\s+MASTER_AUTO_POSITION=1`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.ChangeMasterAutoPosition(ctx, mysql.ReplUser{
+		Name:       "repl",
+		Password:   "secret",
+		MasterHost: "master",
+		AuthPlugin: mysql.CachingSHA2Password,
+		RequireSSL: true,
+	})
+	testutils.FatalErr(t, "repo.ChangeMasterAutoPosition", err)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
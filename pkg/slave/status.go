@@ -62,3 +62,13 @@ type Status struct {
 	ChannelName               string      `db:"Channel_Name"`
 	MasterTLSVersion          string      `db:"Master_TLS_Version"`
 }
+
+// IORunning returns true if replication IO thread is running
+func (s Status) IORunning() bool {
+	return s.SlaveIORunning == "Yes"
+}
+
+// SQLRunning returns true if replication SQL thread is running
+func (s Status) SQLRunning() bool {
+	return s.SlaveSQLRunning == "Yes"
+}
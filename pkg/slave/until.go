@@ -0,0 +1,130 @@
+package slave
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+)
+
+// ErrWaitTimeout is returned when replica has not reached the position in time
+var ErrWaitTimeout = errors.New("timeout of waiting for replica")
+
+// Until represents condition of START SLAVE UNTIL, exactly one condition must be set
+type Until struct {
+	// File and Position are coordinates in binlog of master (MASTER_LOG_FILE, MASTER_LOG_POS)
+	File     string
+	Position int
+	// BeforeGTIDs stops SQL thread before the first transaction of set (SQL_BEFORE_GTIDS)
+	BeforeGTIDs string
+	// AfterGTIDs stops SQL thread after all transactions of set (SQL_AFTER_GTIDS)
+	AfterGTIDs string
+	// AfterMTSGaps stops multi-threaded SQL thread when gaps of relay log are filled (SQL_AFTER_MTS_GAPS)
+	AfterMTSGaps bool
+}
+
+// Validate checks that exactly one condition is set
+func (u Until) Validate() error {
+	count := 0
+
+	for _, set := range []bool{u.File != "", u.BeforeGTIDs != "", u.AfterGTIDs != "", u.AfterMTSGaps} {
+		if set {
+			count++
+		}
+	}
+
+	if count != 1 {
+		return errors.New("exactly one UNTIL condition is required")
+	}
+
+	if u.File != "" && u.Position <= 0 {
+		return errors.New("UNTIL position is required with file")
+	}
+
+	return nil
+}
+
+func (u Until) clause(q quote.Quoter) string {
+	switch {
+	case u.File != "":
+		return fmt.Sprintf(`MASTER_LOG_FILE = %s, MASTER_LOG_POS = %d`, q.String(u.File), u.Position)
+	case u.BeforeGTIDs != "":
+		return `SQL_BEFORE_GTIDS = ` + q.String(u.BeforeGTIDs)
+	case u.AfterGTIDs != "":
+		return `SQL_AFTER_GTIDS = ` + q.String(u.AfterGTIDs)
+	}
+
+	return `SQL_AFTER_MTS_GAPS`
+}
+
+// StartUntil executes START SLAVE UNTIL, IO thread runs while SQL thread stops at the condition
+func (repo *Repository) StartUntil(ctx context.Context, until Until) error {
+	return repo.startUntil(ctx, `START SLAVE`, until)
+}
+
+// StartSQLThreadUntil executes START SLAVE SQL_THREAD UNTIL
+func (repo *Repository) StartSQLThreadUntil(ctx context.Context, until Until) error {
+	return repo.startUntil(ctx, `START SLAVE SQL_THREAD`, until)
+}
+
+func (repo *Repository) startUntil(ctx context.Context, start string, until Until) error {
+	err := until.Validate()
+	if err != nil {
+		return err
+	}
+
+	conn, quoter, err := quote.Conn(ctx, repo.db)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, start+` UNTIL `+until.clause(quoter))
+	if err != nil {
+		return errors.Wrap(err, "unable to start slave until condition")
+	}
+
+	return nil
+}
+
+// WaitForExecutedGTIDSet blocks until replica has executed all transactions of set,
+// ErrWaitTimeout is returned if timeout is exceeded, zero timeout waits without limit
+func (repo *Repository) WaitForExecutedGTIDSet(ctx context.Context, set string, timeout time.Duration) error {
+	var result sql.NullInt64
+
+	err := repo.db.GetContext(ctx, &result, `SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)`, set, timeout.Seconds())
+	if err != nil {
+		return errors.Wrap(err, "unable to wait for executed GTID set")
+	}
+
+	if result.Int64 == 1 {
+		return ErrWaitTimeout
+	}
+
+	return nil
+}
+
+// MasterPosWait blocks until SQL thread has executed binlog of master up to file:pos,
+// ErrWaitTimeout is returned if timeout is exceeded, zero timeout waits without limit
+func (repo *Repository) MasterPosWait(ctx context.Context, file string, pos int, timeout time.Duration) error {
+	var result sql.NullInt64
+
+	err := repo.db.GetContext(ctx, &result, `SELECT MASTER_POS_WAIT(?, ?, ?)`, file, pos, timeout.Seconds())
+	if err != nil {
+		return errors.Wrap(err, "unable to wait for master position")
+	}
+
+	if !result.Valid {
+		return errors.New("SQL thread of replica is not running or replication is not configured")
+	}
+
+	if result.Int64 == -1 {
+		return ErrWaitTimeout
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package slave_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/partyzanex/testutils"
+)
+
+func TestUntil_Validate(t *testing.T) {
+	data := map[string]struct {
+		until slave.Until
+		valid bool
+	}{
+		"empty":       {until: slave.Until{}},
+		"position":    {until: slave.Until{File: "binlog.000002", Position: 4}, valid: true},
+		"no position": {until: slave.Until{File: "binlog.000002"}},
+		"gaps":        {until: slave.Until{AfterMTSGaps: true}, valid: true},
+		"both":        {until: slave.Until{BeforeGTIDs: "uuid:5", AfterGTIDs: "uuid:6"}},
+	}
+
+	for name, test := range data {
+		testutils.AssertEqual(t, name, test.valid, test.until.Validate() == nil)
+	}
+}
+
+func TestRepository_StartUntil(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := slave.New(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`START SLAVE UNTIL MASTER_LOG_FILE = 'binlog.000002', MASTER_LOG_POS = 1200`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`START SLAVE SQL_THREAD UNTIL SQL_BEFORE_GTIDS = 'uuid:5'`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT @@SESSION.sql_mode`).
+		WillReturnRows(sqlmock.NewRows([]string{"sql_mode"}).AddRow(""))
+	mock.ExpectExec(`START SLAVE SQL_THREAD UNTIL SQL_AFTER_MTS_GAPS`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.StartUntil(ctx, slave.Until{File: "binlog.000002", Position: 1200})
+	testutils.FatalErr(t, "repo.StartUntil", err)
+
+	err = repo.StartSQLThreadUntil(ctx, slave.Until{BeforeGTIDs: "uuid:5"})
+	testutils.FatalErr(t, "repo.StartSQLThreadUntil", err)
+
+	err = repo.StartSQLThreadUntil(ctx, slave.Until{AfterMTSGaps: true})
+	testutils.FatalErr(t, "repo.StartSQLThreadUntil", err)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestRepository_Wait(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	repo := slave.New(db)
+	ctx := context.Background()
+	gtidWait := `SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)`
+	posWait := `SELECT MASTER_POS_WAIT(?, ?, ?)`
+
+	mock.ExpectQuery(gtidWait).WithArgs("uuid:1-10", 1.5).WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(0))
+	mock.ExpectQuery(gtidWait).WithArgs("uuid:1-10", 1.5).WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(1))
+	mock.ExpectQuery(posWait).WithArgs("binlog.000002", 1200, 0.0).
+		WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(12))
+	mock.ExpectQuery(posWait).WithArgs("binlog.000002", 1200, 0.0).
+		WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(-1))
+	mock.ExpectQuery(posWait).WithArgs("binlog.000002", 1200, 0.0).
+		WillReturnRows(sqlmock.NewRows([]string{"r"}).AddRow(nil))
+
+	testutils.FatalErr(t, "reached", repo.WaitForExecutedGTIDSet(ctx, "uuid:1-10", 1500*time.Millisecond))
+	testutils.AssertEqual(t, "gtid timeout", slave.ErrWaitTimeout,
+		repo.WaitForExecutedGTIDSet(ctx, "uuid:1-10", 1500*time.Millisecond))
+
+	testutils.FatalErr(t, "reached", repo.MasterPosWait(ctx, "binlog.000002", 1200, 0))
+	testutils.AssertEqual(t, "position timeout", slave.ErrWaitTimeout, repo.MasterPosWait(ctx, "binlog.000002", 1200, 0))
+	testutils.AssertEqual(t, "not running", true, repo.MasterPosWait(ctx, "binlog.000002", 1200, 0) != nil)
+
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
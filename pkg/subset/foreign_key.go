@@ -0,0 +1,58 @@
+package subset
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ForeignKey represents constraint of Table referencing Parent in the current database
+type ForeignKey struct {
+	Name          string
+	Table         string
+	Columns       []string
+	Parent        string
+	ParentColumns []string
+}
+
+// LoadForeignKeys returns foreign keys between tables of the current database of db
+func LoadForeignKeys(ctx context.Context, db *sql.DB) ([]ForeignKey, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT CONSTRAINT_NAME, TABLE_NAME, COLUMN_NAME, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME
+FROM information_schema.KEY_COLUMN_USAGE
+WHERE TABLE_SCHEMA = DATABASE() AND REFERENCED_TABLE_SCHEMA = DATABASE()
+ORDER BY TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get foreign keys: %s", err)
+	}
+
+	defer rows.Close()
+
+	keys := make([]ForeignKey, 0)
+
+	for rows.Next() {
+		var name, table, column, parent, parentColumn string
+
+		err = rows.Scan(&name, &table, &column, &parent, &parentColumn)
+		if err != nil {
+			return nil, err
+		}
+
+		if n := len(keys); n > 0 && keys[n-1].Name == name && keys[n-1].Table == table {
+			keys[n-1].Columns = append(keys[n-1].Columns, column)
+			keys[n-1].ParentColumns = append(keys[n-1].ParentColumns, parentColumn)
+
+			continue
+		}
+
+		keys = append(keys, ForeignKey{
+			Name:          name,
+			Table:         table,
+			Columns:       []string{column},
+			Parent:        parent,
+			ParentColumns: []string{parentColumn},
+		})
+	}
+
+	return keys, rows.Err()
+}
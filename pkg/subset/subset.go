@@ -0,0 +1,431 @@
+// Package subset selects referentially consistent subset of database rows following foreign keys
+package subset
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultMaxDepth = 3
+	DefaultMaxRows  = 10000
+
+	// batchSize is number of keys in one IN list
+	batchSize = 500
+)
+
+// Seed selects the initial rows of table by Where, Percent or Limit
+type Seed struct {
+	Table string
+	Where string
+	// Percent samples rows randomly
+	Percent float64
+	// Limit samples Limit random rows, rows are sorted by RAND(), so the table is read entirely
+	Limit int
+}
+
+// ParseSeed parses 'table:condition' of --subset flag
+func ParseSeed(s string) (Seed, error) {
+	i := strings.Index(s, ":")
+	if i <= 0 || strings.TrimSpace(s[i+1:]) == "" {
+		return Seed{}, fmt.Errorf("invalid subset %q, expected 'table:condition'", s)
+	}
+
+	return Seed{Table: s[:i], Where: strings.TrimSpace(s[i+1:])}, nil
+}
+
+// ParseSample parses 'table:N%' or 'table:N' of --sample flag
+func ParseSample(s string) (Seed, error) {
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return Seed{}, fmt.Errorf("invalid sample %q, expected 'table:N%%' or 'table:N'", s)
+	}
+
+	seed, value := Seed{Table: s[:i]}, strings.TrimSpace(s[i+1:])
+
+	if strings.HasSuffix(value, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return Seed{}, fmt.Errorf("invalid sample %q", s)
+		}
+
+		seed.Percent = percent
+
+		return seed, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return Seed{}, fmt.Errorf("invalid sample %q", s)
+	}
+
+	seed.Limit = limit
+
+	return seed, nil
+}
+
+// condition returns WHERE clause of seed
+func (s Seed) condition() string {
+	switch {
+	case s.Where != "":
+		return " WHERE " + s.Where
+	case s.Percent > 0:
+		return " WHERE RAND() < " + strconv.FormatFloat(s.Percent/100, 'f', -1, 64)
+	}
+
+	return ""
+}
+
+// order returns ORDER BY clause of sampled seed
+func (s Seed) order() string {
+	if s.Limit > 0 {
+		return " ORDER BY RAND()"
+	}
+
+	return ""
+}
+
+// Subset collects rows of seeds and rows related to them by foreign keys:
+// parents of every collected row are required by constraints and are always collected,
+// children are collected for seeds and collected children up to MaxDepth.
+// MaxRows caps seeds and children of table, parents are not capped.
+type Subset struct {
+	DB       *sql.DB
+	Seeds    []Seed
+	MaxDepth int
+	MaxRows  int
+	Verbose  bool
+
+	repo        *dump.Repository
+	quoter      quote.Quoter
+	foreignKeys []ForeignKey
+	primaryKeys map[string][]string
+	keys        map[string]map[string][]string
+}
+
+// item is rows added to table which relations are not followed yet
+type item struct {
+	table    string
+	rows     [][]string
+	depth    int
+	children bool
+}
+
+// Run collects rows and returns conditions of dumped rows for tables,
+// tables without collected rows have false condition
+func (s *Subset) Run(ctx context.Context, tables []string) (map[string]string, error) {
+	s.repo = dump.New(s.DB)
+	s.primaryKeys = make(map[string][]string)
+	s.keys = make(map[string]map[string][]string)
+
+	var err error
+
+	// conditions are executed by dump in sessions of the same server
+	s.quoter, err = quote.Detect(ctx, s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	s.foreignKeys, err = LoadForeignKeys(ctx, s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := make([]item, 0)
+
+	for _, seed := range s.Seeds {
+		pk, err := s.primaryKey(ctx, seed.Table)
+		if err != nil {
+			return nil, err
+		}
+
+		limit := seed.Limit
+		if s.MaxRows > 0 && (limit == 0 || limit > s.MaxRows) {
+			limit = s.MaxRows
+		}
+
+		query := "SELECT " + quote.Idents(pk...) + " FROM " + quote.Ident(seed.Table) + seed.condition() + seed.order()
+		if limit > 0 {
+			query += " LIMIT " + strconv.Itoa(limit)
+		}
+
+		rows, err := s.query(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("unable to select seed rows of %s: %s", seed.Table, err)
+		}
+
+		queue = append(queue, item{table: seed.Table, rows: s.add(seed.Table, rows, false), children: true})
+	}
+
+	for len(queue) > 0 {
+		it := queue[0]
+		queue = queue[1:]
+
+		next, err := s.follow(ctx, it)
+		if err != nil {
+			return nil, err
+		}
+
+		queue = append(queue, next...)
+	}
+
+	where := make(map[string]string, len(tables))
+
+	for _, table := range tables {
+		keys := s.keys[table]
+		if len(keys) == 0 {
+			where[table] = "FALSE"
+			continue
+		}
+
+		rows := make([][]string, 0, len(keys))
+		for _, row := range keys {
+			rows = append(rows, row)
+		}
+
+		sort.Slice(rows, func(i, j int) bool {
+			return strings.Join(rows[i], "\x00") < strings.Join(rows[j], "\x00")
+		})
+
+		where[table] = s.in(s.primaryKeys[table], rows)
+
+		if s.Verbose {
+			logrus.Infof("subset of %s has %d rows", table, len(rows))
+		}
+	}
+
+	return where, nil
+}
+
+// follow collects parents and children of rows of item
+func (s *Subset) follow(ctx context.Context, it item) ([]item, error) {
+	next := make([]item, 0)
+
+	if len(it.rows) == 0 {
+		return next, nil
+	}
+
+	for _, fk := range s.foreignKeys {
+		if fk.Table == it.table {
+			rows, err := s.related(ctx, it, fk.Columns, fk.Parent, fk.ParentColumns, 0)
+			if err != nil {
+				return nil, fmt.Errorf("unable to select parents of %s by %s: %s", it.table, fk.Name, err)
+			}
+
+			// parents of parents are required, their children are not
+			next = append(next, item{table: fk.Parent, rows: s.add(fk.Parent, rows, false), depth: it.depth})
+		}
+
+		if fk.Parent == it.table && it.children && it.depth < s.MaxDepth {
+			limit := 0
+
+			if s.MaxRows > 0 {
+				limit = s.MaxRows - len(s.keys[fk.Table])
+				if limit <= 0 {
+					logrus.Warnf("subset of %s has reached %d rows, children of %s are not collected",
+						fk.Table, s.MaxRows, it.table)
+
+					continue
+				}
+			}
+
+			rows, err := s.related(ctx, it, fk.ParentColumns, fk.Table, fk.Columns, limit)
+			if err != nil {
+				return nil, fmt.Errorf("unable to select children of %s by %s: %s", it.table, fk.Name, err)
+			}
+
+			next = append(next, item{
+				table:    fk.Table,
+				rows:     s.add(fk.Table, rows, true),
+				depth:    it.depth + 1,
+				children: true,
+			})
+		}
+	}
+
+	return next, nil
+}
+
+// related returns primary keys of rows of table which columns are equal to
+// values of columns of item rows, limit is ignored if it is zero
+func (s *Subset) related(ctx context.Context, it item, columns []string, table string, tableColumns []string,
+	limit int) ([][]string, error) {
+	pk, err := s.primaryKey(ctx, it.table)
+	if err != nil {
+		return nil, err
+	}
+
+	relatedPK, err := s.primaryKey(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]string, 0)
+
+	for start := 0; start < len(it.rows); start += batchSize {
+		end := start + batchSize
+		if end > len(it.rows) {
+			end = len(it.rows)
+		}
+
+		// values with NULL do not reference rows
+		values, err := s.query(ctx, "SELECT DISTINCT "+quote.Idents(columns...)+" FROM "+quote.Ident(it.table)+
+			" WHERE "+s.in(pk, it.rows[start:end]))
+		if err != nil {
+			return nil, err
+		}
+
+		for from := 0; from < len(values); from += batchSize {
+			to := from + batchSize
+			if to > len(values) {
+				to = len(values)
+			}
+
+			query := "SELECT " + quote.Idents(relatedPK...) + " FROM " + quote.Ident(table) +
+				" WHERE " + s.in(tableColumns, values[from:to])
+
+			if limit > 0 {
+				query += " LIMIT " + strconv.Itoa(limit-len(result))
+			}
+
+			rows, err := s.query(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, rows...)
+
+			if limit > 0 && len(result) >= limit {
+				return result, nil
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// add adds rows to table and returns rows which were not collected before,
+// children are capped by MaxRows
+func (s *Subset) add(table string, rows [][]string, capped bool) [][]string {
+	keys, ok := s.keys[table]
+	if !ok {
+		keys = make(map[string][]string)
+		s.keys[table] = keys
+	}
+
+	added := make([][]string, 0, len(rows))
+
+	for _, row := range rows {
+		if capped && s.MaxRows > 0 && len(keys) >= s.MaxRows {
+			break
+		}
+
+		key := strings.Join(row, "\x00")
+		if _, ok := keys[key]; ok {
+			continue
+		}
+
+		keys[key] = row
+		added = append(added, row)
+	}
+
+	return added
+}
+
+func (s *Subset) primaryKey(ctx context.Context, table string) ([]string, error) {
+	if pk, ok := s.primaryKeys[table]; ok {
+		return pk, nil
+	}
+
+	pk, err := s.repo.GetPrimaryKey(ctx, dump.Table{Name: table})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get primary key of %s: %s", table, err)
+	}
+
+	if len(pk) == 0 {
+		return nil, fmt.Errorf("table %s has no primary key, rows of subset can not be identified", table)
+	}
+
+	s.primaryKeys[table] = pk
+
+	return pk, nil
+}
+
+// query returns values of rows as strings, rows with NULL are skipped
+func (s *Subset) query(ctx context.Context, query string) ([][]string, error) {
+	rows, err := s.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]string, 0)
+
+Rows:
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		args := make([]interface{}, len(columns))
+
+		for i := range values {
+			args[i] = &values[i]
+		}
+
+		err = rows.Scan(args...)
+		if err != nil {
+			return nil, err
+		}
+
+		row := make([]string, len(columns))
+
+		for i, v := range values {
+			if !v.Valid {
+				continue Rows
+			}
+
+			row[i] = v.String
+		}
+
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+// in returns condition 'columns IN (rows)' with values quoted for SQL mode of source
+func (s *Subset) in(columns []string, rows [][]string) string {
+	tuples := make([]string, len(rows))
+
+	for i, row := range rows {
+		values := make([]string, len(row))
+
+		for j, v := range row {
+			values[j] = s.quoter.String(v)
+		}
+
+		tuples[i] = strings.Join(values, ", ")
+
+		if len(columns) > 1 {
+			tuples[i] = "(" + tuples[i] + ")"
+		}
+	}
+
+	if len(columns) > 1 {
+		return "(" + quote.Idents(columns...) + ") IN (" + strings.Join(tuples, ", ") + ")"
+	}
+
+	return quote.Ident(columns[0]) + " IN (" + strings.Join(tuples, ", ") + ")"
+}
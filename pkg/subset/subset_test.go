@@ -0,0 +1,96 @@
+package subset_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/subset"
+	"github.com/partyzanex/testutils"
+)
+
+func TestParseSample(t *testing.T) {
+	seed, err := subset.ParseSample("users:1.5%")
+	testutils.FatalErr(t, "ParseSample", err)
+	testutils.AssertEqual(t, "percent", 1.5, seed.Percent)
+
+	seed, err = subset.ParseSample("users:100")
+	testutils.FatalErr(t, "ParseSample", err)
+	testutils.AssertEqual(t, "limit", 100, seed.Limit)
+
+	for _, value := range []string{"users", "users:0", "users:200%", ":10"} {
+		_, err = subset.ParseSample(value)
+		testutils.AssertEqual(t, value, true, err != nil)
+	}
+
+	seed, err = subset.ParseSeed("users:id IN (1, 2)")
+	testutils.FatalErr(t, "ParseSeed", err)
+	testutils.AssertEqual(t, "table", "users", seed.Table)
+	testutils.AssertEqual(t, "where", "id IN (1, 2)", seed.Where)
+}
+
+func expectQuery(mock sqlmock.Sqlmock, query string, column string, values ...string) {
+	rows := sqlmock.NewRows([]string{column})
+	for _, v := range values {
+		rows.AddRow(v)
+	}
+
+	mock.ExpectQuery("^" + regexp.QuoteMeta(query) + "$").WillReturnRows(rows)
+}
+
+func TestSubset_Run(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	expectQuery(mock, "SELECT @@SESSION.sql_mode", "sql_mode", "STRICT_TRANS_TABLES")
+	mock.ExpectQuery("information_schema.KEY_COLUMN_USAGE").WillReturnRows(sqlmock.NewRows(
+		[]string{"CONSTRAINT_NAME", "TABLE_NAME", "COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME"}).
+		AddRow("fk_orders_user", "orders", "user_id", "users", "id"))
+	mock.ExpectQuery("information_schema.STATISTICS").WithArgs("users").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+	expectQuery(mock, "SELECT `id` FROM `users` WHERE id IN (1) LIMIT 10", "id", "1")
+
+	// children of seed
+	mock.ExpectQuery("information_schema.STATISTICS").WithArgs("orders").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+	expectQuery(mock, "SELECT DISTINCT `id` FROM `users` WHERE `id` IN ('1')", "id", "1")
+	expectQuery(mock, "SELECT `id` FROM `orders` WHERE `user_id` IN ('1') LIMIT 10", "id", "10", "11")
+
+	// parents of children
+	expectQuery(mock, "SELECT DISTINCT `user_id` FROM `orders` WHERE `id` IN ('10', '11')", "user_id", "1")
+	expectQuery(mock, "SELECT `id` FROM `users` WHERE `id` IN ('1')", "id", "1")
+
+	s := &subset.Subset{
+		DB:       db,
+		Seeds:    []subset.Seed{{Table: "users", Where: "id IN (1)"}},
+		MaxDepth: 1,
+		MaxRows:  10,
+	}
+
+	where, err := s.Run(context.Background(), []string{"logs", "orders", "users"})
+	testutils.FatalErr(t, "Run", err)
+	testutils.AssertEqual(t, "users", "`id` IN ('1')", where["users"])
+	testutils.AssertEqual(t, "orders", "`id` IN ('10', '11')", where["orders"])
+	testutils.AssertEqual(t, "logs", "FALSE", where["logs"])
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
+
+func TestSubset_Run_Sample(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	expectQuery(mock, "SELECT @@SESSION.sql_mode", "sql_mode", "NO_BACKSLASH_ESCAPES")
+	mock.ExpectQuery("information_schema.KEY_COLUMN_USAGE").WillReturnRows(sqlmock.NewRows(
+		[]string{"CONSTRAINT_NAME", "TABLE_NAME", "COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME"}))
+	mock.ExpectQuery("information_schema.STATISTICS").WithArgs("tags").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("name"))
+	expectQuery(mock, "SELECT `name` FROM `tags` ORDER BY RAND() LIMIT 2", "name", `it's`, `a\b`)
+
+	s := &subset.Subset{DB: db, Seeds: []subset.Seed{{Table: "tags", Limit: 2}}}
+
+	where, err := s.Run(context.Background(), []string{"tags"})
+	testutils.FatalErr(t, "Run", err)
+	testutils.AssertEqual(t, "tags", "`name` IN ('a\\b', 'it''s')", where["tags"])
+	testutils.FatalErr(t, "mock.ExpectationsWereMet()", mock.ExpectationsWereMet())
+}
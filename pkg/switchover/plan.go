@@ -0,0 +1,87 @@
+package switchover
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Step represents reversible step of plan
+type Step struct {
+	Description string
+	Do          func(ctx context.Context) error
+	// Undo reverts the step, nil if there is nothing to revert
+	Undo func(ctx context.Context) error
+}
+
+// Plan represents ordered steps
+type Plan struct {
+	Steps []Step
+}
+
+// String returns numbered list of steps, used for dry run
+func (p *Plan) String() string {
+	b := &strings.Builder{}
+
+	for i, step := range p.Steps {
+		fmt.Fprintf(b, "%2d. %s\n", i+1, step.Description)
+	}
+
+	return b.String()
+}
+
+// Execute runs steps in order, if a step fails
+// the failed and all completed steps are reverted in reverse order
+func (p *Plan) Execute(ctx context.Context) error {
+	for i, step := range p.Steps {
+		logrus.Infof("step %d/%d: %s", i+1, len(p.Steps), step.Description)
+
+		err := step.Do(ctx)
+		if err == nil {
+			continue
+		}
+
+		err = errors.Wrapf(err, "step %d (%s) failed", i+1, step.Description)
+		logrus.Error(err)
+
+		rollbackErr := p.rollback(i)
+		if rollbackErr != nil {
+			return errors.Wrapf(err, "rollback failed: %s", rollbackErr)
+		}
+
+		return errors.Wrap(err, "rolled back")
+	}
+
+	return nil
+}
+
+// rollback reverts steps from last to the first,
+// context is not inherited, so cancelled execution is reverted too
+func (p *Plan) rollback(last int) error {
+	ctx := context.Background()
+	errs := make([]string, 0)
+
+	for i := last; i >= 0; i-- {
+		step := p.Steps[i]
+		if step.Undo == nil {
+			continue
+		}
+
+		logrus.Warnf("undo step %d: %s", i+1, step.Description)
+
+		err := step.Undo(ctx)
+		if err != nil {
+			logrus.Errorf("undo step %d failed: %s", i+1, err)
+			errs = append(errs, fmt.Sprintf("step %d: %s", i+1, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
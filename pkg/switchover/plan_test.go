@@ -0,0 +1,56 @@
+package switchover_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/switchover"
+	"github.com/partyzanex/testutils"
+)
+
+func TestPlan_Execute(t *testing.T) {
+	calls := make([]string, 0)
+
+	step := func(name string, fail bool) switchover.Step {
+		return switchover.Step{
+			Description: name,
+			Do: func(ctx context.Context) error {
+				calls = append(calls, "do "+name)
+
+				if fail {
+					return errors.New("expected error")
+				}
+
+				return nil
+			},
+			Undo: func(ctx context.Context) error {
+				calls = append(calls, "undo "+name)
+				return nil
+			},
+		}
+	}
+
+	plan := &switchover.Plan{Steps: []switchover.Step{step("a", false), step("b", false)}}
+	plan.Steps = append(plan.Steps, switchover.Step{
+		Description: "c",
+		Do: func(ctx context.Context) error {
+			calls = append(calls, "do c")
+			return nil
+		},
+	})
+
+	testutils.AssertEqual(t, "String", " 1. a\n 2. b\n 3. c\n", plan.String())
+
+	err := plan.Execute(context.Background())
+	testutils.FatalErr(t, "plan.Execute", err)
+	testutils.AssertEqual(t, "calls", "do a,do b,do c", strings.Join(calls, ","))
+
+	calls = calls[:0]
+	plan.Steps = append(plan.Steps, step("d", true))
+
+	err = plan.Execute(context.Background())
+	testutils.AssertEqual(t, "err", true, err != nil)
+	testutils.AssertEqual(t, "calls", "do a,do b,do c,do d,undo d,undo b,undo a", strings.Join(calls, ","))
+}
@@ -0,0 +1,217 @@
+// Package switchover promotes a replica to master in planned maintenance
+package switchover
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/mysql"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultCatchUpTimeout = time.Minute
+)
+
+// Switchover represents planned change of master
+type Switchover struct {
+	Master    *node.Node
+	Candidate *node.Node
+	Replicas  []*node.Node
+	// User is used by replicas to connect to the new master
+	User mysql.ReplUser
+	// RepointOldMaster makes the old master a replica of the new master
+	RepointOldMaster bool
+	// CatchUpTimeout limits waiting for replicas to apply transactions of the old master
+	CatchUpTimeout time.Duration
+}
+
+// state holds values captured during execution of plan
+type state struct {
+	master    master.Status
+	candidate master.Status
+}
+
+// Plan inspects servers and builds steps of switchover
+func (s *Switchover) Plan(ctx context.Context) (*Plan, error) {
+	if s.Master == nil || s.Candidate == nil {
+		return nil, errors.New("master and candidate are required")
+	}
+
+	if s.CatchUpTimeout <= 0 {
+		s.CatchUpTimeout = DefaultCatchUpTimeout
+	}
+
+	masterInfo, err := s.Master.Server().Info(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "master %s", s.Master)
+	}
+
+	candidateInfo, err := s.Candidate.Server().Info(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "candidate %s", s.Candidate)
+	}
+
+	useGTID := masterInfo.GTIDEnabled() && candidateInfo.GTIDEnabled()
+
+	for _, replica := range s.Replicas {
+		info, err := replica.Server().Info(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "replica %s", replica)
+		}
+
+		useGTID = useGTID && info.GTIDEnabled()
+	}
+
+	err = s.validate(ctx, masterInfo, candidateInfo, useGTID)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &state{}
+	plan := &Plan{}
+
+	mode := "binlog coordinates"
+	if useGTID {
+		mode = "GTID auto-positioning"
+	}
+
+	plan.Steps = append(plan.Steps,
+		Step{
+			Description: fmt.Sprintf("set read_only and super_read_only on old master %s", s.Master),
+			Do: func(ctx context.Context) error {
+				return s.Master.Server().SetReadOnly(ctx, true, true)
+			},
+			Undo: func(ctx context.Context) error {
+				return s.Master.Server().SetReadOnly(ctx, masterInfo.ReadOnly, masterInfo.SuperReadOnly)
+			},
+		},
+		Step{
+			Description: fmt.Sprintf("capture binlog coordinates of old master %s", s.Master),
+			Do: func(ctx context.Context) (err error) {
+				status, err := s.Master.Master().ShowStatus(ctx)
+				if err == nil {
+					st.master = *status
+				}
+
+				return
+			},
+		},
+		Step{
+			Description: fmt.Sprintf("wait up to %s for candidate %s to catch up", s.CatchUpTimeout, s.Candidate),
+			Do: func(ctx context.Context) error {
+				return s.waitExecuted(ctx, s.Candidate, st.master, useGTID)
+			},
+		},
+		Step{
+			Description: fmt.Sprintf("stop replication on candidate %s and capture its binlog coordinates", s.Candidate),
+			Do: func(ctx context.Context) error {
+				err := s.Candidate.Slave().Stop(ctx)
+				if err != nil {
+					return err
+				}
+
+				status, err := s.Candidate.Master().ShowStatus(ctx)
+				if err != nil {
+					return err
+				}
+
+				st.candidate = *status
+
+				return nil
+			},
+			Undo: func(ctx context.Context) error {
+				return s.Candidate.Slave().Start(ctx)
+			},
+		},
+		Step{
+			Description: fmt.Sprintf("reset replication on candidate %s", s.Candidate),
+			Do: func(ctx context.Context) error {
+				return s.Candidate.Slave().ResetAll(ctx)
+			},
+			Undo: func(ctx context.Context) error {
+				return s.Candidate.Repoint(ctx, s.Master.ReplUser(s.User), st.master, useGTID)
+			},
+		},
+		Step{
+			Description: fmt.Sprintf("make candidate %s writable", s.Candidate),
+			Do: func(ctx context.Context) error {
+				return s.Candidate.Server().SetReadOnly(ctx, false, false)
+			},
+			Undo: func(ctx context.Context) error {
+				return s.Candidate.Server().SetReadOnly(ctx, candidateInfo.ReadOnly, candidateInfo.SuperReadOnly)
+			},
+		},
+	)
+
+	for _, replica := range s.Replicas {
+		replica := replica
+
+		plan.Steps = append(plan.Steps, Step{
+			Description: fmt.Sprintf("repoint replica %s to new master %s using %s", replica, s.Candidate, mode),
+			Do: func(ctx context.Context) error {
+				err := s.waitExecuted(ctx, replica, st.master, useGTID)
+				if err != nil {
+					return err
+				}
+
+				return replica.Repoint(ctx, s.Candidate.ReplUser(s.User), st.candidate, useGTID)
+			},
+			Undo: func(ctx context.Context) error {
+				return replica.Repoint(ctx, s.Master.ReplUser(s.User), st.master, useGTID)
+			},
+		})
+	}
+
+	if s.RepointOldMaster {
+		plan.Steps = append(plan.Steps, Step{
+			Description: fmt.Sprintf("repoint old master %s to new master %s using %s", s.Master, s.Candidate, mode),
+			Do: func(ctx context.Context) error {
+				return s.Master.Repoint(ctx, s.Candidate.ReplUser(s.User), st.candidate, useGTID)
+			},
+			Undo: func(ctx context.Context) error {
+				err := s.Master.Slave().Stop(ctx)
+				if err != nil {
+					return err
+				}
+
+				return s.Master.Slave().ResetAll(ctx)
+			},
+		})
+	}
+
+	return plan, nil
+}
+
+func (s *Switchover) validate(ctx context.Context, masterInfo, candidateInfo *server.Info, useGTID bool) error {
+	if !candidateInfo.LogBin {
+		return errors.Errorf("binary logging is disabled on candidate %s", s.Candidate)
+	}
+
+	if !useGTID && len(s.Replicas) > 0 && !candidateInfo.LogSlaveUpdates {
+		return errors.Errorf("log_slave_updates is required on candidate %s to repoint replicas by binlog coordinates", s.Candidate)
+	}
+
+	status, err := s.Candidate.Slave().ShowStatus(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "candidate %s is not a replica", s.Candidate)
+	}
+
+	if status.MasterUUID != "" && masterInfo.ServerUUID != "" && status.MasterUUID != masterInfo.ServerUUID {
+		return errors.Errorf("candidate %s replicates from %s, not from master %s",
+			s.Candidate, status.MasterUUID, s.Master)
+	}
+
+	return nil
+}
+
+func (s *Switchover) waitExecuted(ctx context.Context, replica *node.Node, target master.Status, useGTID bool) error {
+	ctx, cancel := context.WithTimeout(ctx, s.CatchUpTimeout)
+	defer cancel()
+
+	return replica.WaitExecuted(ctx, target, useGTID)
+}
@@ -0,0 +1,192 @@
+package tablesync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/partyzanex/repmy/pkg/quote"
+)
+
+// Kind represents kind of change of replica row
+type Kind string
+
+const (
+	Insert Kind = "INSERT"
+	Update Kind = "UPDATE"
+	Delete Kind = "DELETE"
+)
+
+// Row represents values of table row in order of columns, nil is NULL
+type Row []*string
+
+// Change represents statement which makes row of replica equal to row of master
+type Change struct {
+	Kind    Kind
+	Table   string
+	Columns []string
+	Key     []string
+	// Row is row of master for INSERT and UPDATE, row of replica for DELETE
+	Row Row
+	// Changed are indexes of changed columns for UPDATE
+	Changed []int
+}
+
+// Diff returns minimal changes of replica rows to make them equal to master rows,
+// table is qualified name, rows are identified by key columns
+func Diff(table string, columns, key []string, master, replica []Row) []Change {
+	keyIdx := indexes(columns, key)
+
+	replicaRows := make(map[string]Row, len(replica))
+	for _, row := range replica {
+		replicaRows[row.key(keyIdx)] = row
+	}
+
+	changes := make([]Change, 0)
+	seen := make(map[string]bool, len(master))
+
+	for _, row := range master {
+		k := row.key(keyIdx)
+		seen[k] = true
+
+		other, ok := replicaRows[k]
+		if !ok {
+			changes = append(changes, Change{Kind: Insert, Table: table, Columns: columns, Key: key, Row: row})
+			continue
+		}
+
+		changed := make([]int, 0)
+
+		for i := range columns {
+			if !equal(row[i], other[i]) {
+				changed = append(changed, i)
+			}
+		}
+
+		if len(changed) > 0 {
+			changes = append(changes, Change{
+				Kind: Update, Table: table, Columns: columns, Key: key, Row: row, Changed: changed,
+			})
+		}
+	}
+
+	for _, row := range replica {
+		if !seen[row.key(keyIdx)] {
+			changes = append(changes, Change{Kind: Delete, Table: table, Columns: columns, Key: key, Row: row})
+		}
+	}
+
+	return changes
+}
+
+// Query returns statement with placeholders and its arguments,
+// if replace is true INSERT and UPDATE are executed as REPLACE
+// (to be applied on master, where the row already exists)
+func (c Change) Query(replace bool) (string, []interface{}) {
+	args := make([]interface{}, 0, len(c.Columns))
+
+	q := c.build(replace, func(value *string) string {
+		args = append(args, arg(value))
+		return "?"
+	})
+
+	return q, args
+}
+
+// String returns statement for replica with values as literals
+func (c Change) String() string {
+	return c.build(false, literal) + ";"
+}
+
+// build returns statement, value returns placeholder or literal of value
+func (c Change) build(replace bool, value func(value *string) string) string {
+	keyIdx := indexes(c.Columns, c.Key)
+
+	if c.Kind == Delete {
+		return "DELETE FROM " + c.Table + " WHERE " + c.where(keyIdx, value)
+	}
+
+	if c.Kind == Insert || replace {
+		verb := "INSERT"
+		if replace {
+			verb = "REPLACE"
+		}
+
+		values := make([]string, len(c.Row))
+		for i, v := range c.Row {
+			values[i] = value(v)
+		}
+
+		return fmt.Sprintf("%s INTO %s (%s) VALUES (%s)",
+			verb, c.Table, quote.Idents(c.Columns...), strings.Join(values, ", "))
+	}
+
+	sets := make([]string, 0, len(c.Changed))
+
+	for _, i := range c.Changed {
+		sets = append(sets, quote.Ident(c.Columns[i])+" = "+value(c.Row[i]))
+	}
+
+	return "UPDATE " + c.Table + " SET " + strings.Join(sets, ", ") + " WHERE " + c.where(keyIdx, value)
+}
+
+func (c Change) where(keyIdx []int, value func(value *string) string) string {
+	conds := make([]string, len(keyIdx))
+
+	for i, idx := range keyIdx {
+		conds[i] = quote.Ident(c.Columns[idx]) + " = " + value(c.Row[idx])
+	}
+
+	return strings.Join(conds, " AND ")
+}
+
+// key returns values of key columns joined with separator unlikely to be in values
+func (row Row) key(keyIdx []int) string {
+	parts := make([]string, len(keyIdx))
+
+	for i, idx := range keyIdx {
+		if row[idx] != nil {
+			parts[i] = *row[idx]
+		}
+	}
+
+	return strings.Join(parts, "\x00\x01")
+}
+
+func indexes(columns, names []string) []int {
+	result := make([]int, 0, len(names))
+
+	for _, name := range names {
+		for i, column := range columns {
+			if column == name {
+				result = append(result, i)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func equal(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
+
+func arg(value *string) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	return *value
+}
+
+func literal(value *string) string {
+	if value == nil {
+		return "NULL"
+	}
+
+	return quote.String(*value)
+}
@@ -0,0 +1,65 @@
+package tablesync_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/partyzanex/repmy/pkg/tablesync"
+	"github.com/partyzanex/testutils"
+)
+
+func row(values ...interface{}) tablesync.Row {
+	r := make(tablesync.Row, len(values))
+
+	for i, value := range values {
+		if value != nil {
+			s := fmt.Sprint(value)
+			r[i] = &s
+		}
+	}
+
+	return r
+}
+
+func TestDiff(t *testing.T) {
+	columns, key := []string{"id", "name", "note"}, []string{"id"}
+
+	master := []tablesync.Row{
+		row(1, "a", nil),
+		row(2, "b", "x"),
+		row(3, "c", nil),
+	}
+	replica := []tablesync.Row{
+		row(1, "a", nil),
+		row(2, "B", nil),
+		row(4, "d", "it's"),
+	}
+
+	changes := tablesync.Diff("`db`.`t`", columns, key, master, replica)
+	testutils.AssertEqual(t, "changes", 3, len(changes))
+
+	testutils.AssertEqual(t, "update", "UPDATE `db`.`t` SET `name` = 'b', `note` = 'x' WHERE `id` = '2';",
+		changes[0].String())
+	testutils.AssertEqual(t, "insert", "INSERT INTO `db`.`t` (`id`, `name`, `note`) VALUES ('3', 'c', NULL);",
+		changes[1].String())
+	testutils.AssertEqual(t, "delete", "DELETE FROM `db`.`t` WHERE `id` = '4';",
+		changes[2].String())
+
+	q, args := changes[0].Query(true)
+	testutils.AssertEqual(t, "replace", "REPLACE INTO `db`.`t` (`id`, `name`, `note`) VALUES (?, ?, ?)", q)
+	testutils.AssertEqual(t, "replace args", "[2 b x]", fmt.Sprint(args))
+
+	q, args = changes[0].Query(false)
+	testutils.AssertEqual(t, "update query", "UPDATE `db`.`t` SET `name` = ?, `note` = ? WHERE `id` = ?", q)
+	testutils.AssertEqual(t, "update args", "[b x 2]", fmt.Sprint(args))
+}
+
+func TestDiff_Equal(t *testing.T) {
+	rows := []tablesync.Row{row(1, nil), row(2, "")}
+
+	changes := tablesync.Diff("`t`", []string{"id", "v"}, []string{"id"}, rows, []tablesync.Row{row(1, nil), row(2, "")})
+	testutils.AssertEqual(t, "no changes", 0, len(changes))
+
+	changes = tablesync.Diff("`t`", []string{"id", "v"}, []string{"id"}, rows, []tablesync.Row{row(1, ""), row(2, nil)})
+	testutils.AssertEqual(t, "NULL differs from empty string", 2, len(changes))
+}
@@ -0,0 +1,336 @@
+// Package tablesync repairs rows of replica which differ from master
+// in chunks found by package checksum
+package tablesync
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/partyzanex/repmy/pkg/checksum"
+	"github.com/partyzanex/repmy/pkg/chunk"
+	"github.com/partyzanex/repmy/pkg/dump"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/quote"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Mode represents how changes are applied
+type Mode string
+
+const (
+	// Print writes statements for replica to Syncer.Out
+	Print Mode = "print"
+	// ApplyMaster executes REPLACE and DELETE on master with binlog_format=STATEMENT,
+	// they change nothing on master and repair replica by replication,
+	// tables referenced by foreign keys with ON DELETE CASCADE or SET NULL are refused
+	ApplyMaster Mode = "master"
+	// ApplyReplica executes changes directly on replica with sql_log_bin=0
+	ApplyReplica Mode = "replica"
+
+	DefaultWaitTimeout = time.Minute
+)
+
+// Syncer repairs drifted chunks of replica, tables of default database of master DSN are synced
+type Syncer struct {
+	Master  *node.Node
+	Replica *node.Node
+	Mode    Mode
+	Out     io.Writer
+	// Tables limits synced tables, all drifted tables are synced if empty
+	Tables []string
+	// ChecksumsDatabase and ChecksumsTable is location of checksums on replica
+	ChecksumsDatabase string
+	ChecksumsTable    string
+	// WaitTimeout limits waiting for replica to catch up before its rows are read
+	WaitTimeout time.Duration
+}
+
+// Run repairs drifted chunks and returns number of changes
+func (s *Syncer) Run(ctx context.Context) (int, error) {
+	if s.WaitTimeout <= 0 {
+		s.WaitTimeout = DefaultWaitTimeout
+	}
+
+	var database string
+
+	err := s.Master.DB.QueryRowContext(ctx, `SELECT DATABASE()`).Scan(&database)
+	if err != nil || database == "" {
+		return 0, errors.Errorf("database is not selected in DSN of master %s", s.Master)
+	}
+
+	checker := &checksum.Checker{ChecksumsDatabase: s.ChecksumsDatabase, ChecksumsTable: s.ChecksumsTable}
+
+	drifts, err := checksum.Results(ctx, s.Replica.DB, checker.Checksums(), database, true)
+	if err != nil {
+		return 0, errors.Wrapf(err, "replica %s", s.Replica)
+	}
+
+	wanted := make(map[string]bool, len(s.Tables))
+	for _, table := range s.Tables {
+		wanted[table] = true
+	}
+
+	total := 0
+
+	for _, drift := range drifts {
+		if len(wanted) > 0 && !wanted[drift.Table] {
+			continue
+		}
+
+		n, err := s.sync(ctx, database, drift)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+// sync repairs chunk of drift: rows of master are read with SELECT ... FOR UPDATE
+// in transaction which is committed after changes are applied, so they can not
+// be changed on master meanwhile
+func (s *Syncer) sync(ctx context.Context, database string, drift checksum.Result) (int, error) {
+	if s.Mode == ApplyMaster {
+		// REPLACE deletes the row before insert, so rows of referencing tables would be deleted on master too
+		constraints, err := cascades(ctx, s.Master.DB, database, drift.Table)
+		if err != nil {
+			return 0, errors.Wrapf(err, "master %s", s.Master)
+		}
+
+		if len(constraints) > 0 {
+			return 0, errors.Errorf("table %s is referenced by foreign keys %s with ON DELETE CASCADE or SET NULL, "+
+				"REPLACE on master would change referencing rows, use --on-replica or print changes",
+				drift.Table, strings.Join(constraints, ", "))
+		}
+	}
+
+	conn, err := s.Master.DB.Conn(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to get connection")
+	}
+
+	defer conn.Close()
+
+	if s.Mode == ApplyMaster {
+		// binlog_format can not be changed inside transaction
+		_, err = conn.ExecContext(ctx, `SET SESSION binlog_format = 'STATEMENT'`)
+		if err != nil {
+			return 0, errors.Wrap(err, "unable to set binlog_format")
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to begin transaction on master %s", s.Master)
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	changes, err := s.diff(ctx, tx, database, drift)
+	if err != nil {
+		return 0, err
+	}
+
+	logrus.Infof("table %s chunk %s: %d changes", drift.Table, drift.Chunk, len(changes))
+
+	err = s.apply(ctx, tx, changes)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to commit transaction on master %s", s.Master)
+	}
+
+	return len(changes), nil
+}
+
+// diff reads and locks rows of chunk on master in tx, reads rows of replica and returns changes of replica
+func (s *Syncer) diff(ctx context.Context, tx *sql.Tx, database string, drift checksum.Result) ([]Change, error) {
+	table := dump.Table{Name: drift.Table, Type: dump.BaseTable}
+	repo := dump.New(s.Master.DB)
+
+	columns, err := repo.GetTableColumns(ctx, table)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get columns of %s", table.Name)
+	}
+
+	key, err := repo.GetPrimaryKey(ctx, table)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get primary key of %s", table.Name)
+	}
+
+	if len(key) == 0 {
+		return nil, errors.Errorf("table %s has no primary key, rows can not be matched", table.Name)
+	}
+
+	qualified := quote.Qualified(database, table.Name)
+
+	masterRows, err := readRows(ctx, tx, qualified, columns, key, drift.Chunk, true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "master %s", s.Master)
+	}
+
+	// rows of replica are read after it has applied everything read from master
+	status, err := s.Master.Master().ShowStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.Replica.Server().Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, s.WaitTimeout)
+	defer cancel()
+
+	err = s.Replica.WaitExecuted(waitCtx, *status, info.GTIDEnabled() && status.ExecutedGTIDSet != "")
+	if err != nil {
+		return nil, err
+	}
+
+	replicaRows, err := readRows(ctx, s.Replica.DB, qualified, columns, key, drift.Chunk, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "replica %s", s.Replica)
+	}
+
+	return Diff(qualified, columns, key, masterRows, replicaRows), nil
+}
+
+// apply prints or executes changes, on master they are executed in tx holding locks of rows
+func (s *Syncer) apply(ctx context.Context, tx *sql.Tx, changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if s.Mode == Print || s.Mode == "" {
+		for _, change := range changes {
+			_, err := fmt.Fprintln(s.Out, change.String())
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	var exec interface {
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	} = tx
+
+	if s.Mode == ApplyReplica {
+		conn, err := s.Replica.DB.Conn(ctx)
+		if err != nil {
+			return errors.Wrap(err, "unable to get connection")
+		}
+
+		defer conn.Close()
+
+		_, err = conn.ExecContext(ctx, `SET SESSION sql_log_bin = 0`)
+		if err != nil {
+			return errors.Wrap(err, "unable to disable sql_log_bin")
+		}
+
+		exec = conn
+	}
+
+	for _, change := range changes {
+		q, args := change.Query(s.Mode == ApplyMaster)
+
+		_, err := exec.ExecContext(ctx, q, args...)
+		if err != nil {
+			return errors.Wrapf(err, "unable to execute %s", change)
+		}
+	}
+
+	return nil
+}
+
+// cascades returns names of foreign keys which delete or change rows of other tables
+// when rows of table are deleted
+func cascades(ctx context.Context, db chunk.Queryer, database, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+SELECT CONCAT(TABLE_NAME, '.', CONSTRAINT_NAME) FROM information_schema.REFERENTIAL_CONSTRAINTS
+WHERE UNIQUE_CONSTRAINT_SCHEMA = ? AND REFERENCED_TABLE_NAME = ? AND DELETE_RULE IN ('CASCADE', 'SET NULL')
+ORDER BY TABLE_NAME, CONSTRAINT_NAME`, database, table)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read foreign keys")
+	}
+
+	defer rows.Close()
+
+	names := make([]string, 0)
+
+	for rows.Next() {
+		var name string
+
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan foreign key")
+		}
+
+		names = append(names, name)
+	}
+
+	return names, errors.Wrap(rows.Err(), "unable to read foreign keys")
+}
+
+// readRows returns rows of chunk ordered by key, rows are locked if forUpdate is true
+func readRows(ctx context.Context, db chunk.Queryer, table string, columns, key []string, c chunk.Chunk,
+	forUpdate bool) ([]Row, error) {
+	where, args := c.Where(key)
+
+	q := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s",
+		quote.Idents(columns...), table, where, quote.Idents(key...))
+
+	if forUpdate {
+		q += " FOR UPDATE"
+	}
+
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read rows of %s", table)
+	}
+
+	defer rows.Close()
+
+	result := make([]Row, 0)
+
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to scan row of %s", table)
+		}
+
+		row := make(Row, len(columns))
+
+		for i, value := range values {
+			if value.Valid {
+				v := value.String
+				row[i] = &v
+			}
+		}
+
+		result = append(result, row)
+	}
+
+	return result, errors.Wrapf(rows.Err(), "unable to read rows of %s", table)
+}
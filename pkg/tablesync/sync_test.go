@@ -0,0 +1,42 @@
+package tablesync_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/tablesync"
+	"github.com/partyzanex/testutils"
+)
+
+func TestSyncer_Run_Cascade(t *testing.T) {
+	masterDB, masterMock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	replicaDB, replicaMock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	syncer := &tablesync.Syncer{
+		Master:  &node.Node{Name: "master", DB: masterDB},
+		Replica: &node.Node{Name: "replica", DB: replicaDB},
+		Mode:    tablesync.ApplyMaster,
+	}
+
+	masterMock.ExpectQuery(`SELECT DATABASE\(\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"DATABASE()"}).AddRow("shop"))
+	replicaMock.ExpectQuery(`SELECT tbl, chunk`).WithArgs("shop").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"tbl", "chunk", "lower_boundary", "upper_boundary", "this_cnt", "this_crc", "master_cnt", "master_crc",
+		}).AddRow("customers", 1, nil, nil, 10, "a", 10, "b"))
+	masterMock.ExpectQuery(`FROM information_schema.REFERENTIAL_CONSTRAINTS`).WithArgs("shop", "customers").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("orders.fk_orders_customer"))
+
+	_, err = syncer.Run(context.Background())
+	testutils.AssertEqual(t, "refused", true,
+		err != nil && strings.Contains(err.Error(), "orders.fk_orders_customer"))
+
+	testutils.FatalErr(t, "masterMock.ExpectationsWereMet()", masterMock.ExpectationsWereMet())
+	testutils.FatalErr(t, "replicaMock.ExpectationsWereMet()", replicaMock.ExpectationsWereMet())
+}
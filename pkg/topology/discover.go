@@ -0,0 +1,293 @@
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"strconv"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/partyzanex/repmy/pkg/master"
+	"github.com/partyzanex/repmy/pkg/node"
+	"github.com/partyzanex/repmy/pkg/server"
+	"github.com/partyzanex/repmy/pkg/slave"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultMaxInstances = 256
+)
+
+// Connector opens connection to server by address host:port
+type Connector func(addr string) (*sql.DB, error)
+
+// DSNConnector returns Connector which uses user, password and parameters of dsn
+// for every server and address of dsn as seed
+func DSNConnector(dsn string) (Connector, string, error) {
+	cfg, err := driver.ParseDSN(dsn)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to parse DSN")
+	}
+
+	seed := cfg.Addr
+
+	host, port, err := node.SplitAddr(seed)
+	if err != nil {
+		return nil, "", err
+	}
+
+	connect := func(addr string) (*sql.DB, error) {
+		c := cfg.Clone()
+		c.Net = "tcp"
+		c.Addr = addr
+
+		return sql.Open("mysql", c.FormatDSN())
+	}
+
+	if cfg.Net != "tcp" {
+		// unix socket of seed is replaced by tcp address for other servers
+		seed = net.JoinHostPort(host, strconv.Itoa(port))
+		original := connect
+
+		connect = func(addr string) (*sql.DB, error) {
+			if addr == seed {
+				return sql.Open("mysql", dsn)
+			}
+
+			return original(addr)
+		}
+	}
+
+	return connect, seed, nil
+}
+
+// Discoverer walks replication topology from master to replicas
+// by SHOW SLAVE HOSTS and binlog dump threads and from replicas to masters
+// by SHOW SLAVE STATUS
+type Discoverer struct {
+	Connect Connector
+	// MaxInstances limits number of discovered servers
+	MaxInstances int
+}
+
+// target represents address waiting for inspection
+type target struct {
+	addr string
+	// guessed address is ignored if it is unreachable
+	guessed bool
+}
+
+// inspection represents state of one server
+type inspection struct {
+	info      *server.Info
+	statuses  []slave.Status
+	hosts     []master.SlaveHost
+	dumpHosts []string
+}
+
+// Discover returns topology of servers reachable from seed address
+func (d *Discoverer) Discover(ctx context.Context, seed string) (*Topology, error) {
+	if d.MaxInstances <= 0 {
+		d.MaxInstances = DefaultMaxInstances
+	}
+
+	t := &Topology{}
+	visited := make(map[string]bool)
+	// servers may be reachable by several addresses
+	byUUID := make(map[string]string)
+	alias := make(map[string]string)
+	queue := []target{{addr: seed}}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if visited[current.addr] {
+			continue
+		}
+
+		visited[current.addr] = true
+
+		if len(t.Instances) >= d.MaxInstances {
+			logrus.Warnf("discovery is stopped, more than %d instances", d.MaxInstances)
+			break
+		}
+
+		result, err := d.inspect(ctx, current.addr)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if err != nil {
+			if current.guessed {
+				logrus.Debugf("guessed address %s is skipped: %s", current.addr, err)
+				continue
+			}
+
+			logrus.Warnf("%s: %s", current.addr, err)
+			t.Instances = append(t.Instances, &Instance{Addr: current.addr, Error: err.Error()})
+
+			continue
+		}
+
+		if addr, ok := byUUID[result.info.ServerUUID]; ok && result.info.ServerUUID != "" {
+			alias[current.addr] = addr
+			continue
+		}
+
+		byUUID[result.info.ServerUUID] = current.addr
+
+		t.Instances = append(t.Instances, &Instance{
+			Addr:       current.addr,
+			ServerID:   result.info.ServerID,
+			ServerUUID: result.info.ServerUUID,
+			Version:    result.info.Version.Raw,
+			ReadOnly:   result.info.ReadOnly,
+		})
+
+		for _, status := range result.statuses {
+			masterAddr := net.JoinHostPort(status.MasterHost, strconv.Itoa(int(status.MasterPort)))
+
+			link := &Link{
+				Master:     masterAddr,
+				Replica:    current.addr,
+				Channel:    status.ChannelName,
+				IORunning:  status.SlaveIORunning,
+				SQLRunning: status.SlaveSQLRunning,
+				LastError:  lastError(status),
+			}
+
+			if status.SecondsBehindMaster.Valid {
+				lag := int64(status.SecondsBehindMaster.Int)
+				link.Lag = &lag
+			}
+
+			t.Links = append(t.Links, link)
+			queue = append(queue, target{addr: masterAddr})
+		}
+
+		reported := make(map[string]bool)
+
+		for _, host := range result.hosts {
+			if host.Host == "" {
+				continue
+			}
+
+			port := host.Port
+			if port == 0 {
+				port = node.DefaultPort
+			}
+
+			addr := net.JoinHostPort(host.Host, strconv.Itoa(port))
+			reported[host.Host] = true
+
+			t.Links = append(t.Links, &Link{Master: current.addr, Replica: addr, Reported: true})
+			queue = append(queue, target{addr: addr})
+		}
+
+		for _, host := range result.dumpHosts {
+			if reported[host] {
+				continue
+			}
+
+			addr := net.JoinHostPort(host, strconv.Itoa(node.DefaultPort))
+
+			t.Links = append(t.Links, &Link{Master: current.addr, Replica: addr, Reported: true})
+			queue = append(queue, target{addr: addr, guessed: true})
+		}
+	}
+
+	t.Links = resolveLinks(t, alias)
+	t.sort()
+
+	return t, nil
+}
+
+// inspect reads state of server by address
+func (d *Discoverer) inspect(ctx context.Context, addr string) (*inspection, error) {
+	db, err := d.Connect(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	result := &inspection{}
+
+	result.info, err = server.New(db).Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result.statuses, err = slave.New(db).ShowStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := master.New(db)
+
+	result.hosts, err = repo.ShowSlaveHosts(ctx, result.info.Version)
+	if err != nil {
+		logrus.Warnf("%s: %s", addr, err)
+	}
+
+	result.dumpHosts, err = repo.BinlogDumpHosts(ctx)
+	if err != nil {
+		logrus.Warnf("%s: %s", addr, err)
+	}
+
+	return result, nil
+}
+
+// resolveLinks replaces aliases by addresses of instances,
+// drops links to skipped servers and links reported by master
+// if the replica reported the link itself
+func resolveLinks(t *Topology, alias map[string]string) []*Link {
+	resolve := func(addr string) string {
+		if a, ok := alias[addr]; ok {
+			return a
+		}
+
+		return addr
+	}
+
+	links := make([]*Link, 0, len(t.Links))
+	known := make(map[[2]string]bool)
+
+	for _, link := range t.Links {
+		link.Master, link.Replica = resolve(link.Master), resolve(link.Replica)
+
+		if !link.Reported {
+			known[[2]string{link.Master, link.Replica}] = true
+		}
+	}
+
+	for _, link := range t.Links {
+		if t.Instance(link.Master) == nil || t.Instance(link.Replica) == nil {
+			continue
+		}
+
+		key := [2]string{link.Master, link.Replica}
+
+		if link.Reported {
+			if known[key] {
+				continue
+			}
+
+			known[key] = true
+		}
+
+		links = append(links, link)
+	}
+
+	return links
+}
+
+func lastError(status slave.Status) string {
+	if status.LastIOError != "" {
+		return status.LastIOError
+	}
+
+	return status.LastSQLError
+}
@@ -0,0 +1,157 @@
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteJSON writes topology as indented JSON
+func (t *Topology) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(t)
+}
+
+// WriteTree writes topology as ASCII tree, replicas of several masters
+// are printed under every master but their replicas only once
+func (t *Topology) WriteTree(w io.Writer) error {
+	printed := make(map[string]bool)
+	b := &strings.Builder{}
+
+	var walk func(addr, prefix string)
+
+	walk = func(addr, prefix string) {
+		links := t.Replicas(addr)
+
+		for i, link := range links {
+			branch, next := "├── ", "│   "
+			if i == len(links)-1 {
+				branch, next = "└── ", "    "
+			}
+
+			fmt.Fprintf(b, "%s%s%s %s", prefix, branch, link.Replica, describeLink(link))
+
+			if printed[link.Replica] {
+				b.WriteString(" (see above)\n")
+				continue
+			}
+
+			printed[link.Replica] = true
+
+			fmt.Fprintf(b, " %s\n", describeInstance(t.Instance(link.Replica)))
+			walk(link.Replica, prefix+next)
+		}
+	}
+
+	for _, root := range t.Roots() {
+		if printed[root.Addr] {
+			continue
+		}
+
+		printed[root.Addr] = true
+
+		fmt.Fprintf(b, "%s %s\n", root.Addr, describeInstance(root))
+		walk(root.Addr, "")
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// WriteDOT writes topology as Graphviz DOT graph
+func (t *Topology) WriteDOT(w io.Writer) error {
+	b := &strings.Builder{}
+
+	b.WriteString("digraph replication {\n")
+	b.WriteString("  node [shape=box];\n")
+
+	for _, instance := range t.Instances {
+		label := instance.Addr + "\n" + describeInstance(instance)
+		style := ""
+
+		if instance.Error != "" {
+			style = ", color=red"
+		}
+
+		fmt.Fprintf(b, "  %s [label=%s%s];\n", dotID(instance.Addr), dotID(label), style)
+	}
+
+	for _, link := range t.Links {
+		style := ""
+
+		if link.Reported {
+			style = ", style=dashed"
+		} else if link.IORunning != "Yes" || link.SQLRunning != "Yes" {
+			style = ", color=red"
+		}
+
+		fmt.Fprintf(b, "  %s -> %s [label=%s%s];\n",
+			dotID(link.Master), dotID(link.Replica), dotID(describeLink(link)), style)
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func describeInstance(instance *Instance) string {
+	if instance == nil {
+		return ""
+	}
+
+	if instance.Error != "" {
+		return fmt.Sprintf("[unreachable: %s]", instance.Error)
+	}
+
+	parts := []string{
+		fmt.Sprintf("server_id=%d", instance.ServerID),
+		"uuid=" + instance.ServerUUID,
+	}
+
+	if instance.Version != "" {
+		parts = append(parts, instance.Version)
+	}
+
+	if instance.ReadOnly {
+		parts = append(parts, "read_only")
+	}
+
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func describeLink(link *Link) string {
+	if link.Reported {
+		return "(reported by master)"
+	}
+
+	parts := make([]string, 0, 5)
+
+	if link.Channel != "" {
+		parts = append(parts, "channel="+link.Channel)
+	}
+
+	parts = append(parts, "io="+link.IORunning, "sql="+link.SQLRunning)
+
+	if link.Lag != nil {
+		parts = append(parts, fmt.Sprintf("lag=%ds", *link.Lag))
+	} else {
+		parts = append(parts, "lag=NULL")
+	}
+
+	if link.LastError != "" {
+		parts = append(parts, fmt.Sprintf("error=%q", link.LastError))
+	}
+
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// dotID quotes identifier of DOT language
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,123 @@
+// Package topology discovers replication topology starting from one server
+package topology
+
+import (
+	"sort"
+)
+
+// Instance represents discovered mysql server
+type Instance struct {
+	Addr       string `json:"addr"`
+	ServerID   int64  `json:"server_id,omitempty"`
+	ServerUUID string `json:"server_uuid,omitempty"`
+	Version    string `json:"version,omitempty"`
+	ReadOnly   bool   `json:"read_only"`
+	// Error is set if the server is unreachable
+	Error string `json:"error,omitempty"`
+}
+
+// Link represents replication channel from master to replica
+type Link struct {
+	Master     string `json:"master"`
+	Replica    string `json:"replica"`
+	Channel    string `json:"channel,omitempty"`
+	IORunning  string `json:"io_running,omitempty"`
+	SQLRunning string `json:"sql_running,omitempty"`
+	// Lag is Seconds_Behind_Master, nil if unknown
+	Lag       *int64 `json:"lag,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	// Reported is true if link is known only from master,
+	// the replica is unreachable or does not report its state
+	Reported bool `json:"reported,omitempty"`
+}
+
+// Topology represents replication graph, multi-source replicas have several masters
+type Topology struct {
+	Instances []*Instance `json:"instances"`
+	Links     []*Link     `json:"links"`
+}
+
+// Instance returns instance by address
+func (t *Topology) Instance(addr string) *Instance {
+	for _, instance := range t.Instances {
+		if instance.Addr == addr {
+			return instance
+		}
+	}
+
+	return nil
+}
+
+// Masters returns links of replica to its masters
+func (t *Topology) Masters(addr string) []*Link {
+	links := make([]*Link, 0)
+
+	for _, link := range t.Links {
+		if link.Replica == addr {
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// Replicas returns links of master to its replicas
+func (t *Topology) Replicas(addr string) []*Link {
+	links := make([]*Link, 0)
+
+	for _, link := range t.Links {
+		if link.Master == addr {
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// Roots returns instances which are not replicas of discovered instances,
+// if every instance is a replica (circular replication) the first instance is returned
+func (t *Topology) Roots() []*Instance {
+	roots := make([]*Instance, 0)
+
+	for _, instance := range t.Instances {
+		isRoot := true
+
+		for _, link := range t.Masters(instance.Addr) {
+			if t.Instance(link.Master) != nil {
+				isRoot = false
+				break
+			}
+		}
+
+		if isRoot {
+			roots = append(roots, instance)
+		}
+	}
+
+	if len(roots) == 0 && len(t.Instances) > 0 {
+		roots = append(roots, t.Instances[0])
+	}
+
+	return roots
+}
+
+// sort orders instances and links by addresses, so output is stable
+func (t *Topology) sort() {
+	sort.Slice(t.Instances, func(i, j int) bool {
+		return t.Instances[i].Addr < t.Instances[j].Addr
+	})
+
+	sort.Slice(t.Links, func(i, j int) bool {
+		a, b := t.Links[i], t.Links[j]
+
+		if a.Master != b.Master {
+			return a.Master < b.Master
+		}
+
+		if a.Replica != b.Replica {
+			return a.Replica < b.Replica
+		}
+
+		return a.Channel < b.Channel
+	})
+}
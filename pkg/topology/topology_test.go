@@ -0,0 +1,120 @@
+package topology_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/partyzanex/repmy/pkg/topology"
+	"github.com/partyzanex/testutils"
+)
+
+func mockServer(t *testing.T, uuid string, slaveStatus *sqlmock.Rows, hosts *sqlmock.Rows, dumpHosts ...string) *sql.DB {
+	db, mock, err := sqlmock.New()
+	testutils.FatalErr(t, "sqlmock.New()", err)
+
+	mock.ExpectQuery(`SHOW GLOBAL VARIABLES`).WillReturnRows(
+		sqlmock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("server_uuid", uuid).
+			AddRow("version", "5.7.30-log"),
+	)
+	mock.ExpectQuery(`show slave status`).WillReturnRows(slaveStatus)
+	mock.ExpectQuery(`SHOW SLAVE HOSTS`).WillReturnRows(hosts)
+
+	processlist := sqlmock.NewRows([]string{"HOST"})
+	for _, host := range dumpHosts {
+		processlist.AddRow(host)
+	}
+
+	mock.ExpectQuery(`information_schema.PROCESSLIST`).WillReturnRows(processlist)
+	mock.ExpectClose()
+
+	return db
+}
+
+func TestDiscoverer_Discover(t *testing.T) {
+	statusColumns := []string{"Master_Host", "Master_Port", "Slave_IO_Running", "Slave_SQL_Running", "Seconds_Behind_Master"}
+	hostColumns := []string{"Server_id", "Host", "Port", "Master_id", "Slave_UUID"}
+
+	servers := map[string]*sql.DB{
+		// seed is replica
+		"replica:3306": mockServer(t, "uuid-replica",
+			sqlmock.NewRows(statusColumns).AddRow("master", 3306, "Yes", "Yes", 3),
+			sqlmock.NewRows(hostColumns)),
+		"master:3306": mockServer(t, "uuid-master",
+			sqlmock.NewRows(statusColumns),
+			sqlmock.NewRows(hostColumns).AddRow(2, "replica", 3306, 1, "uuid-replica"),
+			"10.0.0.2:51234", "10.0.0.3:51235"),
+		// address of replica in processlist
+		"10.0.0.2:3306": mockServer(t, "uuid-replica",
+			sqlmock.NewRows(statusColumns).AddRow("master", 3306, "Yes", "Yes", 3),
+			sqlmock.NewRows(hostColumns)),
+	}
+
+	d := &topology.Discoverer{
+		Connect: func(addr string) (*sql.DB, error) {
+			db, ok := servers[addr]
+			if !ok {
+				return nil, errors.New("connection refused")
+			}
+
+			return db, nil
+		},
+	}
+
+	topo, err := d.Discover(context.Background(), "replica:3306")
+	testutils.FatalErr(t, "Discover", err)
+
+	testutils.AssertEqual(t, "instances", 2, len(topo.Instances))
+	testutils.AssertEqual(t, "links", 1, len(topo.Links))
+	testutils.AssertEqual(t, "master", "master:3306", topo.Links[0].Master)
+	testutils.AssertEqual(t, "replica", "replica:3306", topo.Links[0].Replica)
+	testutils.AssertEqual(t, "lag", int64(3), *topo.Links[0].Lag)
+
+	buf := &bytes.Buffer{}
+	testutils.FatalErr(t, "WriteTree", topo.WriteTree(buf))
+
+	exp := "master:3306 [server_id=0 uuid=uuid-master 5.7.30-log]\n" +
+		"└── replica:3306 (io=Yes sql=Yes lag=3s) [server_id=0 uuid=uuid-replica 5.7.30-log]\n"
+	testutils.AssertEqual(t, "tree", exp, buf.String())
+}
+
+func TestTopology_WriteTree(t *testing.T) {
+	topo := &topology.Topology{
+		Instances: []*topology.Instance{
+			{Addr: "a:3306", ServerID: 1},
+			{Addr: "b:3306", ServerID: 2},
+			{Addr: "c:3306", ServerID: 3},
+			{Addr: "d:3306", Error: "timeout"},
+		},
+		Links: []*topology.Link{
+			{Master: "a:3306", Replica: "c:3306", Channel: "a", IORunning: "Yes", SQLRunning: "Yes"},
+			{Master: "b:3306", Replica: "c:3306", Channel: "b", IORunning: "No", SQLRunning: "Yes"},
+			{Master: "c:3306", Replica: "d:3306", Reported: true},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	testutils.FatalErr(t, "WriteTree", topo.WriteTree(buf))
+
+	exp := `a:3306 [server_id=1 uuid=]
+└── c:3306 (channel=a io=Yes sql=Yes lag=NULL) [server_id=3 uuid=]
+    └── d:3306 (reported by master) [unreachable: timeout]
+b:3306 [server_id=2 uuid=]
+└── c:3306 (channel=b io=No sql=Yes lag=NULL) (see above)
+`
+	testutils.AssertEqual(t, "tree", exp, buf.String())
+
+	buf.Reset()
+	testutils.FatalErr(t, "WriteDOT", topo.WriteDOT(buf))
+
+	dot := buf.String()
+	testutils.AssertEqual(t, "multi-source edge", true,
+		strings.Contains(dot, `"b:3306" -> "c:3306" [label="(channel=b io=No sql=Yes lag=NULL)", color=red];`))
+	testutils.AssertEqual(t, "reported edge", true,
+		strings.Contains(dot, `"c:3306" -> "d:3306" [label="(reported by master)", style=dashed];`))
+}